@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/config"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
 	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/handlers"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/migrations"
 	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/services"
 )
 
@@ -23,8 +27,22 @@ func main() {
 	}
 	defer dbService.Close()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	migrated := false
 	if cfg.GetDatabaseURL() != "" {
 		log.Println("Connected to PostgreSQL")
+		// Nilai enum untuk validasi filter; ddl.sql tidak memuat CREATE TYPE
+		if err := services.LoadEnumsFromDB(ctx, dbService); err != nil {
+			log.Printf("WARNING: load pg_enum failed, enum filters will be rejected: %v", err)
+		}
+		// Migrasi milik bot (outbox notifikasi, trigger NOTIFY, dst.)
+		if _, err := migrations.Apply(ctx, dbService); err != nil {
+			log.Printf("WARNING: migrations failed, background jobs disabled: %v", err)
+		} else {
+			migrated = true
+		}
 	} else {
 		log.Println("DATABASE_URL not set, DB queries will be disabled")
 	}
@@ -37,8 +55,20 @@ func main() {
 
 	log.Println("WhatsApp bot running")
 
+	// Kalkulator KPR deterministik (simulasi angsuran dari kpr_rates)
+	calculator := services.NewKPRCalculatorService(dbService, cfg.GetKPRFloatingRate(), cfg.GetKPRPrepaymentPenalty(), cfg.GetKPRMaxDSR())
+
+	// Checklist dokumen pengajuan (tabel milik bot dari migrasi); dibuat lebih dulu karena AI Query
+	// memakainya untuk menjawab "dokumen apa yang kurang"
+	var documents *services.DocumentChecklistService
+	var documentService domain.DocumentService
+	if migrated {
+		documents = services.NewDocumentChecklistService(dbService)
+		documentService = documents
+	}
+
 	// Initialize AI Query service (untuk SELECT aman) dengan privasi Gemini
-	aiQueryService := services.NewAIQueryService(dbService, cfg.GetGeminiAPIKey(), cfg.GetGeminiCanSeeData(), cfg.GetSQLAuditPath(), cfg.GetRelaxSecurity())
+	aiQueryService := services.NewAIQueryService(dbService, cfg.GetGeminiAPIKey(), cfg.GetGeminiCanSeeData(), cfg.GetSQLAuditPath(), cfg.GetRelaxSecurity(), calculator, whatsappService, documentService)
 	services.RefreshAllowedColumnsFromDDL("ddl.sql")
 
 	// Initialize KPR QA service (gabung prompt txt + input user)
@@ -46,17 +76,77 @@ func main() {
 
 	// Initialize handlers
 	messageHandler := handlers.NewMessageHandler(whatsappService, cfg)
-	botHandler := handlers.NewBotHandler(qaService, whatsappService)
+	// Perintah yang butuh tabel milik bot dari migrasi, dicoba berurutan. Developer mitra dicek
+	// paling awal: nomornya hanya boleh melihat pengajuan proyek sendiri.
+	var commands []domain.CommandHandler
+	var partners *services.PartnerService
+	if migrated {
+		partners = services.NewPartnerService(dbService, aiQueryService)
+		otpService := services.NewOTPService(cfg.GetOTPExpiryMinutes() * 60)
+		commands = append(commands,
+			partners,
+			services.NewApprovalService(dbService, otpService, whatsappService, time.Duration(cfg.GetOTPExpiryMinutes())*time.Minute),
+			// Pengajuan KPR terpandu: membuat DRAFT lewat writer yang diaudit
+			services.NewIntakeService(dbService, calculator, services.NewApplicationWriter(dbService)),
+			// Unggahan dokumen nasabah dan perintah staf "cek/terima dokumen"
+			documents,
+		)
+	}
+	// Analitik pipeline approval untuk kepala cabang (chat) dan dashboard (REST)
+	var pipeline *services.PipelineAnalyticsService
+	if cfg.GetDatabaseURL() != "" {
+		pipeline = services.NewPipelineAnalyticsService(dbService)
+		commands = append(commands, pipeline)
+	}
+	// Ringkasan harian kepala cabang: perintah langganan + job terjadwal
+	if migrated && cfg.GetDigestEnabled() {
+		digest, err := services.NewBranchDigestService(dbService, whatsappService, cfg.GetDigestTime())
+		if err != nil {
+			log.Printf("WARNING: branch digest disabled: %v", err)
+		} else {
+			commands = append(commands, digest)
+			go services.NewJobScheduler(digest.Job()).Run(ctx)
+		}
+	}
+	botHandler := handlers.NewBotHandler(qaService, whatsappService, commands...)
+	kprHandler := handlers.NewKPRHandler(calculator, cfg)
 
 	// Setup WhatsApp event handler for listening to user chats
 	whatsappService.AddEventHandler(botHandler.HandleMessage)
 
+	// Notifikasi perubahan status pengajuan ke nasabah
+	if migrated && cfg.GetNotifyEnabled() {
+		notifier, err := services.NewStatusNotifier(dbService, whatsappService, cfg.GetDatabaseURL(),
+			time.Duration(cfg.GetNotifyPollSeconds())*time.Second, cfg.GetNotifyQuietHours())
+		if err != nil {
+			log.Printf("WARNING: status notifier disabled: %v", err)
+		} else {
+			go notifier.Run(ctx)
+		}
+	}
+
+	// Pengingat SLA dan eskalasi approval_workflow ke staf
+	if migrated && cfg.GetSLAEnabled() {
+		sla := services.NewSLAReminderService(dbService, whatsappService,
+			time.Duration(cfg.GetSLACheckMinutes())*time.Minute, time.Duration(cfg.GetSLARemindBeforeHours())*time.Hour)
+		go sla.Run(ctx)
+	}
+
 	// Setup REST API for sending messages
 	if cfg.GetAPIKey() == "" {
 		log.Println("WARNING: API_KEY is empty, REST endpoint will reject requests")
 	}
 
 	http.HandleFunc("/api/send-message", messageHandler.SendMessage)
+	http.HandleFunc("/api/kpr/total-cost", kprHandler.TotalCost)
+	http.HandleFunc("/api/kpr/amortization", kprHandler.Amortization)
+	if pipeline != nil {
+		http.HandleFunc("/api/analytics/pipeline", handlers.NewAnalyticsHandler(pipeline, cfg).Pipeline)
+	}
+	if migrated {
+		http.HandleFunc("/api/kpr/documents", handlers.NewDocumentHandler(documentService, cfg).Documents)
+		http.HandleFunc("/api/partner/applications", handlers.NewPartnerHandler(partners).Applications)
+	}
 
 	go func() {
 		log.Printf("REST API listening on %s", cfg.GetHTTPAddr())
@@ -70,6 +160,7 @@ func main() {
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 	<-sig
 
+	cancel()
 	whatsappService.Disconnect()
 	log.Println("Shutdown")
 }
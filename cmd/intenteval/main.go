@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/config"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/services"
+)
+
+// intenteval menjalankan classifier intent terhadap set berlabel (JSONL) dan mencetak akurasinya
+func main() {
+	path := flag.String("set", "internal/services/testdata/intents.jsonl", "labelled utterances (JSONL)")
+	mode := flag.String("classifier", "rule", "rule | llm")
+	flag.Parse()
+
+	f, err := os.Open(*path)
+	if err != nil {
+		log.Fatalf("open labelled set: %v", err)
+	}
+	defer f.Close()
+
+	var samples []services.LabelledUtterance
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var u services.LabelledUtterance
+		if err := json.Unmarshal(sc.Bytes(), &u); err != nil {
+			log.Fatalf("invalid line %q: %v", sc.Text(), err)
+		}
+		samples = append(samples, u)
+	}
+
+	var clf domain.IntentClassifier = services.NewRuleIntentClassifier()
+	if *mode == "llm" {
+		cfg := config.NewConfig()
+		if cfg.GetGeminiAPIKey() == "" {
+			log.Fatal("GEMINI_API_KEY is required for -classifier=llm")
+		}
+		clf = services.NewLLMIntentClassifier(cfg.GetGeminiAPIKey())
+	}
+
+	rep, err := services.EvaluateIntentClassifier(context.Background(), clf, samples)
+	if err != nil {
+		log.Fatalf("evaluate: %v", err)
+	}
+	fmt.Print(rep.String())
+}
@@ -21,6 +21,17 @@ type Config struct {
 	GeminiCanSeeData  bool
 	SQLAuditPath      string
 	RelaxSecurity     bool
+	KPRFloatingRate   float64
+	KPRPrepayPenalty  float64
+	KPRMaxDSR         float64
+	NotifyEnabled     bool
+	NotifyQuietHours  string
+	NotifyPollSeconds int
+	SLAEnabled        bool
+	SLARemindBefore   int
+	SLACheckMinutes   int
+	DigestEnabled     bool
+	DigestTime        string
 }
 
 func NewConfig() domain.ConfigService {
@@ -77,6 +88,90 @@ func NewConfig() domain.ConfigService {
 		}
 	}
 
+	// Suku bunga floating indikatif (pecahan) untuk simulasi setelah periode fixed berakhir
+	floatingRate := 0.11
+	if v := os.Getenv("KPR_FLOATING_RATE"); v != "" {
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && parsed > 0 && parsed < 1 {
+			floatingRate = parsed
+		}
+	}
+
+	// Penalti pelunasan dipercepat (pecahan sisa pokok) untuk analisis take-over/refinancing
+	prepayPenalty := 0.01
+	if v := os.Getenv("KPR_PREPAYMENT_PENALTY_RATE"); v != "" {
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && parsed >= 0 && parsed < 1 {
+			prepayPenalty = parsed
+		}
+	}
+
+	// Batas debt-service ratio (total angsuran / penghasilan) untuk kalkulator kemampuan pinjam
+	maxDSR := 0.35
+	if v := os.Getenv("KPR_MAX_DSR"); v != "" {
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && parsed > 0 && parsed < 1 {
+			maxDSR = parsed
+		}
+	}
+
+	// Notifikasi status pengajuan: aktif bila DATABASE_URL diset, kecuali NOTIFY_ENABLED=false
+	notifyEnabled := true
+	if v := os.Getenv("NOTIFY_ENABLED"); v != "" {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "false", "0", "no", "off":
+			notifyEnabled = false
+		}
+	}
+
+	// Jam tenang (WIB) saat notifikasi ditahan, format HH:MM-HH:MM; "off" untuk menonaktifkan
+	quietHours, ok := os.LookupEnv("NOTIFY_QUIET_HOURS")
+	if !ok {
+		quietHours = "21:00-07:00"
+	}
+
+	notifyPollSeconds := 60
+	if v := os.Getenv("NOTIFY_POLL_SECONDS"); v != "" {
+		if parsed, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && parsed >= 5 {
+			notifyPollSeconds = parsed
+		}
+	}
+
+	// Pengingat SLA approval_workflow untuk staf: aktif kecuali SLA_ENABLED=false
+	slaEnabled := true
+	if v := os.Getenv("SLA_ENABLED"); v != "" {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "false", "0", "no", "off":
+			slaEnabled = false
+		}
+	}
+
+	// Berapa jam sebelum due_date pengingat pertama dikirim
+	slaRemindBefore := 4
+	if v := os.Getenv("SLA_REMIND_BEFORE_HOURS"); v != "" {
+		if parsed, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && parsed >= 0 {
+			slaRemindBefore = parsed
+		}
+	}
+
+	slaCheckMinutes := 10
+	if v := os.Getenv("SLA_CHECK_MINUTES"); v != "" {
+		if parsed, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && parsed > 0 {
+			slaCheckMinutes = parsed
+		}
+	}
+
+	// Ringkasan harian kepala cabang (opt-in per user); jam kirim HH:MM di zona masing-masing penerima
+	digestEnabled := true
+	if v := os.Getenv("DIGEST_ENABLED"); v != "" {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "false", "0", "no", "off":
+			digestEnabled = false
+		}
+	}
+
+	digestTime := strings.TrimSpace(os.Getenv("DIGEST_TIME"))
+	if digestTime == "" {
+		digestTime = "07:00"
+	}
+
 	return &Config{
 		DatabaseURL:       os.Getenv("DATABASE_URL"),
 		WhatsAppStorePath: storePath,
@@ -88,6 +183,17 @@ func NewConfig() domain.ConfigService {
 		GeminiCanSeeData:  geminiCanSeeData,
 		SQLAuditPath:      auditPath,
 		RelaxSecurity:     relaxSecurity,
+		KPRFloatingRate:   floatingRate,
+		KPRPrepayPenalty:  prepayPenalty,
+		KPRMaxDSR:         maxDSR,
+		NotifyEnabled:     notifyEnabled,
+		NotifyQuietHours:  strings.TrimSpace(quietHours),
+		NotifyPollSeconds: notifyPollSeconds,
+		SLAEnabled:        slaEnabled,
+		SLARemindBefore:   slaRemindBefore,
+		SLACheckMinutes:   slaCheckMinutes,
+		DigestEnabled:     digestEnabled,
+		DigestTime:        digestTime,
 	}
 }
 
@@ -137,3 +243,47 @@ func (c *Config) Validate() error {
 	}
 	return nil
 }
+
+func (c *Config) GetKPRFloatingRate() float64 {
+	return c.KPRFloatingRate
+}
+
+func (c *Config) GetKPRPrepaymentPenalty() float64 {
+	return c.KPRPrepayPenalty
+}
+
+func (c *Config) GetKPRMaxDSR() float64 {
+	return c.KPRMaxDSR
+}
+
+func (c *Config) GetNotifyEnabled() bool {
+	return c.NotifyEnabled
+}
+
+func (c *Config) GetNotifyQuietHours() string {
+	return c.NotifyQuietHours
+}
+
+func (c *Config) GetNotifyPollSeconds() int {
+	return c.NotifyPollSeconds
+}
+
+func (c *Config) GetSLAEnabled() bool {
+	return c.SLAEnabled
+}
+
+func (c *Config) GetSLARemindBeforeHours() int {
+	return c.SLARemindBefore
+}
+
+func (c *Config) GetSLACheckMinutes() int {
+	return c.SLACheckMinutes
+}
+
+func (c *Config) GetDigestEnabled() bool {
+	return c.DigestEnabled
+}
+
+func (c *Config) GetDigestTime() string {
+	return c.DigestTime
+}
@@ -8,13 +8,14 @@ import (
 // WhatsAppService handles WhatsApp messaging operations
 type WhatsAppService interface {
 	SendMessage(ctx context.Context, phone, message string) error
+	SendDocument(ctx context.Context, phone string, doc Document) error
 	IsConnected() bool
 }
 
 // AIQueryService handles AI-powered database queries
 type AIQueryService interface {
 	PlanQuery(ctx context.Context, text string) (*SQLPlan, error)
-	ExecuteQuery(ctx context.Context, plan *SQLPlan) (string, error)
+	ExecuteQuery(ctx context.Context, plan *SQLPlan) (*ResultSet, error)
 	// AnswerWithDB: generate SQL plan, execute, feed DB data back into AI with basePrompt, return final answer
 	AnswerWithDB(ctx context.Context, text string, basePrompt string) (string, error)
 	// AnswerWithDBForUser: sama seperti AnswerWithDB, tetapi terlebih dahulu mengambil konteks user berdasarkan phone,
@@ -22,10 +23,17 @@ type AIQueryService interface {
 	AnswerWithDBForUser(ctx context.Context, userPhone string, text string, basePrompt string) (string, error)
 }
 
+// IntentClassifier maps a user message to a typed intent with a confidence score
+type IntentClassifier interface {
+	Classify(ctx context.Context, text string) (*IntentResult, error)
+}
+
 // DatabaseService handles database operations
 type DatabaseService interface {
 	Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	// WithTx menjalankan fn dalam satu transaksi: commit bila fn mengembalikan nil, selain itu rollback
+	WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error
 	Close() error
 }
 
@@ -41,6 +49,17 @@ type ConfigService interface {
 	GetGeminiCanSeeData() bool
 	GetSQLAuditPath() string
 	GetRelaxSecurity() bool
+	GetKPRFloatingRate() float64
+	GetKPRPrepaymentPenalty() float64
+	GetKPRMaxDSR() float64
+	GetNotifyEnabled() bool
+	GetNotifyQuietHours() string
+	GetNotifyPollSeconds() int
+	GetSLAEnabled() bool
+	GetSLARemindBeforeHours() int
+	GetSLACheckMinutes() int
+	GetDigestEnabled() bool
+	GetDigestTime() string
 }
 
 // OTPService handles OTP generation, validation, and expiry
@@ -50,6 +69,72 @@ type OTPService interface {
 	CleanupExpiredOTPs(ctx context.Context) error
 }
 
+// CommandHandler memproses perintah deterministik (mis. aksi staf) sebelum alur tanya-jawab AI
+type CommandHandler interface {
+	// HandleCommand: handled=false bila pesan bukan perintahnya (atau pengirim tidak berhak),
+	// sehingga pesan diteruskan ke handler berikutnya / alur tanya-jawab biasa
+	HandleCommand(ctx context.Context, phone, text string) (reply string, handled bool)
+}
+
+// ApplicationWriteService membuat baris kpr_applications atas nama nasabah (satu-satunya jalur tulis
+// nasabah): query statis, transaksi, dan jejak audit append-only
+type ApplicationWriteService interface {
+	CreateDraft(ctx context.Context, app DraftApplication) (applicationNumber string, err error)
+}
+
+// MediaHandler memproses gambar/dokumen yang dikirim user (mis. unggahan dokumen KPR).
+// Handler perintah yang juga mengimplementasikan interface ini menerima pesan media.
+type MediaHandler interface {
+	HandleMedia(ctx context.Context, phone string, media IncomingMedia) (reply string, handled bool)
+}
+
+// DocumentService mengelola checklist dokumen pengajuan dan dokumen yang sudah diterima
+type DocumentService interface {
+	// Checklist: phone kosong = pemanggil tepercaya (portal); bila diisi, pengajuan harus milik nomor tsb.
+	// applicationNumber kosong = pengajuan terbaru milik phone.
+	Checklist(ctx context.Context, applicationNumber, phone string) (*DocumentChecklist, error)
+	// MarkReceived mencatat dokumen diterima (unggahan ulang memperbarui catatan sebelumnya)
+	MarkReceived(ctx context.Context, rec DocumentReceipt) error
+}
+
+// PipelineAnalyticsService menghitung metrik pipeline approval_workflow (angka deterministik dari DB)
+type PipelineAnalyticsService interface {
+	// Pipeline: branchCode kosong = semua cabang; days = jendela data ke belakang
+	Pipeline(ctx context.Context, branchCode string, days int) (*PipelineAnalytics, error)
+}
+
+// PartnerService memberi developer mitra akses ke pengajuan proyeknya sendiri (jumlah dan status saja)
+type PartnerService interface {
+	// PartnerByAPIKey: nil tanpa error bila key tidak dikenal atau sudah dicabut
+	PartnerByAPIKey(ctx context.Context, key string) (*DeveloperPartner, error)
+	Portfolio(ctx context.Context, partner DeveloperPartner, q PartnerQuery) (*PartnerPortfolio, error)
+}
+
+// Notifier pushes background notifications until ctx is done
+type Notifier interface {
+	Run(ctx context.Context)
+}
+
+// KPRCalculatorService runs deterministic KPR calculations against kpr_rates
+type KPRCalculatorService interface {
+	ActiveRates(ctx context.Context) ([]KPRRate, error)
+	Simulate(ctx context.Context, req SimulationRequest) (*SimulationResult, error)
+	CheckEligibility(ctx context.Context, req EligibilityRequest) (*EligibilityResult, error)
+	// Affordability menghitung plafon dan harga rumah maksimal dari penghasilan (DSR) per produk aktif
+	Affordability(ctx context.Context, req AffordabilityRequest) (*AffordabilityResult, error)
+	// Recommend mengurutkan produk aktif menurut kecocokan dengan profil pemohon
+	Recommend(ctx context.Context, profile RecommendationProfile) (*RecommendationResult, error)
+	TotalCost(ctx context.Context, req SimulationRequest) (*TotalCostResult, error)
+	Amortization(ctx context.Context, req SimulationRequest) (*AmortizationSchedule, error)
+	// ApplicationAmortization: jadwal untuk pengajuan yang sudah disetujui. phone kosong = pemanggil
+	// tepercaya (portal); bila diisi, pengajuan harus milik nomor tsb. applicationNumber kosong =
+	// pengajuan disetujui terbaru milik phone.
+	ApplicationAmortization(ctx context.Context, applicationNumber, phone string) (*AmortizationSchedule, error)
+	// Refinance membandingkan sisa pinjaman pengajuan yang sudah disetujui dengan promo aktif;
+	// phone dan applicationNumber mengikuti aturan ApplicationAmortization
+	Refinance(ctx context.Context, applicationNumber, phone string) (*RefinanceAdvice, error)
+}
+
 // KPRQAService handles KPR Q&A with optional DB context
 type KPRQAService interface {
 	Ask(ctx context.Context, text string) (string, error)
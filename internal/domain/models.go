@@ -1,23 +1,61 @@
 package domain
 
+import (
+	"strings"
+	"time"
+)
+
+// SQLPlanVersion is the current version of the structured SQLPlan schema
+const SQLPlanVersion = 3
+
 // SQLPlan represents a safe SQL query plan
 type SQLPlan struct {
-    Operation string   `json:"operation"` // SELECT only
-    Table     string   `json:"table"`     // users | kpr_applications | approval_workflows
-    Columns   []string `json:"columns"`   // optional, default "*"
-    Filters   []Filter `json:"filters"`   // equality filters only
-    Limit     int      `json:"limit"`     // optional
-    SQL       string   `json:"sql"`
-    Args      []string `json:"args"`
+	Version   int      `json:"version"`   // schema version, see SQLPlanVersion
+	Operation string   `json:"operation"` // SELECT only
+	Table     string   `json:"table"`     // users | kpr_applications | approval_workflows
+	Columns   []string `json:"columns"`   // optional, default "*"
+	Filters   []Filter `json:"filters"`   // typed filters, see Filter.Op
+	Limit     int      `json:"limit"`     // optional
+	SQL       string   `json:"sql"`
+	Args      []string `json:"args"`
+
+	Aggregates []Aggregate `json:"aggregates,omitempty"` // count | sum | avg | min | max
+	GroupBy    []string    `json:"group_by,omitempty"`
+	OrderBy    []OrderBy   `json:"order_by,omitempty"`
+	Having     []Having    `json:"having,omitempty"`
+	// MinGroupSize is set by the privacy sanitizer (never by the planner): groups smaller than this are dropped
+	MinGroupSize int `json:"min_group_size,omitempty"`
 }
 
-// Filter represents a SQL filter condition
-type Filter struct {
+// Aggregate represents an aggregate expression in a structured plan
+type Aggregate struct {
+	Func   string `json:"func"`   // count | sum | avg | min | max
+	Column string `json:"column"` // "*" is allowed for count
+	Alias  string `json:"alias,omitempty"`
+}
+
+// OrderBy represents a sort key: a column, or an aggregate alias when aggregating
+type OrderBy struct {
 	Column string `json:"column"`
-	Op     string `json:"op"` // '=' only
+	Desc   bool   `json:"desc,omitempty"`
+}
+
+// Having represents a condition over an aggregate
+type Having struct {
+	Func   string `json:"func"`
+	Column string `json:"column"`
+	Op     string `json:"op"` // = != > >= < <=
 	Value  string `json:"value"`
 }
 
+// Filter represents a SQL filter condition
+type Filter struct {
+	Column string   `json:"column"`
+	Op     string   `json:"op"` // = != > >= < <= between in ilike(prefix) is_null is_not_null
+	Value  string   `json:"value"`
+	Values []string `json:"values,omitempty"` // between (2 values) and in
+}
+
 // SendMessageRequest represents request to send message
 type SendMessageRequest struct {
 	Phone   string `json:"phone"`
@@ -59,3 +97,471 @@ type OTPValidateResponse struct {
 	Valid   bool   `json:"valid"`
 	Message string `json:"message,omitempty"`
 }
+
+// Intent represents the classified purpose of a user chat message
+type Intent string
+
+const (
+	IntentGreeting              Intent = "greeting"
+	IntentApplicationStatus     Intent = "application_status"
+	IntentInstallmentSimulation Intent = "installment_simulation"
+	IntentTotalCost             Intent = "total_cost"
+	IntentAmortization          Intent = "amortization_schedule"
+	IntentRateInfo              Intent = "rate_info"
+	IntentEligibility           Intent = "eligibility"
+	IntentRefinance             Intent = "refinance"
+	IntentAffordability         Intent = "affordability"
+	IntentRecommendation        Intent = "recommendation"
+	IntentDocumentChecklist     Intent = "document_checklist"
+	IntentFAQ                   Intent = "faq"
+	IntentHandoff               Intent = "handoff"
+	IntentComplaint             Intent = "complaint"
+	IntentOther                 Intent = "other"
+)
+
+// AllIntents lists every intent in a stable order
+var AllIntents = []Intent{
+	IntentGreeting,
+	IntentApplicationStatus,
+	IntentInstallmentSimulation,
+	IntentTotalCost,
+	IntentAmortization,
+	IntentRateInfo,
+	IntentEligibility,
+	IntentAffordability,
+	IntentRecommendation,
+	IntentRefinance,
+	IntentDocumentChecklist,
+	IntentFAQ,
+	IntentHandoff,
+	IntentComplaint,
+	IntentOther,
+}
+
+// IntentResult represents the outcome of classifying a message
+type IntentResult struct {
+	Intent     Intent  `json:"intent"`
+	Confidence float64 `json:"confidence"` // 0..1
+	Source     string  `json:"source"`     // rule | llm
+}
+
+// ResultColumn describes one column of a query result
+type ResultColumn struct {
+	Name   string `json:"name"`
+	DBType string `json:"db_type"` // Postgres type name reported by the driver, e.g. NUMERIC, VARCHAR, TIMESTAMP
+}
+
+// ResultSet is the typed result of ExecuteQuery. Rendering to text is a separate step.
+type ResultSet struct {
+	Table     string          `json:"table,omitempty"` // main table of the plan, used for column semantics
+	Columns   []ResultColumn  `json:"columns"`
+	Rows      [][]interface{} `json:"rows"`      // typed values: int64, float64, bool, string, time.Time or nil
+	Masked    [][]bool        `json:"masked"`    // per cell; a masked cell's value is nil
+	Truncated bool            `json:"truncated"` // more rows were available than were read
+}
+
+// Len returns the number of rows
+func (r *ResultSet) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.Rows)
+}
+
+// ColumnIndex returns the index of a column (case-insensitive) or -1
+func (r *ResultSet) ColumnIndex(name string) int {
+	if r == nil {
+		return -1
+	}
+	for i, c := range r.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Value returns the value of a column in a row; ok is false when the column is absent, masked or NULL
+func (r *ResultSet) Value(row int, name string) (interface{}, bool) {
+	i := r.ColumnIndex(name)
+	if i < 0 || row < 0 || row >= r.Len() {
+		return nil, false
+	}
+	if r.IsMasked(row, i) || r.Rows[row][i] == nil {
+		return nil, false
+	}
+	return r.Rows[row][i], true
+}
+
+// IsMasked reports whether a cell was redacted
+func (r *ResultSet) IsMasked(row, col int) bool {
+	if r == nil || row < 0 || row >= len(r.Masked) || col < 0 || col >= len(r.Masked[row]) {
+		return false
+	}
+	return r.Masked[row][col]
+}
+
+// KPRRate represents one product row of kpr_rates. Rates and ratios are fractions (0.0725 = 7.25%),
+// MinDownPaymentPercent is already a percentage (10 = 10%).
+type KPRRate struct {
+	ID                    int        `json:"id"`
+	RateName              string     `json:"rate_name"`
+	RateType              string     `json:"rate_type"`
+	PropertyType          string     `json:"property_type"`
+	CustomerSegment       string     `json:"customer_segment"`
+	BaseRate              float64    `json:"base_rate"`
+	Margin                float64    `json:"margin"`
+	EffectiveRate         float64    `json:"effective_rate"`
+	MinLoanAmount         float64    `json:"min_loan_amount"`
+	MaxLoanAmount         float64    `json:"max_loan_amount"`
+	MinTermYears          int        `json:"min_term_years"`
+	MaxTermYears          int        `json:"max_term_years"`
+	MaxLTVRatio           float64    `json:"max_ltv_ratio"`
+	MinIncome             float64    `json:"min_income"`
+	MaxAge                int        `json:"max_age"`
+	MinDownPaymentPercent float64    `json:"min_down_payment_percent"`
+	AdminFee              float64    `json:"admin_fee"`
+	AdminFeePercent       float64    `json:"admin_fee_percent"`
+	AppraisalFee          float64    `json:"appraisal_fee"`
+	InsuranceRate         float64    `json:"insurance_rate"`
+	NotaryFeePercent      float64    `json:"notary_fee_percent"`
+	IsPromotional         bool       `json:"is_promotional"`
+	PromoDescription      string     `json:"promo_description,omitempty"`
+	PromoStartDate        *time.Time `json:"promo_start_date,omitempty"`
+	PromoEndDate          *time.Time `json:"promo_end_date,omitempty"`
+	ExpiryDate            *time.Time `json:"expiry_date,omitempty"`
+}
+
+// SimulationRequest represents the input of an installment simulation
+type SimulationRequest struct {
+	PropertyPrice float64 `json:"property_price"`
+	DownPayment   float64 `json:"down_payment"` // rupiah
+	TenorYears    int     `json:"tenor_years"`
+	RateID        int     `json:"rate_id,omitempty"`       // 0 = pick the cheapest applicable product
+	FixedYears    int     `json:"fixed_years,omitempty"`   // 0 = derive from the product
+	FloatingRate  float64 `json:"floating_rate,omitempty"` // fraction; 0 = configured default
+}
+
+// SimulationPeriod is a span of months paid with the same installment
+type SimulationPeriod struct {
+	FromMonth          int     `json:"from_month"`
+	ToMonth            int     `json:"to_month"`
+	AnnualRate         float64 `json:"annual_rate"`
+	Floating           bool    `json:"floating"`
+	MonthlyInstallment float64 `json:"monthly_installment"`
+}
+
+// SimulationResult represents a deterministic installment simulation
+type SimulationResult struct {
+	Rate               KPRRate            `json:"rate"`
+	PropertyPrice      float64            `json:"property_price"`
+	DownPayment        float64            `json:"down_payment"`
+	DownPaymentPercent float64            `json:"down_payment_percent"`
+	Principal          float64            `json:"principal"`
+	TenorMonths        int                `json:"tenor_months"`
+	Periods            []SimulationPeriod `json:"periods"`
+	TotalInterest      float64            `json:"total_interest"`
+	TotalPayment       float64            `json:"total_payment"`
+	Violations         []string           `json:"violations,omitempty"` // product limits the request breaks
+}
+
+// TotalCostResult is the full cost of a loan: fees paid at signing, interest and insurance over the tenor.
+// Insurance is indicative: insurance_rate per year on the outstanding principal at the start of each year.
+type TotalCostResult struct {
+	Simulation     SimulationResult `json:"simulation"`
+	AdminFee       float64          `json:"admin_fee"` // admin_fee + admin_fee_percent × principal
+	AppraisalFee   float64          `json:"appraisal_fee"`
+	NotaryFee      float64          `json:"notary_fee"`      // notary_fee_percent × principal
+	UpfrontFees    float64          `json:"upfront_fees"`    // admin + appraisal + notary
+	CashAtSigning  float64          `json:"cash_at_signing"` // down payment + upfront fees
+	TotalInterest  float64          `json:"total_interest"`
+	TotalInsurance float64          `json:"total_insurance"`
+	TotalPaid      float64          `json:"total_paid"` // down payment + fees + installments + insurance
+}
+
+// AmortizationRow is one month of an amortization schedule
+type AmortizationRow struct {
+	Month       int     `json:"month"`
+	AnnualRate  float64 `json:"annual_rate"`
+	Installment float64 `json:"installment"`
+	Principal   float64 `json:"principal"`
+	Interest    float64 `json:"interest"`
+	Balance     float64 `json:"balance"` // outstanding principal after this installment
+}
+
+// AmortizationSchedule is the month-by-month schedule of a simulation or an approved application
+type AmortizationSchedule struct {
+	Title             string            `json:"title"`
+	ApplicationNumber string            `json:"application_number,omitempty"`
+	Principal         float64           `json:"principal"`
+	TenorMonths       int               `json:"tenor_months"`
+	Rows              []AmortizationRow `json:"rows"`
+	TotalInterest     float64           `json:"total_interest"`
+	TotalPayment      float64           `json:"total_payment"`
+}
+
+// RefinanceOption compares moving the remaining balance to one active promotional product
+type RefinanceOption struct {
+	Rate           KPRRate            `json:"rate"`
+	Periods        []SimulationPeriod `json:"periods"`
+	TotalPayment   float64            `json:"total_payment"`  // installments of the new loan over the remaining tenor
+	SwitchingCost  float64            `json:"switching_cost"` // new product fees plus prepayment penalty
+	GrossSaving    float64            `json:"gross_saving"`
+	NetSaving      float64            `json:"net_saving"`
+	BreakEvenMonth int                `json:"break_even_month"` // 0 = fees are never recovered
+	Violations     []string           `json:"violations,omitempty"`
+}
+
+// RefinanceAdvice is the take-over analysis of an approved application against active promos
+type RefinanceAdvice struct {
+	ApplicationNumber  string            `json:"application_number"`
+	CurrentRate        float64           `json:"current_rate"`
+	MonthsPaid         int               `json:"months_paid"`
+	RemainingMonths    int               `json:"remaining_months"`
+	Balance            float64           `json:"balance"`
+	CurrentInstallment float64           `json:"current_installment"`
+	CurrentRemaining   float64           `json:"current_remaining"` // installments left on the current loan
+	PenaltyRate        float64           `json:"penalty_rate"`
+	FloatingRate       float64           `json:"floating_rate"`
+	Options            []RefinanceOption `json:"options"`
+}
+
+// Document is a file sent as a WhatsApp document message
+type Document struct {
+	FileName string
+	MimeType string
+	Caption  string
+	Data     []byte
+}
+
+// EligibilityRequest represents the applicant data checked against kpr_rates constraints.
+// Zero values mean "unknown" and skip the corresponding check.
+type EligibilityRequest struct {
+	MonthlyIncome   float64 `json:"monthly_income"`
+	Age             int     `json:"age"`
+	PropertyType    string  `json:"property_type,omitempty"`
+	CustomerSegment string  `json:"customer_segment,omitempty"`
+	PropertyPrice   float64 `json:"property_price,omitempty"`
+	DownPayment     float64 `json:"down_payment,omitempty"`
+	TenorYears      int     `json:"tenor_years,omitempty"`
+}
+
+// ProductEligibility is the outcome for one product
+type ProductEligibility struct {
+	Rate               KPRRate  `json:"rate"`
+	Eligible           bool     `json:"eligible"`
+	Reasons            []string `json:"reasons,omitempty"`             // why the product is not available
+	MonthlyInstallment float64  `json:"monthly_installment,omitempty"` // estimate when price and tenor are known
+}
+
+// EligibilityResult lists qualifying and failing products
+type EligibilityResult struct {
+	Products []ProductEligibility `json:"products"`
+}
+
+// AffordabilityRequest is the input of the reverse calculation from income to maximum loan.
+// Zero values mean "unknown": TenorYears falls back to a default, MaxDSR to the configured maximum.
+type AffordabilityRequest struct {
+	MonthlyIncome float64 `json:"monthly_income"`
+	Obligations   float64 `json:"obligations,omitempty"` // existing monthly installments
+	TenorYears    int     `json:"tenor_years,omitempty"`
+	Age           int     `json:"age,omitempty"`
+	MaxDSR        float64 `json:"max_dsr,omitempty"` // fraction; can only lower the configured maximum
+}
+
+// ProductAffordability is the maximum loan and property price for one product
+type ProductAffordability struct {
+	Rate               KPRRate  `json:"rate"`
+	TenorYears         int      `json:"tenor_years"`
+	StressRate         float64  `json:"stress_rate"` // highest annual rate over the tenor
+	MaxLoan            float64  `json:"max_loan"`
+	MaxPropertyPrice   float64  `json:"max_property_price"`
+	MonthlyInstallment float64  `json:"monthly_installment"`
+	CappedByProduct    bool     `json:"capped_by_product"` // max_loan_amount is lower than the DSR capacity
+	Eligible           bool     `json:"eligible"`
+	Reasons            []string `json:"reasons,omitempty"`
+}
+
+// AffordabilityResult lists products by the largest affordable loan, eligible products first
+type AffordabilityResult struct {
+	MaxDSR         float64                `json:"max_dsr"`
+	MaxInstallment float64                `json:"max_installment"`
+	Obligations    float64                `json:"obligations"`
+	Products       []ProductAffordability `json:"products"`
+}
+
+// RecommendationProfile is the applicant data used to rank products. It is read server-side from
+// user_profiles and never placed in an LLM prompt.
+type RecommendationProfile struct {
+	Age           int     `json:"age"`
+	Occupation    string  `json:"occupation"`
+	MonthlyIncome float64 `json:"monthly_income"`
+	City          string  `json:"city,omitempty"`
+	PropertyType  string  `json:"property_type,omitempty"` // from the latest application, if any
+	TenorYears    int     `json:"tenor_years,omitempty"`   // desired tenor; 0 = default
+}
+
+// ProductRecommendation is the fit of one product for a profile
+type ProductRecommendation struct {
+	Rate       KPRRate  `json:"rate"`
+	Score      float64  `json:"score"`
+	TenorYears int      `json:"tenor_years"` // longest tenor allowed by the product and max_age
+	Eligible   bool     `json:"eligible"`
+	Highlights []string `json:"highlights,omitempty"` // why the product fits, safe to show
+	Reasons    []string `json:"reasons,omitempty"`    // why the product does not fit
+}
+
+// RecommendationResult lists products by fit, eligible products first
+type RecommendationResult struct {
+	Segment  string                  `json:"segment,omitempty"` // occupation segment derived from the profile
+	Products []ProductRecommendation `json:"products"`
+}
+
+// DraftApplication is the data collected by the guided chat intake for a new DRAFT kpr_applications row
+type DraftApplication struct {
+	UserID                  int     `json:"user_id"`
+	Phone                   string  `json:"phone"`
+	PropertyType            string  `json:"property_type"` // application_property_type
+	PropertyValue           float64 `json:"property_value"`
+	LoanAmount              float64 `json:"loan_amount"`
+	TenorYears              int     `json:"loan_term_years"`
+	Purpose                 string  `json:"purpose"`                   // application_purpose
+	PropertyCertificateType string  `json:"property_certificate_type"` // property_certificate_type
+	PropertyAddress         string  `json:"property_address"`
+	DeveloperName           string  `json:"developer_name,omitempty"`
+	KPRRateID               int     `json:"kpr_rate_id"`
+	InterestRate            float64 `json:"interest_rate"`
+	MonthlyInstallment      float64 `json:"monthly_installment"`
+}
+
+// DocumentChecklistItem is one required document and whether it has been received
+type DocumentChecklistItem struct {
+	Code       string     `json:"code"`
+	Label      string     `json:"label"`
+	Received   bool       `json:"received"`
+	Pending    bool       `json:"pending_verification,omitempty"` // uploaded over WhatsApp, not yet verified by staff
+	Source     string     `json:"source,omitempty"`               // WHATSAPP | STAFF
+	ReceivedAt *time.Time `json:"received_at,omitempty"`
+}
+
+// DocumentChecklist is the document status of one application
+type DocumentChecklist struct {
+	ApplicationNumber string                  `json:"application_number"`
+	Purpose           string                  `json:"purpose"`
+	CertificateType   string                  `json:"property_certificate_type"`
+	Items             []DocumentChecklistItem `json:"items"`
+	Missing           []string                `json:"missing"` // codes of documents not yet received
+}
+
+// DocumentReceipt records that a checklist document was received for an application
+type DocumentReceipt struct {
+	ApplicationNumber string `json:"application_number"`
+	DocumentCode      string `json:"document_code"`
+	Source            string `json:"source"` // WHATSAPP | STAFF
+	ReceivedByUserID  int    `json:"received_by_user_id,omitempty"`
+	Phone             string `json:"phone,omitempty"`
+	MessageID         string `json:"message_id,omitempty"`
+	MimeType          string `json:"mime_type,omitempty"`
+	FileName          string `json:"file_name,omitempty"`
+	FileSHA256        string `json:"file_sha256,omitempty"`
+	// WhatsApp download keys of an uploaded file, so staff can fetch it for verification
+	MediaType     string `json:"-"` // image | document
+	DirectPath    string `json:"-"`
+	MediaKey      []byte `json:"-"`
+	FileEncSHA256 string `json:"-"` // hex
+	FileLength    uint64 `json:"-"`
+}
+
+// IncomingMedia describes an image or document a user sent over WhatsApp: metadata plus the
+// keys needed to download it later (the file itself is not downloaded)
+type IncomingMedia struct {
+	MessageID     string
+	Caption       string
+	MimeType      string
+	FileName      string
+	FileSHA256    string // hex
+	MediaType     string // image | document
+	DirectPath    string
+	MediaKey      []byte
+	FileEncSHA256 string // hex
+	FileLength    uint64
+}
+
+// PipelineStageMetric is the completion time of one approval_workflow stage
+type PipelineStageMetric struct {
+	Stage     string  `json:"stage"`
+	Completed int     `json:"completed"`
+	AvgHours  float64 `json:"avg_hours"`
+	P90Hours  float64 `json:"p90_hours"`
+}
+
+// PipelineBacklog counts open approval_workflow items per priority
+type PipelineBacklog struct {
+	Priority string `json:"priority"`
+	Open     int    `json:"open"`
+	Overdue  int    `json:"overdue"`
+}
+
+// PipelineBranchThroughput is completed items per branch and per assignee (no individual staff)
+type PipelineBranchThroughput struct {
+	BranchCode  string  `json:"branch_code"`
+	Completed   int     `json:"completed"`
+	Assignees   int     `json:"assignees"`
+	PerAssignee float64 `json:"per_assignee"`
+}
+
+// PipelineAnalytics are approval pipeline metrics over the last Days days
+type PipelineAnalytics struct {
+	BranchCode     string                     `json:"branch_code,omitempty"` // empty = all branches
+	Days           int                        `json:"days"`
+	GeneratedAt    time.Time                  `json:"generated_at"`
+	Stages         []PipelineStageMetric      `json:"stages"`
+	Backlog        []PipelineBacklog          `json:"backlog"`
+	Items          int                        `json:"items"` // workflow items created in the window
+	Escalated      int                        `json:"escalated"`
+	EscalationRate float64                    `json:"escalation_rate"`
+	Throughput     []PipelineBranchThroughput `json:"throughput"`
+}
+
+// DeveloperPartner is a property developer with its own API keys and WhatsApp numbers.
+// DeveloperName is matched exactly against kpr_applications.developer_name.
+type DeveloperPartner struct {
+	ID            int    `json:"id"`
+	DeveloperName string `json:"developer_name"`
+}
+
+// PartnerQuery narrows a partner portfolio. Empty fields and zero times mean "no filter";
+// the range is half-open [From, To) on created_at.
+type PartnerQuery struct {
+	Status            string    `json:"status,omitempty"`
+	ApplicationNumber string    `json:"application_number,omitempty"`
+	From              time.Time `json:"from,omitempty"`
+	To                time.Time `json:"to,omitempty"`
+}
+
+// PartnerStatusCount is the number of applications in one status
+type PartnerStatusCount struct {
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+// PartnerApplication is one buyer application as a developer may see it: no financial details,
+// BuyerName already masked
+type PartnerApplication struct {
+	ApplicationNumber string     `json:"application_number"`
+	Status            string     `json:"status"`
+	PropertyType      string     `json:"property_type"`
+	BuyerName         string     `json:"buyer_name"`
+	CreatedAt         time.Time  `json:"created_at"`
+	SubmittedAt       *time.Time `json:"submitted_at,omitempty"`
+}
+
+// PartnerPortfolio summarizes the applications of one developer's projects
+type PartnerPortfolio struct {
+	DeveloperName string               `json:"developer_name"`
+	Query         PartnerQuery         `json:"query"`
+	Total         int                  `json:"total"`
+	Statuses      []PartnerStatusCount `json:"statuses"`
+	Applications  []PartnerApplication `json:"applications"`
+	Truncated     bool                 `json:"truncated"` // more applications matched than are listed
+}
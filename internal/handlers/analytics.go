@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// AnalyticsHandler exposes approval pipeline metrics for the dashboard
+type AnalyticsHandler struct {
+	pipeline domain.PipelineAnalyticsService
+	config   domain.ConfigService
+}
+
+func NewAnalyticsHandler(pipeline domain.PipelineAnalyticsService, config domain.ConfigService) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		pipeline: pipeline,
+		config:   config,
+	}
+}
+
+// Pipeline handles GET /api/analytics/pipeline?branch_code=...&days=30
+func (h *AnalyticsHandler) Pipeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !apiKeyAuthorized(r, h.config) {
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	days := 0
+	if v := strings.TrimSpace(r.URL.Query().Get("days")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "days must be a positive integer")
+			return
+		}
+		days = n
+	}
+	res, err := h.pipeline.Pipeline(r.Context(), r.URL.Query().Get("branch_code"), days)
+	if err != nil {
+		log.Printf("Failed to compute pipeline analytics: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "analytics not available")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
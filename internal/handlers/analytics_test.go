@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+type mockPipeline struct {
+	branch string
+	days   int
+	err    error
+}
+
+func (m *mockPipeline) Pipeline(ctx context.Context, branchCode string, days int) (*domain.PipelineAnalytics, error) {
+	m.branch, m.days = branchCode, days
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &domain.PipelineAnalytics{BranchCode: branchCode, Days: days, Items: 4, Escalated: 1, EscalationRate: 0.25}, nil
+}
+
+func TestAnalyticsHandler_Pipeline(t *testing.T) {
+	cases := []struct {
+		method, query, key string
+		err                error
+		want               int
+	}{
+		{http.MethodGet, "?branch_code=JKT01&days=90", "secret", nil, http.StatusOK},
+		{http.MethodGet, "", "secret", nil, http.StatusOK},
+		{http.MethodGet, "?days=abc", "secret", nil, http.StatusBadRequest},
+		{http.MethodGet, "?days=-1", "secret", nil, http.StatusBadRequest},
+		{http.MethodGet, "", "", nil, http.StatusUnauthorized},
+		{http.MethodGet, "", "secret", errors.New("db down"), http.StatusInternalServerError},
+		{http.MethodPost, "", "secret", nil, http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		m := &mockPipeline{err: c.err}
+		h := NewAnalyticsHandler(m, mockConfig{})
+		req := httptest.NewRequest(c.method, "/api/analytics/pipeline"+c.query, nil)
+		if c.key != "" {
+			req.Header.Set("X-API-Key", c.key)
+		}
+		rec := httptest.NewRecorder()
+		h.Pipeline(rec, req)
+		if rec.Code != c.want {
+			t.Fatalf("%s %s: status=%d body=%s", c.method, c.query, rec.Code, rec.Body.String())
+		}
+	}
+
+	m := &mockPipeline{}
+	req := httptest.NewRequest(http.MethodGet, "/api/analytics/pipeline?branch_code=JKT01&days=90", nil)
+	req.Header.Set("X-API-Key", "secret")
+	rec := httptest.NewRecorder()
+	NewAnalyticsHandler(m, mockConfig{}).Pipeline(rec, req)
+	var body domain.PipelineAnalytics
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.BranchCode != "JKT01" || body.Days != 90 || body.EscalationRate != 0.25 {
+		t.Fatalf("body=%+v err=%v", body, err)
+	}
+	if m.branch != "JKT01" || m.days != 90 {
+		t.Fatalf("forwarded branch=%q days=%d", m.branch, m.days)
+	}
+}
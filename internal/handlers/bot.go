@@ -14,26 +14,31 @@ import (
 type BotHandler struct {
 	qa       domain.KPRQAService
 	whatsapp domain.WhatsAppService
+	commands []domain.CommandHandler // perintah non-QA (developer mitra, persetujuan, pengajuan terpandu, dokumen, analitik, ringkasan cabang), dicoba berurutan; yang juga MediaHandler menerima unggahan
 }
 
-func NewBotHandler(qa domain.KPRQAService, whatsapp domain.WhatsAppService) *BotHandler {
+func NewBotHandler(qa domain.KPRQAService, whatsapp domain.WhatsAppService, commands ...domain.CommandHandler) *BotHandler {
 	return &BotHandler{
 		qa:       qa,
 		whatsapp: whatsapp,
+		commands: commands,
 	}
 }
 
 func (h *BotHandler) HandleMessage(evt interface{}) {
 	switch e := evt.(type) {
 	case *waEvents.Message:
-		if e.Message.GetConversation() == "" && e.Message.ExtendedTextMessage == nil {
-			return
-		}
-
 		// Abaikan pesan dari diri sendiri atau dari grup
 		if e.Info.IsFromMe || e.Info.IsGroup {
 			return
 		}
+		if media, ok := services.ExtractMedia(e); ok {
+			h.handleMedia(context.Background(), e.Info.MessageSource.Sender.User, media)
+			return
+		}
+		if e.Message.GetConversation() == "" && e.Message.ExtendedTextMessage == nil {
+			return
+		}
 
 		from := e.Info.MessageSource.Sender
 		text := strings.TrimSpace(services.ExtractText(e))
@@ -51,6 +56,13 @@ func (h *BotHandler) HandleMessage(evt interface{}) {
 }
 
 func (h *BotHandler) handleQueryRequest(ctx context.Context, phone, text string) {
+	// Perintah staf diproses lebih dulu dan tidak pernah diteruskan ke AI
+	for _, c := range h.commands {
+		if reply, ok := c.HandleCommand(ctx, phone, text); ok {
+			h.sendReply(ctx, phone, reply)
+			return
+		}
+	}
 	// Gunakan AskForUser agar akses data digating berdasarkan nomor pengirim
 	result, err := h.qa.AskForUser(ctx, phone, text)
 	if err != nil {
@@ -61,6 +73,21 @@ func (h *BotHandler) handleQueryRequest(ctx context.Context, phone, text string)
 	h.sendReply(ctx, phone, result)
 }
 
+// handleMedia meneruskan gambar/dokumen ke handler yang menerima media (unggahan dokumen KPR);
+// media yang tidak ditangani diabaikan seperti sebelumnya
+func (h *BotHandler) handleMedia(ctx context.Context, phone string, media domain.IncomingMedia) {
+	for _, c := range h.commands {
+		mh, ok := c.(domain.MediaHandler)
+		if !ok {
+			continue
+		}
+		if reply, ok := mh.HandleMedia(ctx, phone, media); ok {
+			h.sendReply(ctx, phone, reply)
+			return
+		}
+	}
+}
+
 func (h *BotHandler) sendReply(ctx context.Context, phone, message string) {
     if err := h.whatsapp.SendMessage(ctx, phone, message); err != nil {
         log.Printf("Failed to send reply: %v", err)
@@ -10,6 +10,7 @@ import (
 type mockWhatsApp struct {
     lastPhone string
     lastMsg   string
+    lastDoc   domain.Document
 }
 
 func (m *mockWhatsApp) SendMessage(ctx context.Context, phone, message string) error {
@@ -18,6 +19,12 @@ func (m *mockWhatsApp) SendMessage(ctx context.Context, phone, message string) e
     return nil
 }
 
+func (m *mockWhatsApp) SendDocument(ctx context.Context, phone string, doc domain.Document) error {
+    m.lastPhone = phone
+    m.lastDoc = doc
+    return nil
+}
+
 func (m *mockWhatsApp) IsConnected() bool { return true }
 
 func TestSendReply_NoPrefixInjection(t *testing.T) {
@@ -36,3 +43,43 @@ func TestSendReply_NoPrefixInjection(t *testing.T) {
 		t.Fatalf("unexpected message: %q", mw.lastMsg)
 	}
 }
+
+type mockApprovals struct{ handled bool }
+
+func (m *mockApprovals) HandleCommand(ctx context.Context, phone, text string) (string, bool) {
+	if !m.handled {
+		return "", false
+	}
+	return "ok: " + text, true
+}
+
+func TestHandleQueryRequest_StaffCommandBypassesQA(t *testing.T) {
+	mw := &mockWhatsApp{}
+	// qa nil: bila perintah staf tidak dicegat, handler akan panic
+	h := NewBotHandler(nil, mw, &mockApprovals{handled: true})
+	h.handleQueryRequest(context.Background(), "628111", "setujui KPR-2025-001")
+	if mw.lastMsg != "ok: setujui KPR-2025-001" {
+		t.Fatalf("unexpected reply: %q", mw.lastMsg)
+	}
+}
+
+type mockUploads struct{ mockApprovals }
+
+func (m *mockUploads) HandleMedia(ctx context.Context, phone string, media domain.IncomingMedia) (string, bool) {
+	return "diterima: " + media.Caption, true
+}
+
+func TestHandleMedia_RoutesToMediaHandlers(t *testing.T) {
+	mw := &mockWhatsApp{}
+	h := NewBotHandler(nil, mw, &mockApprovals{}, &mockUploads{})
+	h.handleMedia(context.Background(), "628111", domain.IncomingMedia{Caption: "KTP"})
+	if mw.lastMsg != "diterima: KTP" {
+		t.Fatalf("unexpected reply: %q", mw.lastMsg)
+	}
+	// tanpa handler media: unggahan diabaikan
+	mw = &mockWhatsApp{}
+	NewBotHandler(nil, mw, &mockApprovals{}).handleMedia(context.Background(), "628111", domain.IncomingMedia{Caption: "KTP"})
+	if mw.lastMsg != "" {
+		t.Fatalf("media must be ignored, got %q", mw.lastMsg)
+	}
+}
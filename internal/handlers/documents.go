@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/services"
+)
+
+// DocumentHandler exposes the per-application document checklist over REST
+type DocumentHandler struct {
+	documents domain.DocumentService
+	config    domain.ConfigService
+}
+
+func NewDocumentHandler(documents domain.DocumentService, config domain.ConfigService) *DocumentHandler {
+	return &DocumentHandler{
+		documents: documents,
+		config:    config,
+	}
+}
+
+type documentChecklistResponse struct {
+	*domain.DocumentChecklist
+	Message string `json:"message"` // checklist siap kirim ke WhatsApp
+}
+
+// markDocumentRequest: dokumen diterima staf di portal / kantor cabang
+type markDocumentRequest struct {
+	ApplicationNumber string `json:"application_number"`
+	DocumentCode      string `json:"document_code"`
+	StaffUserID       int    `json:"staff_user_id"`
+}
+
+// Documents handles GET /api/kpr/documents?application_number=... (checklist dan dokumen yang kurang)
+// dan POST untuk menandai dokumen diterima oleh staf
+func (h *DocumentHandler) Documents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !apiKeyAuthorized(r, h.config) {
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	number := strings.TrimSpace(r.URL.Query().Get("application_number"))
+	if r.Method == http.MethodPost {
+		var req markDocumentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		number = strings.TrimSpace(req.ApplicationNumber)
+		if number == "" || strings.TrimSpace(req.DocumentCode) == "" || req.StaffUserID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "application_number, document_code and staff_user_id are required")
+			return
+		}
+		err := h.documents.MarkReceived(r.Context(), domain.DocumentReceipt{ApplicationNumber: number,
+			DocumentCode: req.DocumentCode, Source: services.DocumentSourceStaff, ReceivedByUserID: req.StaffUserID})
+		switch {
+		case errors.Is(err, services.ErrApplicationNotFound):
+			writeJSONError(w, http.StatusNotFound, "application not found")
+			return
+		case errors.Is(err, services.ErrUnknownDocument):
+			writeJSONError(w, http.StatusBadRequest, "document_code is not on the application checklist")
+			return
+		case err != nil:
+			log.Printf("Failed to mark document received: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "failed to record document")
+			return
+		}
+	}
+	if number == "" {
+		writeJSONError(w, http.StatusBadRequest, "application_number is required")
+		return
+	}
+
+	c, err := h.documents.Checklist(r.Context(), number, "")
+	switch {
+	case errors.Is(err, services.ErrApplicationNotFound):
+		writeJSONError(w, http.StatusNotFound, "application not found")
+		return
+	case err != nil:
+		log.Printf("Failed to load document checklist: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "checklist not available")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(documentChecklistResponse{DocumentChecklist: c, Message: services.FormatDocumentChecklist(c)})
+}
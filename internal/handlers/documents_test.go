@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/services"
+)
+
+type mockDocuments struct {
+	lastReceipt domain.DocumentReceipt
+}
+
+func (m *mockDocuments) Checklist(ctx context.Context, number, phone string) (*domain.DocumentChecklist, error) {
+	if number != "KPR-2025-001" {
+		return nil, services.ErrApplicationNotFound
+	}
+	return &domain.DocumentChecklist{ApplicationNumber: number, Items: []domain.DocumentChecklistItem{
+		{Code: "KTP", Label: "KTP", Received: m.lastReceipt.DocumentCode == "KTP"},
+	}, Missing: []string{}}, nil
+}
+
+func (m *mockDocuments) MarkReceived(ctx context.Context, rec domain.DocumentReceipt) error {
+	if rec.DocumentCode != "KTP" {
+		return services.ErrUnknownDocument
+	}
+	m.lastReceipt = rec
+	return nil
+}
+
+func TestDocumentHandler_Documents(t *testing.T) {
+	docs := &mockDocuments{}
+	h := NewDocumentHandler(docs, mockConfig{})
+	cases := []struct {
+		method, query, body string
+		want                int
+	}{
+		{http.MethodGet, "?application_number=KPR-2025-001", "", http.StatusOK},
+		{http.MethodGet, "?application_number=KPR-404", "", http.StatusNotFound},
+		{http.MethodGet, "", "", http.StatusBadRequest},
+		{http.MethodPost, "", `{"application_number":"KPR-2025-001","document_code":"NPWP","staff_user_id":9}`, http.StatusBadRequest},
+		{http.MethodPost, "", `{"application_number":"KPR-2025-001","document_code":"KTP"}`, http.StatusBadRequest},
+		{http.MethodPost, "", `{"application_number":"KPR-2025-001","document_code":"KTP","staff_user_id":9}`, http.StatusOK},
+		{http.MethodDelete, "", "", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/api/kpr/documents"+c.query, strings.NewReader(c.body))
+		req.Header.Set("X-API-Key", "secret")
+		rec := httptest.NewRecorder()
+		h.Documents(rec, req)
+		if rec.Code != c.want {
+			t.Fatalf("%s %s %s: status=%d body=%s", c.method, c.query, c.body, rec.Code, rec.Body.String())
+		}
+	}
+	if docs.lastReceipt.Source != services.DocumentSourceStaff || docs.lastReceipt.ReceivedByUserID != 9 {
+		t.Fatalf("receipt=%+v", docs.lastReceipt)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/kpr/documents?application_number=KPR-2025-001", nil)
+	rec := httptest.NewRecorder()
+	h.Documents(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("missing api key: status=%d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/kpr/documents?application_number=KPR-2025-001", nil)
+	req.Header.Set("X-API-Key", "secret")
+	rec = httptest.NewRecorder()
+	h.Documents(rec, req)
+	var body struct {
+		Items   []domain.DocumentChecklistItem `json:"items"`
+		Message string                         `json:"message"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || len(body.Items) != 1 || !body.Items[0].Received || body.Message == "" {
+		t.Fatalf("body=%+v err=%v", body, err)
+	}
+}
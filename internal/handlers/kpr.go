@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/services"
+)
+
+// KPRHandler exposes the deterministic KPR calculator over REST
+type KPRHandler struct {
+	calculator domain.KPRCalculatorService
+	config     domain.ConfigService
+}
+
+func NewKPRHandler(calculator domain.KPRCalculatorService, config domain.ConfigService) *KPRHandler {
+	return &KPRHandler{
+		calculator: calculator,
+		config:     config,
+	}
+}
+
+// totalCostRequest: DP boleh dikirim sebagai rupiah (down_payment) atau persen (down_payment_percent)
+type totalCostRequest struct {
+	domain.SimulationRequest
+	DownPaymentPercent float64 `json:"down_payment_percent,omitempty"`
+}
+
+type totalCostResponse struct {
+	*domain.TotalCostResult
+	Message string `json:"message"` // rincian siap kirim ke WhatsApp
+}
+
+// TotalCost handles POST /api/kpr/total-cost
+func (h *KPRHandler) TotalCost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req totalCostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.DownPayment <= 0 && req.DownPaymentPercent > 0 {
+		req.DownPayment = math.Round(req.PropertyPrice * req.DownPaymentPercent / 100)
+	}
+	if err := services.ValidateSimulationRequest(req.SimulationRequest); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.calculator.TotalCost(r.Context(), req.SimulationRequest)
+	if err != nil {
+		log.Printf("Failed to calculate total cost: %v", err)
+		writeJSONError(w, http.StatusUnprocessableEntity, "kpr product not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(totalCostResponse{TotalCostResult: res, Message: services.FormatTotalCost(res)})
+}
+
+// Amortization handles GET /api/kpr/amortization?application_number=...&format=pdf|csv untuk
+// pengajuan yang sudah disetujui, dan POST dengan body simulasi (sama seperti total-cost)
+func (h *KPRHandler) Amortization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = "pdf"
+	}
+	if format != "pdf" && format != "csv" {
+		writeJSONError(w, http.StatusBadRequest, "format must be pdf or csv")
+		return
+	}
+
+	var sched *domain.AmortizationSchedule
+	var err error
+	if r.Method == http.MethodGet {
+		number := strings.TrimSpace(r.URL.Query().Get("application_number"))
+		if number == "" {
+			writeJSONError(w, http.StatusBadRequest, "application_number is required")
+			return
+		}
+		sched, err = h.calculator.ApplicationAmortization(r.Context(), number, "")
+	} else {
+		var req totalCostRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.DownPayment <= 0 && req.DownPaymentPercent > 0 {
+			req.DownPayment = math.Round(req.PropertyPrice * req.DownPaymentPercent / 100)
+		}
+		if err := services.ValidateSimulationRequest(req.SimulationRequest); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sched, err = h.calculator.Amortization(r.Context(), req.SimulationRequest)
+	}
+	switch {
+	case errors.Is(err, services.ErrApplicationNotFound):
+		writeJSONError(w, http.StatusNotFound, "application not found")
+		return
+	case errors.Is(err, services.ErrApplicationNotApproved):
+		writeJSONError(w, http.StatusConflict, "application not approved")
+		return
+	case err != nil:
+		log.Printf("Failed to build amortization schedule: %v", err)
+		writeJSONError(w, http.StatusUnprocessableEntity, "schedule not available")
+		return
+	}
+
+	doc, err := services.ScheduleFile(sched, format)
+	if err != nil {
+		log.Printf("Failed to render amortization schedule: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to render schedule")
+		return
+	}
+	w.Header().Set("Content-Type", doc.MimeType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+doc.FileName+`"`)
+	_, _ = w.Write(doc.Data)
+}
+
+// authorized memeriksa X-API-Key (atau ?api_key); API_KEY kosong berarti semua request ditolak
+func (h *KPRHandler) authorized(r *http.Request) bool {
+	return apiKeyAuthorized(r, h.config)
+}
+
+func apiKeyAuthorized(r *http.Request, config domain.ConfigService) bool {
+	key := requestAPIKey(r)
+	apiKey := config.GetAPIKey()
+	return apiKey != "" && key == apiKey
+}
+
+// requestAPIKey membaca key dari header X-API-Key, atau ?api_key bila header kosong
+func requestAPIKey(r *http.Request) string {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key = r.URL.Query().Get("api_key")
+	}
+	return key
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": msg})
+}
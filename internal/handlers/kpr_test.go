@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/services"
+)
+
+type mockConfig struct{ domain.ConfigService }
+
+func (mockConfig) GetAPIKey() string { return "secret" }
+
+type mockCalculator struct {
+	domain.KPRCalculatorService
+	lastReq domain.SimulationRequest
+}
+
+func (m *mockCalculator) TotalCost(ctx context.Context, req domain.SimulationRequest) (*domain.TotalCostResult, error) {
+	m.lastReq = req
+	return &domain.TotalCostResult{Simulation: domain.SimulationResult{Rate: domain.KPRRate{RateName: "KPR Griya"}}, TotalPaid: 1}, nil
+}
+
+func (m *mockCalculator) ApplicationAmortization(ctx context.Context, number, phone string) (*domain.AmortizationSchedule, error) {
+	if number != "KPR-2025-001" {
+		return nil, services.ErrApplicationNotFound
+	}
+	return &domain.AmortizationSchedule{ApplicationNumber: number, TenorMonths: 1, Rows: []domain.AmortizationRow{{Month: 1, Installment: 100}}}, nil
+}
+
+func TestKPRHandler_Amortization(t *testing.T) {
+	h := NewKPRHandler(&mockCalculator{}, mockConfig{})
+	cases := []struct {
+		query       string
+		want        int
+		contentType string
+	}{
+		{"?application_number=KPR-2025-001&format=csv", http.StatusOK, "text/csv"},
+		{"?application_number=KPR-2025-001", http.StatusOK, "application/pdf"},
+		{"?application_number=KPR-404", http.StatusNotFound, "application/json"},
+		{"?application_number=KPR-2025-001&format=docx", http.StatusBadRequest, "application/json"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/kpr/amortization"+c.query, nil)
+		req.Header.Set("X-API-Key", "secret")
+		rec := httptest.NewRecorder()
+		h.Amortization(rec, req)
+		if rec.Code != c.want || rec.Header().Get("Content-Type") != c.contentType {
+			t.Fatalf("%s: status=%d type=%q", c.query, rec.Code, rec.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestKPRHandler_TotalCost(t *testing.T) {
+	calc := &mockCalculator{}
+	h := NewKPRHandler(calc, mockConfig{})
+	cases := []struct {
+		name, key, body string
+		want            int
+	}{
+		{"unauthorized", "wrong", `{}`, http.StatusUnauthorized},
+		{"invalid input", "secret", `{"property_price":500000000,"down_payment":600000000,"tenor_years":10}`, http.StatusBadRequest},
+		{"ok with dp percent", "secret", `{"property_price":1000000000,"down_payment_percent":20,"tenor_years":20}`, http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/kpr/total-cost", strings.NewReader(c.body))
+		req.Header.Set("X-API-Key", c.key)
+		rec := httptest.NewRecorder()
+		h.TotalCost(rec, req)
+		if rec.Code != c.want {
+			t.Fatalf("%s: status=%d body=%s", c.name, rec.Code, rec.Body.String())
+		}
+	}
+	if calc.lastReq.DownPayment != 200000000 {
+		t.Fatalf("dp percent not converted: %+v", calc.lastReq)
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/services"
+)
+
+// PartnerHandler exposes a developer's own applications, authenticated by that developer's API key.
+// The global API_KEY carries no developer identity and is not accepted here.
+type PartnerHandler struct {
+	partners domain.PartnerService
+}
+
+func NewPartnerHandler(partners domain.PartnerService) *PartnerHandler {
+	return &PartnerHandler{partners: partners}
+}
+
+// Applications handles GET /api/partner/applications?status=APPROVED&from=2026-01-01&to=2026-01-31&application_number=...
+// from/to are inclusive dates on created_at (WIB).
+func (h *PartnerHandler) Applications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	partner, err := h.partners.PartnerByAPIKey(r.Context(), requestAPIKey(r))
+	if err != nil {
+		log.Printf("Failed to look up partner key: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "partner lookup failed")
+		return
+	}
+	if partner == nil {
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	qs := r.URL.Query()
+	q := domain.PartnerQuery{
+		Status:            strings.ToUpper(strings.TrimSpace(qs.Get("status"))),
+		ApplicationNumber: strings.ToUpper(strings.TrimSpace(qs.Get("application_number"))),
+	}
+	if q.Status != "" && !services.IsApplicationStatus(q.Status) {
+		writeJSONError(w, http.StatusBadRequest, "unknown status")
+		return
+	}
+	if q.From, err = parsePartnerDate(qs.Get("from")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "from must be YYYY-MM-DD")
+		return
+	}
+	to, err := parsePartnerDate(qs.Get("to"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "to must be YYYY-MM-DD")
+		return
+	}
+	if !to.IsZero() {
+		q.To = to.AddDate(0, 0, 1)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		writeJSONError(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+	res, err := h.partners.Portfolio(r.Context(), *partner, q)
+	if err != nil {
+		log.Printf("Failed to load partner portfolio %d: %v", partner.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "applications not available")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// parsePartnerDate: "" = tanpa batas; tanggal dibaca sebagai WIB (sama dengan timestamp di DB)
+func parsePartnerDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.FixedZone("WIB", 7*3600))
+}
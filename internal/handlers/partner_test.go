@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+type mockPartners struct {
+	last domain.PartnerQuery
+}
+
+func (m *mockPartners) PartnerByAPIKey(ctx context.Context, key string) (*domain.DeveloperPartner, error) {
+	if key != "dev-key" {
+		return nil, nil
+	}
+	return &domain.DeveloperPartner{ID: 7, DeveloperName: "PT Maju Jaya"}, nil
+}
+
+func (m *mockPartners) Portfolio(ctx context.Context, partner domain.DeveloperPartner, q domain.PartnerQuery) (*domain.PartnerPortfolio, error) {
+	m.last = q
+	return &domain.PartnerPortfolio{DeveloperName: partner.DeveloperName, Query: q, Total: 1,
+		Statuses:     []domain.PartnerStatusCount{{Status: "SUBMITTED", Count: 1}},
+		Applications: []domain.PartnerApplication{{ApplicationNumber: "KPR-2025-001", Status: "SUBMITTED", BuyerName: "B*** S***"}}}, nil
+}
+
+func TestPartnerHandler_Applications(t *testing.T) {
+	m := &mockPartners{}
+	h := NewPartnerHandler(m)
+	cases := []struct {
+		method, query, key string
+		want               int
+	}{
+		{http.MethodGet, "", "dev-key", http.StatusOK},
+		{http.MethodGet, "?status=approved&from=2026-03-01&to=2026-03-31", "dev-key", http.StatusOK},
+		{http.MethodGet, "", "", http.StatusUnauthorized},
+		{http.MethodGet, "", "secret", http.StatusUnauthorized}, // API_KEY global tidak membawa identitas developer
+		{http.MethodGet, "?from=01-03-2026", "dev-key", http.StatusBadRequest},
+		{http.MethodGet, "?status=approvedd", "dev-key", http.StatusBadRequest},
+		{http.MethodGet, "?from=2026-03-31&to=2026-03-01", "dev-key", http.StatusBadRequest},
+		{http.MethodPost, "", "dev-key", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/api/partner/applications"+c.query, nil)
+		if c.key != "" {
+			req.Header.Set("X-API-Key", c.key)
+		}
+		rec := httptest.NewRecorder()
+		h.Applications(rec, req)
+		if rec.Code != c.want {
+			t.Fatalf("%s %s key=%q: status=%d body=%s", c.method, c.query, c.key, rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/partner/applications?status=approved&from=2026-03-01&to=2026-03-31&api_key=dev-key", nil)
+	rec := httptest.NewRecorder()
+	h.Applications(rec, req)
+	wib := time.FixedZone("WIB", 7*3600)
+	if m.last.Status != "APPROVED" || !m.last.From.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, wib)) || !m.last.To.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, wib)) {
+		t.Fatalf("query=%+v", m.last)
+	}
+	var body domain.PartnerPortfolio
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.DeveloperName != "PT Maju Jaya" || body.Applications[0].BuyerName != "B*** S***" {
+		t.Fatalf("body=%+v err=%v", body, err)
+	}
+}
@@ -0,0 +1,43 @@
+-- Notifikasi perubahan status pengajuan ke nasabah via WhatsApp.
+-- notification_log adalah outbox sekaligus tabel de-duplikasi: satu dedupe_key hanya dikirim sekali.
+CREATE TABLE IF NOT EXISTS notification_log (
+	id bigserial PRIMARY KEY,
+	dedupe_key varchar(200) NOT NULL UNIQUE, -- status:<application_id>:<status> | stage:<approval_workflow.id>
+	application_id int4 NOT NULL,
+	phone varchar(20) NOT NULL,
+	message text NOT NULL,
+	status varchar(10) DEFAULT 'PENDING' NOT NULL, -- PENDING | SENDING | SENT | FAILED
+	attempts int4 DEFAULT 0 NOT NULL,
+	send_after timestamptz DEFAULT now() NOT NULL, -- ditunda sampai jam tenang berakhir
+	last_error text NULL,
+	created_at timestamptz DEFAULT now() NOT NULL,
+	sent_at timestamptz NULL
+);
+CREATE INDEX IF NOT EXISTS notification_log_due_idx ON notification_log (send_after) WHERE status IN ('PENDING', 'SENDING');
+
+-- Payload hanya berisi id; detail (nomor HP, status) dibaca ulang oleh notifier.
+CREATE OR REPLACE FUNCTION notify_kpr_application_status() RETURNS trigger AS $$
+BEGIN
+	IF NEW.status IS DISTINCT FROM OLD.status THEN
+		PERFORM pg_notify('kpr_status_changed', json_build_object('kind', 'status', 'id', NEW.id)::text);
+	END IF;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS kpr_applications_status_notify ON kpr_applications;
+CREATE TRIGGER kpr_applications_status_notify
+	AFTER UPDATE OF status ON kpr_applications
+	FOR EACH ROW EXECUTE FUNCTION notify_kpr_application_status();
+
+CREATE OR REPLACE FUNCTION notify_approval_workflow_stage() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('kpr_status_changed', json_build_object('kind', 'stage', 'id', NEW.id)::text);
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS approval_workflow_stage_notify ON approval_workflow;
+CREATE TRIGGER approval_workflow_stage_notify
+	AFTER INSERT ON approval_workflow
+	FOR EACH ROW EXECUTE FUNCTION notify_approval_workflow_stage();
@@ -0,0 +1,12 @@
+-- Riwayat pengingat SLA approval_workflow: satu jenis pengingat per item per penerima hanya dikirim sekali.
+CREATE TABLE IF NOT EXISTS workflow_reminders (
+	id bigserial PRIMARY KEY,
+	workflow_id int4 NOT NULL,
+	kind varchar(20) NOT NULL, -- DUE_SOON | OVERDUE | ESCALATED
+	recipient_user_id int4 NOT NULL,
+	phone varchar(20) NOT NULL,
+	message text NOT NULL,
+	sent_at timestamptz DEFAULT now() NOT NULL,
+	CONSTRAINT workflow_reminders_once UNIQUE (workflow_id, kind, recipient_user_id)
+);
+CREATE INDEX IF NOT EXISTS workflow_reminders_workflow_idx ON workflow_reminders (workflow_id);
@@ -0,0 +1,29 @@
+-- Jejak audit keputusan staf (setujui/tolak) yang dikirim lewat WhatsApp.
+-- Tabel append-only: UPDATE dan DELETE ditolak oleh trigger.
+CREATE TABLE IF NOT EXISTS approval_audit_log (
+	id bigserial PRIMARY KEY,
+	workflow_id int4 NOT NULL,
+	application_id int4 NOT NULL,
+	application_number varchar(20) NOT NULL,
+	stage varchar(50) NOT NULL,
+	actor_user_id int4 NOT NULL,
+	actor_phone varchar(20) NOT NULL,
+	action varchar(10) NOT NULL, -- APPROVE | REJECT
+	previous_status varchar(30) NOT NULL,
+	new_status varchar(30) NOT NULL,
+	notes text NULL,
+	channel varchar(20) DEFAULT 'WHATSAPP_OTP' NOT NULL,
+	created_at timestamptz DEFAULT now() NOT NULL
+);
+CREATE INDEX IF NOT EXISTS approval_audit_log_workflow_idx ON approval_audit_log (workflow_id);
+
+CREATE OR REPLACE FUNCTION approval_audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'approval_audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS approval_audit_log_no_change ON approval_audit_log;
+CREATE TRIGGER approval_audit_log_no_change
+	BEFORE UPDATE OR DELETE ON approval_audit_log
+	FOR EACH ROW EXECUTE FUNCTION approval_audit_log_immutable();
@@ -0,0 +1,17 @@
+-- Ringkasan harian untuk kepala cabang: langganan per user (opt-in) dan log pengiriman.
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+	user_id int4 PRIMARY KEY,
+	enabled bool DEFAULT true NOT NULL,
+	timezone varchar(4) DEFAULT 'WIB' NOT NULL, -- WIB | WITA | WIT
+	created_at timestamptz DEFAULT now() NOT NULL,
+	updated_at timestamptz DEFAULT now() NOT NULL
+);
+
+-- Satu ringkasan per user per tanggal lokal
+CREATE TABLE IF NOT EXISTS digest_log (
+	user_id int4 NOT NULL,
+	digest_date date NOT NULL,
+	branch_code varchar(10) NOT NULL,
+	sent_at timestamptz DEFAULT now() NOT NULL,
+	PRIMARY KEY (user_id, digest_date)
+);
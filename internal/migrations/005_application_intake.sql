@@ -0,0 +1,37 @@
+-- Pengajuan KPR terpandu lewat chat: progres per nomor WhatsApp dan audit pembuatan DRAFT.
+CREATE TABLE IF NOT EXISTS intake_sessions (
+	phone varchar(20) PRIMARY KEY,
+	user_id int4 NOT NULL,
+	step varchar(30) NOT NULL,
+	data jsonb DEFAULT '{}'::jsonb NOT NULL,
+	created_at timestamptz DEFAULT now() NOT NULL,
+	updated_at timestamptz DEFAULT now() NOT NULL
+);
+
+-- Nomor pengajuan dari bot: KPR-WA-<tahun>-<urut>, terpisah dari penomoran portal
+CREATE SEQUENCE IF NOT EXISTS bot_application_number_seq;
+
+-- Jejak audit setiap baris kpr_applications yang dibuat bot (append-only)
+CREATE TABLE IF NOT EXISTS application_audit_log (
+	id bigserial PRIMARY KEY,
+	application_id int4 NOT NULL,
+	application_number varchar(20) NOT NULL,
+	actor_user_id int4 NOT NULL,
+	actor_phone varchar(20) NOT NULL,
+	action varchar(20) NOT NULL, -- CREATE_DRAFT
+	payload jsonb NOT NULL,
+	channel varchar(20) DEFAULT 'WHATSAPP' NOT NULL,
+	created_at timestamptz DEFAULT now() NOT NULL
+);
+CREATE INDEX IF NOT EXISTS application_audit_log_application_idx ON application_audit_log (application_id);
+
+CREATE OR REPLACE FUNCTION application_audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'application_audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS application_audit_log_no_change ON application_audit_log;
+CREATE TRIGGER application_audit_log_no_change
+	BEFORE UPDATE OR DELETE ON application_audit_log
+	FOR EACH ROW EXECUTE FUNCTION application_audit_log_immutable();
@@ -0,0 +1,43 @@
+-- Checklist dokumen KPR per tujuan pengajuan dan jenis sertifikat, serta dokumen yang sudah diterima.
+-- purpose / certificate_type NULL berarti berlaku untuk semua nilai. Baris yang lebih spesifik
+-- dengan document_code sama menggantikan label baris umum.
+CREATE TABLE IF NOT EXISTS document_requirements (
+	id serial PRIMARY KEY,
+	document_code varchar(40) NOT NULL,
+	label varchar(150) NOT NULL,
+	keywords varchar(255) DEFAULT '' NOT NULL, -- sinonim dipisah koma untuk mencocokkan caption unggahan
+	purpose varchar(50) NULL, -- application_purpose
+	certificate_type varchar(50) NULL, -- property_certificate_type
+	sort_order int4 DEFAULT 100 NOT NULL,
+	is_active bool DEFAULT true NOT NULL,
+	created_at timestamptz DEFAULT now() NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS document_requirements_scope_key
+	ON document_requirements (document_code, COALESCE(purpose, ''), COALESCE(certificate_type, ''));
+
+INSERT INTO document_requirements (document_code, label, keywords, sort_order) VALUES
+	('KTP', 'KTP pemohon (dan pasangan bila sudah menikah)', 'ktp,e-ktp,kartu tanda penduduk', 10),
+	('KK', 'Kartu Keluarga', 'kk,kartu keluarga', 20),
+	('NPWP', 'NPWP', 'npwp', 30),
+	('SLIP_GAJI', 'Slip gaji 3 bulan terakhir', 'slip gaji,slip,gaji,payslip', 40),
+	('REKENING_KORAN', 'Rekening koran / tabungan 3 bulan terakhir', 'rekening koran,rekening,mutasi,buku tabungan', 50),
+	('SERTIFIKAT', 'Fotokopi sertifikat properti', 'sertifikat,shm,shgb,hgb', 60),
+	('PBB', 'SPPT PBB tahun terakhir', 'pbb,sppt', 70),
+	('IMB_PBG', 'IMB / PBG bangunan', 'imb,pbg,izin bangunan', 80)
+ON CONFLICT DO NOTHING;
+
+-- Dokumen yang sudah diterima per pengajuan: dari unggahan WhatsApp nasabah atau ditandai staf.
+-- Satu baris per dokumen; unggahan ulang memperbarui baris yang sama.
+CREATE TABLE IF NOT EXISTS application_documents (
+	application_id int4 NOT NULL,
+	document_code varchar(40) NOT NULL,
+	source varchar(20) NOT NULL, -- WHATSAPP | STAFF
+	received_by_user_id int4 NULL,
+	phone varchar(20) NULL,
+	message_id varchar(64) NULL,
+	mime_type varchar(100) NULL,
+	file_name varchar(255) NULL,
+	file_sha256 varchar(64) NULL,
+	received_at timestamptz DEFAULT now() NOT NULL,
+	PRIMARY KEY (application_id, document_code)
+);
@@ -0,0 +1,24 @@
+-- Developer mitra: developer_name harus sama persis dengan kpr_applications.developer_name.
+-- Akses developer hanya lewat nomor WhatsApp dan API key terdaftar di bawah ini.
+CREATE TABLE IF NOT EXISTS developer_partners (
+	id serial PRIMARY KEY,
+	developer_name varchar(100) NOT NULL UNIQUE,
+	is_active bool DEFAULT true NOT NULL,
+	created_at timestamptz DEFAULT now() NOT NULL
+);
+
+-- Nomor WhatsApp PIC developer (format sama dengan users.phone, mis. 6281234567890)
+CREATE TABLE IF NOT EXISTS developer_partner_phones (
+	phone varchar(20) PRIMARY KEY,
+	partner_id int4 NOT NULL REFERENCES developer_partners(id),
+	created_at timestamptz DEFAULT now() NOT NULL
+);
+
+-- API key per developer; hanya hash SHA-256 (hex) yang disimpan, key dicabut dengan mengisi revoked_at
+CREATE TABLE IF NOT EXISTS developer_partner_keys (
+	key_hash char(64) PRIMARY KEY,
+	partner_id int4 NOT NULL REFERENCES developer_partners(id),
+	label varchar(100) NULL,
+	created_at timestamptz DEFAULT now() NOT NULL,
+	revoked_at timestamptz NULL
+);
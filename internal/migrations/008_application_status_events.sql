@@ -0,0 +1,27 @@
+-- Riwayat transisi status pengajuan: satu baris per perubahan status. Notifikasi memakai id event
+-- sebagai dedupe_key, sehingga kembali ke status yang pernah dikirim (REVIEW -> SUBMITTED -> REVIEW)
+-- tetap diberitahukan, sedangkan event yang sama dari NOTIFY dan polling tetap dikirim sekali.
+CREATE TABLE IF NOT EXISTS application_status_events (
+	id bigserial PRIMARY KEY,
+	application_id int4 NOT NULL,
+	status varchar(50) NOT NULL,
+	created_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS application_status_events_created_idx ON application_status_events (created_at);
+
+-- Payload kini berisi id event (bukan id pengajuan); status yang dikirim adalah status saat transisi.
+CREATE OR REPLACE FUNCTION notify_kpr_application_status() RETURNS trigger AS $$
+DECLARE
+	event_id bigint;
+BEGIN
+	IF NEW.status IS DISTINCT FROM OLD.status THEN
+		INSERT INTO application_status_events (application_id, status)
+		VALUES (NEW.id, COALESCE(NEW.status::text, ''))
+		RETURNING id INTO event_id;
+		PERFORM pg_notify('kpr_status_changed', json_build_object('kind', 'status', 'id', event_id)::text);
+	END IF;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+COMMENT ON COLUMN notification_log.dedupe_key IS 'status:<application_status_events.id> | stage:<approval_workflow.id>';
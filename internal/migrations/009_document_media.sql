@@ -0,0 +1,8 @@
+-- Kunci unduh WhatsApp untuk dokumen yang diunggah nasabah, agar staf dapat mengambil file dan
+-- memverifikasinya. Unggahan WhatsApp berstatus menunggu verifikasi sampai staf menandainya
+-- (source berubah menjadi STAFF); kunci dan metadata file unggahan tetap disimpan.
+ALTER TABLE application_documents ADD COLUMN IF NOT EXISTS media_type varchar(20) NULL; -- image | document
+ALTER TABLE application_documents ADD COLUMN IF NOT EXISTS direct_path text NULL;
+ALTER TABLE application_documents ADD COLUMN IF NOT EXISTS media_key bytea NULL;
+ALTER TABLE application_documents ADD COLUMN IF NOT EXISTS file_enc_sha256 varchar(64) NULL;
+ALTER TABLE application_documents ADD COLUMN IF NOT EXISTS file_length int8 NULL;
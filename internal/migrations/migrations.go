@@ -0,0 +1,89 @@
+// Package migrations berisi migrasi skema milik bot (tabel dan trigger tambahan di luar ddl.sql).
+// File *.sql di-embed ke binary dan dijalankan berurutan sesuai nama saat startup.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Migration adalah satu file migrasi; Version = nama file tanpa ekstensi
+type Migration struct {
+	Version string
+	SQL     string
+}
+
+// List mengembalikan semua migrasi terurut menurut versi
+func List() ([]Migration, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	out := make([]Migration, 0, len(names))
+	for _, n := range names {
+		b, err := files.ReadFile(n)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Migration{Version: strings.TrimSuffix(n, ".sql"), SQL: string(b)})
+	}
+	return out, nil
+}
+
+// Apply menjalankan migrasi yang belum tercatat di schema_migrations. Setiap file dijalankan
+// atomik bersama pencatatan versinya, sehingga gagal di tengah tidak meninggalkan migrasi
+// setengah jadi. File migrasi tidak boleh berisi BEGIN/COMMIT sendiri. Mengembalikan versi
+// yang baru diterapkan.
+func Apply(ctx context.Context, db domain.DatabaseService) ([]string, error) {
+	if _, err := db.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version varchar(100) PRIMARY KEY,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)`); err != nil {
+		return nil, fmt.Errorf("schema_migrations: %w", err)
+	}
+	applied := map[string]bool{}
+	rows, err := db.Query(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		applied[v] = true
+	}
+	rows.Close()
+
+	all, err := List()
+	if err != nil {
+		return nil, err
+	}
+	var done []string
+	for _, m := range all {
+		if applied[m.Version] {
+			continue
+		}
+		// Tanpa argumen, pgx memakai simple protocol: banyak statement dalam satu Exec dijalankan
+		// Postgres sebagai satu transaksi implisit, termasuk pencatatan versinya.
+		script := m.SQL + "\nINSERT INTO schema_migrations (version) VALUES ('" + m.Version + "');"
+		if _, err := db.Exec(ctx, script); err != nil {
+			return done, fmt.Errorf("migration %s: %w", m.Version, err)
+		}
+		log.Printf("[MIGRATE] applied %s", m.Version)
+		done = append(done, m.Version)
+	}
+	return done, nil
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestList_OrderedAndSelfContained(t *testing.T) {
+	all, err := List()
+	if err != nil || len(all) == 0 {
+		t.Fatalf("list: %v (n=%d)", err, len(all))
+	}
+	version := regexp.MustCompile(`^\d{3}_[a-z0-9_]+$`)
+	for i, m := range all {
+		if !version.MatchString(m.Version) {
+			t.Fatalf("bad migration name %q", m.Version)
+		}
+		if i > 0 && all[i-1].Version >= m.Version {
+			t.Fatalf("migrations out of order: %s then %s", all[i-1].Version, m.Version)
+		}
+		// Apply sudah membungkus setiap file dalam satu transaksi implisit
+		upper := strings.ToUpper(m.SQL)
+		if strings.Contains(upper, "BEGIN;") || strings.Contains(upper, "COMMIT;") {
+			t.Fatalf("%s must not manage its own transaction", m.Version)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package nlu
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// monthNames: nama bulan lengkap dan singkatan umum
+var monthNames = map[string]time.Month{
+	"januari": time.January, "jan": time.January,
+	"februari": time.February, "pebruari": time.February, "feb": time.February,
+	"maret": time.March, "mar": time.March,
+	"april": time.April, "apr": time.April,
+	"mei":  time.May,
+	"juni": time.June, "jun": time.June,
+	"juli": time.July, "jul": time.July,
+	"agustus": time.August, "agu": time.August, "agt": time.August, "ags": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September,
+	"oktober": time.October, "okt": time.October,
+	"november": time.November, "nopember": time.November, "nov": time.November,
+	"desember": time.December, "des": time.December,
+}
+
+// monthAlt: alternasi regex nama bulan, terpanjang lebih dulu
+var monthAlt = func() string {
+	names := make([]string, 0, len(monthNames))
+	for n := range monthNames {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return strings.Join(names, "|")
+}()
+
+// countWords: bilangan kata kecil untuk "tiga bulan terakhir"
+const countWords = `dua belas|satu|dua|tiga|empat|lima|enam|tujuh|delapan|sembilan|sepuluh|sebelas`
+
+// datePattern menerjemahkan satu bentuk tanggal menjadi rentang [from, to)
+type datePattern struct {
+	re    *regexp.Regexp
+	parse func(m []string, today time.Time) (from, to time.Time, conf float64, ok bool)
+}
+
+// datePatterns diurutkan dari bentuk paling spesifik; span yang sudah terpakai tidak dibaca ulang
+var datePatterns = []datePattern{
+	// 2025-03-17
+	{regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`), func(m []string, today time.Time) (time.Time, time.Time, float64, bool) {
+		y, _ := strconv.Atoi(m[1])
+		mo, _ := strconv.Atoi(m[2])
+		d, _ := strconv.Atoi(m[3])
+		from, ok := validDate(y, time.Month(mo), d, today.Location())
+		return from, from.AddDate(0, 0, 1), 0.95, ok
+	}},
+	// 17 agustus 2025
+	{regexp.MustCompile(`\b(\d{1,2})\s+(` + monthAlt + `)\s+(\d{4})\b`), func(m []string, today time.Time) (time.Time, time.Time, float64, bool) {
+		d, _ := strconv.Atoi(m[1])
+		y, _ := strconv.Atoi(m[3])
+		from, ok := validDate(y, monthNames[m[2]], d, today.Location())
+		return from, from.AddDate(0, 0, 1), 0.95, ok
+	}},
+	// sejak maret 2025 / sejak maret
+	{regexp.MustCompile(`\b(?:sejak|mulai|dari)\s+(?:bulan\s+)?(` + monthAlt + `)(?:\s+(\d{4}))?\b`), func(m []string, today time.Time) (time.Time, time.Time, float64, bool) {
+		from, conf := monthStart(m[1], m[2], today)
+		return from, today.AddDate(0, 0, 1), conf, !from.After(today)
+	}},
+	// 3 bulan terakhir, seminggu terakhir, 2 tahun belakangan
+	{regexp.MustCompile(`\b(?:(\d{1,3}|` + countWords + `)\s+(hari|minggu|pekan|bulan|tahun)|se(hari|minggu|pekan|bulan|tahun))\s+(?:terakhir|belakangan|ke\s+belakang)\b`), func(m []string, today time.Time) (time.Time, time.Time, float64, bool) {
+		n, unit := 1, m[3]
+		if m[2] != "" {
+			n, unit = countValue(m[1]), m[2]
+		}
+		return shift(today, unit, -n), today.AddDate(0, 0, 1), 0.9, n > 0
+	}},
+	// 2 bulan lalu, seminggu yang lalu: periode kalender N satuan sebelum hari ini
+	{regexp.MustCompile(`\b(?:(\d{1,3}|` + countWords + `)\s+(hari|minggu|pekan|bulan|tahun)|se(hari|minggu|pekan|bulan|tahun))\s+(?:yang\s+)?lalu\b`), func(m []string, today time.Time) (time.Time, time.Time, float64, bool) {
+		n, unit := 1, m[3]
+		if m[2] != "" {
+			n, unit = countValue(m[1]), m[2]
+		}
+		from, to := period(today, unit, -n)
+		return from, to, 0.85, n > 0
+	}},
+	// minggu ini, bulan lalu, tahun depan
+	{regexp.MustCompile(`\b(minggu|pekan|bulan|tahun)\s+(ini|lalu|kemarin|depan)\b`), func(m []string, today time.Time) (time.Time, time.Time, float64, bool) {
+		from, to := period(today, m[1], relOffset(m[2]))
+		return from, to, 0.9, true
+	}},
+	// tahun 2024
+	{regexp.MustCompile(`\btahun\s+(\d{4})\b`), func(m []string, today time.Time) (time.Time, time.Time, float64, bool) {
+		y, _ := strconv.Atoi(m[1])
+		from := time.Date(y, time.January, 1, 0, 0, 0, 0, today.Location())
+		return from, from.AddDate(1, 0, 0), 0.9, y >= 1900
+	}},
+	// maret 2025 / bulan maret
+	{regexp.MustCompile(`\b(?:bulan\s+)?(` + monthAlt + `)(?:\s+(\d{4}))?\b`), func(m []string, today time.Time) (time.Time, time.Time, float64, bool) {
+		if m[2] == "" && len(m[1]) <= 4 && m[1] != "mei" && !strings.HasPrefix(m[0], "bulan") {
+			return time.Time{}, time.Time{}, 0, false // singkatan tanpa tahun terlalu ambigu ("des", "jun")
+		}
+		from, conf := monthStart(m[1], m[2], today)
+		return from, from.AddDate(0, 1, 0), conf, true
+	}},
+	// hari ini, kemarin, besok, lusa
+	{regexp.MustCompile(`\b(hari\s+ini|kemarin|besok|lusa)\b`), func(m []string, today time.Time) (time.Time, time.Time, float64, bool) {
+		off := map[string]int{"kemarin": -1, "besok": 1, "lusa": 2}[strings.Join(strings.Fields(m[1]), " ")]
+		from := today.AddDate(0, 0, off)
+		return from, from.AddDate(0, 0, 1), 0.9, true
+	}},
+}
+
+// extractDates membaca ekspresi tanggal relatif/absolut relatif terhadap now
+func extractDates(low string, now time.Time) []Entity {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var out []Entity
+	for _, p := range datePatterns {
+		for _, idx := range p.re.FindAllStringSubmatchIndex(low, -1) {
+			m := make([]string, len(idx)/2)
+			for i := range m {
+				if idx[2*i] >= 0 {
+					m[i] = low[idx[2*i]:idx[2*i+1]]
+				}
+			}
+			from, to, conf, ok := p.parse(m, today)
+			if !ok {
+				continue
+			}
+			out = append(out, Entity{Kind: KindDate, Span: Span{idx[0], idx[1]}, From: from, To: to, Confidence: conf})
+		}
+	}
+	return out
+}
+
+// validDate menolak tanggal yang dinormalisasi time.Date ("31 februari")
+func validDate(y int, m time.Month, d int, loc *time.Location) (time.Time, bool) {
+	t := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	return t, t.Year() == y && t.Month() == m && t.Day() == d
+}
+
+// monthStart: awal bulan; tanpa tahun dipakai kemunculan terakhir yang tidak di masa depan
+func monthStart(name, year string, today time.Time) (time.Time, float64) {
+	mo := monthNames[name]
+	if year != "" {
+		y, _ := strconv.Atoi(year)
+		return time.Date(y, mo, 1, 0, 0, 0, 0, today.Location()), 0.95
+	}
+	y := today.Year()
+	if mo > today.Month() {
+		y--
+	}
+	return time.Date(y, mo, 1, 0, 0, 0, 0, today.Location()), 0.7
+}
+
+// countValue: "3" atau "tiga" -> 3
+func countValue(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	v, _ := ParseWords(s)
+	return int(v)
+}
+
+func relOffset(rel string) int {
+	switch rel {
+	case "lalu", "kemarin":
+		return -1
+	case "depan":
+		return 1
+	}
+	return 0
+}
+
+// shift menggeser today sebanyak n satuan (hari/minggu/bulan/tahun)
+func shift(today time.Time, unit string, n int) time.Time {
+	switch unit {
+	case "minggu", "pekan":
+		return today.AddDate(0, 0, 7*n)
+	case "bulan":
+		return today.AddDate(0, n, 0)
+	case "tahun":
+		return today.AddDate(n, 0, 0)
+	}
+	return today.AddDate(0, 0, n)
+}
+
+// period mengembalikan periode kalender (hari, minggu Senin–Minggu, bulan, tahun) berjarak n dari today
+func period(today time.Time, unit string, n int) (time.Time, time.Time) {
+	switch unit {
+	case "minggu", "pekan":
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		from := monday.AddDate(0, 0, 7*n)
+		return from, from.AddDate(0, 0, 7)
+	case "bulan":
+		from := time.Date(today.Year(), today.Month()+time.Month(n), 1, 0, 0, 0, 0, today.Location())
+		return from, from.AddDate(0, 1, 0)
+	case "tahun":
+		from := time.Date(today.Year()+n, time.January, 1, 0, 0, 0, 0, today.Location())
+		return from, from.AddDate(1, 0, 0)
+	}
+	from := today.AddDate(0, 0, n)
+	return from, from.AddDate(0, 0, 1)
+}
@@ -0,0 +1,242 @@
+// Package nlu mengekstrak nominal rupiah, persentase, durasi, dan tanggal relatif dari teks chat
+// berbahasa Indonesia ("500 jt", "1,2 M", "lima belas tahun", "DP 20%", "bulan depan").
+// Setiap entitas membawa posisi (span) pada teks asli dan tingkat keyakinan 0–1, sehingga
+// pemakai (kalkulator, planner) bisa memilih entitas berdasarkan label konteks.
+package nlu
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kind adalah jenis entitas hasil ekstraksi
+type Kind string
+
+const (
+	KindAmount   Kind = "amount"   // Value dalam rupiah
+	KindPercent  Kind = "percent"  // Value dalam persen (20 = 20%)
+	KindDuration Kind = "duration" // Value dalam bulan
+	KindDate     Kind = "date"     // rentang [From, To)
+)
+
+// Label konteks: kata kunci terdekat sebelum entitas
+const (
+	LabelPrice       = "price"
+	LabelDownPayment = "dp"
+	LabelIncome      = "income"
+	LabelLoan        = "loan"
+	LabelInstallment = "installment"
+	LabelObligation  = "obligation"
+	LabelTenor       = "tenor"
+	LabelFixed       = "fixed"
+	LabelRate        = "rate"
+	LabelAge         = "age"
+	LabelDSR         = "dsr"
+)
+
+// Span adalah posisi byte [Start, End) pada teks asli
+type Span struct {
+	Start int
+	End   int
+}
+
+// Entity adalah satu ekspresi bertipe yang ditemukan di teks
+type Entity struct {
+	Kind       Kind
+	Text       string
+	Span       Span
+	Value      float64
+	From       time.Time // KindDate
+	To         time.Time // KindDate, eksklusif
+	Label      string
+	Confidence float64
+}
+
+// Years: durasi dalam tahun penuh (KindDuration)
+func (e Entity) Years() int {
+	return int(e.Value) / 12
+}
+
+// Extract mengembalikan semua entitas di text, terurut menurut posisi. now menentukan
+// tanggal relatif ("bulan ini") dan zona waktunya.
+func Extract(text string, now time.Time) []Entity {
+	low := lowerASCII(text)
+	used := make([]bool, len(low))
+	var out []Entity
+	add := func(e Entity) {
+		for i := e.Span.Start; i < e.Span.End; i++ {
+			if used[i] {
+				return
+			}
+		}
+		for i := e.Span.Start; i < e.Span.End; i++ {
+			used[i] = true
+		}
+		out = append(out, e)
+	}
+	// tanggal lebih dulu agar "3 bulan terakhir" tidak terbaca sebagai durasi
+	for _, e := range extractDates(low, now) {
+		add(e)
+	}
+	for _, e := range extractNumbers(low) {
+		add(e)
+	}
+	for _, e := range extractWordNumbers(low) {
+		add(e)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Span.Start < out[j].Span.Start })
+
+	prevEnd := 0
+	kept := out[:0]
+	for _, e := range out {
+		if e.Kind != KindDate {
+			e.Label = labelBefore(low, e.Span.Start, prevEnd)
+		}
+		prevEnd = e.Span.End
+		if e.Kind == "" && !resolveBare(&e) {
+			continue
+		}
+		e.Text = text[e.Span.Start:e.Span.End]
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// First mengembalikan entitas pertama berjenis kind dengan salah satu label (tanpa label = apa saja)
+func First(ents []Entity, kind Kind, labels ...string) (Entity, bool) {
+	for _, e := range ents {
+		if e.Kind != kind {
+			continue
+		}
+		if len(labels) == 0 {
+			return e, true
+		}
+		for _, l := range labels {
+			if e.Label == l {
+				return e, true
+			}
+		}
+	}
+	return Entity{}, false
+}
+
+// DateRange mengembalikan rentang tanggal pertama di text ("bulan ini", "3 bulan terakhir", "maret 2025")
+func DateRange(text string, now time.Time) (from, to time.Time, ok bool) {
+	e, ok := First(Extract(text, now), KindDate)
+	return e.From, e.To, ok
+}
+
+// resolveBare menafsirkan angka tanpa satuan dari labelnya: "tenor 25" = 25 tahun, "usia 45" = 45 tahun,
+// "harga 850000000" = rupiah. Angka lain (nomor pengajuan, jam, dsb.) dibuang.
+func resolveBare(e *Entity) bool {
+	v := e.Value
+	switch e.Label {
+	case LabelTenor, LabelFixed:
+		if v >= 1 && v <= 40 {
+			e.Kind, e.Value, e.Confidence = KindDuration, v*12, 0.6
+			return true
+		}
+		if e.Label == LabelTenor && v > 40 && v <= 480 && int(v)%12 == 0 {
+			// "tenor 240": kemungkinan besar bulan
+			e.Kind, e.Confidence = KindDuration, 0.5
+			return true
+		}
+	case LabelAge:
+		if v >= 17 && v <= 80 {
+			e.Kind, e.Value, e.Confidence = KindDuration, v*12, 0.6
+			return true
+		}
+	case LabelDSR:
+		if v > 0 && v <= 100 {
+			e.Kind, e.Confidence = KindPercent, 0.6
+			return true
+		}
+	case LabelPrice, LabelDownPayment, LabelIncome, LabelLoan, LabelInstallment, LabelObligation:
+		if v >= 1000 {
+			e.Kind, e.Confidence = KindAmount, 0.6
+			return true
+		}
+	}
+	return false
+}
+
+// labelKeywords: kata kunci konteks per label
+var labelKeywords = map[string][]string{
+	LabelPrice:       {"harga rumah", "harga properti", "nilai properti", "harga", "seharga", "rumah", "properti", "apartemen", "ruko"},
+	LabelDownPayment: {"dp", "uang muka", "down payment"},
+	LabelIncome:      {"gaji", "penghasilan", "pendapatan", "income", "salary", "gajian"},
+	LabelLoan:        {"pinjaman", "pinjam", "plafon", "plafond", "pokok"},
+	LabelInstallment: {"cicilan", "angsuran"},
+	LabelObligation:  {"cicilan lain", "angsuran lain", "kewajiban", "hutang", "utang", "cicilan mobil", "cicilan motor", "kartu kredit", "pinjaman lain"},
+	LabelTenor:       {"tenor", "jangka waktu", "lama pinjaman", "selama"},
+	LabelFixed:       {"fixed", "fix", "bunga tetap"},
+	LabelRate:        {"bunga", "suku bunga", "rate"},
+	LabelAge:         {"umur", "usia"},
+	LabelDSR:         {"dsr"},
+}
+
+// labelWindow: jarak maksimal (byte) kata kunci sebelum entitas
+const labelWindow = 30
+
+// labelBefore mencari kata kunci yang berakhir paling dekat sebelum pos, tanpa melewati entitas
+// sebelumnya (prevEnd). Bila sama dekat, kata kunci terpanjang menang ("cicilan lain" > "cicilan").
+func labelBefore(low string, pos, prevEnd int) string {
+	start := pos - labelWindow
+	if start < prevEnd {
+		start = prevEnd
+	}
+	if start < 0 {
+		start = 0
+	}
+	win := low[start:pos]
+	best, bestEnd, bestLen := "", -1, 0
+	for label, keys := range labelKeywords {
+		for _, k := range keys {
+			i := lastWordIndex(win, k)
+			if i < 0 {
+				continue
+			}
+			end := i + len(k)
+			if end > bestEnd || (end == bestEnd && len(k) > bestLen) || (end == bestEnd && len(k) == bestLen && label < best) {
+				best, bestEnd, bestLen = label, end, len(k)
+			}
+		}
+	}
+	return best
+}
+
+// lastWordIndex: posisi kemunculan terakhir k di s yang dibatasi non-huruf di kedua sisi
+func lastWordIndex(s, k string) int {
+	for end := len(s); end > 0; {
+		i := strings.LastIndex(s[:end], k)
+		if i < 0 {
+			return -1
+		}
+		j := i + len(k)
+		if (i == 0 || !isLetter(s[i-1])) && (j == len(s) || !isLetter(s[j])) {
+			return i
+		}
+		end = i + len(k) - 1
+	}
+	return -1
+}
+
+// lowerASCII menurunkan huruf ASCII saja agar posisi byte sama dengan teks asli
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
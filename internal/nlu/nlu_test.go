@@ -0,0 +1,231 @@
+package nlu
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// render meringkas entitas non-tanggal: "amount 1e+09 price"
+func render(ents []Entity) string {
+	var parts []string
+	for _, e := range ents {
+		if e.Kind == KindDate {
+			continue
+		}
+		s := fmt.Sprintf("%s %g", e.Kind, e.Value)
+		if e.Label != "" {
+			s += " " + e.Label
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " | ")
+}
+
+func TestParseAmount(t *testing.T) {
+	cases := []struct {
+		num, unit string
+		want      float64
+	}{
+		{"1,2", "miliar", 1.2e9},
+		{"1.5", "M", 1.5e9},
+		{"500", "jt", 5e8},
+		{"750", "rb", 750e3},
+		{"850.000.000", "", 8.5e8},
+		{"12.500.000", "", 12.5e6},
+		{"1.250,5", "juta", 1250.5e6},
+		{"7,5", "%", 7.5},
+		{"20", "persen", 20},
+		{"2", "triliun", 2e12},
+		{"15", "k", 15e3},
+	}
+	for _, c := range cases {
+		got, ok := ParseAmount(c.num, c.unit)
+		if !ok || got != c.want {
+			t.Errorf("ParseAmount(%q, %q)=%v,%v want %v", c.num, c.unit, got, ok, c.want)
+		}
+	}
+	if _, ok := ParseAmount("", ""); ok {
+		t.Errorf("malformed number should fail")
+	}
+}
+
+func TestParseWords(t *testing.T) {
+	cases := []struct {
+		text string
+		want float64
+	}{
+		{"lima", 5},
+		{"sepuluh", 10},
+		{"sebelas", 11},
+		{"lima belas", 15},
+		{"dua puluh", 20},
+		{"dua puluh lima", 25},
+		{"seratus", 100},
+		{"seratus lima puluh ribu", 150e3},
+		{"dua ratus lima puluh juta", 250e6},
+		{"satu setengah miliar", 1.5e9},
+		{"setengah juta", 5e5},
+		{"seribu", 1e3},
+		{"dua juta lima ratus ribu", 2.5e6},
+		{"Tiga Ratus Juta", 3e8},
+	}
+	for _, c := range cases {
+		got, ok := ParseWords(c.text)
+		if !ok || got != c.want {
+			t.Errorf("ParseWords(%q)=%v,%v want %v", c.text, got, ok, c.want)
+		}
+	}
+	for _, bad := range []string{"", "dua tiga", "belas", "rumah", "puluh lima"} {
+		if _, ok := ParseWords(bad); ok {
+			t.Errorf("ParseWords(%q) should fail", bad)
+		}
+	}
+}
+
+func TestExtract_Values(t *testing.T) {
+	now := time.Date(2026, 3, 18, 10, 30, 0, 0, time.UTC)
+	cases := []struct {
+		text string
+		want string
+	}{
+		// nominal
+		{"harga 500 jt", "amount 5e+08 price"},
+		{"rumah 1,2 M", "amount 1.2e+09 price"},
+		{"Rp 750.000.000", "amount 7.5e+08"},
+		{"rp750rb", "amount 750000"},
+		{"harga Rp. 1.250.000.000", "amount 1.25e+09 price"},
+		{"budget 850000000", "amount 8.5e+08"},
+		{"gaji 12.500.000", "amount 1.25e+07 income"},
+		{"penghasilan saya rp 12.500.000", "amount 1.25e+07 income"},
+		{"seharga dua ratus lima puluh juta", "amount 2.5e+08 price"},
+		{"satu setengah miliar", "amount 1.5e+09"},
+		{"gaji 1 milyard", "amount 1e+09 income"},
+		// persen
+		{"DP 20%", "percent 20 dp"},
+		{"uang muka 10 persen", "percent 10 dp"},
+		{"bunga 7,5%", "percent 7.5 rate"},
+		{"dp lima persen", "percent 5 dp"},
+		{"dsr 30%", "percent 30 dsr"},
+		{"dsr 40", "percent 40 dsr"},
+		// durasi
+		{"tenor 20 tahun", "duration 240 tenor"},
+		{"tenor 240 bulan", "duration 240 tenor"},
+		{"selama 15 thn", "duration 180 tenor"},
+		{"tenor 25", "duration 300 tenor"},
+		{"lima belas tahun", "duration 180"},
+		{"fixed 3 tahun", "duration 36 fixed"},
+		{"umur 30", "duration 360 age"},
+		{"usia 45 tahun", "duration 540 age"},
+		{"setengah tahun", "duration 6"},
+		// gabungan: label dari kata kunci terdekat, tidak melewati entitas sebelumnya
+		{"simulasi harga 1 miliar dp 20% tenor 20 tahun", "amount 1e+09 price | percent 20 dp | duration 240 tenor"},
+		{"rumah 850 juta, uang muka 170jt, 15 tahun", "amount 8.5e+08 price | amount 1.7e+08 dp | duration 180"},
+		{"cicilan rumah 1,5 m dp 10 persen tenor 25", "amount 1.5e+09 price | percent 10 dp | duration 300 tenor"},
+		{"penghasilan 20 jt cicilan lain 3 juta", "amount 2e+07 income | amount 3e+06 obligation"},
+		{"gaji 15 juta umur 35 kartu kredit 1,5 juta", "amount 1.5e+07 income | duration 420 age | amount 1.5e+06 obligation"},
+		{"cicilan 5 juta per bulan", "amount 5e+06 installment"},
+		{"plafon 600 juta", "amount 6e+08 loan"},
+		// bukan entitas
+		{"status KPR-2025-001 gimana", ""},
+		{"rumah 36 m2 di bekasi", ""},
+		{"jam 10:30 bisa?", ""},
+		{"ada 3 dokumen lagi", ""},
+		{"satu lagi dong", ""},
+		{"tenor 99", ""},
+		{"hubungi 081234567890", ""},
+		{"wa ke 6281234567890", ""},
+	}
+	for _, c := range cases {
+		if got := render(Extract(c.text, now)); got != c.want {
+			t.Errorf("%q:\n got  %s\n want %s", c.text, got, c.want)
+		}
+	}
+}
+
+func TestExtract_SpansAndConfidence(t *testing.T) {
+	text := "Harga Rp 1,2 M, DP 20%, tenor lima belas tahun"
+	ents := Extract(text, time.Now())
+	want := []struct {
+		text string
+		conf float64
+	}{{"Rp 1,2 M", 0.95}, {"20%", 0.95}, {"lima belas tahun", 0.85}}
+	if len(ents) != len(want) {
+		t.Fatalf("got %d entities: %+v", len(ents), ents)
+	}
+	for i, w := range want {
+		e := ents[i]
+		if e.Text != w.text || text[e.Span.Start:e.Span.End] != w.text || e.Confidence != w.conf {
+			t.Errorf("entity %d: %+v want %q conf %v", i, e, w.text, w.conf)
+		}
+	}
+	if e, _ := First(ents, KindDuration, LabelTenor); e.Years() != 15 {
+		t.Errorf("tenor years=%d", e.Years())
+	}
+	if _, ok := First(ents, KindAmount, LabelIncome); ok {
+		t.Errorf("no income expected")
+	}
+}
+
+func TestExtract_Dates(t *testing.T) {
+	// Rabu, 18 Maret 2026
+	now := time.Date(2026, 3, 18, 10, 30, 0, 0, time.UTC)
+	cases := []struct {
+		text     string
+		from, to string
+	}{
+		{"pengajuan hari ini", "2026-03-18", "2026-03-19"},
+		{"yang masuk kemarin", "2026-03-17", "2026-03-18"},
+		{"besok", "2026-03-19", "2026-03-20"},
+		{"lusa", "2026-03-20", "2026-03-21"},
+		{"minggu ini", "2026-03-16", "2026-03-23"},
+		{"pekan lalu", "2026-03-09", "2026-03-16"},
+		{"minggu depan", "2026-03-23", "2026-03-30"},
+		{"bulan ini", "2026-03-01", "2026-04-01"},
+		{"bulan lalu", "2026-02-01", "2026-03-01"},
+		{"bulan kemarin", "2026-02-01", "2026-03-01"},
+		{"bulan depan", "2026-04-01", "2026-05-01"},
+		{"tahun ini", "2026-01-01", "2027-01-01"},
+		{"tahun lalu", "2025-01-01", "2026-01-01"},
+		{"3 bulan terakhir", "2025-12-18", "2026-03-19"},
+		{"tiga bulan terakhir", "2025-12-18", "2026-03-19"},
+		{"7 hari terakhir", "2026-03-11", "2026-03-19"},
+		{"seminggu terakhir", "2026-03-11", "2026-03-19"},
+		{"setahun belakangan", "2025-03-18", "2026-03-19"},
+		{"2 bulan lalu", "2026-01-01", "2026-02-01"},
+		{"sebulan yang lalu", "2026-02-01", "2026-03-01"},
+		{"maret 2025", "2025-03-01", "2025-04-01"},
+		{"bulan mei", "2025-05-01", "2025-06-01"},
+		{"januari", "2026-01-01", "2026-02-01"},
+		{"Des 2025", "2025-12-01", "2026-01-01"},
+		{"sejak januari 2026", "2026-01-01", "2026-03-19"},
+		{"sejak desember", "2025-12-01", "2026-03-19"},
+		{"mulai bulan februari", "2026-02-01", "2026-03-19"},
+		{"tahun 2024", "2024-01-01", "2025-01-01"},
+		{"17 agustus 2025", "2025-08-17", "2025-08-18"},
+		{"per 2025-12-31", "2025-12-31", "2026-01-01"},
+	}
+	for _, c := range cases {
+		from, to, ok := DateRange(c.text, now)
+		if !ok || from.Format("2006-01-02") != c.from || to.Format("2006-01-02") != c.to {
+			t.Errorf("%q: got %s–%s ok=%v want %s–%s", c.text, from.Format("2006-01-02"), to.Format("2006-01-02"), ok, c.from, c.to)
+		}
+	}
+	for _, text := range []string{"tenor 20 tahun", "status KPR-2025-001", "des", "sejak 2 hari"} {
+		if from, to, ok := DateRange(text, now); ok {
+			t.Errorf("%q: unexpected range %s–%s", text, from, to)
+		}
+	}
+}
+
+func TestExtract_DatesDoNotBecomeDurations(t *testing.T) {
+	now := time.Date(2026, 3, 18, 0, 0, 0, 0, time.UTC)
+	ents := Extract("berapa pengajuan 3 bulan terakhir dengan tenor 20 tahun", now)
+	if len(ents) != 2 || ents[0].Kind != KindDate || ents[1].Kind != KindDuration || ents[1].Label != LabelTenor {
+		t.Fatalf("got %+v", ents)
+	}
+	if ents[0].Text != "3 bulan terakhir" || ents[0].Confidence != 0.9 {
+		t.Fatalf("date=%+v", ents[0])
+	}
+}
@@ -0,0 +1,238 @@
+package nlu
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// numberPattern: angka dengan awalan "rp" dan satuan opsional; batas kata dicek manual
+// karena RE2 tidak mendukung lookahead ("36 m2" bukan 36 miliar)
+var numberPattern = regexp.MustCompile(`(rp\.?\s*)?(\d+(?:[.,]\d+)*)(?:\s*(%|persen|triliun|milyard|miliar|milyar|juta|jt|ribu|rb|m|k|tahun|thn|th|bulan|bln))?`)
+
+// thousandsPattern: "1.250.000" (titik sebagai pemisah ribuan)
+var thousandsPattern = regexp.MustCompile(`^\d{1,3}(?:\.\d{3})+$`)
+
+// ParseAmount mengubah angka gaya Indonesia dengan satuan: ("1,2", "miliar") -> 1.2e9,
+// ("850.000.000", "") -> 8.5e8, ("7,5", "%") -> 7.5
+func ParseAmount(num, unit string) (float64, bool) {
+	unit = strings.ToLower(unit)
+	mult := unitMultiplier(unit)
+	s := num
+	if mult > 1 || unit == "%" || unit == "persen" {
+		// dengan satuan, koma/titik tunggal adalah desimal: "1,5 miliar", "7.5%"
+		if strings.Count(s, ",")+strings.Count(s, ".") == 1 {
+			s = strings.NewReplacer(",", ".").Replace(s)
+		} else {
+			s = strings.NewReplacer(".", "", ",", ".").Replace(s)
+		}
+	} else {
+		// tanpa satuan, titik adalah pemisah ribuan: "850.000.000"
+		s = strings.NewReplacer(".", "", ",", ".").Replace(s)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f * mult, true
+}
+
+func unitMultiplier(unit string) float64 {
+	switch unit {
+	case "triliun":
+		return 1e12
+	case "miliar", "milyar", "milyard", "m":
+		return 1e9
+	case "juta", "jt":
+		return 1e6
+	case "ribu", "rb", "k":
+		return 1e3
+	}
+	return 1
+}
+
+// extractNumbers membaca angka berdigit. Angka tanpa satuan dikembalikan dengan Kind kosong
+// bila maknanya bergantung pada label ("tenor 25", "usia 45").
+func extractNumbers(low string) []Entity {
+	var out []Entity
+	for _, m := range numberPattern.FindAllStringSubmatchIndex(low, -1) {
+		start, end := m[0], m[1]
+		if start > 0 && (isLetter(low[start-1]) || isDigit(low[start-1]) || strings.IndexByte("-/:_", low[start-1]) >= 0) {
+			continue // bagian dari kode/nomor: "KPR-2025-001", "10:30"
+		}
+		num := low[m[4]:m[5]]
+		unit := ""
+		if m[6] >= 0 {
+			unit = low[m[6]:m[7]]
+			if unit != "%" && end < len(low) && (isLetter(low[end]) || isDigit(low[end])) {
+				unit, end = "", m[5] // "36 m2", "5 tahunan"
+			}
+		}
+		if end < len(low) && unit == "" && (isLetter(low[end]) || low[end] == '-' || low[end] == '/' || low[end] == ':') {
+			continue
+		}
+		v, ok := ParseAmount(num, unit)
+		if !ok {
+			continue
+		}
+		e := Entity{Span: Span{start, end}, Value: v, Confidence: 0.95}
+		switch unit {
+		case "%", "persen":
+			e.Kind = KindPercent
+		case "tahun", "thn", "th":
+			e.Kind, e.Value, e.Confidence = KindDuration, v*12, 0.9
+		case "bulan", "bln":
+			e.Kind, e.Confidence = KindDuration, 0.9
+		case "":
+			switch {
+			case m[2] >= 0:
+				e.Kind = KindAmount // "Rp 750.000.000"
+			case thousandsPattern.MatchString(num):
+				e.Kind, e.Confidence = KindAmount, 0.7
+			case v >= 100000 && !strings.ContainsAny(num, ".,") && !looksLikePhone(num):
+				e.Kind, e.Confidence = KindAmount, 0.6
+			}
+		default:
+			e.Kind = KindAmount
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// looksLikePhone: "0812...", "62812..." adalah nomor telepon, bukan nominal
+func looksLikePhone(num string) bool {
+	return strings.HasPrefix(num, "0") || (strings.HasPrefix(num, "628") && len(num) >= 11)
+}
+
+// Kata bilangan. "se-" (seratus, seribu, ...) ditulis sebagai kata tersendiri.
+var (
+	wordDigits = map[string]float64{
+		"nol": 0, "satu": 1, "dua": 2, "tiga": 3, "empat": 4, "lima": 5,
+		"enam": 6, "tujuh": 7, "delapan": 8, "sembilan": 9,
+	}
+	wordScales = map[string]float64{
+		"ribu": 1e3, "juta": 1e6, "miliar": 1e9, "milyar": 1e9, "triliun": 1e12,
+		"seribu": 1e3, "sejuta": 1e6, "semiliar": 1e9, "semilyar": 1e9,
+	}
+	wordPattern = regexp.MustCompile(`[a-z]+`)
+)
+
+// wordAcc mengakumulasi kata bilangan; add mengembalikan false bila kata bukan lanjutan bilangan
+type wordAcc struct {
+	total, group, cur float64
+	n                 int  // jumlah kata yang terbaca
+	scaled            bool // memakai ribu/juta/miliar
+}
+
+func (a *wordAcc) add(w string) bool {
+	if d, ok := wordDigits[w]; ok {
+		if a.cur != 0 {
+			return false // "dua tiga" bukan satu bilangan
+		}
+		a.cur = d
+	} else if s, ok := wordScales[w]; ok {
+		n := a.group + a.cur
+		if n == 0 || strings.HasPrefix(w, "se") {
+			if n != 0 {
+				return false
+			}
+			n = 1
+		}
+		a.total += n * s
+		a.group, a.cur, a.scaled = 0, 0, true
+	} else {
+		switch w {
+		case "sepuluh":
+			a.cur += 10
+		case "sebelas":
+			a.cur += 11
+		case "seratus":
+			a.group += 100
+		case "setengah":
+			a.cur += 0.5
+		case "belas":
+			if a.cur == 0 || a.cur >= 10 {
+				return false
+			}
+			a.cur += 10
+		case "puluh":
+			if a.cur == 0 || a.cur >= 10 {
+				return false
+			}
+			a.group += a.cur * 10
+			a.cur = 0
+		case "ratus":
+			if a.cur == 0 || a.cur >= 10 {
+				return false
+			}
+			a.group += a.cur * 100
+			a.cur = 0
+		default:
+			return false
+		}
+	}
+	a.n++
+	return true
+}
+
+func (a *wordAcc) value() float64 {
+	return a.total + a.group + a.cur
+}
+
+// ParseWords membaca bilangan kata: "lima belas" -> 15, "dua ratus lima puluh juta" -> 2.5e8,
+// "satu setengah miliar" -> 1.5e9
+func ParseWords(s string) (float64, bool) {
+	var a wordAcc
+	for _, w := range strings.Fields(lowerASCII(s)) {
+		if !a.add(w) {
+			return 0, false
+		}
+	}
+	return a.value(), a.n > 0
+}
+
+// extractWordNumbers membaca bilangan kata yang diikuti satuan (tahun/bulan/persen) atau
+// memakai skala rupiah (juta/miliar). Kata bilangan lepas ("satu lagi") diabaikan.
+func extractWordNumbers(low string) []Entity {
+	words := wordPattern.FindAllStringIndex(low, -1)
+	var out []Entity
+	for i := 0; i < len(words); {
+		var a wordAcc
+		j := i
+		for j < len(words) {
+			// bilangan harus berurutan, hanya dipisah spasi
+			if j > i && strings.TrimSpace(low[words[j-1][1]:words[j][0]]) != "" {
+				break
+			}
+			if !a.add(low[words[j][0]:words[j][1]]) {
+				break
+			}
+			j++
+		}
+		if j == i {
+			i++
+			continue
+		}
+		e := Entity{Span: Span{words[i][0], words[j-1][1]}, Value: a.value(), Confidence: 0.85}
+		unit := ""
+		if j < len(words) && strings.TrimSpace(low[words[j-1][1]:words[j][0]]) == "" {
+			unit = low[words[j][0]:words[j][1]]
+		}
+		switch {
+		case unit == "tahun":
+			e.Kind, e.Value, e.Span.End = KindDuration, e.Value*12, words[j][1]
+		case unit == "bulan":
+			e.Kind, e.Span.End = KindDuration, words[j][1]
+		case unit == "persen":
+			e.Kind, e.Span.End = KindPercent, words[j][1]
+		case a.scaled:
+			e.Kind = KindAmount
+		}
+		if e.Kind != "" && e.Value > 0 {
+			out = append(out, e)
+		}
+		i = j
+	}
+	return out
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/nlu"
+)
+
+// affordabilityDefaultTenor: tenor (tahun) bila user tidak menyebutnya
+const affordabilityDefaultTenor = 20
+
+// principalForPayment: pokok maksimal yang angsuran anuitasnya = pay (kebalikan annuityPayment)
+func principalForPayment(pay, annual float64, n int) float64 {
+	if pay <= 0 || n <= 0 {
+		return 0
+	}
+	r := annual / 12
+	if r == 0 {
+		return pay * float64(n)
+	}
+	return pay * (1 - math.Pow(1+r, -float64(n))) / r
+}
+
+// affordabilityFor menghitung plafon dan harga rumah maksimal untuk satu produk (fungsi murni).
+// capacity = penghasilan × DSR − cicilan lain. Produk fixed berjangka dihitung dengan bunga
+// tertinggi selama tenor (floating indikatif) agar angsuran tetap dalam batas DSR setelah masa fixed.
+func affordabilityFor(r domain.KPRRate, req domain.AffordabilityRequest, capacity, floating float64) domain.ProductAffordability {
+	pa := domain.ProductAffordability{Rate: r, TenorYears: req.TenorYears}
+	if pa.TenorYears <= 0 {
+		pa.TenorYears = affordabilityDefaultTenor
+	}
+	if r.MaxTermYears > 0 && pa.TenorYears > r.MaxTermYears {
+		pa.TenorYears = r.MaxTermYears
+	}
+	if req.Age > 0 && r.MaxAge > 0 && req.Age+pa.TenorYears > r.MaxAge {
+		pa.TenorYears = r.MaxAge - req.Age
+	}
+	switch {
+	case pa.TenorYears <= 0:
+		pa.Reasons = append(pa.Reasons, fmt.Sprintf("usia maksimal %d tahun", r.MaxAge))
+	case r.MinTermYears > 0 && pa.TenorYears < r.MinTermYears:
+		if req.Age > 0 && r.MaxAge > 0 && req.Age+r.MinTermYears > r.MaxAge {
+			pa.Reasons = append(pa.Reasons, fmt.Sprintf("usia saat lunas melebihi %d tahun", r.MaxAge))
+		} else {
+			pa.Reasons = append(pa.Reasons, fmt.Sprintf("tenor harus %d–%d tahun", r.MinTermYears, r.MaxTermYears))
+		}
+	}
+	if r.MinIncome > 0 && req.MonthlyIncome < r.MinIncome {
+		pa.Reasons = append(pa.Reasons, "penghasilan di bawah minimum produk "+formatRupiah(r.MinIncome))
+	}
+	if capacity <= 0 {
+		pa.Reasons = append(pa.Reasons, "cicilan lain sudah mencapai batas DSR")
+	}
+	if len(pa.Reasons) > 0 {
+		return pa
+	}
+
+	n := pa.TenorYears * 12
+	pa.StressRate = r.EffectiveRate
+	if productFixedYears(r, pa.TenorYears) < pa.TenorYears && floating > pa.StressRate {
+		pa.StressRate = floating
+	}
+	// dibulatkan ke bawah ke Rp 100.000 agar angsuran tidak melewati batas
+	pa.MaxLoan = math.Floor(principalForPayment(capacity, pa.StressRate, n)/1e5) * 1e5
+	if r.MaxLoanAmount > 0 && pa.MaxLoan > r.MaxLoanAmount {
+		pa.MaxLoan, pa.CappedByProduct = r.MaxLoanAmount, true
+	}
+	if r.MinLoanAmount > 0 && pa.MaxLoan < r.MinLoanAmount {
+		pa.Reasons = append(pa.Reasons, "plafon minimal "+formatRupiah(r.MinLoanAmount))
+		return pa
+	}
+	// harga rumah dibatasi LTV maksimal dan DP minimal produk
+	ltv := 1.0
+	if r.MaxLTVRatio > 0 {
+		ltv = r.MaxLTVRatio
+	}
+	if r.MinDownPaymentPercent > 0 {
+		ltv = math.Min(ltv, 1-r.MinDownPaymentPercent/100)
+	}
+	if ltv > 0 {
+		pa.MaxPropertyPrice = math.Floor(pa.MaxLoan / ltv)
+	}
+	pa.MonthlyInstallment = annuityPayment(pa.MaxLoan, pa.StressRate, n)
+	pa.Eligible = true
+	return pa
+}
+
+// affordability menghitung semua produk (fungsi murni): yang memenuhi syarat lebih dulu, lalu
+// plafon terbesar. DSR permintaan hanya bisa menurunkan batas yang dikonfigurasi.
+func affordability(rates []domain.KPRRate, req domain.AffordabilityRequest, maxDSR, floating float64) *domain.AffordabilityResult {
+	dsr := maxDSR
+	if req.MaxDSR > 0 && req.MaxDSR < dsr {
+		dsr = req.MaxDSR
+	}
+	res := &domain.AffordabilityResult{MaxDSR: dsr, Obligations: req.Obligations, Products: []domain.ProductAffordability{}}
+	res.MaxInstallment = math.Max(0, req.MonthlyIncome*dsr-req.Obligations)
+	for _, r := range rates {
+		res.Products = append(res.Products, affordabilityFor(r, req, res.MaxInstallment, floating))
+	}
+	sort.SliceStable(res.Products, func(i, j int) bool {
+		pi, pj := res.Products[i], res.Products[j]
+		if pi.Eligible != pj.Eligible {
+			return pi.Eligible
+		}
+		return pi.MaxLoan > pj.MaxLoan
+	})
+	return res
+}
+
+// Affordability menghitung kemampuan pinjam terhadap produk aktif kpr_rates
+func (s *KPRCalculatorService) Affordability(ctx context.Context, req domain.AffordabilityRequest) (*domain.AffordabilityResult, error) {
+	if req.MonthlyIncome <= 0 {
+		return nil, fmt.Errorf("penghasilan harus lebih dari 0")
+	}
+	if req.Obligations < 0 {
+		return nil, fmt.Errorf("cicilan lain tidak boleh negatif")
+	}
+	if req.TenorYears < 0 || req.TenorYears > 40 {
+		return nil, fmt.Errorf("tenor harus 1–40 tahun")
+	}
+	rates, err := s.ActiveRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("belum ada produk KPR aktif")
+	}
+	return affordability(rates, req, s.maxDSR, s.floatingRate), nil
+}
+
+// -------------------------
+// Kemampuan pinjam lewat chat
+// -------------------------
+
+// parseAffordabilityInput mengekstrak penghasilan, usia, tenor, cicilan lain, dan DSR dari teks
+func parseAffordabilityInput(text string) domain.AffordabilityRequest {
+	req := domain.AffordabilityRequest{}
+	ents := nlu.Extract(text, time.Now())
+	if e, ok := nlu.First(ents, nlu.KindPercent, nlu.LabelDSR); ok {
+		req.MaxDSR = e.Value / 100
+	}
+	if e, ok := nlu.First(ents, nlu.KindAmount, nlu.LabelObligation); ok {
+		req.Obligations = e.Value
+	}
+	el := parseEligibilityInput(text)
+	req.MonthlyIncome, req.Age, req.TenorYears = el.MonthlyIncome, el.Age, el.TenorYears
+	if req.Obligations >= req.MonthlyIncome && req.MonthlyIncome > 0 {
+		// angka cicilan yang tertangkap ternyata penghasilan/harga; abaikan
+		req.Obligations = 0
+	}
+	return req
+}
+
+const affordabilityHowTo = "Untuk menghitung kemampuan pinjam, sebutkan penghasilan per bulan. Boleh tambah cicilan lain dan tenor. Contoh: *gaji 12 juta cicilan lain 1 juta tenor 20 tahun, bisa pinjam berapa?*"
+
+// profileAmountBand: lebar pita pembulatan nominal bila penghasilan berasal dari profil
+const profileAmountBand = 100_000_000
+
+// coarseRupiah menampilkan nominal sebagai pita Rp 100 juta agar penghasilan profil tidak bisa
+// dihitung balik dari plafon, tenor, dan bunga
+func coarseRupiah(v float64) string {
+	if v < profileAmountBand {
+		return "di bawah " + formatRupiah(profileAmountBand)
+	}
+	lo := math.Floor(v/profileAmountBand) * profileAmountBand
+	return formatRupiah(lo) + "–" + formatRupiah(lo+profileAmountBand)
+}
+
+// formatAffordability merender kemampuan pinjam untuk WhatsApp tanpa menyebut penghasilan. Bila
+// penghasilan diambil dari profil, batas angsuran/DSR tidak ditampilkan dan nominal dibulatkan.
+func formatAffordability(res *domain.AffordabilityResult, fromProfile bool) string {
+	var b strings.Builder
+	b.WriteString("*Estimasi kemampuan KPR*\n")
+	if fromProfile {
+		b.WriteString("_Penghasilan diambil dari profil kamu; nominal dibulatkan per Rp 100 juta._\n")
+	} else {
+		fmt.Fprintf(&b, "Batas angsuran: *%s*/bulan (DSR maksimal %s dari penghasilan", formatRupiah(res.MaxInstallment), formatRateFraction(res.MaxDSR))
+		if res.Obligations > 0 {
+			fmt.Fprintf(&b, ", dikurangi cicilan lain %s", formatRupiah(res.Obligations))
+		}
+		b.WriteString(").\n")
+	}
+
+	var ok, fail []string
+	for _, p := range res.Products {
+		if !p.Eligible {
+			fail = append(fail, fmt.Sprintf("• %s: %s", p.Rate.RateName, strings.Join(p.Reasons, "; ")))
+			continue
+		}
+		line := fmt.Sprintf("• %s — tenor %d tahun, bunga %s: pinjaman maks *%s*, harga rumah s.d. *%s*, angsuran ± %s/bulan",
+			p.Rate.RateName, p.TenorYears, formatRateFraction(p.StressRate), formatRupiah(p.MaxLoan), formatRupiah(p.MaxPropertyPrice), formatRupiah(p.MonthlyInstallment))
+		if fromProfile {
+			line = fmt.Sprintf("• %s — tenor %d tahun, bunga %s: pinjaman maks *%s*, harga rumah *%s*",
+				p.Rate.RateName, p.TenorYears, formatRateFraction(p.StressRate), coarseRupiah(p.MaxLoan), coarseRupiah(p.MaxPropertyPrice))
+		}
+		if p.CappedByProduct {
+			line += " (dibatasi plafon maksimal produk)"
+		}
+		ok = append(ok, line)
+	}
+	if len(ok) > 0 {
+		b.WriteString("\n✅ Perkiraan maksimal per produk:\n" + strings.Join(ok, "\n") + "\n")
+	} else {
+		b.WriteString("\nBelum ada produk aktif yang sesuai dengan kemampuan ini.\n")
+	}
+	if len(fail) > 0 {
+		b.WriteString("\n❌ Belum memenuhi:\n" + strings.Join(fail, "\n") + "\n")
+	}
+	b.WriteString("_Angsuran anuitas; produk fixed berjangka dihitung dengan bunga floating indikatif agar tetap dalam batas DSR. Harga rumah mengikuti LTV/DP minimal produk. Hasil indikatif; keputusan akhir mengikuti analisa kredit BNI._")
+	return b.String()
+}
+
+// answerAffordability menjawab "gaji 12 juta bisa pinjam berapa?" secara deterministik. Untuk user
+// terdaftar, penghasilan dan usia yang tidak disebut di chat diambil dari user_profiles.
+func (a *AIQueryService) answerAffordability(ctx context.Context, phone, text string) (string, bool) {
+	if a.calculator == nil {
+		return "", false
+	}
+	req := parseAffordabilityInput(text)
+	fromProfile := false
+	if req.MonthlyIncome <= 0 || req.Age <= 0 {
+		if income, age, found := a.profileEligibility(ctx, phone); found {
+			if req.MonthlyIncome <= 0 && income > 0 {
+				req.MonthlyIncome = income
+				fromProfile = true
+			}
+			if req.Age <= 0 && age > 0 {
+				req.Age = age
+			}
+		}
+	}
+	if req.MonthlyIncome <= 0 {
+		return affordabilityHowTo, true
+	}
+	res, err := a.calculator.Affordability(ctx, req)
+	if err != nil {
+		log.Printf("[AI] affordability error: %v", err)
+		return "Maaf, kemampuan pinjam belum bisa dihitung saat ini. " + affordabilityHowTo, true
+	}
+	return formatAffordability(res, fromProfile), true
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestPrincipalForPayment(t *testing.T) {
+	for _, c := range []struct {
+		annual float64
+		n      int
+	}{{0.075, 240}, {0.11, 120}, {0, 60}} {
+		p := principalForPayment(4_000_000, c.annual, c.n)
+		if got := annuityPayment(p, c.annual, c.n); math.Abs(got-4_000_000) > 0.01 {
+			t.Errorf("rate=%v n=%d: annuity(%.2f)=%.2f", c.annual, c.n, p, got)
+		}
+	}
+	if principalForPayment(0, 0.07, 240) != 0 || principalForPayment(1e6, 0.07, 0) != 0 {
+		t.Fatal("non-positive input should give 0")
+	}
+}
+
+func TestAffordability(t *testing.T) {
+	fixed := domain.KPRRate{ID: 1, RateName: "Griya Fixed", RateType: "FIXED", EffectiveRate: 0.075, MinTermYears: 5, MaxTermYears: 25,
+		MaxLTVRatio: 0.9, MinDownPaymentPercent: 20, MaxLoanAmount: 5e9, MaxAge: 65}
+	promo := domain.KPRRate{ID: 2, RateName: "Promo Fixed 3 Tahun", RateType: "FIXED", EffectiveRate: 0.05, MinTermYears: 5, MaxTermYears: 30,
+		MaxLTVRatio: 0.8, MaxLoanAmount: 5e9}
+	capped := domain.KPRRate{ID: 3, RateName: "Griya Mikro", RateType: "FIXED", EffectiveRate: 0.06, MinTermYears: 1, MaxTermYears: 15,
+		MaxLTVRatio: 0.9, MaxLoanAmount: 150_000_000}
+	premium := domain.KPRRate{ID: 4, RateName: "Griya Premium", RateType: "FIXED", EffectiveRate: 0.07, MinTermYears: 5, MaxTermYears: 20,
+		MinIncome: 25_000_000, MaxLTVRatio: 0.9}
+	rates := []domain.KPRRate{premium, capped, promo, fixed}
+
+	res := affordability(rates, domain.AffordabilityRequest{MonthlyIncome: 12_000_000, Obligations: 1_000_000, TenorYears: 20, Age: 50}, 0.35, 0.11)
+	if res.MaxInstallment != 3_200_000 || res.MaxDSR != 0.35 {
+		t.Fatalf("capacity=%.0f dsr=%v", res.MaxInstallment, res.MaxDSR)
+	}
+	byName := map[string]domain.ProductAffordability{}
+	var order []string
+	for _, p := range res.Products {
+		byName[p.Rate.RateName] = p
+		order = append(order, p.Rate.RateName)
+	}
+
+	f := byName["Griya Fixed"]
+	// usia 50 + tenor 20 > 65: tenor dipotong menjadi 15 tahun
+	if !f.Eligible || f.TenorYears != 15 || f.StressRate != 0.075 {
+		t.Fatalf("fixed=%+v", f)
+	}
+	if want := math.Floor(principalForPayment(3_200_000, 0.075, 180)/1e5) * 1e5; f.MaxLoan != want {
+		t.Fatalf("fixed max loan=%.0f want %.0f", f.MaxLoan, want)
+	}
+	// DP minimal 20% lebih ketat dari LTV 90%
+	if f.MaxPropertyPrice != math.Floor(f.MaxLoan/0.8) || f.MonthlyInstallment > 3_200_000 {
+		t.Fatalf("fixed=%+v", f)
+	}
+
+	p := byName["Promo Fixed 3 Tahun"]
+	if !p.Eligible || p.StressRate != 0.11 || p.TenorYears != 20 {
+		t.Fatalf("promo should be stressed at floating rate: %+v", p)
+	}
+	c := byName["Griya Mikro"]
+	if !c.Eligible || !c.CappedByProduct || c.MaxLoan != 150_000_000 || c.TenorYears != 15 {
+		t.Fatalf("capped=%+v", c)
+	}
+	if pr := byName["Griya Premium"]; pr.Eligible || len(pr.Reasons) != 1 || !strings.Contains(pr.Reasons[0], "penghasilan di bawah minimum") {
+		t.Fatalf("premium=%+v", pr)
+	}
+	if order[len(order)-1] != "Griya Premium" || byName[order[0]].MaxLoan < byName[order[1]].MaxLoan {
+		t.Fatalf("order=%v", order)
+	}
+
+	// DSR dari chat hanya boleh menurunkan batas
+	if got := affordability(rates, domain.AffordabilityRequest{MonthlyIncome: 10_000_000, MaxDSR: 0.5}, 0.35, 0.11); got.MaxDSR != 0.35 {
+		t.Fatalf("dsr=%v", got.MaxDSR)
+	}
+	if got := affordability(rates, domain.AffordabilityRequest{MonthlyIncome: 10_000_000, MaxDSR: 0.3}, 0.35, 0.11); got.MaxDSR != 0.3 {
+		t.Fatalf("dsr=%v", got.MaxDSR)
+	}
+	// cicilan lain melebihi batas: tidak ada produk
+	over := affordability(rates, domain.AffordabilityRequest{MonthlyIncome: 10_000_000, Obligations: 4_000_000}, 0.35, 0.11)
+	if over.MaxInstallment != 0 || over.Products[0].Eligible {
+		t.Fatalf("over=%+v", over)
+	}
+	// usia melewati batas produk
+	old := affordability([]domain.KPRRate{fixed}, domain.AffordabilityRequest{MonthlyIncome: 30_000_000, Age: 63}, 0.35, 0.11)
+	if old.Products[0].Eligible || !strings.Contains(old.Products[0].Reasons[0], "usia saat lunas") {
+		t.Fatalf("old=%+v", old.Products[0])
+	}
+}
+
+func TestParseAffordabilityInput(t *testing.T) {
+	cases := []struct {
+		text string
+		want domain.AffordabilityRequest
+	}{
+		{"gaji saya 12 juta, bisa pinjam berapa?", domain.AffordabilityRequest{MonthlyIncome: 12e6}},
+		{"penghasilan 20 jt cicilan lain 3 juta tenor 15 tahun", domain.AffordabilityRequest{MonthlyIncome: 20e6, Obligations: 3e6, TenorYears: 15}},
+		{"gaji 15 juta umur 35 kartu kredit 1,5 juta dsr 30%", domain.AffordabilityRequest{MonthlyIncome: 15e6, Obligations: 1.5e6, Age: 35, MaxDSR: 0.3}},
+		{"bisa pinjam berapa ya", domain.AffordabilityRequest{}},
+	}
+	for _, c := range cases {
+		got := parseAffordabilityInput(c.text)
+		if math.Abs(got.MaxDSR-c.want.MaxDSR) > 1e-9 {
+			t.Errorf("%q: dsr=%v want %v", c.text, got.MaxDSR, c.want.MaxDSR)
+		}
+		got.MaxDSR = c.want.MaxDSR
+		if got != c.want {
+			t.Errorf("%q: got %+v want %+v", c.text, got, c.want)
+		}
+	}
+}
+
+func TestFormatAffordability(t *testing.T) {
+	res := &domain.AffordabilityResult{MaxDSR: 0.35, MaxInstallment: 3_200_000, Obligations: 1_000_000, Products: []domain.ProductAffordability{
+		{Rate: domain.KPRRate{RateName: "Griya Fixed"}, TenorYears: 15, StressRate: 0.075, MaxLoan: 345_000_000, MaxPropertyPrice: 431_250_000, MonthlyInstallment: 3_198_000, Eligible: true},
+		{Rate: domain.KPRRate{RateName: "Griya Mikro"}, TenorYears: 15, StressRate: 0.06, MaxLoan: 150_000_000, MaxPropertyPrice: 166_666_666, MonthlyInstallment: 1_265_000, CappedByProduct: true, Eligible: true},
+		{Rate: domain.KPRRate{RateName: "Griya Premium"}, Reasons: []string{"penghasilan di bawah minimum produk Rp 25.000.000"}},
+	}}
+	got := formatAffordability(res, false)
+	for _, want := range []string{"Batas angsuran: *Rp 3.200.000*/bulan", "DSR maksimal 35%", "cicilan lain Rp 1.000.000",
+		"Griya Fixed — tenor 15 tahun, bunga 7,5%: pinjaman maks *Rp 345.000.000*, harga rumah s.d. *Rp 431.250.000*",
+		"(dibatasi plafon maksimal produk)", "❌ Belum memenuhi:\n• Griya Premium"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "12.000.000") || strings.Contains(got, "diambil dari profil") {
+		t.Errorf("income must not be shown:\n%s", got)
+	}
+
+	got = formatAffordability(res, true)
+	for _, want := range []string{"diambil dari profil", "pinjaman maks *Rp 300.000.000–Rp 400.000.000*", "harga rumah *Rp 400.000.000–Rp 500.000.000*",
+		"Griya Mikro — tenor 15 tahun, bunga 6%: pinjaman maks *Rp 100.000.000–Rp 200.000.000*"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	for _, leak := range []string{"Batas angsuran", "DSR maksimal", "3.200.000", "345.000.000", "3.198.000"} {
+		if strings.Contains(got, leak) {
+			t.Errorf("profile reply leaks %q:\n%s", leak, got)
+		}
+	}
+}
+
+func TestFormatAffordability_ProfileIncomeNotRecoverable(t *testing.T) {
+	rates := []domain.KPRRate{
+		{ID: 1, RateName: "Griya Fixed", RateType: "FIXED", EffectiveRate: 0.075, MinTermYears: 5, MaxTermYears: 25, MaxLTVRatio: 0.9, MinDownPaymentPercent: 20, MaxLoanAmount: 5e9},
+		{ID: 2, RateName: "Griya Mikro", RateType: "FIXED", EffectiveRate: 0.06, MinTermYears: 1, MaxTermYears: 15, MaxLTVRatio: 0.9, MaxLoanAmount: 150_000_000},
+	}
+	// penghasilan profil berbeda dalam satu pita menghasilkan balasan yang identik
+	var replies []string
+	for _, income := range []float64{12_000_000, 12_150_000, 12_400_000} {
+		res := affordability(rates, domain.AffordabilityRequest{MonthlyIncome: income, Obligations: 1_000_000, TenorYears: 15}, 0.35, 0.11)
+		replies = append(replies, formatAffordability(res, true))
+	}
+	for i := 1; i < len(replies); i++ {
+		if replies[i] != replies[0] {
+			t.Fatalf("profile income is recoverable:\n%s\n---\n%s", replies[0], replies[i])
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// Fungsi agregat yang didukung format Plan
+var supportedAggregateFuncs = []string{"count", "sum", "avg", "min", "max"}
+
+// minAggregateGroupSize adalah ambang k-anonymity untuk agregat atas tabel sensitif:
+// grup dengan anggota lebih sedikit tidak dikembalikan.
+const minAggregateGroupSize = 5
+
+// piiColumns tidak boleh dibuka lewat agregat min/max maupun dipakai sebagai group_by
+var piiColumns = map[string]struct{}{
+	"email": {}, "phone": {}, "monthly_income": {}, "nik": {}, "npwp": {},
+	"password_hash": {}, "bank_account_number": {},
+}
+
+// identifierColumns membuat setiap grup berisi satu orang; tidak boleh jadi group_by di tabel sensitif
+// dan, seperti PII, tidak boleh dibuka lewat min/max
+var identifierColumns = map[string]struct{}{
+	"id": {}, "user_id": {}, "username": {}, "full_name": {}, "application_number": {},
+	"application_id": {}, "assigned_to": {}, "birth_date": {}, "address": {}, "property_address": {},
+}
+
+var aliasPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// normalizeAggregateFunc mengembalikan nama fungsi agregat kanonik
+func normalizeAggregateFunc(fn string) (string, bool) {
+	fn = strings.ToLower(strings.TrimSpace(fn))
+	if fn == "average" || fn == "mean" {
+		fn = "avg"
+	}
+	for _, f := range supportedAggregateFuncs {
+		if f == fn {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// isStarColumn: count(*) boleh ditulis dengan column "*" atau kosong
+func isStarColumn(col string) bool {
+	col = strings.TrimSpace(col)
+	return col == "" || col == "*"
+}
+
+// aggregateAlias menentukan nama kolom keluaran agregat (alias eksplisit atau func_kolom)
+func aggregateAlias(ag domain.Aggregate) string {
+	if al := strings.ToLower(strings.TrimSpace(ag.Alias)); al != "" {
+		return al
+	}
+	fn, _ := normalizeAggregateFunc(ag.Func)
+	if isStarColumn(ag.Column) {
+		return fn
+	}
+	return fn + "_" + strings.ToLower(strings.TrimSpace(ag.Column))
+}
+
+// aggregateExpr mengompilasi satu agregat menjadi ekspresi SQL atas kolom tabel utama
+func aggregateExpr(table, alias string, fn, col string) (string, error) {
+	nfn, ok := normalizeAggregateFunc(fn)
+	if !ok {
+		return "", fmt.Errorf("fungsi agregat %q tidak didukung", fn)
+	}
+	if isStarColumn(col) {
+		if nfn != "count" {
+			return "", fmt.Errorf("%s membutuhkan kolom", nfn)
+		}
+		return "COUNT(*)", nil
+	}
+	col = strings.ToLower(strings.TrimSpace(col))
+	if !isColumnIn(table, col) {
+		return "", fmt.Errorf("kolom agregat %q tidak ada di tabel %s", col, table)
+	}
+	if nfn == "sum" || nfn == "avg" {
+		if k := columnKind(table, col); k != kindNumeric && k != kindInteger {
+			return "", fmt.Errorf("%s(%s) membutuhkan kolom numerik", nfn, col)
+		}
+	}
+	return fmt.Sprintf("%s(%s.%s)", strings.ToUpper(nfn), alias, col), nil
+}
+
+// aggregateSelectList merakit daftar SELECT untuk rencana agregat: kolom group_by lalu agregat.
+// Mengembalikan juga peta nama keluaran -> ekspresi ORDER BY.
+func aggregateSelectList(p *domain.SQLPlan, alias string) (string, map[string]string, error) {
+	items := []string{}
+	outputs := map[string]string{}
+	for _, g := range p.GroupBy {
+		g = strings.ToLower(strings.TrimSpace(g))
+		if !isColumnIn(p.Table, g) {
+			return "", nil, fmt.Errorf("kolom group_by %q tidak ada di tabel %s", g, p.Table)
+		}
+		items = append(items, alias+"."+g)
+		outputs[g] = alias + "." + g
+	}
+	for _, ag := range p.Aggregates {
+		expr, err := aggregateExpr(p.Table, alias, ag.Func, ag.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		name := aggregateAlias(ag)
+		if !aliasPattern.MatchString(name) {
+			return "", nil, fmt.Errorf("alias agregat %q tidak valid", name)
+		}
+		if _, dup := outputs[name]; dup {
+			return "", nil, fmt.Errorf("nama keluaran %q dipakai lebih dari sekali", name)
+		}
+		items = append(items, expr+" AS "+name)
+		outputs[name] = name
+	}
+	return strings.Join(items, ", "), outputs, nil
+}
+
+// havingClause mengompilasi kondisi HAVING (termasuk ambang k-anonymity) menjadi SQL berparameter
+func havingClause(p *domain.SQLPlan, alias string, argIdx int) (string, []interface{}, error) {
+	conds := []string{}
+	args := []interface{}{}
+	for _, h := range p.Having {
+		expr, err := aggregateExpr(p.Table, alias, h.Func, h.Column)
+		if err != nil {
+			return "", nil, fmt.Errorf("having: %w", err)
+		}
+		op, ok := normalizeFilterOp(h.Op)
+		if !ok || op == opBetween || op == opIn || op == opILike || op == opIsNull || op == opIsNotNull {
+			return "", nil, fmt.Errorf("having: operator %q tidak didukung", h.Op)
+		}
+		v, err := havingValue(p.Table, h)
+		if err != nil {
+			return "", nil, err
+		}
+		conds = append(conds, fmt.Sprintf("%s %s $%d", expr, op, argIdx))
+		args = append(args, v)
+		argIdx++
+	}
+	if p.MinGroupSize > 0 {
+		conds = append(conds, fmt.Sprintf("COUNT(*) >= %d", p.MinGroupSize))
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+// havingValue mengubah nilai pembanding HAVING: count -> bilangan bulat, sum/avg -> angka,
+// min/max -> tipe kolomnya
+func havingValue(table string, h domain.Having) (interface{}, error) {
+	v := strings.ReplaceAll(strings.TrimSpace(h.Value), "_", "")
+	fn, _ := normalizeAggregateFunc(h.Func)
+	switch {
+	case fn == "count":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("having: %q bukan bilangan bulat", h.Value)
+		}
+		return n, nil
+	case fn == "sum" || fn == "avg":
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("having: %q bukan angka", h.Value)
+		}
+		return f, nil
+	}
+	cv, err := coerceFilterValue(table, strings.ToLower(strings.TrimSpace(h.Column)), h.Value)
+	if err != nil {
+		return nil, fmt.Errorf("having: %w", err)
+	}
+	return cv, nil
+}
+
+// orderByClause mengompilasi ORDER BY; pada rencana agregat hanya kolom group_by atau alias agregat
+func orderByClause(p *domain.SQLPlan, alias string, outputs map[string]string) (string, error) {
+	keys := []string{}
+	for _, o := range p.OrderBy {
+		col := strings.ToLower(strings.TrimSpace(o.Column))
+		var expr string
+		if outputs != nil {
+			e, ok := outputs[col]
+			if !ok {
+				return "", fmt.Errorf("order_by %q harus kolom group_by atau alias agregat", col)
+			}
+			expr = e
+		} else {
+			if !isColumnIn(p.Table, col) {
+				return "", fmt.Errorf("kolom order_by %q tidak ada di tabel %s", col, p.Table)
+			}
+			expr = alias + "." + col
+		}
+		if o.Desc {
+			expr += " DESC"
+		}
+		keys = append(keys, expr)
+	}
+	return strings.Join(keys, ", "), nil
+}
+
+// sanitizeAggregatesForPrivacy menerapkan aturan k-anonymity untuk agregat atas tabel sensitif:
+// tidak ada group_by maupun min/max pada kolom identitas/PII, dan grup kecil dibuang. min/max
+// mengembalikan nilai satu orang sehingga ambang ukuran grup tidak melindunginya.
+// Filter dibatasi ke kolom kategori (enum/bool) dengan "=": rentang tanggal/angka atau teks bebas
+// memungkinkan dua agregat yang hanya berbeda satu orang (serangan selisih).
+func sanitizeAggregatesForPrivacy(p *domain.SQLPlan) error {
+	for _, f := range p.Filters {
+		lc := strings.ToLower(strings.TrimSpace(f.Column))
+		op, _ := normalizeFilterOp(f.Op)
+		_, pii := piiColumns[lc]
+		_, ident := identifierColumns[lc]
+		kind := columnKind(p.Table, lc)
+		if pii || ident || op != opEq || (kind != kindEnum && kind != kindBool) {
+			return fmt.Errorf("Statistik data pengguna hanya bisa difilter per kategori (misal: status = APPROVED); filter %s tidak diizinkan.", lc)
+		}
+	}
+	for _, g := range p.GroupBy {
+		lc := strings.ToLower(strings.TrimSpace(g))
+		_, pii := piiColumns[lc]
+		_, ident := identifierColumns[lc]
+		if pii || ident {
+			return fmt.Errorf("Pengelompokan berdasarkan %s tidak diizinkan karena bisa menampilkan data per orang.", lc)
+		}
+	}
+	check := func(fn, col string) error {
+		nfn, _ := normalizeAggregateFunc(fn)
+		lc := strings.ToLower(strings.TrimSpace(col))
+		_, pii := piiColumns[lc]
+		_, ident := identifierColumns[lc]
+		if (pii || ident) && (nfn == "min" || nfn == "max") {
+			return fmt.Errorf("Nilai %s dari %s tidak diizinkan karena menampilkan data satu orang.", nfn, col)
+		}
+		return nil
+	}
+	for _, ag := range p.Aggregates {
+		if err := check(ag.Func, ag.Column); err != nil {
+			return err
+		}
+	}
+	for _, h := range p.Having {
+		if err := check(h.Func, h.Column); err != nil {
+			return err
+		}
+	}
+	p.Columns = nil
+	p.MinGroupSize = minAggregateGroupSize
+	return nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestBuildSafeSelect_Aggregates(t *testing.T) {
+	a := &AIQueryService{}
+	p := &domain.SQLPlan{
+		Operation:  "SELECT",
+		Table:      "kpr_applications",
+		Aggregates: []domain.Aggregate{{Func: "count", Column: "*", Alias: "jumlah"}, {Func: "avg", Column: "loan_amount"}},
+		GroupBy:    []string{"status"},
+		Filters:    []domain.Filter{{Column: "submitted_at", Op: "between", Values: []string{"2026-10-01", "2026-10-31"}}},
+		Having:     []domain.Having{{Func: "count", Column: "*", Op: ">=", Value: "2"}},
+		OrderBy:    []domain.OrderBy{{Column: "jumlah", Desc: true}, {Column: "status"}},
+		Limit:      10,
+	}
+	q, args, err := a.buildSafeSelect(p)
+	if err != nil {
+		t.Fatalf("buildSafeSelect error: %v", err)
+	}
+	want := "SELECT t.status, COUNT(*) AS jumlah, AVG(t.loan_amount) AS avg_loan_amount FROM kpr_applications t WHERE t.submitted_at BETWEEN $1 AND $2 GROUP BY t.status HAVING COUNT(*) >= $3 ORDER BY jumlah DESC, t.status LIMIT 10"
+	if q != want {
+		t.Fatalf("query:\n got %s\nwant %s", q, want)
+	}
+	if len(args) != 3 || args[2] != int64(2) {
+		t.Fatalf("args=%#v; want 3 with int64 having value", args)
+	}
+}
+
+func TestBuildSafeSelect_AggregateErrors(t *testing.T) {
+	a := &AIQueryService{}
+	cases := []struct {
+		name string
+		plan domain.SQLPlan
+		want string
+	}{
+		{"sum over text", domain.SQLPlan{Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "sum", Column: "developer_name"}}}, "numerik"},
+		{"unknown func", domain.SQLPlan{Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "median", Column: "loan_amount"}}}, "median"},
+		{"group_by unknown column", domain.SQLPlan{Table: "kpr_rates", Aggregates: []domain.Aggregate{{Func: "count", Column: "*"}}, GroupBy: []string{"status"}}, "group_by"},
+		{"order_by not in output", domain.SQLPlan{Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "count", Column: "*"}}, GroupBy: []string{"status"}, OrderBy: []domain.OrderBy{{Column: "loan_amount"}}}, "order_by"},
+		{"alias injection", domain.SQLPlan{Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "count", Column: "*", Alias: "x; drop table users"}}}, "alias"},
+		{"group_by without aggregates", domain.SQLPlan{Table: "kpr_applications", GroupBy: []string{"status"}}, "aggregates"},
+	}
+	for _, c := range cases {
+		p := c.plan
+		if _, _, err := a.buildSafeSelect(&p); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: err=%v; want containing %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestSanitizePlanForPrivacy_AggregateKAnonymity(t *testing.T) {
+	a := &AIQueryService{}
+
+	// agregat tanpa filter spesifik di tabel sensitif diizinkan dengan ukuran grup minimum
+	p := &domain.SQLPlan{Operation: "SELECT", Table: "user_profiles", Aggregates: []domain.Aggregate{{Func: "avg", Column: "monthly_income"}}, GroupBy: []string{"city"}}
+	if err := a.sanitizePlanForPrivacy(p); err != nil {
+		t.Fatalf("aggregate rejected: %v", err)
+	}
+	q, _, err := a.buildSafeSelect(p)
+	if err != nil {
+		t.Fatalf("buildSafeSelect error: %v", err)
+	}
+	if !strings.Contains(q, "HAVING COUNT(*) >= 5") {
+		t.Fatalf("query without k-anonymity threshold: %s", q)
+	}
+
+	// filter kategori tetap boleh
+	cat := &domain.SQLPlan{Operation: "SELECT", Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "count", Column: "*"}},
+		Filters: []domain.Filter{{Column: "status", Op: "=", Value: "APPROVED"}}}
+	if err := a.sanitizePlanForPrivacy(cat); err != nil {
+		t.Fatalf("categorical filter rejected: %v", err)
+	}
+
+	// baris per orang tetap membutuhkan filter spesifik
+	rows := &domain.SQLPlan{Operation: "SELECT", Table: "user_profiles", Columns: []string{"city"}}
+	if err := a.sanitizePlanForPrivacy(rows); err == nil {
+		t.Fatalf("row-level access without restrictive filter allowed")
+	}
+
+	rejected := []*domain.SQLPlan{
+		{Operation: "SELECT", Table: "user_profiles", Aggregates: []domain.Aggregate{{Func: "count", Column: "*"}}, GroupBy: []string{"user_id"}},
+		{Operation: "SELECT", Table: "user_profiles", Aggregates: []domain.Aggregate{{Func: "max", Column: "monthly_income"}}},
+		{Operation: "SELECT", Table: "user_profiles", Aggregates: []domain.Aggregate{{Func: "max", Column: "full_name"}}},
+		{Operation: "SELECT", Table: "user_profiles", Aggregates: []domain.Aggregate{{Func: "min", Column: "birth_date"}}},
+		{Operation: "SELECT", Table: "users", Aggregates: []domain.Aggregate{{Func: "count", Column: "*"}}, GroupBy: []string{"email"}},
+		// filter yang mempersempit sampai beda satu orang membuka serangan selisih
+		{Operation: "SELECT", Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "sum", Column: "loan_amount"}},
+			Filters: []domain.Filter{{Column: "created_at", Op: "<", Value: "2026-03-01 10:15:00"}}},
+		{Operation: "SELECT", Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "avg", Column: "loan_amount"}},
+			Filters: []domain.Filter{{Column: "loan_amount", Op: ">", Value: "999000000"}}},
+		{Operation: "SELECT", Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "sum", Column: "loan_amount"}},
+			Filters: []domain.Filter{{Column: "status", Op: "!=", Value: "APPROVED"}}},
+		{Operation: "SELECT", Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "sum", Column: "loan_amount"}},
+			Filters: []domain.Filter{{Column: "developer_name", Op: "=", Value: "PT Maju Jaya"}}},
+		{Operation: "SELECT", Table: "user_profiles", Aggregates: []domain.Aggregate{{Func: "avg", Column: "monthly_income"}},
+			Filters: []domain.Filter{{Column: "full_name", Op: "ilike", Value: "Bud"}}},
+	}
+	for i, rp := range rejected {
+		if err := a.sanitizePlanForPrivacy(rp); err == nil {
+			t.Fatalf("rejected[%d]: privacy-violating aggregate allowed", i)
+		}
+	}
+}
+
+func TestDecodePlanJSON_Aggregates(t *testing.T) {
+	p, err := decodePlanJSON(`{"operation":"SELECT","table":"kpr_applications","aggregates":[{"func":"COUNT","column":"*","alias":"Jumlah"}],"group_by":["Status"],"having":[{"func":"count","column":"*","op":"gte","value":3}],"order_by":[{"column":"jumlah","desc":true}]}`)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(p.Aggregates) != 1 || p.Aggregates[0].Func != "count" || p.Aggregates[0].Alias != "jumlah" {
+		t.Fatalf("aggregates=%+v", p.Aggregates)
+	}
+	if len(p.Having) != 1 || p.Having[0].Op != ">=" || p.Having[0].Value != "3" {
+		t.Fatalf("having=%+v", p.Having)
+	}
+	if p.MinGroupSize != 0 {
+		t.Fatalf("planner must not set min_group_size")
+	}
+	a := &AIQueryService{}
+	if errs := a.validatePlan(p); len(errs) != 0 {
+		t.Fatalf("valid aggregate plan rejected: %v", errs)
+	}
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+var (
+	// ErrApplicationNotFound: nomor pengajuan tidak ada (atau bukan milik nomor WhatsApp pemohon)
+	ErrApplicationNotFound = errors.New("pengajuan KPR tidak ditemukan")
+	// ErrApplicationNotApproved: jadwal angsuran hanya dibuat untuk pengajuan yang sudah disetujui
+	ErrApplicationNotApproved = errors.New("pengajuan KPR belum disetujui")
+)
+
+// approvedStatuses: status kpr_applications yang sudah memiliki jadwal angsuran tetap
+var approvedStatuses = map[string]bool{"APPROVED": true, "DISBURSED": true}
+
+// Amortization menghitung jadwal bulanan untuk simulasi (produk sama seperti Simulate)
+func (s *KPRCalculatorService) Amortization(ctx context.Context, req domain.SimulationRequest) (*domain.AmortizationSchedule, error) {
+	sim, err := s.Simulate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	sched := amortizationSchedule(sim)
+	sched.Title = "Simulasi " + sim.Rate.RateName
+	return sched, nil
+}
+
+const applicationLoanQuery = `SELECT a.application_number, a.loan_amount, a.interest_rate, a.loan_term_years, a.status::text
+FROM kpr_applications a JOIN users u ON u.id = a.user_id
+WHERE ($1 = '' OR a.application_number = $1) AND ($2 = '' OR u.phone = $2)
+ORDER BY COALESCE(a.approved_at, a.created_at) DESC
+LIMIT 1`
+
+// ApplicationAmortization menghitung jadwal dari loan_amount, interest_rate, dan loan_term_years pengajuan
+func (s *KPRCalculatorService) ApplicationAmortization(ctx context.Context, applicationNumber, phone string) (*domain.AmortizationSchedule, error) {
+	applicationNumber = strings.ToUpper(strings.TrimSpace(applicationNumber))
+	phone = strings.TrimSpace(phone)
+	if applicationNumber == "" && phone == "" {
+		return nil, ErrApplicationNotFound
+	}
+	q := applicationLoanQuery
+	if applicationNumber == "" {
+		// tanpa nomor: pengajuan terbaru milik user yang sudah disetujui
+		q = strings.Replace(q, "ORDER BY", "AND a.status::text IN ('APPROVED', 'DISBURSED')\nORDER BY", 1)
+	}
+	rows, err := s.db.Query(ctx, q, applicationNumber, phone)
+	if err != nil {
+		return nil, fmt.Errorf("kpr_applications: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrApplicationNotFound
+	}
+	var number, status string
+	var loan, rate float64
+	var years int
+	if err := rows.Scan(&number, &loan, &rate, &years, &status); err != nil {
+		return nil, err
+	}
+	if !approvedStatuses[strings.ToUpper(status)] {
+		return nil, ErrApplicationNotApproved
+	}
+	if loan <= 0 || years <= 0 {
+		return nil, fmt.Errorf("data pinjaman %s tidak lengkap", number)
+	}
+	sched := amortizationSchedule(fixedLoanSimulation(loan, rate, years*12))
+	sched.Title = "Pengajuan " + number
+	sched.ApplicationNumber = number
+	return sched, nil
+}
+
+// fixedLoanSimulation: pinjaman anuitas dengan satu bunga sepanjang n bulan (data kpr_applications)
+func fixedLoanSimulation(loan, rate float64, n int) *domain.SimulationResult {
+	return &domain.SimulationResult{
+		Principal:   loan,
+		TenorMonths: n,
+		Periods:     []domain.SimulationPeriod{{FromMonth: 1, ToMonth: n, AnnualRate: rate, MonthlyInstallment: annuityPayment(loan, rate, n)}},
+	}
+}
+
+// amortizationSchedule menjabarkan periode simulasi menjadi baris bulanan (fungsi murni).
+// Angsuran terakhir disesuaikan agar sisa pokok tepat nol.
+func amortizationSchedule(sim *domain.SimulationResult) *domain.AmortizationSchedule {
+	sched := &domain.AmortizationSchedule{Principal: sim.Principal, TenorMonths: sim.TenorMonths}
+	bal := sim.Principal
+	for _, p := range sim.Periods {
+		for m := p.FromMonth; m <= p.ToMonth; m++ {
+			interest := bal * p.AnnualRate / 12
+			pay := p.MonthlyInstallment
+			principal := pay - interest
+			if m == sim.TenorMonths || principal > bal {
+				principal = bal
+				pay = principal + interest
+			}
+			bal = math.Max(0, bal-principal)
+			sched.Rows = append(sched.Rows, domain.AmortizationRow{
+				Month: m, AnnualRate: p.AnnualRate, Installment: pay, Principal: principal, Interest: interest, Balance: bal,
+			})
+			sched.TotalInterest += interest
+			sched.TotalPayment += pay
+		}
+	}
+	return sched
+}
+
+// ScheduleCSV merender jadwal sebagai CSV (angka polos dua desimal agar mudah diolah spreadsheet)
+func ScheduleCSV(s *domain.AmortizationSchedule) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	records := [][]string{{"bulan", "bunga_tahunan", "angsuran", "pokok", "bunga", "sisa_pokok"}}
+	for _, r := range s.Rows {
+		records = append(records, []string{
+			strconv.Itoa(r.Month), strconv.FormatFloat(r.AnnualRate, 'f', 4, 64),
+			money(r.Installment), money(r.Principal), money(r.Interest), money(r.Balance),
+		})
+	}
+	records = append(records, []string{"total", "", money(s.TotalPayment), money(s.Principal), money(s.TotalInterest), ""})
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// schedulePDFRowsPerPage: baris tabel per halaman A4 dengan font 8pt
+const schedulePDFRowsPerPage = 60
+
+// SchedulePDF merender jadwal sebagai PDF A4 berhalaman (tanpa layanan eksternal)
+func SchedulePDF(s *domain.AmortizationSchedule) []byte {
+	header := fmt.Sprintf("%-6s %-8s %16s %16s %16s %18s", "Bulan", "Bunga", "Angsuran", "Pokok", "Bunga", "Sisa pokok")
+	pages := [][]string{}
+	for start := 0; ; start += schedulePDFRowsPerPage {
+		end := start + schedulePDFRowsPerPage
+		if end > len(s.Rows) {
+			end = len(s.Rows)
+		}
+		lines := []string{}
+		if start == 0 {
+			lines = append(lines,
+				"Jadwal Angsuran KPR - "+s.Title,
+				fmt.Sprintf("Plafon %s, tenor %s", formatRupiah(s.Principal), formatTenorMonths(s.TenorMonths)),
+				"")
+		}
+		lines = append(lines, header)
+		for _, r := range s.Rows[start:end] {
+			lines = append(lines, fmt.Sprintf("%-6d %-8s %16s %16s %16s %18s", r.Month, formatRateFraction(r.AnnualRate),
+				formatNumberID(r.Installment, 0), formatNumberID(r.Principal, 0), formatNumberID(r.Interest, 0), formatNumberID(r.Balance, 0)))
+		}
+		last := end >= len(s.Rows)
+		if last {
+			lines = append(lines, "",
+				"Total angsuran: "+formatRupiah(s.TotalPayment),
+				"Total bunga: "+formatRupiah(s.TotalInterest),
+				"Dokumen indikatif; angka final mengikuti perjanjian kredit BNI.")
+		}
+		pages = append(pages, lines)
+		if last {
+			break
+		}
+	}
+	return renderTextPDF(pages)
+}
+
+// ScheduleFile menyiapkan dokumen jadwal dalam format "pdf" atau "csv"
+func ScheduleFile(s *domain.AmortizationSchedule, format string) (domain.Document, error) {
+	name := "jadwal-angsuran"
+	if s.ApplicationNumber != "" {
+		name += "-" + strings.ToLower(s.ApplicationNumber)
+	}
+	if format == "csv" {
+		data, err := ScheduleCSV(s)
+		if err != nil {
+			return domain.Document{}, err
+		}
+		return domain.Document{FileName: name + ".csv", MimeType: "text/csv", Data: data}, nil
+	}
+	return domain.Document{FileName: name + ".pdf", MimeType: "application/pdf", Data: SchedulePDF(s)}, nil
+}
+
+// -------------------------
+// Jadwal angsuran lewat chat
+// -------------------------
+
+const scheduleHowTo = "Jadwal angsuran bisa aku kirim untuk simulasi (contoh: *jadwal angsuran harga 1 miliar dp 20% tenor 20 tahun*) atau untuk pengajuan kamu yang sudah disetujui (sebutkan nomor pengajuannya). Tambahkan kata *csv* bila ingin format spreadsheet."
+
+// scheduleFormat: PDF kecuali user meminta CSV/Excel
+func scheduleFormat(text string) string {
+	low := strings.ToLower(text)
+	for _, k := range []string{"csv", "excel", "spreadsheet"} {
+		if strings.Contains(low, k) {
+			return "csv"
+		}
+	}
+	return "pdf"
+}
+
+// answerSchedule membuat jadwal angsuran lalu mengirimnya sebagai dokumen WhatsApp.
+// Sumber: nomor pengajuan di teks, parameter simulasi di teks, simulasi terakhir user,
+// atau pengajuan disetujui terbaru milik nomor pengirim.
+func (a *AIQueryService) answerSchedule(ctx context.Context, phone, text string) (string, bool) {
+	if a.calculator == nil {
+		return "", false
+	}
+	if phone == "" || a.whatsapp == nil {
+		return "Jadwal angsuran dikirim sebagai dokumen, jadi hanya tersedia lewat chat WhatsApp.", true
+	}
+	var sched *domain.AmortizationSchedule
+	var err error
+	app := extractAppNumber(text)
+	in := parseSimulationInput(text)
+	var last *domain.SimulationRequest
+	var selected string
+	if m := a.mem.Get(phone); m != nil {
+		last, selected = m.LastSimulation, m.SelectedApplication
+	}
+	switch {
+	case app != "":
+		sched, err = a.calculator.ApplicationAmortization(ctx, app, phone)
+	case in.Price > 0 && in.TenorYears > 0 && (in.DP > 0 || in.DPPercent > 0):
+		req, reply := a.simulationRequestFromText(ctx, phone, text, scheduleHowTo)
+		if reply != "" {
+			return reply, true
+		}
+		sched, err = a.calculator.Amortization(ctx, req)
+	case last != nil:
+		sched, err = a.calculator.Amortization(ctx, *last)
+	case selected != "":
+		app = selected
+		sched, err = a.calculator.ApplicationAmortization(ctx, app, phone)
+	default:
+		sched, err = a.calculator.ApplicationAmortization(ctx, "", phone)
+		if errors.Is(err, ErrApplicationNotFound) {
+			return scheduleHowTo, true
+		}
+	}
+	switch {
+	case errors.Is(err, ErrApplicationNotFound):
+		return fmt.Sprintf("Pengajuan %s tidak ditemukan untuk nomor WhatsApp ini.", app), true
+	case errors.Is(err, ErrApplicationNotApproved):
+		return "Jadwal angsuran baru tersedia setelah pengajuan KPR kamu disetujui.", true
+	case err != nil:
+		log.Printf("[AI] amortization error: %v", err)
+		return "Maaf, jadwal angsuran belum bisa dibuat saat ini.", true
+	}
+	format := scheduleFormat(text)
+	doc, err := ScheduleFile(sched, format)
+	if err != nil {
+		log.Printf("[AI] amortization file error: %v", err)
+		return "Maaf, jadwal angsuran belum bisa dibuat saat ini.", true
+	}
+	doc.Caption = fmt.Sprintf("Jadwal angsuran %s (%d bulan)", sched.Title, sched.TenorMonths)
+	if err := a.whatsapp.SendDocument(ctx, phone, doc); err != nil {
+		log.Printf("[AI] send schedule error: %v", err)
+		return "Maaf, dokumen jadwal angsuran gagal dikirim. Coba lagi sebentar lagi ya.", true
+	}
+	return fmt.Sprintf("Jadwal angsuran %s (%d bulan) sudah aku kirim sebagai dokumen %s. Total angsuran %s, total bunga %s.",
+		sched.Title, sched.TenorMonths, strings.ToUpper(format), formatRupiah(sched.TotalPayment), formatRupiah(sched.TotalInterest)), true
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestAmortizationSchedule(t *testing.T) {
+	sim, err := simulateWithRate(sampleRate(), domain.SimulationRequest{PropertyPrice: 1000000000, DownPayment: 200000000, TenorYears: 20, FloatingRate: 0.11})
+	if err != nil {
+		t.Fatalf("simulate error: %v", err)
+	}
+	s := amortizationSchedule(sim)
+	if len(s.Rows) != 240 || s.Rows[239].Balance != 0 {
+		t.Fatalf("rows=%d last=%+v", len(s.Rows), s.Rows[len(s.Rows)-1])
+	}
+	if s.Rows[36].AnnualRate != 0.11 || s.Rows[35].AnnualRate != sampleRate().EffectiveRate {
+		t.Fatalf("floating period must start at month 37")
+	}
+	var principal float64
+	for _, r := range s.Rows {
+		principal += r.Principal
+	}
+	if math.Abs(principal-sim.Principal) > 1e-3 || math.Abs(s.TotalPayment-sim.TotalPayment) > 1 {
+		t.Fatalf("principal=%.2f total=%.2f sim=%.2f", principal, s.TotalPayment, sim.TotalPayment)
+	}
+
+	data, err := ScheduleCSV(s)
+	if err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil || len(records) != 242 || records[0][0] != "bulan" {
+		t.Fatalf("csv records=%d err=%v", len(records), err)
+	}
+
+	pdf := SchedulePDF(s)
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.Contains(pdf, []byte("/Count 4")) || !bytes.Contains(pdf, []byte("(Halaman 4/4)")) {
+		t.Fatalf("pdf must have 4 pages for 240 months")
+	}
+	// setiap offset di tabel xref menunjuk ke awal objek
+	xref := pdf[bytes.LastIndex(pdf, []byte("xref\n")):]
+	for i, line := range strings.Split(string(xref), "\n")[3:] {
+		if !strings.HasSuffix(line, " n ") {
+			break
+		}
+		var off int
+		fmt.Sscanf(line, "%d", &off)
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(pdf[off:], []byte(want)) {
+			t.Fatalf("xref entry %d points to %q", i+1, pdf[off:off+10])
+		}
+	}
+}
+
+type fakeScheduleCalculator struct {
+	domain.KPRCalculatorService
+	gotApp, gotPhone string
+}
+
+func (f *fakeScheduleCalculator) ApplicationAmortization(ctx context.Context, app, phone string) (*domain.AmortizationSchedule, error) {
+	f.gotApp, f.gotPhone = app, phone
+	if app == "KPR-2025-009" {
+		return nil, ErrApplicationNotApproved
+	}
+	return &domain.AmortizationSchedule{Title: "Pengajuan " + app, ApplicationNumber: app, TenorMonths: 12, Rows: []domain.AmortizationRow{{Month: 1}}}, nil
+}
+
+type fakeDocSender struct {
+	domain.WhatsAppService
+	phone string
+	doc   domain.Document
+}
+
+func (f *fakeDocSender) SendDocument(ctx context.Context, phone string, doc domain.Document) error {
+	f.phone, f.doc = phone, doc
+	return nil
+}
+
+func TestAnswerSchedule_SendsDocumentForOwnApplication(t *testing.T) {
+	calc, wa := &fakeScheduleCalculator{}, &fakeDocSender{}
+	a := &AIQueryService{calculator: calc, whatsapp: wa, mem: NewMemoryStore()}
+	reply, ok := a.answerSchedule(context.Background(), "628123", "jadwal angsuran KPR-2025-001 csv")
+	if !ok || calc.gotApp != "KPR-2025-001" || calc.gotPhone != "628123" {
+		t.Fatalf("reply=%q app=%q phone=%q", reply, calc.gotApp, calc.gotPhone)
+	}
+	if wa.phone != "628123" || wa.doc.FileName != "jadwal-angsuran-kpr-2025-001.csv" || wa.doc.MimeType != "text/csv" {
+		t.Fatalf("document=%+v to %q", wa.doc, wa.phone)
+	}
+	if reply, _ := a.answerSchedule(context.Background(), "628123", "jadwal angsuran KPR-2025-009"); !strings.Contains(reply, "disetujui") {
+		t.Fatalf("not approved reply=%q", reply)
+	}
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/nlu"
+)
+
+// ApplicationChoice adalah satu baris "list pengajuan" yang bisa dipilih dengan nomor urut
+type ApplicationChoice struct {
+	Number    string
+	Status    string
+	CreatedAt time.Time
+}
+
+var (
+	// applicationListPattern: "list pengajuan", "daftar pengajuan kpr saya", "pengajuan saya apa saja"
+	applicationListPattern = regexp.MustCompile(`(?i)^\s*(?:(?:list|daftar|lihat|tampilkan|semua)\s+(?:semua\s+)?(?:pengajuan|aplikasi)(?:\s+(?:kpr|saya|aku|ku|kamu))*|(?:pengajuan|aplikasi)(?:\s+kpr)?\s*(?:saya|aku|ku)?\s+apa\s+(?:saja|aja))\s*[?.!]*\s*$`)
+	// applicationChoicePattern: "2", "pilih 2", "nomor 2", "yang ke-2", "yang kedua"
+	applicationChoicePattern = regexp.MustCompile(`(?i)^\s*(?:pilih\s+)?(?:yang\s+)?(?:nomor\s+|no\.?\s*)?(?:ke[- ]?)?(\d{1,2}|pertama|kedua|ketiga|keempat|kelima)\s*[.!]?\s*$`)
+)
+
+// ordinalChoices: bilangan urut kata untuk pilihan daftar
+var ordinalChoices = map[string]int{"pertama": 1, "kedua": 2, "ketiga": 3, "keempat": 4, "kelima": 5}
+
+// selectedFieldPatterns: atribut pengajuan yang ditanyakan pada pertanyaan lanjutan
+var selectedFieldPatterns = []struct {
+	re    *regexp.Regexp
+	field string
+}{
+	{regexp.MustCompile(`(?i)\b(?:cicilan|angsuran)(?:nya)?\b`), "installment"},
+	{regexp.MustCompile(`(?i)\b(?:plafon|plafond|pinjaman)(?:nya)?\b`), "loan"},
+	{regexp.MustCompile(`(?i)\b(?:tenor|jangka\s+waktu)(?:nya)?\b`), "tenor"},
+	{regexp.MustCompile(`(?i)\bbunga(?:nya)?\b`), "rate"},
+	{regexp.MustCompile(`(?i)\b(?:dp|uang\s+muka)(?:nya)?\b`), "dp"},
+	{regexp.MustCompile(`(?i)\bstatus(?:nya)?\b`), "status"},
+	{regexp.MustCompile(`(?i)\b(?:detail|rincian|ringkasan)(?:nya)?\b`), "all"},
+}
+
+// selectedFollowUpIntents: intent yang boleh dijawab dari pengajuan terpilih. Intent lain punya
+// penangan sendiri (sebagian sudah memakai applicationFor) atau bertanya soal produk, bukan pengajuan.
+var selectedFollowUpIntents = map[domain.Intent]bool{
+	domain.IntentApplicationStatus: true, domain.IntentInstallmentSimulation: true, domain.IntentOther: true,
+}
+
+// selectedRatePattern: "bunganya" merujuk ke pengajuan, sedangkan "bunga KPR" bertanya soal produk
+var selectedRatePattern = regexp.MustCompile(`(?i)\bbunganya\b`)
+
+// applicationListQuery: 10 pengajuan terbaru milik nomor pengirim untuk "list pengajuan"
+const applicationListQuery = `SELECT a.application_number, COALESCE(a.status::text, ''), a.created_at
+FROM kpr_applications a JOIN users u ON u.id = a.user_id
+WHERE u.phone = $1
+ORDER BY a.created_at DESC
+LIMIT 10`
+
+const selectedApplicationQuery = `SELECT a.application_number, COALESCE(a.status::text, ''), a.loan_amount, a.monthly_installment,
+	a.loan_term_years, a.interest_rate, a.down_payment, a.created_at
+FROM kpr_applications a JOIN users u ON u.id = a.user_id
+WHERE a.application_number = $1 AND u.phone = $2
+LIMIT 1`
+
+// applicationFor: nomor pengajuan di teks, atau pengajuan yang dipilih user dari "list pengajuan"
+func (a *AIQueryService) applicationFor(phone, text string) string {
+	if app := extractAppNumber(text); app != "" {
+		return app
+	}
+	if m := a.mem.Get(phone); m != nil {
+		return m.SelectedApplication
+	}
+	return ""
+}
+
+// parseApplicationChoice membaca nomor urut pilihan; ok=false bila teks bukan pilihan
+func parseApplicationChoice(text string) (int, bool) {
+	m := applicationChoicePattern.FindStringSubmatch(text)
+	if m == nil {
+		return 0, false
+	}
+	if n, ok := ordinalChoices[strings.ToLower(m[1])]; ok {
+		return n, true
+	}
+	n, err := strconv.Atoi(m[1])
+	return n, err == nil
+}
+
+// matchApplicationChoice mencocokkan balasan user dengan daftar terakhir: nomor urut atau nomor
+// pengajuan yang ada di daftar. Nomor urut di luar daftar mengembalikan found=true, idx=-1.
+func matchApplicationChoice(text string, choices []ApplicationChoice) (idx int, found bool) {
+	if n, ok := parseApplicationChoice(text); ok {
+		if n < 1 || n > len(choices) {
+			return -1, true
+		}
+		return n - 1, true
+	}
+	app := extractAppNumber(text)
+	if app == "" || len(strings.Fields(text)) > 3 {
+		return 0, false
+	}
+	for i, c := range choices {
+		if strings.EqualFold(c.Number, app) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// listApplications membaca pengajuan terbaru milik nomor pengirim
+func (a *AIQueryService) listApplications(ctx context.Context, phone string) ([]ApplicationChoice, error) {
+	rows, err := a.db.Query(ctx, applicationListQuery, phone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ApplicationChoice
+	for rows.Next() {
+		var c ApplicationChoice
+		if err := rows.Scan(&c.Number, &c.Status, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// formatApplicationList merender daftar bernomor; pengajuan yang sedang dipilih ditandai
+func formatApplicationList(intro string, choices []ApplicationChoice, selected string) string {
+	var b strings.Builder
+	b.WriteString(intro)
+	b.WriteString("\n")
+	for i, c := range choices {
+		fmt.Fprintf(&b, "%d. %s — %s — %s", i+1, c.Number, humanizeEnum(c.Status), formatDateID(c.CreatedAt))
+		if strings.EqualFold(c.Number, selected) {
+			b.WriteString(" ✅")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\nBalas nomor urut (mis. *1*) atau nomor pengajuan untuk memilih.")
+	return b.String()
+}
+
+// answerApplicationList menangani "list pengajuan" dan balasan pilihannya. Daftar hanya berlaku
+// untuk satu balasan berikutnya; pilihan disimpan di SelectedApplication.
+func (a *AIQueryService) answerApplicationList(ctx context.Context, phone, text string) (string, bool) {
+	var choices []ApplicationChoice
+	if m := a.mem.Get(phone); m != nil && len(m.ApplicationChoices) > 0 {
+		choices = m.ApplicationChoices
+		a.mem.Update(phone, func(m *UserMemory) { m.ApplicationChoices = nil })
+	}
+	if len(choices) > 0 {
+		if idx, ok := matchApplicationChoice(text, choices); ok {
+			if idx < 0 {
+				a.mem.Update(phone, func(m *UserMemory) { m.ApplicationChoices = choices })
+				return fmt.Sprintf("Pilih nomor 1–%d ya.", len(choices)), true
+			}
+			c := choices[idx]
+			a.selectApplication(phone, c.Number)
+			return fmt.Sprintf("Oke, pengajuan *%s* (status %s, dibuat %s) dipilih. Pertanyaan berikutnya seperti \"cicilannya berapa?\" akan merujuk ke pengajuan ini.",
+				c.Number, humanizeEnum(c.Status), formatDateID(c.CreatedAt)), true
+		}
+	}
+	if !applicationListPattern.MatchString(text) {
+		return "", false
+	}
+	if strings.TrimSpace(phone) == "" {
+		return "Daftar pengajuan hanya tersedia lewat chat WhatsApp dari nomor yang terdaftar.", true
+	}
+	if a.db == nil {
+		return "", false
+	}
+	reply, err := a.offerApplicationChoices(ctx, phone, "📋 *Pengajuan KPR kamu*:")
+	if err != nil {
+		log.Printf("[AI] application list error: %v", err)
+		return "Maaf, daftar pengajuan belum bisa ditampilkan saat ini.", true
+	}
+	return reply, true
+}
+
+// offerApplicationChoices menampilkan daftar pengajuan dan menunggu pilihan user.
+// Satu pengajuan langsung dipilih; tanpa pengajuan dijawab dengan ajakan mengajukan.
+func (a *AIQueryService) offerApplicationChoices(ctx context.Context, phone, intro string) (string, error) {
+	choices, err := a.listApplications(ctx, phone)
+	if err != nil {
+		return "", err
+	}
+	switch len(choices) {
+	case 0:
+		return "Belum ada pengajuan KPR atas nomor WhatsApp ini. Ketik *ajukan KPR* untuk memulai.", nil
+	case 1:
+		c := choices[0]
+		a.selectApplication(phone, c.Number)
+		return fmt.Sprintf("Kamu punya 1 pengajuan: *%s* — %s — %s. Pertanyaan berikutnya akan merujuk ke pengajuan ini.",
+			c.Number, humanizeEnum(c.Status), formatDateID(c.CreatedAt)), nil
+	}
+	var selected string
+	if m := a.mem.Get(phone); m != nil {
+		selected = m.SelectedApplication
+	}
+	a.mem.Update(phone, func(m *UserMemory) { m.ApplicationChoices = choices })
+	return formatApplicationList(intro, choices, selected), nil
+}
+
+// selectApplication mengingat pengajuan terpilih; konteks percakapan pindah dari simulasi terakhir
+func (a *AIQueryService) selectApplication(phone, number string) {
+	a.mem.Update(phone, func(m *UserMemory) {
+		m.SelectedApplication = number
+		m.LastSimulation = nil
+	})
+}
+
+// rememberMentionedApplication: nomor pengajuan yang disebut di pesan menjadi pengajuan terpilih,
+// sama seperti memilihnya dari "list pengajuan"
+func (a *AIQueryService) rememberMentionedApplication(phone, text string) {
+	if app := extractAppNumber(text); app != "" {
+		a.selectApplication(phone, app)
+	}
+}
+
+// selectedApplicationFields mengembalikan atribut yang ditanyakan; nil bila bukan pertanyaan lanjutan
+func selectedApplicationFields(text string) map[string]bool {
+	var out map[string]bool
+	for _, p := range selectedFieldPatterns {
+		if p.re.MatchString(text) {
+			if out == nil {
+				out = map[string]bool{}
+			}
+			out[p.field] = true
+		}
+	}
+	return out
+}
+
+// answerSelectedApplication menjawab pertanyaan lanjutan tanpa nomor ("cicilannya berapa?") dari
+// pengajuan terpilih. Teks yang membawa angka/tanggal (mis. simulasi baru) tidak ditangani di sini.
+func (a *AIQueryService) answerSelectedApplication(ctx context.Context, phone, text string, intent domain.Intent) (string, bool) {
+	followUp := selectedFollowUpIntents[intent] || (intent == domain.IntentRateInfo && selectedRatePattern.MatchString(text))
+	if a.db == nil || strings.TrimSpace(phone) == "" || !followUp || extractAppNumber(text) != "" {
+		return "", false
+	}
+	m := a.mem.Get(phone)
+	if m == nil || m.SelectedApplication == "" {
+		return "", false
+	}
+	fields := selectedApplicationFields(text)
+	if fields == nil || len(nlu.Extract(text, time.Now())) > 0 {
+		return "", false
+	}
+	rows, err := a.db.Query(ctx, selectedApplicationQuery, m.SelectedApplication, phone)
+	if err != nil {
+		log.Printf("[AI] selected application error: %v", err)
+		return "", false
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			log.Printf("[AI] selected application error: %v", err)
+			return "", false
+		}
+		return fmt.Sprintf("Pengajuan %s tidak ditemukan untuk nomor WhatsApp ini. Kirim *list pengajuan* untuk memilih lagi.", m.SelectedApplication), true
+	}
+	var d selectedApplication
+	if err := rows.Scan(&d.Number, &d.Status, &d.Loan, &d.Installment, &d.TenorYears, &d.Rate, &d.DownPayment, &d.CreatedAt); err != nil {
+		log.Printf("[AI] selected application error: %v", err)
+		return "", false
+	}
+	return formatSelectedApplication(d, fields), true
+}
+
+// selectedApplication: data pengajuan milik pengirim untuk pertanyaan lanjutan
+type selectedApplication struct {
+	Number, Status    string
+	Loan, Installment float64
+	TenorYears        int
+	Rate, DownPayment float64
+	CreatedAt         time.Time
+}
+
+// formatSelectedApplication hanya menampilkan atribut yang ditanyakan ("all" = ringkasan lengkap)
+func formatSelectedApplication(d selectedApplication, fields map[string]bool) string {
+	all := fields["all"]
+	var parts []string
+	if all || fields["status"] {
+		parts = append(parts, "status "+humanizeEnum(d.Status))
+	}
+	if all || fields["loan"] {
+		parts = append(parts, "plafon "+formatRupiah(d.Loan))
+	}
+	if all || fields["installment"] {
+		parts = append(parts, "angsuran "+formatRupiah(d.Installment)+"/bulan")
+	}
+	if all || fields["tenor"] || fields["installment"] {
+		parts = append(parts, fmt.Sprintf("tenor %d tahun", d.TenorYears))
+	}
+	if all || fields["rate"] {
+		parts = append(parts, "bunga "+formatRateFraction(d.Rate))
+	}
+	if all || fields["dp"] {
+		parts = append(parts, "uang muka "+formatRupiah(d.DownPayment))
+	}
+	return fmt.Sprintf("Pengajuan *%s*: %s.", d.Number, strings.Join(parts, ", "))
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestApplicationListPattern(t *testing.T) {
+	cases := map[string]bool{
+		"list pengajuan":                true,
+		"List pengajuan KPR saya?":      true,
+		"daftar pengajuan kpr":          true,
+		"lihat semua pengajuan":         true,
+		"pengajuan saya apa saja":       true,
+		"daftar kpr":                    false,
+		"status pengajuan KPR-2025-001": false,
+		"ajukan kpr":                    false,
+	}
+	for text, want := range cases {
+		if got := applicationListPattern.MatchString(text); got != want {
+			t.Errorf("%q: got %v", text, got)
+		}
+	}
+	if intakeStartPattern.MatchString("daftar pengajuan kpr") && !applicationListPattern.MatchString("daftar pengajuan kpr") {
+		t.Fatalf("list command must win over intake start")
+	}
+}
+
+func TestMatchApplicationChoice(t *testing.T) {
+	choices := []ApplicationChoice{{Number: "KPR-2026-002"}, {Number: "KPR-2025-001"}}
+	cases := []struct {
+		text  string
+		idx   int
+		found bool
+	}{
+		{"2", 1, true},
+		{"pilih 1", 0, true},
+		{"nomor 2.", 1, true},
+		{"yang ke-2", 1, true},
+		{"yang pertama", 0, true},
+		{"kpr-2025-001", 1, true},
+		{"3", -1, true},
+		{"KPR-2024-777", 0, false},
+		{"cicilannya berapa?", 0, false},
+		{"simulasi 2 miliar 20 tahun", 0, false},
+	}
+	for _, c := range cases {
+		idx, found := matchApplicationChoice(c.text, choices)
+		if idx != c.idx || found != c.found {
+			t.Errorf("%q: got idx=%d found=%v", c.text, idx, found)
+		}
+	}
+}
+
+func TestFormatApplicationList(t *testing.T) {
+	choices := []ApplicationChoice{
+		{Number: "KPR-2026-002", Status: "CREDIT_ANALYSIS", CreatedAt: time.Date(2026, 3, 2, 9, 0, 0, 0, wib)},
+		{Number: "KPR-2025-001", Status: "APPROVED", CreatedAt: time.Date(2025, 11, 20, 9, 0, 0, 0, wib)},
+	}
+	got := formatApplicationList("📋 *Pengajuan KPR kamu*:", choices, "KPR-2025-001")
+	for _, want := range []string{"1. KPR-2026-002 — credit analysis — 2 Maret 2026\n", "2. KPR-2025-001 — approved — 20 November 2025 ✅", "Balas nomor urut"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestAnswerApplicationList_ChoiceIsRemembered(t *testing.T) {
+	a := &AIQueryService{mem: NewMemoryStore()} // tanpa DB: pilihan dibaca dari memori
+	choices := []ApplicationChoice{{Number: "KPR-2026-002", Status: "SUBMITTED"}, {Number: "KPR-2025-001", Status: "APPROVED"}}
+	a.mem.Update("628123", func(m *UserMemory) {
+		m.ApplicationChoices = choices
+		m.LastSimulation = &domain.SimulationRequest{PropertyPrice: 500_000_000}
+	})
+
+	reply, ok := a.answerApplicationList(context.Background(), "628123", "5")
+	if !ok || reply != "Pilih nomor 1–2 ya." {
+		t.Fatalf("out of range reply=%q ok=%v", reply, ok)
+	}
+	reply, ok = a.answerApplicationList(context.Background(), "628123", "yang kedua")
+	if !ok || !strings.Contains(reply, "*KPR-2025-001*") || !strings.Contains(reply, "cicilannya berapa?") {
+		t.Fatalf("choice reply=%q ok=%v", reply, ok)
+	}
+	m := a.mem.Get("628123")
+	if m.SelectedApplication != "KPR-2025-001" || m.ApplicationChoices != nil || m.LastSimulation != nil {
+		t.Fatalf("memory=%+v", m)
+	}
+	if got := a.applicationFor("628123", "timeline pengajuan saya"); got != "KPR-2025-001" {
+		t.Fatalf("applicationFor selected=%q", got)
+	}
+	if got := a.applicationFor("628123", "timeline KPR-2026-002"); got != "KPR-2026-002" {
+		t.Fatalf("explicit number must win, got %q", got)
+	}
+
+	// daftar hanya berlaku untuk satu balasan: angka berikutnya bukan pilihan lagi
+	if reply, ok := a.answerApplicationList(context.Background(), "628123", "1"); ok {
+		t.Fatalf("stale choice handled: %q", reply)
+	}
+	if reply, _ := a.answerApplicationList(context.Background(), "", "list pengajuan"); !strings.Contains(reply, "WhatsApp") {
+		t.Fatalf("anonymous list reply=%q", reply)
+	}
+}
+
+func TestAnswerSchedule_UsesSelectedApplication(t *testing.T) {
+	calc, wa := &fakeScheduleCalculator{}, &fakeDocSender{}
+	a := &AIQueryService{calculator: calc, whatsapp: wa, mem: NewMemoryStore()}
+	a.selectApplication("628123", "KPR-2025-001")
+	if _, ok := a.answerSchedule(context.Background(), "628123", "kirim jadwal angsurannya"); !ok || calc.gotApp != "KPR-2025-001" {
+		t.Fatalf("app=%q", calc.gotApp)
+	}
+}
+
+func TestAnswerSchedule_MentionedApplicationReplacesLastSimulation(t *testing.T) {
+	calc, wa := &fakeScheduleCalculator{}, &fakeDocSender{}
+	a := &AIQueryService{calculator: calc, whatsapp: wa, mem: NewMemoryStore()}
+	a.mem.Update("628123", func(m *UserMemory) {
+		m.LastSimulation = &domain.SimulationRequest{PropertyPrice: 500_000_000, DownPayment: 100_000_000, TenorYears: 15}
+	})
+	a.rememberMentionedApplication("628123", "KPR-2025-001 statusnya?")
+	if _, ok := a.answerSchedule(context.Background(), "628123", "jadwal angsurannya"); !ok || calc.gotApp != "KPR-2025-001" {
+		t.Fatalf("schedule must follow the mentioned application, app=%q", calc.gotApp)
+	}
+}
+
+func TestFormatSelectedApplication(t *testing.T) {
+	d := selectedApplication{Number: "KPR-2025-001", Status: "APPROVED", Loan: 400_000_000, Installment: 3_500_000,
+		TenorYears: 20, Rate: 0.0725, DownPayment: 100_000_000}
+	fields := selectedApplicationFields("cicilannya berapa?")
+	if got := formatSelectedApplication(d, fields); got != "Pengajuan *KPR-2025-001*: angsuran Rp 3.500.000/bulan, tenor 20 tahun." {
+		t.Errorf("installment reply=%q", got)
+	}
+	got := formatSelectedApplication(d, selectedApplicationFields("rinciannya dong"))
+	for _, want := range []string{"status approved", "plafon Rp 400.000.000", "uang muka Rp 100.000.000", "bunga "} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in %q", want, got)
+		}
+	}
+	if selectedApplicationFields("halo") != nil {
+		t.Errorf("greeting must not be a follow-up")
+	}
+}
+
+func TestSummarizeGeneral_ListsEveryApplication(t *testing.T) {
+	rs := &domain.ResultSet{
+		Columns: []domain.ResultColumn{{Name: "application_number"}, {Name: "status"}},
+		Rows:    [][]interface{}{{"KPR-2026-002", "SUBMITTED"}, {"KPR-2025-001", "APPROVED"}},
+	}
+	got := summarizeGeneral("", rs)
+	if !strings.Contains(got, "Ditemukan 2 pengajuan") || !strings.Contains(got, "KPR-2025-001 (APPROVED)") || !strings.Contains(got, "list pengajuan") {
+		t.Fatalf("summarizeGeneral=%q", got)
+	}
+}
+
+// cannedDriver adalah driver database/sql minimal: setiap query dijawab baris tetap berdasarkan
+// potongan teks SQL-nya, cukup untuk menguji alur percakapan tanpa PostgreSQL.
+type cannedDriver struct{ rows map[string][][]driver.Value }
+
+type cannedConn struct{ d *cannedDriver }
+
+type cannedRows struct {
+	vals [][]driver.Value
+	i    int
+}
+
+func (d *cannedDriver) Open(string) (driver.Conn, error) { return &cannedConn{d}, nil }
+
+func (c *cannedConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *cannedConn) Close() error                        { return nil }
+func (c *cannedConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (c *cannedConn) QueryContext(_ context.Context, q string, _ []driver.NamedValue) (driver.Rows, error) {
+	for frag, vals := range c.d.rows {
+		if strings.Contains(q, frag) {
+			return &cannedRows{vals: vals}, nil
+		}
+	}
+	return &cannedRows{}, nil
+}
+
+func (r *cannedRows) Columns() []string {
+	if len(r.vals) == 0 {
+		return nil
+	}
+	return make([]string, len(r.vals[0]))
+}
+func (r *cannedRows) Close() error { return nil }
+func (r *cannedRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.vals) {
+		return io.EOF
+	}
+	copy(dest, r.vals[r.i])
+	r.i++
+	return nil
+}
+
+// cannedDB membungkus *sql.DB sebagai domain.DatabaseService
+type cannedDB struct{ db *sql.DB }
+
+func (c *cannedDB) Query(ctx context.Context, q string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.QueryContext(ctx, q, args...)
+}
+func (c *cannedDB) Exec(ctx context.Context, q string, args ...interface{}) (sql.Result, error) {
+	return c.db.ExecContext(ctx, q, args...)
+}
+func (c *cannedDB) WithTx(context.Context, func(*sql.Tx) error) error { return driver.ErrSkip }
+func (c *cannedDB) Close() error                                      { return c.db.Close() }
+
+func newCannedDB(t *testing.T, rows map[string][][]driver.Value) *cannedDB {
+	t.Helper()
+	d := &cannedDriver{rows: rows}
+	name := "canned-" + t.Name()
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &cannedDB{db: db}
+}
+
+func TestAnswerWithDBForUser_SelectionSurvivesRegistrationLookup(t *testing.T) {
+	created := time.Date(2025, 11, 20, 9, 0, 0, 0, wib)
+	db := newCannedDB(t, map[string][][]driver.Value{
+		"ORDER BY a.created_at DESC": {
+			{"KPR-2026-002", "SUBMITTED", created},
+			{"KPR-2025-001", "APPROVED", created},
+		},
+		"FROM users u JOIN roles r": {{int64(7), "budi", "budi@example.com", "ACTIVE", "2025-01-01", "user"}},
+		"FROM user_profiles":        {{"Budi", "Karyawan"}},
+		"a.application_number = $1": {{"KPR-2025-001", "APPROVED", 400_000_000.0, 3_500_000.0, int64(20), 0.0725, 100_000_000.0, created}},
+	})
+	a := &AIQueryService{db: db, mem: NewMemoryStore(), intents: NewIntentClassifier("")}
+	ctx := context.Background()
+
+	turns := []struct{ text, want string }{
+		{"list pengajuan", "KPR-2025-001"},
+		{"2", "*KPR-2025-001*"},
+		// pesan umum pertama melewati pencarian registrasi; pilihan pengajuan tidak boleh hilang
+		{"apa itu kpr?", ""},
+		{"cicilannya berapa?", "angsuran Rp 3.500.000/bulan"},
+	}
+	for _, tr := range turns {
+		reply, err := a.AnswerWithDBForUser(ctx, "628123", tr.text, "")
+		if err != nil {
+			t.Fatalf("%q: %v", tr.text, err)
+		}
+		if !strings.Contains(reply, tr.want) {
+			t.Fatalf("%q: reply=%q", tr.text, reply)
+		}
+	}
+	if m := a.mem.Get("628123"); !m.Registered || m.Role != "user" || m.SelectedApplication != "KPR-2025-001" {
+		t.Fatalf("memory=%+v", m)
+	}
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// ErrWorkflowChanged: tahap sudah diputus/dialihkan sejak OTP diminta
+var ErrWorkflowChanged = errors.New("tahap persetujuan sudah berubah")
+
+// approvalMaxOTPAttempts: kode OTP salah sebanyak ini membatalkan aksi yang menunggu konfirmasi
+const approvalMaxOTPAttempts = 3
+
+// staffCommand adalah hasil parse pesan staf
+type staffCommand struct {
+	Kind      string // list | approve | reject | otp | cancel
+	AppNumber string
+	Notes     string
+	Code      string
+}
+
+var (
+	staffDecisionPattern = regexp.MustCompile(`(?is)^\s*(setujui|approve|tolak|reject)\s+(KPR-[A-Z0-9-]+)\b[\s,.:;-]*(?:(?:alasan|catatan|karena)\s*:?\s*)?(.*)$`)
+	staffListPattern     = regexp.MustCompile(`(?i)^\s*(?:list|daftar|lihat)?\s*(?:pending|antrian|antrean|tugas)(?:\s+(?:saya|aku|persetujuan|approval))?\s*$`)
+	otpCodePattern       = regexp.MustCompile(`^\s*(\d{6})\s*$`)
+)
+
+// parseStaffCommand mengenali perintah staf; ok=false untuk pesan lain
+func parseStaffCommand(text string) (staffCommand, bool) {
+	if m := staffDecisionPattern.FindStringSubmatch(text); m != nil {
+		kind := "approve"
+		if v := strings.ToLower(m[1]); v == "tolak" || v == "reject" {
+			kind = "reject"
+		}
+		return staffCommand{Kind: kind, AppNumber: strings.ToUpper(m[2]), Notes: strings.TrimSpace(m[3])}, true
+	}
+	if staffListPattern.MatchString(text) {
+		return staffCommand{Kind: "list"}, true
+	}
+	if m := otpCodePattern.FindStringSubmatch(text); m != nil {
+		return staffCommand{Kind: "otp", Code: m[1]}, true
+	}
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "batal", "batalkan", "cancel":
+		return staffCommand{Kind: "cancel"}, true
+	}
+	return staffCommand{}, false
+}
+
+// workflowItem adalah satu tahap approval_workflow yang terbuka untuk staf
+type workflowItem struct {
+	ID            int
+	ApplicationID int
+	AppNumber     string
+	Stage         string
+	Status        string
+	Priority      string
+	Due           *time.Time
+}
+
+// pendingDecision: aksi staf yang menunggu konfirmasi OTP
+type pendingDecision struct {
+	UserID    int
+	Action    string // approve | reject
+	Notes     string
+	Item      workflowItem
+	ExpiresAt time.Time
+	Attempts  int
+}
+
+// ApprovalService menjalankan aksi setujui/tolak staf atas approval_workflow. Satu-satunya jalur tulis
+// bot: query statis berparameter, konfirmasi OTP, transaksi, dan jejak audit append-only.
+type ApprovalService struct {
+	db       domain.DatabaseService
+	otp      domain.OTPService
+	whatsapp domain.WhatsAppService
+	otpTTL   time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]*pendingDecision // key: phone
+}
+
+func NewApprovalService(db domain.DatabaseService, otp domain.OTPService, whatsapp domain.WhatsAppService, otpTTL time.Duration) domain.CommandHandler {
+	if otpTTL <= 0 {
+		otpTTL = 5 * time.Minute
+	}
+	return &ApprovalService{db: db, otp: otp, whatsapp: whatsapp, otpTTL: otpTTL, now: time.Now, pending: map[string]*pendingDecision{}}
+}
+
+func (s *ApprovalService) HandleCommand(ctx context.Context, phone, text string) (string, bool) {
+	cmd, ok := parseStaffCommand(text)
+	if !ok || strings.TrimSpace(phone) == "" {
+		return "", false
+	}
+	// Kode OTP dan "batal" hanya berarti sesuatu bila ada aksi yang menunggu konfirmasi
+	if cmd.Kind == "otp" || cmd.Kind == "cancel" {
+		s.mu.Lock()
+		pd := s.pending[phone]
+		s.mu.Unlock()
+		if pd == nil {
+			return "", false
+		}
+		if cmd.Kind == "cancel" {
+			s.clearPending(phone)
+			return fmt.Sprintf("Aksi untuk %s dibatalkan.", pd.Item.AppNumber), true
+		}
+		return s.confirm(ctx, phone, pd, cmd.Code), true
+	}
+
+	userID, err := s.staffUserID(ctx, phone)
+	if err != nil {
+		log.Printf("[APPROVAL] staff lookup error: %v", err)
+		return "", false
+	}
+	if userID == 0 {
+		return "", false // bukan staf aktif: perlakukan sebagai pesan biasa
+	}
+	if cmd.Kind == "list" {
+		items, err := s.openItems(ctx, userID, "")
+		if err != nil {
+			log.Printf("[APPROVAL] list error: %v", err)
+			return "Maaf, daftar persetujuan belum bisa diambil saat ini.", true
+		}
+		return formatPendingItems(items, s.now()), true
+	}
+	return s.request(ctx, phone, userID, cmd), true
+}
+
+// request memvalidasi perintah lalu mengirim OTP; keputusan baru ditulis setelah OTP dikonfirmasi
+func (s *ApprovalService) request(ctx context.Context, phone string, userID int, cmd staffCommand) string {
+	if cmd.Kind == "reject" && cmd.Notes == "" {
+		return fmt.Sprintf("Penolakan wajib disertai alasan. Contoh: *tolak %s alasan DSR melebihi batas*.", cmd.AppNumber)
+	}
+	items, err := s.openItems(ctx, userID, cmd.AppNumber)
+	if err != nil {
+		log.Printf("[APPROVAL] lookup error: %v", err)
+		return "Maaf, data persetujuan belum bisa diambil saat ini."
+	}
+	if len(items) == 0 {
+		return fmt.Sprintf("Tidak ada tahap persetujuan %s yang sedang ditugaskan ke kamu.", cmd.AppNumber)
+	}
+	item := items[0]
+	otp, err := s.otp.GenerateOTP(ctx, phone, int(s.otpTTL.Seconds()))
+	if err != nil {
+		log.Printf("[APPROVAL] otp error: %v", err)
+		return "Maaf, kode OTP gagal dibuat. Coba lagi sebentar lagi."
+	}
+	verb := actionVerb(cmd.Kind)
+	if err := s.whatsapp.SendMessage(ctx, phone, fmt.Sprintf("Kode OTP untuk %s %s: *%s*. Berlaku %d menit. Jangan bagikan kode ini.",
+		verb, item.AppNumber, otp.Code, int(s.otpTTL.Minutes()))); err != nil {
+		log.Printf("[APPROVAL] send otp error: %v", err)
+		return "Maaf, kode OTP gagal dikirim. Coba lagi sebentar lagi."
+	}
+	s.mu.Lock()
+	s.pending[phone] = &pendingDecision{UserID: userID, Action: cmd.Kind, Notes: cmd.Notes, Item: item, ExpiresAt: s.now().Add(s.otpTTL)}
+	s.mu.Unlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "Konfirmasi: *%s* tahap *%s* pengajuan *%s*.", verb, humanizeEnum(item.Stage), item.AppNumber)
+	if cmd.Notes != "" {
+		fmt.Fprintf(&b, "\nCatatan: %s", cmd.Notes)
+	}
+	b.WriteString("\nBalas dengan kode OTP 6 digit yang baru dikirim, atau *batal*.")
+	return b.String()
+}
+
+// confirm memvalidasi OTP lalu menjalankan keputusan
+func (s *ApprovalService) confirm(ctx context.Context, phone string, pd *pendingDecision, code string) string {
+	if s.now().After(pd.ExpiresAt) {
+		s.clearPending(phone)
+		return "Kode OTP sudah kedaluwarsa. Kirim ulang perintahnya untuk meminta kode baru."
+	}
+	res, err := s.otp.ValidateOTP(ctx, phone, code)
+	if err != nil || res == nil || !res.Valid {
+		s.mu.Lock()
+		pd.Attempts++
+		exhausted := pd.Attempts >= approvalMaxOTPAttempts
+		if exhausted {
+			delete(s.pending, phone)
+		}
+		s.mu.Unlock()
+		if exhausted {
+			return "Kode OTP salah terlalu banyak. Aksi dibatalkan; kirim ulang perintahnya untuk meminta kode baru."
+		}
+		return "Kode OTP salah. Coba lagi."
+	}
+	s.clearPending(phone)
+	err = s.decide(ctx, phone, pd)
+	switch {
+	case errors.Is(err, ErrWorkflowChanged):
+		return fmt.Sprintf("Tahap %s sudah diproses atau dialihkan sebelum konfirmasi. Tidak ada perubahan.", pd.Item.AppNumber)
+	case err != nil:
+		log.Printf("[APPROVAL] decide error: %v", err)
+		return "Maaf, keputusan gagal disimpan. Tidak ada perubahan; silakan coba lagi."
+	}
+	log.Printf("[APPROVAL] workflow %d %s by user %d", pd.Item.ID, pd.Action, pd.UserID)
+	if pd.Action == "reject" {
+		return fmt.Sprintf("✅ Tahap *%s* pengajuan *%s* ditolak dan tercatat di audit.", humanizeEnum(pd.Item.Stage), pd.Item.AppNumber)
+	}
+	return fmt.Sprintf("✅ Tahap *%s* pengajuan *%s* disetujui dan tercatat di audit.", humanizeEnum(pd.Item.Stage), pd.Item.AppNumber)
+}
+
+func (s *ApprovalService) clearPending(phone string) {
+	s.mu.Lock()
+	delete(s.pending, phone)
+	s.mu.Unlock()
+}
+
+func actionVerb(kind string) string {
+	if kind == "reject" {
+		return "tolak"
+	}
+	return "setujui"
+}
+
+// staffUserID: users.id untuk nomor WhatsApp yang terdaftar sebagai branch_staff aktif (0 bila bukan staf)
+func (s *ApprovalService) staffUserID(ctx context.Context, phone string) (int, error) {
+	rows, err := s.db.Query(ctx, `SELECT u.id FROM users u JOIN branch_staff b ON b.user_id = u.id
+WHERE u.phone = $1 AND COALESCE(b.is_active, true) AND (b.end_date IS NULL OR b.end_date >= CURRENT_DATE)
+LIMIT 1`, phone)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var id int
+	if rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+	}
+	return id, rows.Err()
+}
+
+// openWorkflowStatuses: status approval_workflow yang masih bisa diputus
+const openWorkflowStatuses = `('PENDING', 'IN_PROGRESS')`
+
+const staffOpenItemsQuery = `SELECT w.id, w.application_id, a.application_number, w.stage::text, COALESCE(w.status::text, 'PENDING'),
+	COALESCE(w.priority::text, 'NORMAL'), w.due_date
+FROM approval_workflow w JOIN kpr_applications a ON a.id = w.application_id
+WHERE (w.assigned_to = $1 OR w.escalated_to = $1) AND w.completed_at IS NULL
+	AND COALESCE(w.status::text, 'PENDING') IN ` + openWorkflowStatuses + `
+	AND ($2 = '' OR a.application_number = $2)
+ORDER BY w.due_date NULLS LAST, w.id
+LIMIT 20`
+
+// openItems: tahap terbuka yang ditugaskan (atau dieskalasi) ke staf, opsional untuk satu pengajuan
+func (s *ApprovalService) openItems(ctx context.Context, userID int, appNumber string) ([]workflowItem, error) {
+	rows, err := s.db.Query(ctx, staffOpenItemsQuery, userID, appNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []workflowItem
+	for rows.Next() {
+		var it workflowItem
+		var due sql.NullTime
+		if err := rows.Scan(&it.ID, &it.ApplicationID, &it.AppNumber, &it.Stage, &it.Status, &it.Priority, &due); err != nil {
+			return nil, err
+		}
+		if due.Valid {
+			t := due.Time
+			it.Due = &t
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// decide menulis keputusan dan jejak audit dalam satu transaksi. Baris dikunci dan penugasan
+// diperiksa ulang, sehingga keputusan ganda atau tahap yang sudah dialihkan tidak tertimpa.
+func (s *ApprovalService) decide(ctx context.Context, phone string, pd *pendingDecision) error {
+	newStatus := "APPROVED"
+	if pd.Action == "reject" {
+		newStatus = "REJECTED"
+	}
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var prev string
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE(status::text, 'PENDING') FROM approval_workflow
+WHERE id = $1 AND (assigned_to = $2 OR escalated_to = $2) AND completed_at IS NULL
+	AND COALESCE(status::text, 'PENDING') IN `+openWorkflowStatuses+`
+FOR UPDATE`, pd.Item.ID, pd.UserID).Scan(&prev)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrWorkflowChanged
+		}
+		if err != nil {
+			return err
+		}
+		if pd.Action == "reject" {
+			_, err = tx.ExecContext(ctx, `UPDATE approval_workflow SET status = 'REJECTED', completed_at = LOCALTIMESTAMP,
+	approval_notes = $2, rejection_reason = $2, updated_at = LOCALTIMESTAMP WHERE id = $1`, pd.Item.ID, pd.Notes)
+		} else {
+			_, err = tx.ExecContext(ctx, `UPDATE approval_workflow SET status = 'APPROVED', completed_at = LOCALTIMESTAMP,
+	approval_notes = COALESCE(NULLIF($2, ''), approval_notes), updated_at = LOCALTIMESTAMP WHERE id = $1`, pd.Item.ID, pd.Notes)
+		}
+		if err != nil {
+			return err
+		}
+		action := "APPROVE"
+		if pd.Action == "reject" {
+			action = "REJECT"
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO approval_audit_log
+	(workflow_id, application_id, application_number, stage, actor_user_id, actor_phone, action, previous_status, new_status, notes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))`,
+			pd.Item.ID, pd.Item.ApplicationID, pd.Item.AppNumber, pd.Item.Stage, pd.UserID, phone, action, prev, newStatus, pd.Notes)
+		return err
+	})
+}
+
+// formatPendingItems merender antrian persetujuan staf
+func formatPendingItems(items []workflowItem, now time.Time) string {
+	if len(items) == 0 {
+		return "Tidak ada tahap persetujuan yang menunggu kamu saat ini. 👍"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Antrian persetujuan kamu (%d)*\n", len(items))
+	// due_date adalah timestamp tanpa zona (waktu dinding WIB); bandingkan dengan jam dinding WIB
+	wall := wibWallClock(now)
+	for i, it := range items {
+		fmt.Fprintf(&b, "%d. *%s* — %s, prioritas %s", i+1, it.AppNumber, humanizeEnum(it.Stage), strings.ToUpper(it.Priority))
+		if it.Due != nil {
+			if it.Due.Before(wall) {
+				fmt.Fprintf(&b, ", ⚠️ lewat jatuh tempo %s", formatDateTimeID(*it.Due))
+			} else {
+				fmt.Fprintf(&b, ", jatuh tempo %s", formatDateTimeID(*it.Due))
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\nKetik *setujui KPR-XXXX* atau *tolak KPR-XXXX alasan ...*; setiap keputusan dikonfirmasi dengan OTP.")
+	return b.String()
+}
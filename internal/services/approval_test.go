@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestParseStaffCommand(t *testing.T) {
+	cases := []struct {
+		text string
+		ok   bool
+		want staffCommand
+	}{
+		{"setujui KPR-2025-001", true, staffCommand{Kind: "approve", AppNumber: "KPR-2025-001"}},
+		{"Setujui kpr-2025-001 catatan: dokumen lengkap", true, staffCommand{Kind: "approve", AppNumber: "KPR-2025-001", Notes: "dokumen lengkap"}},
+		{"tolak KPR-2025-002 alasan DSR di atas 40%", true, staffCommand{Kind: "reject", AppNumber: "KPR-2025-002", Notes: "DSR di atas 40%"}},
+		{"tolak KPR-2025-002, karena slip gaji tidak valid", true, staffCommand{Kind: "reject", AppNumber: "KPR-2025-002", Notes: "slip gaji tidak valid"}},
+		{"tolak KPR-2025-002", true, staffCommand{Kind: "reject", AppNumber: "KPR-2025-002"}},
+		{"pending", true, staffCommand{Kind: "list"}},
+		{"list pending", true, staffCommand{Kind: "list"}},
+		{"antrian persetujuan", true, staffCommand{Kind: "list"}},
+		{" 123456 ", true, staffCommand{Kind: "otp", Code: "123456"}},
+		{"batal", true, staffCommand{Kind: "cancel"}},
+		{"status KPR-2025-001", false, staffCommand{}},
+		{"kenapa pengajuan saya ditolak", false, staffCommand{}},
+		{"12345", false, staffCommand{}},
+		{"setujui saja", false, staffCommand{}},
+	}
+	for _, c := range cases {
+		got, ok := parseStaffCommand(c.text)
+		if ok != c.ok || got != c.want {
+			t.Fatalf("parseStaffCommand(%q)=%+v,%v; want %+v,%v", c.text, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+type fakeOTP struct {
+	domain.OTPService
+	code string
+}
+
+func (f *fakeOTP) ValidateOTP(ctx context.Context, phone, code string) (*domain.OTPValidateResponse, error) {
+	return &domain.OTPValidateResponse{Phone: phone, Valid: code == f.code}, nil
+}
+
+func TestApprovalService_ConfirmWithoutDecision(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, wib)
+	newSvc := func() *ApprovalService {
+		s := NewApprovalService(nil, &fakeOTP{code: "654321"}, nil, 5*time.Minute).(*ApprovalService)
+		s.now = func() time.Time { return now }
+		s.pending["628111"] = &pendingDecision{UserID: 3, Action: "approve", Item: workflowItem{ID: 9, AppNumber: "KPR-2025-001"}, ExpiresAt: now.Add(time.Minute)}
+		return s
+	}
+	ctx := context.Background()
+
+	// tanpa aksi yang menunggu, kode 6 digit bukan perintah staf
+	s := newSvc()
+	if _, ok := s.HandleCommand(ctx, "628999", "123456"); ok {
+		t.Fatalf("otp without pending action must not be handled")
+	}
+
+	// salah tiga kali membatalkan aksi
+	for i := 1; i <= approvalMaxOTPAttempts; i++ {
+		reply, ok := s.HandleCommand(ctx, "628111", "000000")
+		if !ok {
+			t.Fatalf("attempt %d not handled", i)
+		}
+		if i < approvalMaxOTPAttempts && !strings.Contains(reply, "salah. Coba lagi") {
+			t.Fatalf("attempt %d reply=%q", i, reply)
+		}
+		if i == approvalMaxOTPAttempts && !strings.Contains(reply, "dibatalkan") {
+			t.Fatalf("final attempt reply=%q", reply)
+		}
+	}
+	if _, ok := s.HandleCommand(ctx, "628111", "654321"); ok {
+		t.Fatalf("pending action must be cleared after too many attempts")
+	}
+
+	s = newSvc()
+	if reply, ok := s.HandleCommand(ctx, "628111", "batal"); !ok || !strings.Contains(reply, "KPR-2025-001 dibatalkan") {
+		t.Fatalf("cancel reply=%q ok=%v", reply, ok)
+	}
+
+	s = newSvc()
+	now = now.Add(2 * time.Minute)
+	if reply, _ := s.HandleCommand(ctx, "628111", "654321"); !strings.Contains(reply, "kedaluwarsa") {
+		t.Fatalf("expired reply=%q", reply)
+	}
+}
+
+func TestFormatPendingItems(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, wib)
+	past := time.Date(2026, 10, 15, 17, 0, 0, 0, time.UTC)
+	future := time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC)
+	out := formatPendingItems([]workflowItem{
+		{AppNumber: "KPR-2025-001", Stage: "CREDIT_ANALYSIS", Priority: "HIGH", Due: &past},
+		{AppNumber: "KPR-2025-002", Stage: "FINAL_APPROVAL", Priority: "normal", Due: &future},
+	}, now)
+	for _, want := range []string{"(2)", "1. *KPR-2025-001* — credit analysis, prioritas HIGH, ⚠️ lewat jatuh tempo", "2. *KPR-2025-002* — final approval, prioritas NORMAL, jatuh tempo 16 Oktober 2026 pukul 17.00", "OTP"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in %q", want, out)
+		}
+	}
+	if out := formatPendingItems(nil, now); !strings.Contains(out, "Tidak ada") {
+		t.Fatalf("empty=%q", out)
+	}
+}
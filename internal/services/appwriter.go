@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// ApplicationWriter membuat pengajuan DRAFT dari chat. Hanya query statis berparameter; setiap
+// baris yang dibuat dicatat di application_audit_log dalam transaksi yang sama.
+type ApplicationWriter struct {
+	db  domain.DatabaseService
+	now func() time.Time
+}
+
+func NewApplicationWriter(db domain.DatabaseService) domain.ApplicationWriteService {
+	return &ApplicationWriter{db: db, now: time.Now}
+}
+
+// validateDraft memeriksa ulang data sebelum ditulis (pertahanan kedua setelah alur chat)
+func validateDraft(app domain.DraftApplication) error {
+	var missing []string
+	for name, v := range map[string]string{
+		"property_type": app.PropertyType, "purpose": app.Purpose,
+		"property_certificate_type": app.PropertyCertificateType, "property_address": app.PropertyAddress,
+	} {
+		if strings.TrimSpace(v) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("draft incomplete: %s", strings.Join(missing, ", "))
+	}
+	switch {
+	case app.UserID <= 0:
+		return fmt.Errorf("draft without user")
+	case app.PropertyValue <= 0 || app.LoanAmount <= 0 || app.LoanAmount >= app.PropertyValue:
+		return fmt.Errorf("loan amount must be positive and below property value")
+	case app.TenorYears <= 0 || app.KPRRateID <= 0 || app.InterestRate <= 0 || app.MonthlyInstallment <= 0:
+		return fmt.Errorf("draft without product terms")
+	case len(app.DeveloperName) > 100:
+		return fmt.Errorf("developer name too long")
+	}
+	return nil
+}
+
+const insertDraftQuery = `INSERT INTO kpr_applications (application_number, user_id, kpr_rate_id, property_type, property_value,
+	loan_amount, loan_term_years, interest_rate, monthly_installment, down_payment, property_address,
+	property_certificate_type, developer_name, purpose, status, ltv_ratio)
+VALUES ($1, $2, $3, $4::application_property_type, $5, $6, $7, $8, $9, $10, $11,
+	$12::property_certificate_type, NULLIF($13, ''), $14::application_purpose, 'DRAFT', $15)
+RETURNING id`
+
+func (w *ApplicationWriter) CreateDraft(ctx context.Context, app domain.DraftApplication) (string, error) {
+	if err := validateDraft(app); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(app)
+	if err != nil {
+		return "", err
+	}
+	var number string
+	err = w.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var seq int64
+		if err := tx.QueryRowContext(ctx, "SELECT nextval('bot_application_number_seq')").Scan(&seq); err != nil {
+			return err
+		}
+		number = fmt.Sprintf("KPR-WA-%d-%06d", w.now().In(wib).Year(), seq)
+		var id int
+		if err := tx.QueryRowContext(ctx, insertDraftQuery, number, app.UserID, app.KPRRateID, app.PropertyType, app.PropertyValue,
+			app.LoanAmount, app.TenorYears, app.InterestRate, app.MonthlyInstallment, app.PropertyValue-app.LoanAmount, app.PropertyAddress,
+			app.PropertyCertificateType, app.DeveloperName, app.Purpose, app.LoanAmount/app.PropertyValue).Scan(&id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO application_audit_log (application_id, application_number, actor_user_id, actor_phone, action, payload)
+VALUES ($1, $2, $3, $4, 'CREATE_DRAFT', $5)`, id, number, app.UserID, app.Phone, string(payload))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	log.Printf("[INTAKE] draft %s created for user %d", number, app.UserID)
+	return number, nil
+}
@@ -59,6 +59,24 @@ func (d *DatabaseService) Exec(ctx context.Context, query string, args ...interf
 	return res, nil
 }
 
+func (d *DatabaseService) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if d.db == nil {
+		return fmt.Errorf("database not available")
+	}
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("[DB] Rollback error: %v", rbErr)
+		}
+		return err
+	}
+	log.Printf("[DB] Tx commit")
+	return tx.Commit()
+}
+
 func (d *DatabaseService) Close() error {
 	if d.db != nil {
 		return d.db.Close()
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// indonesiaZones: zona waktu yang bisa dipilih kepala cabang untuk jam kirim ringkasan
+var indonesiaZones = map[string]*time.Location{
+	"WIB":  wib,
+	"WITA": time.FixedZone("WITA", 8*3600),
+	"WIT":  time.FixedZone("WIT", 9*3600),
+}
+
+func zoneFor(name string) *time.Location {
+	if loc, ok := indonesiaZones[strings.ToUpper(strings.TrimSpace(name))]; ok {
+		return loc
+	}
+	return wib
+}
+
+// branchDigest adalah angka ringkasan satu cabang untuk satu tanggal lokal
+type branchDigest struct {
+	BranchCode  string
+	Date        time.Time // tanggal yang diringkas (zona lokal penerima)
+	Timezone    string
+	New         int
+	Submitted   int
+	Approved    int
+	Rejected    int
+	Overdue     int // snapshot saat ringkasan dibuat
+	OverdueHigh int // bagian dari Overdue dengan prioritas HIGH/URGENT
+	Unassigned  int // pengajuan baru semua cabang yang belum punya tahap workflow (belum bisa dikaitkan ke cabang)
+}
+
+// digestWindow: rentang [awal, akhir) tanggal lokal date dalam jam dinding WIB, karena kolom
+// timestamp di DB disimpan tanpa zona dalam WIB
+func digestWindow(date time.Time, loc *time.Location) (time.Time, time.Time) {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	return wibWallClock(start), wibWallClock(start.AddDate(0, 0, 1))
+}
+
+// digestDue: ringkasan hari lokal now sudah waktunya dikirim bila jam lokal >= sendAt (menit sejak
+// tengah malam). Mengembalikan tanggal lokal hari ini (kunci de-duplikasi) dan tanggal yang diringkas (kemarin).
+func digestDue(now time.Time, loc *time.Location, sendAt int) (today, covered time.Time, ok bool) {
+	local := now.In(loc)
+	if local.Hour()*60+local.Minute() < sendAt {
+		return time.Time{}, time.Time{}, false
+	}
+	today = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return today, today.AddDate(0, 0, -1), true
+}
+
+// formatDigest merender ringkasan cabang untuk WhatsApp
+func formatDigest(d branchDigest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Ringkasan harian cabang %s*\n", d.BranchCode)
+	fmt.Fprintf(&b, "%s\n\n", formatDateID(d.Date))
+	fmt.Fprintf(&b, "• Pengajuan baru: %d\n", d.New)
+	fmt.Fprintf(&b, "• Diajukan (submitted): %d\n", d.Submitted)
+	fmt.Fprintf(&b, "• Disetujui: %d\n", d.Approved)
+	fmt.Fprintf(&b, "• Ditolak: %d\n", d.Rejected)
+	if d.Unassigned > 0 {
+		fmt.Fprintf(&b, "• Pengajuan baru belum masuk workflow (semua cabang): %d\n", d.Unassigned)
+	}
+	b.WriteString("_Angka cabang hanya mencakup pengajuan yang sudah punya tahap workflow di cabang ini._\n")
+	if d.Overdue > 0 {
+		fmt.Fprintf(&b, "\n⚠️ Tahap persetujuan lewat jatuh tempo saat ini: *%d*", d.Overdue)
+		if d.OverdueHigh > 0 {
+			fmt.Fprintf(&b, " (%d prioritas HIGH/URGENT)", d.OverdueHigh)
+		}
+		b.WriteString("\n")
+	} else {
+		b.WriteString("\n✅ Tidak ada tahap persetujuan yang lewat jatuh tempo.\n")
+	}
+	fmt.Fprintf(&b, "_Zona waktu %s. Ketik *berhenti ringkasan* untuk berhenti berlangganan._", d.Timezone)
+	return b.String()
+}
+
+// digestCommand adalah perintah langganan ringkasan dari kepala cabang
+type digestCommand struct {
+	Kind     string // subscribe | unsubscribe | now
+	Timezone string // kosong = tidak diubah
+}
+
+var (
+	digestSubscribePattern   = regexp.MustCompile(`(?i)^\s*(?:langganan|berlangganan|aktifkan)\s+ringkasan(?:\s+(?:harian|cabang))?(?:\s+(WIB|WITA|WIT))?\s*$`)
+	digestUnsubscribePattern = regexp.MustCompile(`(?i)^\s*(?:berhenti|stop|matikan)\s+(?:langganan\s+)?ringkasan(?:\s+(?:harian|cabang))?\s*$`)
+	digestNowPattern         = regexp.MustCompile(`(?i)^\s*ringkasan\s+(?:harian|cabang)\s*$`)
+)
+
+func parseDigestCommand(text string) (digestCommand, bool) {
+	if m := digestSubscribePattern.FindStringSubmatch(text); m != nil {
+		return digestCommand{Kind: "subscribe", Timezone: strings.ToUpper(m[1])}, true
+	}
+	if digestUnsubscribePattern.MatchString(text) {
+		return digestCommand{Kind: "unsubscribe"}, true
+	}
+	if digestNowPattern.MatchString(text) {
+		return digestCommand{Kind: "now"}, true
+	}
+	return digestCommand{}, false
+}
+
+// managerPositionFilter: posisi branch_staff yang dianggap kepala cabang
+const managerPositionFilter = `(b."position"::text ILIKE '%MANAGER%' OR b."position"::text ILIKE 'KEPALA%')`
+
+// activeStaffFilter: baris branch_staff yang masih berlaku
+const activeStaffFilter = `COALESCE(b.is_active, true) AND (b.end_date IS NULL OR b.end_date >= CURRENT_DATE)`
+
+// BranchDigestService mengirim ringkasan harian per branch_code ke kepala cabang yang berlangganan
+type BranchDigestService struct {
+	db       domain.DatabaseService
+	whatsapp domain.WhatsAppService
+	sendAt   int // menit sejak tengah malam, zona lokal penerima
+}
+
+// NewBranchDigestService: sendAt berformat HH:MM (jam lokal masing-masing penerima)
+func NewBranchDigestService(db domain.DatabaseService, whatsapp domain.WhatsAppService, sendAt string) (*BranchDigestService, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(sendAt))
+	if err != nil {
+		return nil, fmt.Errorf("digest time %q: want HH:MM", sendAt)
+	}
+	return &BranchDigestService{db: db, whatsapp: whatsapp, sendAt: t.Hour()*60 + t.Minute()}, nil
+}
+
+// Job: diperiksa tiap menit; setiap penerima mendapat satu ringkasan per tanggal lokalnya
+func (s *BranchDigestService) Job() Job {
+	return Job{Name: "branch-digest", Interval: time.Minute, Run: s.runDue}
+}
+
+type digestRecipient struct {
+	UserID     int
+	Phone      string
+	Timezone   string
+	BranchCode string
+}
+
+const digestRecipientsQuery = `SELECT s.user_id, u.phone, s.timezone, b.branch_code
+FROM digest_subscriptions s
+JOIN users u ON u.id = s.user_id
+JOIN branch_staff b ON b.user_id = s.user_id
+WHERE s.enabled AND COALESCE(u.phone, '') <> '' AND ` + activeStaffFilter + ` AND ` + managerPositionFilter + `
+ORDER BY s.user_id`
+
+func (s *BranchDigestService) runDue(ctx context.Context, now time.Time) error {
+	if !s.whatsapp.IsConnected() {
+		return nil
+	}
+	rows, err := s.db.Query(ctx, digestRecipientsQuery)
+	if err != nil {
+		return fmt.Errorf("digest recipients: %w", err)
+	}
+	var recipients []digestRecipient
+	for rows.Next() {
+		var r digestRecipient
+		if err := rows.Scan(&r.UserID, &r.Phone, &r.Timezone, &r.BranchCode); err != nil {
+			rows.Close()
+			return err
+		}
+		recipients = append(recipients, r)
+	}
+	rows.Close()
+
+	cache := map[string]*branchDigest{} // branch|zona: satu query per cabang per zona
+	for _, r := range recipients {
+		loc := zoneFor(r.Timezone)
+		today, covered, ok := digestDue(now, loc, s.sendAt)
+		if !ok {
+			continue
+		}
+		res, err := s.db.Exec(ctx, `INSERT INTO digest_log (user_id, digest_date, branch_code) VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING`, r.UserID, today.Format("2006-01-02"), r.BranchCode)
+		if err != nil {
+			return fmt.Errorf("digest log: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue // sudah terkirim hari ini
+		}
+		key := r.BranchCode + "|" + loc.String()
+		d, ok := cache[key]
+		if !ok {
+			if d, err = s.branchStats(ctx, r.BranchCode, covered, loc, now); err != nil {
+				s.unclaim(ctx, r.UserID, today)
+				log.Printf("[DIGEST] stats %s: %v", r.BranchCode, err)
+				continue
+			}
+			cache[key] = d
+		}
+		if err := s.whatsapp.SendMessage(ctx, r.Phone, formatDigest(*d)); err != nil {
+			s.unclaim(ctx, r.UserID, today)
+			log.Printf("[DIGEST] send to user %d: %v", r.UserID, err)
+		}
+	}
+	return nil
+}
+
+// unclaim menghapus log agar ringkasan dicoba lagi pada tick berikutnya
+func (s *BranchDigestService) unclaim(ctx context.Context, userID int, today time.Time) {
+	_, _ = s.db.Exec(ctx, "DELETE FROM digest_log WHERE user_id = $1 AND digest_date = $2", userID, today.Format("2006-01-02"))
+}
+
+// branchStatsQuery: pengajuan dikaitkan ke cabang lewat staf yang ditugaskan pada tahap workflow terbarunya.
+// kpr_applications tidak menyimpan cabang, sehingga pengajuan tanpa tahap workflow (DRAFT/SUBMITTED baru)
+// tidak bisa dikaitkan; jumlahnya dilaporkan terpisah untuk semua cabang.
+const branchStatsQuery = `WITH app_branch AS (
+	SELECT DISTINCT ON (w.application_id) w.application_id, b.branch_code
+	FROM approval_workflow w JOIN branch_staff b ON b.user_id = w.assigned_to
+	ORDER BY w.application_id, w.created_at DESC, b.is_active DESC NULLS LAST
+), overdue AS (
+	SELECT COALESCE(w.priority::text, 'NORMAL') AS priority
+	FROM approval_workflow w JOIN branch_staff b ON b.user_id = w.assigned_to
+	WHERE b.branch_code = $1 AND ` + activeStaffFilter + ` AND w.completed_at IS NULL
+		AND COALESCE(w.status::text, 'PENDING') IN ` + openWorkflowStatuses + ` AND w.due_date < $4
+)
+SELECT
+	COUNT(*) FILTER (WHERE a.created_at >= $2 AND a.created_at < $3),
+	COUNT(*) FILTER (WHERE a.submitted_at >= $2 AND a.submitted_at < $3),
+	COUNT(*) FILTER (WHERE a.approved_at >= $2 AND a.approved_at < $3),
+	COUNT(*) FILTER (WHERE a.rejected_at >= $2 AND a.rejected_at < $3),
+	(SELECT COUNT(*) FROM overdue),
+	(SELECT COUNT(*) FROM overdue WHERE priority IN ('HIGH', 'URGENT')),
+	(SELECT COUNT(*) FROM kpr_applications x WHERE x.created_at >= $2 AND x.created_at < $3
+		AND NOT EXISTS (SELECT 1 FROM app_branch u WHERE u.application_id = x.id))
+FROM kpr_applications a JOIN app_branch ab ON ab.application_id = a.id
+WHERE ab.branch_code = $1`
+
+func (s *BranchDigestService) branchStats(ctx context.Context, branch string, date time.Time, loc *time.Location, now time.Time) (*branchDigest, error) {
+	start, end := digestWindow(date, loc)
+	rows, err := s.db.Query(ctx, branchStatsQuery, branch, start, end, wibWallClock(now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	d := &branchDigest{BranchCode: branch, Date: date, Timezone: zoneName(loc)}
+	if rows.Next() {
+		if err := rows.Scan(&d.New, &d.Submitted, &d.Approved, &d.Rejected, &d.Overdue, &d.OverdueHigh, &d.Unassigned); err != nil {
+			return nil, err
+		}
+	}
+	return d, rows.Err()
+}
+
+func zoneName(loc *time.Location) string {
+	name, _ := time.Date(2026, 1, 1, 0, 0, 0, 0, loc).Zone()
+	return name
+}
+
+// HandleCommand: langganan/berhenti/minta ringkasan, khusus kepala cabang aktif
+func (s *BranchDigestService) HandleCommand(ctx context.Context, phone, text string) (string, bool) {
+	cmd, ok := parseDigestCommand(text)
+	if !ok || strings.TrimSpace(phone) == "" {
+		return "", false
+	}
+	rows, err := s.db.Query(ctx, `SELECT b.user_id, b.branch_code, COALESCE(s.timezone, 'WIB')
+FROM branch_staff b JOIN users u ON u.id = b.user_id LEFT JOIN digest_subscriptions s ON s.user_id = b.user_id
+WHERE u.phone = $1 AND `+activeStaffFilter+` AND `+managerPositionFilter+`
+LIMIT 1`, phone)
+	if err != nil {
+		log.Printf("[DIGEST] manager lookup error: %v", err)
+		return "", false
+	}
+	var userID int
+	var branch, tz string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&userID, &branch, &tz)
+	}
+	rows.Close()
+	if err != nil || !found {
+		return "", false // bukan kepala cabang: perlakukan sebagai pesan biasa
+	}
+
+	switch cmd.Kind {
+	case "subscribe":
+		if cmd.Timezone != "" {
+			tz = cmd.Timezone
+		}
+		if _, err := s.db.Exec(ctx, `INSERT INTO digest_subscriptions (user_id, enabled, timezone) VALUES ($1, true, $2)
+ON CONFLICT (user_id) DO UPDATE SET enabled = true, timezone = EXCLUDED.timezone, updated_at = now()`, userID, tz); err != nil {
+			log.Printf("[DIGEST] subscribe error: %v", err)
+			return "Maaf, langganan ringkasan belum bisa disimpan saat ini.", true
+		}
+		return fmt.Sprintf("Ringkasan harian cabang %s akan dikirim setiap hari pukul %02d.%02d %s. Ketik *berhenti ringkasan* untuk berhenti.",
+			branch, s.sendAt/60, s.sendAt%60, tz), true
+	case "unsubscribe":
+		if _, err := s.db.Exec(ctx, "UPDATE digest_subscriptions SET enabled = false, updated_at = now() WHERE user_id = $1", userID); err != nil {
+			log.Printf("[DIGEST] unsubscribe error: %v", err)
+			return "Maaf, langganan ringkasan belum bisa diubah saat ini.", true
+		}
+		return "Ringkasan harian dihentikan. Ketik *langganan ringkasan* untuk mengaktifkan lagi.", true
+	}
+	// "now": ringkasan kemarin sesuai zona penerima, tanpa mencatat log pengiriman
+	loc := zoneFor(tz)
+	local := time.Now().In(loc)
+	yesterday := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -1)
+	d, err := s.branchStats(ctx, branch, yesterday, loc, time.Now())
+	if err != nil {
+		log.Printf("[DIGEST] stats %s: %v", branch, err)
+		return "Maaf, ringkasan cabang belum bisa dibuat saat ini.", true
+	}
+	return formatDigest(*d), true
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestDue(t *testing.T) {
+	sendAt := 7 * 60
+	// 2026-10-16 23:30 UTC = 17 Okt 06:30 WIB = 07:30 WITA = 08:30 WIT
+	now := time.Date(2026, 10, 16, 23, 30, 0, 0, time.UTC)
+	cases := []struct {
+		zone       string
+		ok         bool
+		today, cov string
+	}{
+		{"WIB", false, "", ""},
+		{"WITA", true, "2026-10-17", "2026-10-16"},
+		{"wit", true, "2026-10-17", "2026-10-16"},
+		{"", false, "", ""}, // zona tidak dikenal = WIB
+	}
+	for _, c := range cases {
+		today, covered, ok := digestDue(now, zoneFor(c.zone), sendAt)
+		if ok != c.ok {
+			t.Fatalf("%s: ok=%v; want %v", c.zone, ok, c.ok)
+		}
+		if ok && (today.Format("2006-01-02") != c.today || covered.Format("2006-01-02") != c.cov) {
+			t.Fatalf("%s: today=%s covered=%s", c.zone, today, covered)
+		}
+	}
+}
+
+func TestDigestWindow(t *testing.T) {
+	// tanggal lokal WIT 16 Okt = 15 Okt 22:00 s/d 16 Okt 22:00 jam dinding WIB
+	start, end := digestWindow(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), zoneFor("WIT"))
+	if want := time.Date(2026, 10, 15, 22, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Fatalf("start=%s; want %s", start, want)
+	}
+	if want := time.Date(2026, 10, 16, 22, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Fatalf("end=%s; want %s", end, want)
+	}
+	start, _ = digestWindow(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), zoneFor("WIB"))
+	if want := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Fatalf("WIB start=%s; want %s", start, want)
+	}
+}
+
+func TestFormatDigest(t *testing.T) {
+	d := branchDigest{BranchCode: "JKT-01", Date: time.Date(2026, 10, 16, 0, 0, 0, 0, wib), Timezone: "WIB",
+		New: 4, Submitted: 3, Approved: 2, Rejected: 1, Overdue: 5, OverdueHigh: 2, Unassigned: 3}
+	out := formatDigest(d)
+	for _, want := range []string{"cabang JKT-01", "16 Oktober 2026", "Pengajuan baru: 4", "Disetujui: 2", "Ditolak: 1", "*5* (2 prioritas HIGH/URGENT)", "Zona waktu WIB",
+		"sudah punya tahap workflow", "belum masuk workflow (semua cabang): 3"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in %q", want, out)
+		}
+	}
+	d.Overdue, d.OverdueHigh, d.Unassigned = 0, 0, 0
+	if out := formatDigest(d); strings.Contains(out, "belum masuk workflow") {
+		t.Fatalf("zero unassigned must be omitted: %q", out)
+	}
+	if out := formatDigest(d); !strings.Contains(out, "Tidak ada tahap persetujuan yang lewat") {
+		t.Fatalf("no overdue=%q", out)
+	}
+}
+
+func TestParseDigestCommand(t *testing.T) {
+	cases := []struct {
+		text string
+		ok   bool
+		want digestCommand
+	}{
+		{"langganan ringkasan", true, digestCommand{Kind: "subscribe"}},
+		{"Langganan ringkasan harian wita", true, digestCommand{Kind: "subscribe", Timezone: "WITA"}},
+		{"aktifkan ringkasan WIT", true, digestCommand{Kind: "subscribe", Timezone: "WIT"}},
+		{"berhenti ringkasan", true, digestCommand{Kind: "unsubscribe"}},
+		{"stop langganan ringkasan harian", true, digestCommand{Kind: "unsubscribe"}},
+		{"ringkasan cabang", true, digestCommand{Kind: "now"}},
+		{"ringkasan pengajuan saya", false, digestCommand{}},
+		{"langganan ringkasan WITB", false, digestCommand{}},
+	}
+	for _, c := range cases {
+		got, ok := parseDigestCommand(c.text)
+		if ok != c.ok || got != c.want {
+			t.Fatalf("parseDigestCommand(%q)=%+v,%v; want %+v,%v", c.text, got, ok, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// ErrUnknownDocument: kode dokumen tidak ada di checklist pengajuan
+var ErrUnknownDocument = errors.New("dokumen tidak ada di checklist pengajuan")
+
+// Sumber catatan application_documents
+const (
+	DocumentSourceWhatsApp = "WHATSAPP"
+	DocumentSourceStaff    = "STAFF"
+)
+
+// documentRequirement adalah satu baris document_requirements (Purpose/CertificateType kosong = semua)
+type documentRequirement struct {
+	Code            string
+	Label           string
+	Keywords        []string
+	Purpose         string
+	CertificateType string
+}
+
+// applicableRequirements memilih syarat yang berlaku untuk tujuan dan sertifikat pengajuan.
+// Untuk kode yang sama, baris paling spesifik menang; urutan input (sort_order) dipertahankan.
+func applicableRequirements(reqs []documentRequirement, purpose, cert string) []documentRequirement {
+	specificity := func(r documentRequirement) int {
+		n := 0
+		if r.Purpose != "" {
+			n++
+		}
+		if r.CertificateType != "" {
+			n++
+		}
+		return n
+	}
+	best := map[string]int{}
+	var order []string
+	for i, r := range reqs {
+		if r.Purpose != "" && !strings.EqualFold(r.Purpose, purpose) {
+			continue
+		}
+		if r.CertificateType != "" && !strings.EqualFold(r.CertificateType, cert) {
+			continue
+		}
+		j, seen := best[r.Code]
+		if !seen {
+			order = append(order, r.Code)
+		}
+		if !seen || specificity(r) > specificity(reqs[j]) {
+			best[r.Code] = i
+		}
+	}
+	out := make([]documentRequirement, 0, len(order))
+	for _, code := range order {
+		out = append(out, reqs[best[code]])
+	}
+	return out
+}
+
+// buildChecklist menggabungkan syarat yang berlaku dengan dokumen yang sudah diterima (fungsi murni)
+func buildChecklist(number, purpose, cert string, reqs []documentRequirement, received map[string]domain.DocumentChecklistItem) *domain.DocumentChecklist {
+	c := &domain.DocumentChecklist{ApplicationNumber: number, Purpose: purpose, CertificateType: cert, Missing: []string{}}
+	for _, r := range applicableRequirements(reqs, purpose, cert) {
+		item := domain.DocumentChecklistItem{Code: r.Code, Label: r.Label}
+		if got, ok := received[r.Code]; ok {
+			item.Received, item.Source, item.ReceivedAt = true, got.Source, got.ReceivedAt
+			item.Pending = got.Source == DocumentSourceWhatsApp
+		} else {
+			c.Missing = append(c.Missing, r.Code)
+		}
+		c.Items = append(c.Items, item)
+	}
+	return c
+}
+
+// normalizeDocText: huruf kecil, non-alfanumerik jadi spasi, diapit spasi untuk pencocokan per kata
+func normalizeDocText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return " " + strings.Join(strings.Fields(b.String()), " ") + " "
+}
+
+// matchDocument mencari dokumen yang disebut pada caption/teks. Kata kunci yang tercakup kata kunci
+// dokumen lain yang lebih panjang diabaikan; bila masih ada dua dokumen berbeda, hasilnya ambigu.
+func matchDocument(text string, reqs []documentRequirement) (documentRequirement, bool) {
+	if app := extractAppNumber(text); app != "" {
+		text = strings.ReplaceAll(strings.ToUpper(text), app, " ")
+	}
+	norm := normalizeDocText(text)
+	longest := map[string]string{} // kode -> kata kunci terpanjang yang cocok
+	byCode := map[string]documentRequirement{}
+	for _, r := range reqs {
+		for _, kw := range append([]string{strings.ReplaceAll(r.Code, "_", " ")}, r.Keywords...) {
+			k := strings.TrimSpace(normalizeDocText(kw))
+			if k != "" && strings.Contains(norm, " "+k+" ") && len(k) > len(longest[r.Code]) {
+				longest[r.Code], byCode[r.Code] = k, r
+			}
+		}
+	}
+	var found []documentRequirement
+	for code, k := range longest {
+		covered := false
+		for other, longer := range longest {
+			covered = covered || (other != code && len(longer) > len(k) && strings.Contains(" "+longer+" ", " "+k+" "))
+		}
+		if !covered {
+			found = append(found, byCode[code])
+		}
+	}
+	if len(found) != 1 {
+		return documentRequirement{}, false
+	}
+	return found[0], true
+}
+
+// FormatDocumentChecklist merender checklist untuk WhatsApp
+func FormatDocumentChecklist(c *domain.DocumentChecklist) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📄 Dokumen pengajuan *%s*:\n", c.ApplicationNumber)
+	pending := 0
+	for _, it := range c.Items {
+		switch {
+		case it.Pending:
+			pending++
+			fmt.Fprintf(&b, "⏳ %s (menunggu verifikasi petugas)\n", it.Label)
+		case it.Received:
+			fmt.Fprintf(&b, "✅ %s\n", it.Label)
+		default:
+			fmt.Fprintf(&b, "⬜ %s\n", it.Label)
+		}
+	}
+	if len(c.Items) == 0 {
+		b.WriteString("Belum ada checklist dokumen untuk pengajuan ini.")
+		return b.String()
+	}
+	if len(c.Missing) == 0 && pending == 0 {
+		b.WriteString("\nSemua dokumen sudah kami terima 🎉")
+		return b.String()
+	}
+	if len(c.Missing) == 0 {
+		fmt.Fprintf(&b, "\nSemua dokumen sudah dikirim; %d masih menunggu verifikasi petugas.", pending)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\nMasih kurang %d dokumen. Kirim foto/PDF-nya di chat ini dengan caption nama dokumen (contoh: *slip gaji*).", len(c.Missing))
+	return b.String()
+}
+
+// missingLabels: label dokumen yang belum diterima
+func missingLabels(c *domain.DocumentChecklist) []string {
+	var out []string
+	for _, it := range c.Items {
+		if !it.Received {
+			out = append(out, it.Label)
+		}
+	}
+	return out
+}
+
+// DocumentChecklistService melayani checklist dokumen: REST, intent chat, unggahan WhatsApp
+// nasabah, dan perintah staf untuk menandai dokumen diterima
+type DocumentChecklistService struct {
+	db domain.DatabaseService
+}
+
+func NewDocumentChecklistService(db domain.DatabaseService) *DocumentChecklistService {
+	return &DocumentChecklistService{db: db}
+}
+
+const documentApplicationQuery = `SELECT a.id, a.application_number, a.purpose::text, a.property_certificate_type::text
+FROM kpr_applications a JOIN users u ON u.id = a.user_id
+WHERE ($1 = '' OR a.application_number = $1) AND ($2 = '' OR u.phone = $2)
+ORDER BY a.created_at DESC
+LIMIT 1`
+
+// documentApp adalah pengajuan beserta syarat dokumen yang berlaku
+type documentApp struct {
+	ID        int
+	Checklist *domain.DocumentChecklist
+	Reqs      []documentRequirement
+}
+
+func (s *DocumentChecklistService) load(ctx context.Context, applicationNumber, phone string) (*documentApp, error) {
+	applicationNumber = strings.ToUpper(strings.TrimSpace(applicationNumber))
+	phone = strings.TrimSpace(phone)
+	if applicationNumber == "" && phone == "" {
+		return nil, ErrApplicationNotFound
+	}
+	rows, err := s.db.Query(ctx, documentApplicationQuery, applicationNumber, phone)
+	if err != nil {
+		return nil, fmt.Errorf("kpr_applications: %w", err)
+	}
+	var id int
+	var number, purpose, cert string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&id, &number, &purpose, &cert)
+	} else {
+		err = rows.Err()
+	}
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrApplicationNotFound
+	}
+
+	rows, err = s.db.Query(ctx, `SELECT document_code, label, keywords, COALESCE(purpose, ''), COALESCE(certificate_type, '')
+FROM document_requirements WHERE is_active ORDER BY sort_order, id`)
+	if err != nil {
+		return nil, fmt.Errorf("document_requirements: %w", err)
+	}
+	var all []documentRequirement
+	for rows.Next() {
+		var r documentRequirement
+		var keywords string
+		if err := rows.Scan(&r.Code, &r.Label, &keywords, &r.Purpose, &r.CertificateType); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, k := range strings.Split(keywords, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				r.Keywords = append(r.Keywords, k)
+			}
+		}
+		all = append(all, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(ctx, "SELECT document_code, source, received_at FROM application_documents WHERE application_id = $1", id)
+	if err != nil {
+		return nil, fmt.Errorf("application_documents: %w", err)
+	}
+	defer rows.Close()
+	received := map[string]domain.DocumentChecklistItem{}
+	for rows.Next() {
+		var it domain.DocumentChecklistItem
+		var at time.Time
+		if err := rows.Scan(&it.Code, &it.Source, &at); err != nil {
+			return nil, err
+		}
+		it.ReceivedAt = &at
+		received[it.Code] = it
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	reqs := applicableRequirements(all, purpose, cert)
+	return &documentApp{ID: id, Checklist: buildChecklist(number, purpose, cert, reqs, received), Reqs: reqs}, nil
+}
+
+func (s *DocumentChecklistService) Checklist(ctx context.Context, applicationNumber, phone string) (*domain.DocumentChecklist, error) {
+	app, err := s.load(ctx, applicationNumber, phone)
+	if err != nil {
+		return nil, err
+	}
+	return app.Checklist, nil
+}
+
+// MarkReceived: untuk sumber WHATSAPP pengajuan harus milik rec.Phone; sumber STAFF berasal dari
+// pemanggil tepercaya (portal, atau perintah staf yang sudah dicek penugasannya)
+func (s *DocumentChecklistService) MarkReceived(ctx context.Context, rec domain.DocumentReceipt) error {
+	owner := ""
+	switch rec.Source {
+	case DocumentSourceWhatsApp:
+		if strings.TrimSpace(rec.Phone) == "" {
+			return ErrApplicationNotFound
+		}
+		owner = rec.Phone
+	case DocumentSourceStaff:
+		if rec.ReceivedByUserID <= 0 {
+			return fmt.Errorf("received_by_user_id is required for staff receipts")
+		}
+	default:
+		return fmt.Errorf("unknown document source %q", rec.Source)
+	}
+	if strings.TrimSpace(rec.ApplicationNumber) == "" {
+		return ErrApplicationNotFound
+	}
+	app, err := s.load(ctx, rec.ApplicationNumber, owner)
+	if err != nil {
+		return err
+	}
+	return s.record(ctx, app, rec)
+}
+
+// recordDocumentQuery mencatat satu dokumen. Unggahan WhatsApp (ulang) menggantikan file dan kembali
+// menunggu verifikasi; tanda terima staf mengubah source menjadi STAFF tanpa menghapus file unggahan.
+const recordDocumentQuery = `INSERT INTO application_documents (application_id, document_code, source, received_by_user_id,
+	phone, message_id, mime_type, file_name, file_sha256, media_type, direct_path, media_key, file_enc_sha256, file_length, received_at)
+VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''),
+	NULLIF($10, ''), NULLIF($11, ''), $12, NULLIF($13, ''), NULLIF($14, 0), now())
+ON CONFLICT (application_id, document_code) DO UPDATE SET source = EXCLUDED.source,
+	received_by_user_id = EXCLUDED.received_by_user_id,
+	phone = CASE WHEN EXCLUDED.source = 'WHATSAPP' THEN EXCLUDED.phone ELSE application_documents.phone END,
+	message_id = COALESCE(EXCLUDED.message_id, application_documents.message_id),
+	mime_type = COALESCE(EXCLUDED.mime_type, application_documents.mime_type),
+	file_name = COALESCE(EXCLUDED.file_name, application_documents.file_name),
+	file_sha256 = COALESCE(EXCLUDED.file_sha256, application_documents.file_sha256),
+	media_type = COALESCE(EXCLUDED.media_type, application_documents.media_type),
+	direct_path = COALESCE(EXCLUDED.direct_path, application_documents.direct_path),
+	media_key = COALESCE(EXCLUDED.media_key, application_documents.media_key),
+	file_enc_sha256 = COALESCE(EXCLUDED.file_enc_sha256, application_documents.file_enc_sha256),
+	file_length = COALESCE(EXCLUDED.file_length, application_documents.file_length),
+	received_at = now()`
+
+func (s *DocumentChecklistService) record(ctx context.Context, app *documentApp, rec domain.DocumentReceipt) error {
+	code := strings.ToUpper(strings.TrimSpace(rec.DocumentCode))
+	known := false
+	for _, r := range app.Reqs {
+		known = known || r.Code == code
+	}
+	if !known {
+		return ErrUnknownDocument
+	}
+	_, err := s.db.Exec(ctx, recordDocumentQuery, app.ID, code, rec.Source, rec.ReceivedByUserID, rec.Phone, rec.MessageID,
+		rec.MimeType, rec.FileName, rec.FileSHA256, rec.MediaType, rec.DirectPath, rec.MediaKey, rec.FileEncSHA256, int64(rec.FileLength))
+	if err != nil {
+		return fmt.Errorf("application_documents: %w", err)
+	}
+	log.Printf("[DOCS] %s received for %s via %s", code, app.Checklist.ApplicationNumber, rec.Source)
+	for i, it := range app.Checklist.Items {
+		if it.Code == code {
+			app.Checklist.Items[i].Received, app.Checklist.Items[i].Source = true, rec.Source
+			app.Checklist.Items[i].Pending = rec.Source == DocumentSourceWhatsApp
+			app.Checklist.Missing = removeString(app.Checklist.Missing, code)
+		}
+	}
+	return nil
+}
+
+func removeString(list []string, v string) []string {
+	out := list[:0]
+	for _, s := range list {
+		if s != v {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// HandleMedia mencatat unggahan dokumen nasabah (menunggu verifikasi petugas). Caption menyebut jenis dokumen (dan opsional nomor
+// pengajuan; default pengajuan terbaru milik pengirim). Pengirim tanpa pengajuan diabaikan seperti sebelumnya.
+func (s *DocumentChecklistService) HandleMedia(ctx context.Context, phone string, media domain.IncomingMedia) (string, bool) {
+	number := extractAppNumber(media.Caption)
+	app, err := s.load(ctx, number, phone)
+	switch {
+	case errors.Is(err, ErrApplicationNotFound) && number != "":
+		return fmt.Sprintf("Pengajuan %s tidak ditemukan untuk nomor WhatsApp ini, jadi dokumennya belum bisa dicatat.", number), true
+	case errors.Is(err, ErrApplicationNotFound):
+		return "", false
+	case err != nil:
+		log.Printf("[DOCS] load error: %v", err)
+		return "Maaf, dokumen belum bisa dicatat saat ini. Coba kirim ulang sebentar lagi ya.", true
+	}
+	req, ok := matchDocument(media.Caption, app.Reqs)
+	if !ok {
+		msg := "Aku belum tahu ini dokumen apa. Kirim ulang dengan caption nama dokumennya, misalnya *KTP* atau *slip gaji*."
+		if missing := missingLabels(app.Checklist); len(missing) > 0 {
+			msg += "\nYang masih kurang: " + strings.Join(missing, "; ") + "."
+		}
+		return msg, true
+	}
+	err = s.record(ctx, app, domain.DocumentReceipt{
+		ApplicationNumber: app.Checklist.ApplicationNumber, DocumentCode: req.Code, Source: DocumentSourceWhatsApp,
+		Phone: phone, MessageID: media.MessageID, MimeType: media.MimeType, FileName: media.FileName, FileSHA256: media.FileSHA256,
+		MediaType: media.MediaType, DirectPath: media.DirectPath, MediaKey: media.MediaKey,
+		FileEncSHA256: media.FileEncSHA256, FileLength: media.FileLength,
+	})
+	if err != nil {
+		log.Printf("[DOCS] record error: %v", err)
+		return "Maaf, dokumen belum bisa dicatat saat ini. Coba kirim ulang sebentar lagi ya.", true
+	}
+	// belum ✅: dokumen baru dianggap diterima setelah petugas memverifikasi filenya
+	msg := fmt.Sprintf("📥 *%s* untuk pengajuan *%s* sudah kami catat dan menunggu verifikasi petugas.", req.Label, app.Checklist.ApplicationNumber)
+	if missing := missingLabels(app.Checklist); len(missing) > 0 {
+		msg += "\nMasih kurang: " + strings.Join(missing, "; ") + "."
+	} else {
+		msg += "\nSemua dokumen sudah dikirim 🎉 Petugas akan memverifikasinya."
+	}
+	return msg, true
+}
+
+var (
+	staffMarkDocumentPattern  = regexp.MustCompile(`(?i)^\s*(?:terima|tandai)\s+dokumen\s+(KPR[-A-Z0-9_]*-?[0-9]+)\s+(.+?)\s*$`)
+	staffCheckDocumentPattern = regexp.MustCompile(`(?i)^\s*cek\s+dokumen\s+(KPR[-A-Z0-9_]*-?[0-9]+)\s*$`)
+)
+
+// staffDocumentQuery: users.id staf aktif untuk nomor WA, dan apakah ia pernah ditugaskan pada pengajuan tsb
+const staffDocumentQuery = `SELECT u.id, EXISTS (SELECT 1 FROM approval_workflow w JOIN kpr_applications a ON a.id = w.application_id
+	WHERE a.application_number = $2 AND (w.assigned_to = u.id OR w.escalated_to = u.id))
+FROM users u JOIN branch_staff b ON b.user_id = u.id
+WHERE u.phone = $1 AND ` + activeStaffFilter + `
+LIMIT 1`
+
+// HandleCommand melayani staf: "cek dokumen <nomor>" dan "terima dokumen <nomor> <dokumen>".
+// Hanya staf yang ditugaskan pada workflow pengajuan tsb; selain staf, pesan diteruskan ke alur biasa.
+func (s *DocumentChecklistService) HandleCommand(ctx context.Context, phone, text string) (string, bool) {
+	var number, docText string
+	if m := staffMarkDocumentPattern.FindStringSubmatch(text); m != nil {
+		number, docText = strings.ToUpper(m[1]), m[2]
+	} else if m := staffCheckDocumentPattern.FindStringSubmatch(text); m != nil {
+		number = strings.ToUpper(m[1])
+	} else {
+		return "", false
+	}
+	rows, err := s.db.Query(ctx, staffDocumentQuery, phone, number)
+	if err != nil {
+		log.Printf("[DOCS] staff lookup error: %v", err)
+		return "", false
+	}
+	var userID int
+	var assigned bool
+	if rows.Next() {
+		err = rows.Scan(&userID, &assigned)
+	}
+	rows.Close()
+	if err != nil || userID == 0 {
+		return "", false
+	}
+	if !assigned {
+		return fmt.Sprintf("Pengajuan %s tidak ditugaskan ke kamu.", number), true
+	}
+	app, err := s.load(ctx, number, "")
+	if errors.Is(err, ErrApplicationNotFound) {
+		return fmt.Sprintf("Pengajuan %s tidak ditemukan.", number), true
+	}
+	if err != nil {
+		log.Printf("[DOCS] load error: %v", err)
+		return "Maaf, checklist dokumen belum bisa diproses saat ini.", true
+	}
+	if docText == "" {
+		return FormatDocumentChecklist(app.Checklist), true
+	}
+	req, ok := matchDocument(docText, app.Reqs)
+	if !ok {
+		codes := make([]string, 0, len(app.Reqs))
+		for _, r := range app.Reqs {
+			codes = append(codes, r.Code)
+		}
+		return "Dokumen tidak dikenali. Pilihan: " + strings.Join(codes, ", ") + ".", true
+	}
+	err = s.record(ctx, app, domain.DocumentReceipt{ApplicationNumber: number, DocumentCode: req.Code,
+		Source: DocumentSourceStaff, ReceivedByUserID: userID, Phone: phone})
+	if err != nil {
+		log.Printf("[DOCS] record error: %v", err)
+		return "Maaf, dokumen belum bisa ditandai saat ini.", true
+	}
+	return fmt.Sprintf("✅ *%s* ditandai diterima.\n\n%s", req.Label, FormatDocumentChecklist(app.Checklist)), true
+}
+
+// -------------------------
+// Checklist dokumen lewat chat nasabah
+// -------------------------
+
+// answerDocuments menjawab "dokumen apa saja yang kurang" untuk pengajuan milik nomor pengirim.
+// Tanpa pengajuan, pertanyaan diteruskan ke alur biasa (persyaratan umum dijawab dari prompt).
+func (a *AIQueryService) answerDocuments(ctx context.Context, phone, text string) (string, bool) {
+	if a.documents == nil {
+		return "", false
+	}
+	if phone == "" {
+		return "Status dokumen pengajuan hanya bisa dicek lewat chat WhatsApp dari nomor yang terdaftar.", true
+	}
+	app := a.applicationFor(phone, text)
+	c, err := a.documents.Checklist(ctx, app, phone)
+	switch {
+	case errors.Is(err, ErrApplicationNotFound) && app != "":
+		return fmt.Sprintf("Pengajuan %s tidak ditemukan untuk nomor WhatsApp ini.", app), true
+	case errors.Is(err, ErrApplicationNotFound):
+		return "", false
+	case err != nil:
+		log.Printf("[AI] document checklist error: %v", err)
+		return "Maaf, checklist dokumen belum bisa dicek saat ini.", true
+	}
+	return FormatDocumentChecklist(c), true
+}
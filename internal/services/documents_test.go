@@ -0,0 +1,114 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+var testDocumentRequirements = []documentRequirement{
+	{Code: "KTP", Label: "KTP", Keywords: []string{"ktp", "e-ktp"}},
+	{Code: "SLIP_GAJI", Label: "Slip gaji", Keywords: []string{"slip gaji", "slip", "gaji"}},
+	{Code: "REKENING_KORAN", Label: "Rekening koran", Keywords: []string{"rekening koran", "rekening"}},
+	{Code: "SERTIFIKAT", Label: "Sertifikat", Keywords: []string{"sertifikat", "shm"}},
+	{Code: "SERTIFIKAT", Label: "Sertifikat strata (SHMSRS)", Keywords: []string{"shmsrs"}, CertificateType: "STRATA_TITLE"},
+	{Code: "SPT", Label: "SPT tahunan", Keywords: []string{"spt"}, Purpose: "INVESTMENT"},
+	{Code: "PERJANJIAN_SEWA", Label: "Perjanjian sewa", Keywords: []string{"sewa"}, Purpose: "INVESTMENT", CertificateType: "SHM"},
+}
+
+func TestApplicableRequirements(t *testing.T) {
+	codes := func(reqs []documentRequirement) string {
+		var out []string
+		for _, r := range reqs {
+			out = append(out, r.Code+":"+r.Label)
+		}
+		return strings.Join(out, ",")
+	}
+	cases := []struct {
+		purpose, cert, want string
+	}{
+		{"PRIMARY_RESIDENCE", "SHM", "KTP:KTP,SLIP_GAJI:Slip gaji,REKENING_KORAN:Rekening koran,SERTIFIKAT:Sertifikat"},
+		{"PRIMARY_RESIDENCE", "STRATA_TITLE", "KTP:KTP,SLIP_GAJI:Slip gaji,REKENING_KORAN:Rekening koran,SERTIFIKAT:Sertifikat strata (SHMSRS)"},
+		{"investment", "shm", "KTP:KTP,SLIP_GAJI:Slip gaji,REKENING_KORAN:Rekening koran,SERTIFIKAT:Sertifikat,SPT:SPT tahunan,PERJANJIAN_SEWA:Perjanjian sewa"},
+		{"INVESTMENT", "SHGB", "KTP:KTP,SLIP_GAJI:Slip gaji,REKENING_KORAN:Rekening koran,SERTIFIKAT:Sertifikat,SPT:SPT tahunan"},
+	}
+	for _, c := range cases {
+		if got := codes(applicableRequirements(testDocumentRequirements, c.purpose, c.cert)); got != c.want {
+			t.Fatalf("%s/%s:\n got %s\nwant %s", c.purpose, c.cert, got, c.want)
+		}
+	}
+}
+
+func TestMatchDocument(t *testing.T) {
+	reqs := applicableRequirements(testDocumentRequirements, "PRIMARY_RESIDENCE", "SHM")
+	cases := []struct {
+		caption, want string
+		ok            bool
+	}{
+		{"KTP", "KTP", true},
+		{"ini e-KTP saya", "KTP", true},
+		{"slip gaji bulan maret", "SLIP_GAJI", true},
+		{"Rekening koran KPR-WA-2026-000001", "REKENING_KORAN", true},
+		{"scan_sertifikat_shm", "SERTIFIKAT", true},
+		{"slip-gaji", "SLIP_GAJI", true},
+		{"ktp dan slip", "", false},
+		{"foto rumah", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		got, ok := matchDocument(c.caption, reqs)
+		if ok != c.ok || (ok && got.Code != c.want) {
+			t.Fatalf("matchDocument(%q)=%s,%v; want %s,%v", c.caption, got.Code, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestBuildChecklistAndFormat(t *testing.T) {
+	received := map[string]domain.DocumentChecklistItem{
+		"KTP": {Code: "KTP", Source: DocumentSourceWhatsApp},
+		"SPT": {Code: "SPT", Source: DocumentSourceStaff}, // tidak berlaku untuk tujuan ini: diabaikan
+	}
+	c := buildChecklist("KPR-2025-001", "PRIMARY_RESIDENCE", "SHM", testDocumentRequirements, received)
+	if len(c.Items) != 4 || !c.Items[0].Received || !c.Items[0].Pending || c.Items[0].Source != DocumentSourceWhatsApp {
+		t.Fatalf("items=%+v", c.Items)
+	}
+	if strings.Join(c.Missing, ",") != "SLIP_GAJI,REKENING_KORAN,SERTIFIKAT" {
+		t.Fatalf("missing=%v", c.Missing)
+	}
+	msg := FormatDocumentChecklist(c)
+	for _, want := range []string{"*KPR-2025-001*", "⏳ KTP (menunggu verifikasi petugas)", "⬜ Slip gaji", "Masih kurang 3 dokumen"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("message missing %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "✅") {
+		t.Fatalf("unverified upload must not be shown as received:\n%s", msg)
+	}
+
+	for _, code := range c.Missing {
+		received[code] = domain.DocumentChecklistItem{Code: code, Source: DocumentSourceStaff}
+	}
+	c = buildChecklist("KPR-2025-001", "PRIMARY_RESIDENCE", "SHM", testDocumentRequirements, received)
+	if msg := FormatDocumentChecklist(c); len(c.Missing) != 0 || !strings.Contains(msg, "1 masih menunggu verifikasi") || strings.Contains(msg, "sudah kami terima") {
+		t.Fatalf("uploaded but unverified checklist: %s", msg)
+	}
+
+	received["KTP"] = domain.DocumentChecklistItem{Code: "KTP", Source: DocumentSourceStaff}
+	c = buildChecklist("KPR-2025-001", "PRIMARY_RESIDENCE", "SHM", testDocumentRequirements, received)
+	if len(c.Missing) != 0 || !strings.Contains(FormatDocumentChecklist(c), "sudah kami terima") {
+		t.Fatalf("complete checklist: %+v", c)
+	}
+}
+
+func TestStaffDocumentPatterns(t *testing.T) {
+	if m := staffMarkDocumentPattern.FindStringSubmatch("terima dokumen kpr-2025-001 slip gaji"); m == nil || m[1] != "kpr-2025-001" || m[2] != "slip gaji" {
+		t.Fatalf("mark pattern: %v", m)
+	}
+	if m := staffCheckDocumentPattern.FindStringSubmatch("Cek dokumen KPR-WA-2026-000003"); m == nil || m[1] != "KPR-WA-2026-000003" {
+		t.Fatalf("check pattern: %v", m)
+	}
+	if staffMarkDocumentPattern.MatchString("dokumen apa saja yang kurang") {
+		t.Fatalf("customer question must not look like a staff command")
+	}
+}
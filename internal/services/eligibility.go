@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/nlu"
+)
+
+// propertyTypeSynonyms memetakan kata user ke kata yang mungkin muncul pada enum kpr_property_type
+var propertyTypeSynonyms = map[string][]string{
+	"rumah":     {"rumah", "house", "landed"},
+	"apartemen": {"apartemen", "apartment", "rusun"},
+	"ruko":      {"ruko", "shophouse"},
+	"tanah":     {"tanah", "kavling", "land"},
+}
+
+// genericSegments: produk dengan segmen ini berlaku untuk semua nasabah
+var genericSegments = []string{"all", "umum", "general", "semua"}
+
+// enumMatches membandingkan nilai enum produk dengan permintaan (kosong = cocok)
+func enumMatches(productValue, want string, synonyms []string) bool {
+	pv := strings.ToLower(strings.TrimSpace(productValue))
+	want = strings.ToLower(strings.TrimSpace(want))
+	if want == "" || pv == "" {
+		return true
+	}
+	if strings.Contains(pv, want) {
+		return true
+	}
+	for _, s := range synonyms {
+		if strings.Contains(pv, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateEligibility memeriksa satu produk terhadap data pemohon (fungsi murni).
+// Alasan tidak pernah menyebut penghasilan pemohon, hanya batas produk.
+func evaluateEligibility(r domain.KPRRate, req domain.EligibilityRequest) domain.ProductEligibility {
+	pe := domain.ProductEligibility{Rate: r}
+	if req.MonthlyIncome > 0 && r.MinIncome > 0 && req.MonthlyIncome < r.MinIncome {
+		pe.Reasons = append(pe.Reasons, "penghasilan di bawah minimum produk "+formatRupiah(r.MinIncome))
+	}
+	if req.Age > 0 && r.MaxAge > 0 {
+		if req.Age+req.TenorYears > r.MaxAge {
+			if req.TenorYears > 0 {
+				pe.Reasons = append(pe.Reasons, fmt.Sprintf("usia saat lunas melebihi %d tahun", r.MaxAge))
+			} else {
+				pe.Reasons = append(pe.Reasons, fmt.Sprintf("usia maksimal %d tahun", r.MaxAge))
+			}
+		}
+	}
+	if !enumMatches(r.PropertyType, req.PropertyType, propertyTypeSynonyms[strings.ToLower(req.PropertyType)]) {
+		pe.Reasons = append(pe.Reasons, "khusus properti "+strings.ToLower(r.PropertyType))
+	}
+	if req.CustomerSegment != "" && !enumMatches(r.CustomerSegment, req.CustomerSegment, nil) {
+		generic := false
+		for _, g := range genericSegments {
+			if strings.Contains(strings.ToLower(r.CustomerSegment), g) {
+				generic = true
+			}
+		}
+		if !generic {
+			pe.Reasons = append(pe.Reasons, "khusus segmen "+strings.ToLower(r.CustomerSegment))
+		}
+	}
+	if req.PropertyPrice > 0 {
+		principal := req.PropertyPrice - req.DownPayment
+		pe.Reasons = append(pe.Reasons, simulationViolations(r, req.PropertyPrice, req.DownPayment, principal, req.TenorYears)...)
+		if req.TenorYears > 0 && principal > 0 {
+			pe.MonthlyInstallment = annuityPayment(principal, r.EffectiveRate, req.TenorYears*12)
+		}
+	} else if req.TenorYears > 0 && (r.MinTermYears > 0 && req.TenorYears < r.MinTermYears || r.MaxTermYears > 0 && req.TenorYears > r.MaxTermYears) {
+		pe.Reasons = append(pe.Reasons, fmt.Sprintf("tenor harus %d–%d tahun", r.MinTermYears, r.MaxTermYears))
+	}
+	pe.Eligible = len(pe.Reasons) == 0
+	return pe
+}
+
+// CheckEligibility mengevaluasi semua produk aktif; produk yang memenuhi syarat didahulukan
+func (s *KPRCalculatorService) CheckEligibility(ctx context.Context, req domain.EligibilityRequest) (*domain.EligibilityResult, error) {
+	if req.MonthlyIncome <= 0 && req.Age <= 0 && req.PropertyPrice <= 0 {
+		return nil, fmt.Errorf("data pemohon kosong")
+	}
+	rates, err := s.ActiveRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &domain.EligibilityResult{}
+	var failed []domain.ProductEligibility
+	for _, r := range rates {
+		pe := evaluateEligibility(r, req)
+		if pe.Eligible {
+			res.Products = append(res.Products, pe)
+		} else {
+			failed = append(failed, pe)
+		}
+	}
+	res.Products = append(res.Products, failed...)
+	return res, nil
+}
+
+// -------------------------
+// Cek kelayakan lewat chat
+// -------------------------
+
+// parseEligibilityInput mengekstrak penghasilan, usia, jenis properti, harga, DP, dan tenor.
+// Nominal berlabel penghasilan dan angka berlabel usia tidak ikut terbaca sebagai harga/tenor.
+func parseEligibilityInput(text string) domain.EligibilityRequest {
+	req := domain.EligibilityRequest{}
+	ents := nlu.Extract(text, time.Now())
+	if e, ok := nlu.First(ents, nlu.KindAmount, nlu.LabelIncome); ok {
+		req.MonthlyIncome = e.Value
+	}
+	if e, ok := nlu.First(ents, nlu.KindDuration, nlu.LabelAge); ok {
+		req.Age = e.Years()
+	}
+	low := strings.ToLower(text)
+	for _, w := range []string{"apartemen", "ruko", "tanah", "rumah"} {
+		if strings.Contains(low, w) {
+			req.PropertyType = w
+			break
+		}
+	}
+	sim := parseSimulationInput(text)
+	req.PropertyPrice, req.DownPayment, req.TenorYears = sim.Price, sim.DP, sim.TenorYears
+	return req
+}
+
+// ageAt menghitung usia penuh pada tanggal now
+func ageAt(birth, now time.Time) int {
+	age := now.Year() - birth.Year()
+	if now.YearDay() < birth.YearDay() {
+		age--
+	}
+	return age
+}
+
+// profileEligibility mengambil penghasilan dan usia dari user_profiles milik nomor ini.
+// Nilai penghasilan hanya dipakai untuk evaluasi dan tidak pernah dicatat atau ditampilkan.
+func (a *AIQueryService) profileEligibility(ctx context.Context, phone string) (float64, int, bool) {
+	if a.db == nil || strings.TrimSpace(phone) == "" {
+		return 0, 0, false
+	}
+	rows, err := a.db.Query(ctx, "SELECT up.monthly_income, up.birth_date FROM user_profiles up JOIN users u ON u.id = up.user_id WHERE u.phone = $1 LIMIT 1", phone)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer rows.Close()
+	var income sql.NullFloat64
+	var birth sql.NullTime
+	if !rows.Next() || rows.Scan(&income, &birth) != nil {
+		return 0, 0, false
+	}
+	age := 0
+	if birth.Valid {
+		age = ageAt(birth.Time, time.Now())
+	}
+	return income.Float64, age, income.Valid
+}
+
+const eligibilityHowTo = "Untuk cek kelayakan, sebutkan penghasilan per bulan dan usia. Boleh tambah harga rumah, DP, dan tenor. Contoh: *cek kelayakan gaji 15 juta umur 30 rumah 800 juta dp 20% tenor 20 tahun*."
+
+// formatEligibility merender hasil kelayakan untuk WhatsApp tanpa menyebut penghasilan pemohon
+func formatEligibility(res *domain.EligibilityResult, fromProfile bool) string {
+	var ok, fail []string
+	for _, p := range res.Products {
+		if p.Eligible {
+			line := fmt.Sprintf("• %s — bunga %s", p.Rate.RateName, formatRateFraction(p.Rate.EffectiveRate))
+			if p.MonthlyInstallment > 0 {
+				line += ", estimasi angsuran " + formatRupiah(p.MonthlyInstallment) + "/bulan"
+			}
+			ok = append(ok, line)
+		} else {
+			fail = append(fail, fmt.Sprintf("• %s: %s", p.Rate.RateName, strings.Join(p.Reasons, "; ")))
+		}
+	}
+	var b strings.Builder
+	b.WriteString("*Cek kelayakan KPR*\n")
+	if fromProfile {
+		b.WriteString("_Penghasilan dan usia diambil dari profil kamu._\n")
+	}
+	if len(ok) > 0 {
+		b.WriteString("\n✅ Memenuhi syarat:\n" + strings.Join(ok, "\n") + "\n")
+	} else {
+		b.WriteString("\nBelum ada produk aktif yang memenuhi syarat.\n")
+	}
+	if len(fail) > 0 {
+		b.WriteString("\n❌ Belum memenuhi:\n" + strings.Join(fail, "\n") + "\n")
+	}
+	b.WriteString("_Hasil indikatif; keputusan akhir mengikuti analisa kredit BNI._")
+	return b.String()
+}
+
+// answerEligibility menjawab pertanyaan kelayakan secara deterministik. Untuk user terdaftar,
+// penghasilan dan usia yang tidak disebut di chat diambil dari user_profiles.
+func (a *AIQueryService) answerEligibility(ctx context.Context, phone, text string) (string, bool) {
+	if a.calculator == nil {
+		return "", false
+	}
+	req := parseEligibilityInput(text)
+	fromProfile := false
+	if req.MonthlyIncome <= 0 || req.Age <= 0 {
+		if income, age, found := a.profileEligibility(ctx, phone); found {
+			if req.MonthlyIncome <= 0 && income > 0 {
+				req.MonthlyIncome = income
+				fromProfile = true
+			}
+			if req.Age <= 0 && age > 0 {
+				req.Age = age
+				fromProfile = true
+			}
+		}
+	}
+	if req.MonthlyIncome <= 0 {
+		return eligibilityHowTo, true
+	}
+	res, err := a.calculator.CheckEligibility(ctx, req)
+	if err != nil {
+		log.Printf("[AI] eligibility error: %v", err)
+		return "Maaf, data produk KPR belum tersedia untuk cek kelayakan saat ini.", true
+	}
+	return formatEligibility(res, fromProfile), true
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestEvaluateEligibility(t *testing.T) {
+	r := sampleRate()
+	r.MinIncome, r.MaxAge, r.PropertyType = 10000000, 65, "RUMAH"
+	cases := []struct {
+		name string
+		req  domain.EligibilityRequest
+		want []string
+	}{
+		{"eligible", domain.EligibilityRequest{MonthlyIncome: 15000000, Age: 30, PropertyType: "rumah", PropertyPrice: 800000000, DownPayment: 160000000, TenorYears: 20}, nil},
+		{"income", domain.EligibilityRequest{MonthlyIncome: 7000000, Age: 30}, []string{"penghasilan di bawah minimum produk Rp 10.000.000"}},
+		{"age at payoff", domain.EligibilityRequest{MonthlyIncome: 15000000, Age: 50, TenorYears: 20}, []string{"usia saat lunas melebihi 65 tahun"}},
+		{"property type", domain.EligibilityRequest{MonthlyIncome: 15000000, PropertyType: "apartemen"}, []string{"khusus properti rumah"}},
+		{"dp", domain.EligibilityRequest{MonthlyIncome: 15000000, PropertyPrice: 500000000, DownPayment: 25000000, TenorYears: 10}, []string{"DP minimal"}},
+	}
+	for _, c := range cases {
+		pe := evaluateEligibility(r, c.req)
+		if pe.Eligible != (len(c.want) == 0) {
+			t.Fatalf("%s: eligible=%v reasons=%v", c.name, pe.Eligible, pe.Reasons)
+		}
+		joined := strings.Join(pe.Reasons, "; ")
+		for _, w := range c.want {
+			if !strings.Contains(joined, w) {
+				t.Fatalf("%s: reasons %q missing %q", c.name, joined, w)
+			}
+		}
+	}
+}
+
+func TestFormatEligibility_NeverEchoesIncome(t *testing.T) {
+	r := sampleRate()
+	r.MinIncome = 10000000
+	req := domain.EligibilityRequest{MonthlyIncome: 7350000, Age: 30}
+	res := &domain.EligibilityResult{Products: []domain.ProductEligibility{evaluateEligibility(r, req)}}
+	out := formatEligibility(res, true)
+	if strings.Contains(out, "7.350.000") || strings.Contains(out, "7350000") {
+		t.Fatalf("income leaked: %q", out)
+	}
+	if !strings.Contains(out, r.RateName) || !strings.Contains(out, "Belum memenuhi") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestParseEligibilityInput(t *testing.T) {
+	got := parseEligibilityInput("cek kelayakan gaji 15 juta umur 30 rumah 800 juta dp 20% tenor 20 tahun")
+	want := domain.EligibilityRequest{MonthlyIncome: 15e6, Age: 30, PropertyType: "rumah", PropertyPrice: 8e8, DownPayment: 1.6e8, TenorYears: 20}
+	if got != want {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+	if got := parseEligibilityInput("penghasilan saya rp 12.500.000 usia 45"); got.MonthlyIncome != 12.5e6 || got.Age != 45 || got.PropertyPrice != 0 {
+		t.Fatalf("got %+v", got)
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// Operator filter yang didukung format Plan. Semua dikompilasi ke SQL berparameter.
+const (
+	opEq        = "="
+	opNe        = "!="
+	opGt        = ">"
+	opGte       = ">="
+	opLt        = "<"
+	opLte       = "<="
+	opBetween   = "between"
+	opIn        = "in"
+	opILike     = "ilike" // prefix match: value%
+	opIsNull    = "is_null"
+	opIsNotNull = "is_not_null"
+)
+
+// supportedFilterOps dalam urutan stabil (dipakai untuk skema JSON dan prompt)
+var supportedFilterOps = []string{opEq, opNe, opGt, opGte, opLt, opLte, opBetween, opIn, opILike, opIsNull, opIsNotNull}
+
+// maxInValues membatasi jumlah nilai pada operator IN
+const maxInValues = 50
+
+// normalizeFilterOp memetakan alias operator ke bentuk kanonik
+func normalizeFilterOp(op string) (string, bool) {
+	switch strings.ToLower(strings.Join(strings.Fields(op), " ")) {
+	case "=", "==", "eq":
+		return opEq, true
+	case "!=", "<>", "ne", "neq":
+		return opNe, true
+	case ">", "gt":
+		return opGt, true
+	case ">=", "gte":
+		return opGte, true
+	case "<", "lt":
+		return opLt, true
+	case "<=", "lte":
+		return opLte, true
+	case "between":
+		return opBetween, true
+	case "in":
+		return opIn, true
+	case "ilike", "prefix", "starts_with", "like":
+		return opILike, true
+	case "is_null", "is null", "isnull":
+		return opIsNull, true
+	case "is_not_null", "is not null", "not_null", "notnull":
+		return opIsNotNull, true
+	}
+	return "", false
+}
+
+// Jenis kolom hasil pemetaan columnTypes
+const (
+	kindText      = "text"
+	kindNumeric   = "numeric"
+	kindInteger   = "integer"
+	kindDate      = "date"
+	kindTimestamp = "timestamp"
+	kindBool      = "bool"
+	kindEnum      = "enum"
+)
+
+// baseColumnType menormalisasi tipe DDL: "numeric(15" -> "numeric", "public.rate_type" -> "rate_type"
+func baseColumnType(typ string) string {
+	t := strings.ToLower(strings.TrimSpace(typ))
+	if i := strings.Index(t, "("); i != -1 {
+		t = t[:i]
+	}
+	return strings.TrimPrefix(t, "public.")
+}
+
+// columnKind menentukan jenis kolom dari columnTypes/columnEnums
+func columnKind(table, col string) string {
+	table = strings.ToLower(strings.TrimSpace(table))
+	col = strings.ToLower(strings.TrimSpace(col))
+	if _, ok := columnEnums[table][col]; ok {
+		return kindEnum
+	}
+	raw := columnTypes[table][col]
+	switch baseColumnType(raw) {
+	case "numeric", "decimal", "float4", "float8", "real", "double":
+		return kindNumeric
+	case "int2", "int4", "int8", "int", "integer", "bigint", "smallint", "serial", "serial4", "serial8", "bigserial":
+		return kindInteger
+	case "date":
+		return kindDate
+	case "timestamp", "timestamptz":
+		return kindTimestamp
+	case "bool", "boolean":
+		return kindBool
+	case "varchar", "text", "char", "bpchar", "json", "jsonb", "":
+		return kindText
+	}
+	if strings.HasPrefix(strings.ToLower(raw), "public.") {
+		// tipe buatan user (enum) yang nilainya tidak ada di DDL
+		return kindEnum
+	}
+	return kindText
+}
+
+var dateLayouts = []string{"2006-01-02", "02-01-2006", "02/01/2006", "2006/01/02"}
+var timestampLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// coerceFilterValue mengubah nilai string dari rencana menjadi nilai bertipe sesuai kolom
+func coerceFilterValue(table, col, v string) (interface{}, error) {
+	v = strings.TrimSpace(v)
+	switch columnKind(table, col) {
+	case kindInteger:
+		n, err := strconv.ParseInt(strings.ReplaceAll(v, "_", ""), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q bukan bilangan bulat", col, v)
+		}
+		return n, nil
+	case kindNumeric:
+		f, err := strconv.ParseFloat(strings.ReplaceAll(v, "_", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q bukan angka", col, v)
+		}
+		return f, nil
+	case kindDate:
+		for _, l := range dateLayouts {
+			if t, err := time.Parse(l, v); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: %q bukan tanggal (YYYY-MM-DD)", col, v)
+	case kindTimestamp:
+		for _, l := range timestampLayouts {
+			if t, err := time.Parse(l, v); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: %q bukan timestamp", col, v)
+	case kindBool:
+		switch strings.ToLower(v) {
+		case "true", "t", "1", "ya", "yes":
+			return true, nil
+		case "false", "f", "0", "tidak", "no":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%s: %q bukan boolean", col, v)
+	case kindEnum:
+		vs, known := columnEnums[strings.ToLower(table)][strings.ToLower(col)]
+		if !known {
+			// nilai enum belum dimuat (ddl.sql maupun pg_enum): tolak daripada meneruskan nilai mentah
+			return nil, fmt.Errorf("%s: nilai enum belum diketahui, filter tidak bisa divalidasi", col)
+		}
+		for _, e := range vs {
+			if strings.EqualFold(e, v) {
+				return e, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: %q bukan salah satu dari {%s}", col, v, strings.Join(vs, ","))
+	}
+	return v, nil
+}
+
+// escapeLikePrefix meloloskan wildcard LIKE agar nilai dicocokkan sebagai awalan literal
+func escapeLikePrefix(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(v) + "%"
+}
+
+// filterValues mengembalikan daftar nilai untuk operator multi-nilai (between/in).
+// Values diutamakan; Value berisi koma dipakai sebagai cadangan.
+func filterValues(f domain.Filter) []string {
+	if len(f.Values) > 0 {
+		return f.Values
+	}
+	if strings.TrimSpace(f.Value) == "" {
+		return nil
+	}
+	parts := strings.Split(f.Value, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// compileFilter menerjemahkan satu filter menjadi potongan WHERE berparameter.
+// target adalah kolom berkualifikasi (mis. "t.status"); argIdx adalah nomor parameter berikutnya.
+func compileFilter(target, table, col string, f domain.Filter, argIdx int) (string, []interface{}, error) {
+	op, ok := normalizeFilterOp(f.Op)
+	if !ok {
+		return "", nil, fmt.Errorf("%s: operator %q tidak didukung", col, f.Op)
+	}
+	switch op {
+	case opIsNull:
+		return target + " IS NULL", nil, nil
+	case opIsNotNull:
+		return target + " IS NOT NULL", nil, nil
+	case opILike:
+		if strings.TrimSpace(f.Value) == "" {
+			return "", nil, fmt.Errorf("%s: ilike membutuhkan value", col)
+		}
+		return fmt.Sprintf("%s::text ILIKE $%d", target, argIdx), []interface{}{escapeLikePrefix(strings.TrimSpace(f.Value))}, nil
+	case opBetween:
+		vals := filterValues(f)
+		if len(vals) != 2 {
+			return "", nil, fmt.Errorf("%s: between membutuhkan tepat 2 nilai", col)
+		}
+		lo, err := coerceFilterValue(table, col, vals[0])
+		if err != nil {
+			return "", nil, err
+		}
+		hi, err := coerceFilterValue(table, col, vals[1])
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("%s BETWEEN $%d AND $%d", target, argIdx, argIdx+1), []interface{}{lo, hi}, nil
+	case opIn:
+		vals := filterValues(f)
+		if len(vals) == 0 || len(vals) > maxInValues {
+			return "", nil, fmt.Errorf("%s: in membutuhkan 1..%d nilai", col, maxInValues)
+		}
+		ph := make([]string, 0, len(vals))
+		args := make([]interface{}, 0, len(vals))
+		for i, s := range vals {
+			v, err := coerceFilterValue(table, col, s)
+			if err != nil {
+				return "", nil, err
+			}
+			ph = append(ph, fmt.Sprintf("$%d", argIdx+i))
+			args = append(args, v)
+		}
+		return fmt.Sprintf("%s IN (%s)", target, strings.Join(ph, ", ")), args, nil
+	}
+	v, err := coerceFilterValue(table, col, f.Value)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("%s %s $%d", target, op, argIdx), []interface{}{v}, nil
+}
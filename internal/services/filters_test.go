@@ -0,0 +1,75 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestBuildSafeSelect_Operators(t *testing.T) {
+	a := &AIQueryService{}
+	p := &domain.SQLPlan{
+		Operation: "SELECT",
+		Table:     "kpr_applications",
+		Columns:   []string{"application_number", "loan_amount"},
+		Filters: []domain.Filter{
+			{Column: "loan_amount", Op: ">", Value: "500000000"},
+			{Column: "submitted_at", Op: "between", Values: []string{"2026-10-01", "2026-10-31 23:59:59"}},
+			{Column: "status", Op: "in", Values: []string{"SUBMITTED", "CREDIT_ANALYSIS"}},
+			{Column: "developer_name", Op: "ilike", Value: "Griya_50%"},
+			{Column: "rejected_at", Op: "is null"},
+		},
+	}
+	q, args, err := a.buildSafeSelect(p)
+	if err != nil {
+		t.Fatalf("buildSafeSelect error: %v", err)
+	}
+	want := "SELECT application_number,loan_amount FROM kpr_applications t WHERE t.loan_amount > $1 AND t.submitted_at BETWEEN $2 AND $3 AND t.status IN ($4, $5) AND t.developer_name::text ILIKE $6 AND t.rejected_at IS NULL LIMIT 100"
+	if q != want {
+		t.Fatalf("query:\n got %s\nwant %s", q, want)
+	}
+	if len(args) != 6 {
+		t.Fatalf("args=%v; want 6", args)
+	}
+	if f, ok := args[0].(float64); !ok || f != 500000000 {
+		t.Fatalf("loan_amount arg=%#v; want float64", args[0])
+	}
+	if ts, ok := args[1].(time.Time); !ok || ts.Format("2006-01-02") != "2026-10-01" {
+		t.Fatalf("submitted_at arg=%#v; want time.Time", args[1])
+	}
+	if args[5] != `Griya\_50\%%` {
+		t.Fatalf("ilike arg=%q; want escaped prefix", args[5])
+	}
+}
+
+func TestBuildSafeSelect_InvalidValueFailsClosed(t *testing.T) {
+	a := &AIQueryService{}
+	p := &domain.SQLPlan{Operation: "SELECT", Table: "kpr_applications", Filters: []domain.Filter{{Column: "user_id", Op: "=", Value: "1 OR 1=1"}}}
+	if _, _, err := a.buildSafeSelect(p); err == nil || !strings.Contains(err.Error(), "user_id") {
+		t.Fatalf("expected coercion error for non-integer user_id, got %v", err)
+	}
+}
+
+func TestCoerceFilterValue_Enum(t *testing.T) {
+	orig := columnEnums
+	defer func() { columnEnums = orig }()
+	columnEnums = map[string]map[string][]string{"kpr_applications": {"status": {"PENDING", "REVIEW", "APPROVED"}}}
+
+	v, err := coerceFilterValue("kpr_applications", "status", "review")
+	if err != nil || v != "REVIEW" {
+		t.Fatalf("coerce enum = %v, %v; want REVIEW", v, err)
+	}
+	if _, err := coerceFilterValue("kpr_applications", "status", "LOST"); err == nil {
+		t.Fatalf("expected error for unknown enum value")
+	}
+
+	// tipe enum tanpa nilai yang diketahui: ditolak, bukan diteruskan mentah ke Postgres
+	origTypes := columnTypes
+	defer func() { columnTypes = origTypes }()
+	columnTypes = map[string]map[string]string{"kpr_applications": {"property_type": "public.application_property_type"}}
+	if _, err := coerceFilterValue("kpr_applications", "property_type", "RUMAH"); err == nil {
+		t.Fatalf("expected error for enum without known values")
+	}
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// Format tampilan berbahasa Indonesia untuk balasan bot dan konteks AI.
+// Jenis tampilan ditentukan dari columnTypes (presisi/skala) dan nama kolom.
+
+var bulanID = [...]string{"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli", "Agustus", "September", "Oktober", "November", "Desember"}
+
+// Jenis semantik kolom untuk tampilan
+const (
+	semPlain    = "plain"
+	semMoney    = "money"
+	semFraction = "fraction" // 0.0725 -> 7,25%
+	semPercent  = "percent"  // 10.00 -> 10%
+	semYears    = "years"
+	semMonths   = "months"
+	semDate     = "date"
+	semDateTime = "datetime"
+	semBool     = "bool"
+)
+
+// groupThousands menyisipkan titik sebagai pemisah ribuan: "500000000" -> "500.000.000"
+func groupThousands(digits string) string {
+	neg := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	if neg {
+		return "-" + b.String()
+	}
+	return b.String()
+}
+
+// formatNumberID memformat angka dengan maksimal dec digit desimal: 1234.5 -> "1.234,5"
+func formatNumberID(v float64, dec int) string {
+	s := strconv.FormatFloat(v, 'f', dec, 64)
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i != -1 {
+		intPart, frac = s[:i], strings.TrimRight(s[i+1:], "0")
+	}
+	out := groupThousands(intPart)
+	if frac != "" {
+		out += "," + frac
+	}
+	return out
+}
+
+// formatRupiah: 500000000 -> "Rp 500.000.000" (dibulatkan ke rupiah terdekat)
+func formatRupiah(v float64) string {
+	return "Rp " + formatNumberID(math.Round(v), 0)
+}
+
+// formatPercent: 7.25 -> "7,25%"
+func formatPercent(v float64) string {
+	return formatNumberID(v, 2) + "%"
+}
+
+// formatRateFraction: tarif disimpan sebagai pecahan, 0.0725 -> "7,25%"
+func formatRateFraction(v float64) string {
+	return formatPercent(v * 100)
+}
+
+// formatDateID: 2026-10-16 -> "16 Oktober 2026"
+func formatDateID(t time.Time) string {
+	return fmt.Sprintf("%d %s %d", t.Day(), bulanID[t.Month()-1], t.Year())
+}
+
+// formatDateTimeID menambahkan jam bila bukan tengah malam: "16 Oktober 2026 pukul 09.30"
+func formatDateTimeID(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return formatDateID(t)
+	}
+	return fmt.Sprintf("%s pukul %02d.%02d", formatDateID(t), t.Hour(), t.Minute())
+}
+
+// formatDurationID: "45 menit", "3 jam", "2 hari 5 jam"
+func formatDurationID(d time.Duration) string {
+	if d < time.Hour {
+		return fmt.Sprintf("%d menit", int(d.Minutes()))
+	}
+	days, hours := int(d.Hours())/24, int(d.Hours())%24
+	switch {
+	case days == 0:
+		return fmt.Sprintf("%d jam", hours)
+	case hours == 0:
+		return fmt.Sprintf("%d hari", days)
+	default:
+		return fmt.Sprintf("%d hari %d jam", days, hours)
+	}
+}
+
+// formatTenorMonths: 180 -> "15 tahun", 30 -> "2 tahun 6 bulan", 8 -> "8 bulan"
+func formatTenorMonths(months int) string {
+	y, m := months/12, months%12
+	switch {
+	case y > 0 && m > 0:
+		return fmt.Sprintf("%d tahun %d bulan", y, m)
+	case y > 0:
+		return fmt.Sprintf("%d tahun", y)
+	}
+	return fmt.Sprintf("%d bulan", m)
+}
+
+// numericScale mengembalikan skala dari tipe "numeric(p,s)" (-1 bila tidak diketahui)
+func numericScale(typ string) int {
+	i, j := strings.Index(typ, "("), strings.Index(typ, ")")
+	if i == -1 || j < i {
+		return -1
+	}
+	parts := strings.Split(typ[i+1:j], ",")
+	if len(parts) != 2 {
+		return -1
+	}
+	s, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return -1
+	}
+	return s
+}
+
+// aggregatePrefixes: kolom keluaran agregat (avg_loan_amount) mewarisi semantik kolom asalnya
+var aggregatePrefixes = []string{"avg_", "sum_", "min_", "max_"}
+
+// columnSemantic menentukan cara tampil kolom dari columnTypes dan nama kolom
+func columnSemantic(table, col, dbType string) string {
+	table = strings.ToLower(strings.TrimSpace(table))
+	col = strings.ToLower(strings.TrimSpace(col))
+	if _, ok := columnTypes[table][col]; !ok {
+		for _, p := range aggregatePrefixes {
+			if base := strings.TrimPrefix(col, p); base != col {
+				if _, ok := columnTypes[table][base]; ok {
+					col = base
+					break
+				}
+			}
+		}
+	}
+	raw, known := columnTypes[table][col]
+	kind := columnKind(table, col)
+	if !known {
+		switch strings.ToUpper(dbType) {
+		case "NUMERIC", "DECIMAL", "FLOAT4", "FLOAT8":
+			kind = kindNumeric
+		case "INT2", "INT4", "INT8":
+			kind = kindInteger
+		case "DATE":
+			kind = kindDate
+		case "TIMESTAMP", "TIMESTAMPTZ":
+			kind = kindTimestamp
+		case "BOOL":
+			kind = kindBool
+		default:
+			kind = kindText
+		}
+	}
+	switch kind {
+	case kindNumeric:
+		scale := numericScale(raw)
+		switch {
+		case strings.Contains(col, "percent") && scale >= 0 && scale <= 2:
+			return semPercent
+		case scale == 4 || strings.Contains(col, "rate") || strings.Contains(col, "ratio") || strings.Contains(col, "percent"):
+			return semFraction
+		case known:
+			return semMoney
+		}
+	case kindInteger:
+		switch {
+		case strings.HasSuffix(col, "_years"):
+			return semYears
+		case strings.HasSuffix(col, "_months"):
+			return semMonths
+		}
+	case kindDate:
+		return semDate
+	case kindTimestamp:
+		return semDateTime
+	case kindBool:
+		return semBool
+	}
+	return semPlain
+}
+
+// toFloat mengubah nilai numerik hasil query menjadi float64
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int64:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// formatCell memformat satu nilai sesuai semantik kolomnya
+func formatCell(table, col, dbType string, v interface{}) string {
+	if v == nil {
+		return "-"
+	}
+	sem := columnSemantic(table, col, dbType)
+	if t, ok := v.(time.Time); ok {
+		if sem == semDate {
+			return formatDateID(t)
+		}
+		return formatDateTimeID(t)
+	}
+	if b, ok := v.(bool); ok {
+		if b {
+			return "Ya"
+		}
+		return "Tidak"
+	}
+	if n, ok := v.(int64); ok && (strings.EqualFold(col, "id") || strings.HasSuffix(strings.ToLower(col), "_id")) {
+		return strconv.FormatInt(n, 10)
+	}
+	if f, ok := toFloat(v); ok {
+		switch sem {
+		case semMoney:
+			return formatRupiah(f)
+		case semFraction:
+			return formatRateFraction(f)
+		case semPercent:
+			return formatPercent(f)
+		case semYears:
+			return formatTenorMonths(int(f) * 12)
+		case semMonths:
+			return formatTenorMonths(int(f))
+		}
+		if _, isStr := v.(string); !isStr {
+			return formatNumberID(f, 2)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// formatResultValue memformat sel ResultSet berdasarkan kolom dan tabel asalnya
+func formatResultValue(rs *domain.ResultSet, row, col int) string {
+	c := rs.Columns[col]
+	return formatCell(rs.Table, c.Name, c.DBType, rs.Rows[row][col])
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatCell_IndonesianLocale(t *testing.T) {
+	cases := []struct {
+		table, col string
+		v          interface{}
+		want       string
+	}{
+		{"kpr_applications", "loan_amount", 500000000.0, "Rp 500.000.000"},
+		{"kpr_applications", "monthly_installment", 4123456.78, "Rp 4.123.457"},
+		{"kpr_applications", "interest_rate", 0.0725, "7,25%"},
+		{"kpr_rates", "effective_rate", 0.065, "6,5%"},
+		{"kpr_rates", "min_down_payment_percent", 10.0, "10%"},
+		{"kpr_rates", "max_ltv_ratio", 0.9, "90%"},
+		{"kpr_applications", "loan_term_years", int64(15), "15 tahun"},
+		{"kpr_rates", "promo_end_date", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), "16 Oktober 2026"},
+		{"kpr_applications", "submitted_at", time.Date(2026, 1, 5, 14, 7, 0, 0, time.UTC), "5 Januari 2026 pukul 14.07"},
+		{"kpr_rates", "is_active", true, "Ya"},
+		{"kpr_applications", "avg_loan_amount", 750000000.5, "Rp 750.000.001"},
+		{"kpr_applications", "user_id", int64(12345), "12345"},
+		{"kpr_applications", "application_number", "KPR-2026-001", "KPR-2026-001"},
+		{"", "count", int64(1234), "1.234"},
+	}
+	for _, c := range cases {
+		if got := formatCell(c.table, c.col, "", c.v); got != c.want {
+			t.Fatalf("%s.%s=%v: got %q want %q", c.table, c.col, c.v, got, c.want)
+		}
+	}
+}
+
+func TestFormatTenorMonths(t *testing.T) {
+	for months, want := range map[int]string{180: "15 tahun", 30: "2 tahun 6 bulan", 8: "8 bulan"} {
+		if got := formatTenorMonths(months); got != want {
+			t.Fatalf("%d: got %q want %q", months, got, want)
+		}
+	}
+}
+
+func TestFormatDurationID(t *testing.T) {
+	cases := map[time.Duration]string{
+		45 * time.Minute: "45 menit",
+		3 * time.Hour:    "3 jam",
+		48 * time.Hour:   "2 hari",
+		53 * time.Hour:   "2 hari 5 jam",
+	}
+	for d, want := range cases {
+		if got := formatDurationID(d); got != want {
+			t.Fatalf("formatDurationID(%s)=%q; want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/nlu"
+)
+
+// Langkah intake, berurutan
+const (
+	intakeStepPropertyType  = "property_type"
+	intakeStepPropertyValue = "property_value"
+	intakeStepLoanAmount    = "loan_amount"
+	intakeStepTenor         = "tenor"
+	intakeStepPurpose       = "purpose"
+	intakeStepCertificate   = "certificate"
+	intakeStepAddress       = "address"
+	intakeStepDeveloper     = "developer"
+	intakeStepConfirm       = "confirm"
+)
+
+// intakeTTL: sesi yang tidak dilanjutkan selama ini dianggap kedaluwarsa
+const intakeTTL = 24 * time.Hour
+
+// intakeEnumTypes: kolom kpr_applications yang diisi intake beserta tipe enum Postgres-nya
+var intakeEnumTypes = map[string]string{
+	"property_type":             "application_property_type",
+	"purpose":                   "application_purpose",
+	"property_certificate_type": "property_certificate_type",
+}
+
+var (
+	intakeStartPattern    = regexp.MustCompile(`(?i)\b(?:ajukan|mengajukan|daftar|buat|bikin|mulai)\s+(?:pengajuan\s+)?kpr\b|\bpengajuan\s+kpr\s+baru\b`)
+	intakeQuestionPattern = regexp.MustCompile(`(?i)\b(?:cara|bagaimana|gimana|syarat|persyaratan|berapa|apa\s+saja|bisakah)\b`)
+	plainNumberPattern    = regexp.MustCompile(`^\s*(?:rp\.?\s*)?(\d{1,3}(?:\.\d{3})+|\d+)\s*$`)
+)
+
+// intakeState adalah progres satu nasabah; disimpan sebagai JSON di intake_sessions.data
+type intakeState struct {
+	Step     string                  `json:"step"`
+	Draft    domain.DraftApplication `json:"draft"`
+	RateName string                  `json:"rate_name,omitempty"`
+}
+
+// IntakeService memandu nasabah terdaftar membuat pengajuan KPR DRAFT langkah demi langkah.
+// Setiap jawaban divalidasi terhadap kpr_rates dan enum DDL; progres disimpan di intake_sessions.
+type IntakeService struct {
+	db         domain.DatabaseService
+	calculator domain.KPRCalculatorService
+	writer     domain.ApplicationWriteService
+	// enums mengembalikan nilai enum untuk kolom kpr_applications (diganti di test)
+	enums func(ctx context.Context, column string) ([]string, error)
+
+	mu        sync.Mutex
+	enumCache map[string][]string
+}
+
+func NewIntakeService(db domain.DatabaseService, calculator domain.KPRCalculatorService, writer domain.ApplicationWriteService) domain.CommandHandler {
+	s := &IntakeService{db: db, calculator: calculator, writer: writer, enumCache: map[string][]string{}}
+	s.enums = s.enumValues
+	return s
+}
+
+// enumValues: nilai enum yang dimuat saat startup (ddl.sql/pg_enum); selain itu query pg_enum (di-cache)
+func (s *IntakeService) enumValues(ctx context.Context, column string) ([]string, error) {
+	if vs := columnEnums["kpr_applications"][column]; len(vs) > 0 {
+		return vs, nil
+	}
+	s.mu.Lock()
+	vs, ok := s.enumCache[column]
+	s.mu.Unlock()
+	if ok {
+		return vs, nil
+	}
+	enums, err := queryPgEnums(ctx, s.db, intakeEnumTypes[column])
+	if err != nil {
+		return nil, err
+	}
+	vs = enums[intakeEnumTypes[column]]
+	if len(vs) == 0 {
+		return nil, fmt.Errorf("enum %s not found", intakeEnumTypes[column])
+	}
+	s.mu.Lock()
+	s.enumCache[column] = vs
+	s.mu.Unlock()
+	return vs, nil
+}
+
+// matchEnumChoice mencocokkan jawaban user ke satu nilai enum: nomor pilihan, nama (tanpa
+// garis bawah), atau sinonim jenis properti. Jawaban yang cocok ke lebih dari satu nilai ditolak.
+func matchEnumChoice(text string, values []string) (string, bool) {
+	t := strings.ToLower(strings.TrimSpace(strings.Trim(text, ".")))
+	if t == "" {
+		return "", false
+	}
+	if n, err := strconv.Atoi(t); err == nil {
+		if n >= 1 && n <= len(values) {
+			return values[n-1], true
+		}
+		return "", false
+	}
+	norm := strings.NewReplacer("_", " ", "-", " ").Replace(t)
+	var hits []string
+	for _, v := range values {
+		hv := humanizeEnum(v)
+		if norm == hv {
+			return v, true
+		}
+		if strings.Contains(norm, hv) || (len(norm) >= 3 && strings.Contains(hv, norm)) {
+			hits = append(hits, v)
+		}
+	}
+	if len(hits) == 0 {
+		seen := map[string]bool{}
+		for key, syns := range propertyTypeSynonyms {
+			if !strings.Contains(norm, key) {
+				continue
+			}
+			for _, v := range values {
+				if !seen[v] && enumMatches(v, key, syns) {
+					seen[v] = true
+					hits = append(hits, v)
+				}
+			}
+		}
+	}
+	if len(hits) == 1 {
+		return hits[0], true
+	}
+	return "", false
+}
+
+// propertyTypeKey memetakan enum application_property_type ke kata kunci kpr_rates.property_type
+func propertyTypeKey(value string) string {
+	low := strings.ToLower(value)
+	for key, syns := range propertyTypeSynonyms {
+		for _, syn := range syns {
+			if strings.Contains(low, syn) {
+				return key
+			}
+		}
+	}
+	return humanizeEnum(value)
+}
+
+func formatEnumOptions(values []string) string {
+	var b strings.Builder
+	for i, v := range values {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, humanizeEnum(v))
+	}
+	return b.String()
+}
+
+// parseIntakeAmount membaca nominal rupiah ("850 juta", "1,2 M", "Rp 750.000.000", "delapan ratus juta")
+// atau persen ("80%")
+func parseIntakeAmount(text string) (value float64, percent bool, ok bool) {
+	if m := plainNumberPattern.FindStringSubmatch(text); m != nil {
+		v, ok := nlu.ParseAmount(m[1], "")
+		return v, false, ok
+	}
+	for _, e := range nlu.Extract(text, time.Now()) {
+		switch e.Kind {
+		case nlu.KindAmount:
+			return e.Value, false, true
+		case nlu.KindPercent:
+			return e.Value, true, true
+		}
+	}
+	return 0, false, false
+}
+
+// parseIntakeTenor membaca tenor dalam tahun ("20 tahun", "240 bulan", "lima belas tahun", "15")
+func parseIntakeTenor(text string) int {
+	if e, ok := nlu.First(nlu.Extract(text, time.Now()), nlu.KindDuration); ok {
+		return e.Years()
+	}
+	if m := plainNumberPattern.FindStringSubmatch(text); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 0
+}
+
+func isYes(text string) bool {
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(text), ".!")) {
+	case "ya", "iya", "y", "ok", "oke", "setuju", "benar", "lanjut", "yes":
+		return true
+	}
+	return false
+}
+
+// eligibleProducts menjalankan CheckEligibility untuk data intake dan memisahkan produk yang memenuhi syarat.
+// Produk tetap berurutan termurah lebih dulu.
+func (s *IntakeService) eligibleProducts(ctx context.Context, d domain.DraftApplication) ([]domain.ProductEligibility, []domain.ProductEligibility, error) {
+	res, err := s.calculator.CheckEligibility(ctx, domain.EligibilityRequest{
+		PropertyType:  propertyTypeKey(d.PropertyType),
+		PropertyPrice: d.PropertyValue,
+		DownPayment:   d.PropertyValue - d.LoanAmount,
+		TenorYears:    d.TenorYears,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var ok, failed []domain.ProductEligibility
+	for _, p := range res.Products {
+		if d.TenorYears == 0 {
+			// tenor belum ditanya: abaikan batas tenor produk
+			reasons := p.Reasons[:0:0]
+			for _, r := range p.Reasons {
+				if !strings.HasPrefix(r, "tenor ") {
+					reasons = append(reasons, r)
+				}
+			}
+			p.Reasons, p.Eligible = reasons, len(reasons) == 0
+		}
+		if p.Eligible {
+			ok = append(ok, p)
+		} else {
+			failed = append(failed, p)
+		}
+	}
+	return ok, failed, nil
+}
+
+// firstReasons: alasan produk termurah yang gagal, sebagai petunjuk perbaikan
+func firstReasons(failed []domain.ProductEligibility) string {
+	if len(failed) == 0 || len(failed[0].Reasons) == 0 {
+		return "belum ada produk KPR aktif yang sesuai"
+	}
+	return strings.Join(failed[0].Reasons, "; ")
+}
+
+// advance memproses satu jawaban untuk langkah saat ini (tanpa menulis ke DB).
+// done=true bila user mengonfirmasi ringkasan dan draf siap dibuat.
+func (s *IntakeService) advance(ctx context.Context, st *intakeState, text string) (reply string, done bool, err error) {
+	text = strings.TrimSpace(text)
+	d := &st.Draft
+	switch st.Step {
+	case intakeStepPropertyType:
+		values, err := s.enums(ctx, "property_type")
+		if err != nil {
+			return "", false, err
+		}
+		v, ok := matchEnumChoice(text, values)
+		if !ok {
+			return "Jenis properti belum dikenali. Pilih salah satu (ketik nomornya):" + formatEnumOptions(values), false, nil
+		}
+		d.PropertyType = v
+		st.Step = intakeStepPropertyValue
+		return fmt.Sprintf("Jenis properti: *%s*.\nBerapa harga/nilai properti? Contoh: *850 juta*.", humanizeEnum(v)), false, nil
+
+	case intakeStepPropertyValue:
+		v, pct, ok := parseIntakeAmount(text)
+		if !ok || pct || v < 1e7 {
+			return "Nilai properti belum terbaca. Tulis nominal rupiah, contoh: *850 juta* atau *Rp 1,2 M*.", false, nil
+		}
+		d.PropertyValue = v
+		st.Step = intakeStepLoanAmount
+		return fmt.Sprintf("Nilai properti: *%s*.\nBerapa plafon pinjaman yang diajukan? Bisa nominal (*680 juta*) atau persen dari harga (*80%%*).", formatRupiah(v)), false, nil
+
+	case intakeStepLoanAmount:
+		v, pct, ok := parseIntakeAmount(text)
+		if ok && pct {
+			v = d.PropertyValue * v / 100
+		}
+		if !ok || v <= 0 || v >= d.PropertyValue {
+			return fmt.Sprintf("Plafon pinjaman harus lebih dari 0 dan di bawah nilai properti (%s). Contoh: *680 juta* atau *80%%*.", formatRupiah(d.PropertyValue)), false, nil
+		}
+		d.LoanAmount = v
+		d.TenorYears = 0
+		eligible, failed, err := s.eligibleProducts(ctx, *d)
+		if err != nil {
+			return "", false, err
+		}
+		if len(eligible) == 0 {
+			return fmt.Sprintf("Plafon %s belum sesuai produk KPR aktif: %s. Masukkan plafon lain.", formatRupiah(v), firstReasons(failed)), false, nil
+		}
+		minT, maxT := 0, 0
+		for _, p := range eligible {
+			if minT == 0 || p.Rate.MinTermYears < minT {
+				minT = p.Rate.MinTermYears
+			}
+			if p.Rate.MaxTermYears > maxT {
+				maxT = p.Rate.MaxTermYears
+			}
+		}
+		st.Step = intakeStepTenor
+		hint := ""
+		if maxT > 0 {
+			hint = fmt.Sprintf(" (produk yang sesuai: %d–%d tahun)", minT, maxT)
+		}
+		return fmt.Sprintf("Plafon: *%s* (DP %s).\nTenor berapa tahun?%s", formatRupiah(v), formatRupiah(d.PropertyValue-v), hint), false, nil
+
+	case intakeStepTenor:
+		years := parseIntakeTenor(text)
+		if years <= 0 || years > 40 {
+			return "Tenor belum terbaca. Tulis dalam tahun, contoh: *20 tahun*.", false, nil
+		}
+		d.TenorYears = years
+		eligible, failed, err := s.eligibleProducts(ctx, *d)
+		if err != nil {
+			return "", false, err
+		}
+		if len(eligible) == 0 {
+			d.TenorYears = 0
+			return fmt.Sprintf("Tenor %d tahun belum sesuai produk KPR aktif: %s. Masukkan tenor lain.", years, firstReasons(failed)), false, nil
+		}
+		p := eligible[0] // ActiveRates terurut termurah lebih dulu
+		d.KPRRateID, d.InterestRate, d.MonthlyInstallment = p.Rate.ID, p.Rate.EffectiveRate, p.MonthlyInstallment
+		st.RateName = p.Rate.RateName
+		values, err := s.enums(ctx, "purpose")
+		if err != nil {
+			return "", false, err
+		}
+		st.Step = intakeStepPurpose
+		return fmt.Sprintf("Tenor *%d tahun* dengan produk *%s* (bunga %s, estimasi angsuran %s/bulan).\nApa tujuan pengajuan? Ketik nomornya:%s",
+			years, p.Rate.RateName, formatRateFraction(p.Rate.EffectiveRate), formatRupiah(p.MonthlyInstallment), formatEnumOptions(values)), false, nil
+
+	case intakeStepPurpose:
+		values, err := s.enums(ctx, "purpose")
+		if err != nil {
+			return "", false, err
+		}
+		v, ok := matchEnumChoice(text, values)
+		if !ok {
+			return "Tujuan belum dikenali. Pilih salah satu (ketik nomornya):" + formatEnumOptions(values), false, nil
+		}
+		d.Purpose = v
+		certs, err := s.enums(ctx, "property_certificate_type")
+		if err != nil {
+			return "", false, err
+		}
+		st.Step = intakeStepCertificate
+		return fmt.Sprintf("Tujuan: *%s*.\nJenis sertifikat properti? Ketik nomornya:%s", humanizeEnum(v), formatEnumOptions(certs)), false, nil
+
+	case intakeStepCertificate:
+		values, err := s.enums(ctx, "property_certificate_type")
+		if err != nil {
+			return "", false, err
+		}
+		v, ok := matchEnumChoice(text, values)
+		if !ok {
+			return "Jenis sertifikat belum dikenali. Pilih salah satu (ketik nomornya):" + formatEnumOptions(values), false, nil
+		}
+		d.PropertyCertificateType = v
+		st.Step = intakeStepAddress
+		return fmt.Sprintf("Sertifikat: *%s*.\nTulis alamat lengkap properti.", humanizeEnum(v)), false, nil
+
+	case intakeStepAddress:
+		if len([]rune(text)) < 10 {
+			return "Alamat terlalu singkat. Tulis alamat lengkap (jalan, nomor, kota).", false, nil
+		}
+		d.PropertyAddress = text
+		st.Step = intakeStepDeveloper
+		return "Nama developer? Ketik *-* bila properti bukan dari developer.", false, nil
+
+	case intakeStepDeveloper:
+		switch strings.ToLower(text) {
+		case "-", "tidak ada", "tidak", "bukan developer", "tanpa developer":
+			d.DeveloperName = ""
+		default:
+			if len(text) > 100 {
+				return "Nama developer maksimal 100 karakter.", false, nil
+			}
+			d.DeveloperName = text
+		}
+		st.Step = intakeStepConfirm
+		return formatIntakeSummary(st) + "\n\nKetik *ya* untuk membuat draf pengajuan, atau *batal*.", false, nil
+
+	case intakeStepConfirm:
+		if !isYes(text) {
+			return "Ketik *ya* untuk membuat draf pengajuan, atau *batal* untuk membatalkan.", false, nil
+		}
+		return "", true, nil
+	}
+	return "", false, fmt.Errorf("unknown intake step %q", st.Step)
+}
+
+// formatIntakeSummary merender data yang akan disimpan sebagai DRAFT
+func formatIntakeSummary(st *intakeState) string {
+	d := st.Draft
+	var b strings.Builder
+	b.WriteString("*Ringkasan pengajuan KPR*\n")
+	fmt.Fprintf(&b, "• Jenis properti: %s\n", humanizeEnum(d.PropertyType))
+	fmt.Fprintf(&b, "• Nilai properti: %s\n", formatRupiah(d.PropertyValue))
+	fmt.Fprintf(&b, "• Plafon: %s (DP %s)\n", formatRupiah(d.LoanAmount), formatRupiah(d.PropertyValue-d.LoanAmount))
+	fmt.Fprintf(&b, "• Tenor: %d tahun\n", d.TenorYears)
+	fmt.Fprintf(&b, "• Produk: %s, bunga %s, estimasi angsuran %s/bulan\n", st.RateName, formatRateFraction(d.InterestRate), formatRupiah(d.MonthlyInstallment))
+	fmt.Fprintf(&b, "• Tujuan: %s\n", humanizeEnum(d.Purpose))
+	fmt.Fprintf(&b, "• Sertifikat: %s\n", humanizeEnum(d.PropertyCertificateType))
+	fmt.Fprintf(&b, "• Alamat: %s\n", d.PropertyAddress)
+	if d.DeveloperName != "" {
+		fmt.Fprintf(&b, "• Developer: %s", d.DeveloperName)
+	} else {
+		b.WriteString("• Developer: -")
+	}
+	return b.String()
+}
+
+// HandleCommand memulai intake ("ajukan KPR") atau melanjutkan sesi yang sedang berjalan
+func (s *IntakeService) HandleCommand(ctx context.Context, phone, text string) (string, bool) {
+	if strings.TrimSpace(phone) == "" {
+		return "", false
+	}
+	st, err := s.load(ctx, phone)
+	if err != nil {
+		log.Printf("[INTAKE] load error: %v", err)
+		return "", false
+	}
+	if st == nil {
+		if !intakeStartPattern.MatchString(text) || intakeQuestionPattern.MatchString(text) || applicationListPattern.MatchString(text) {
+			return "", false
+		}
+		return s.start(ctx, phone), true
+	}
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "batal", "batalkan", "cancel":
+		s.delete(ctx, phone)
+		return "Pengajuan dibatalkan dan progresnya dihapus. Ketik *ajukan KPR* untuk mulai lagi.", true
+	}
+	reply, done, err := s.advance(ctx, st, text)
+	if err != nil {
+		log.Printf("[INTAKE] step %s error: %v", st.Step, err)
+		return "Maaf, jawaban belum bisa diproses saat ini. Coba lagi sebentar lagi, atau ketik *batal*.", true
+	}
+	if done {
+		st.Draft.Phone = phone
+		number, err := s.writer.CreateDraft(ctx, st.Draft)
+		if err != nil {
+			log.Printf("[INTAKE] create draft error: %v", err)
+			return "Maaf, draf pengajuan gagal disimpan. Progres kamu tetap tersimpan; ketik *ya* untuk mencoba lagi.", true
+		}
+		s.delete(ctx, phone)
+		return fmt.Sprintf("✅ Draf pengajuan *%s* sudah dibuat dengan status DRAFT. Petugas kami akan menghubungi kamu untuk kelengkapan dokumen sebelum pengajuan dikirim.", number), true
+	}
+	if err := s.save(ctx, phone, st); err != nil {
+		log.Printf("[INTAKE] save error: %v", err)
+		return "Maaf, progres pengajuan gagal disimpan. Coba kirim ulang jawabanmu.", true
+	}
+	return reply, true
+}
+
+// start membuka sesi baru untuk nasabah terdaftar
+func (s *IntakeService) start(ctx context.Context, phone string) string {
+	rows, err := s.db.Query(ctx, "SELECT id FROM users WHERE phone = $1 LIMIT 1", phone)
+	if err != nil {
+		log.Printf("[INTAKE] user lookup error: %v", err)
+		return "Maaf, pengajuan lewat chat belum bisa dimulai saat ini."
+	}
+	var userID int
+	if rows.Next() {
+		err = rows.Scan(&userID)
+	}
+	rows.Close()
+	if err != nil || userID == 0 {
+		return "Pengajuan lewat chat hanya untuk nomor WhatsApp yang sudah terdaftar sebagai nasabah. Silakan daftar dulu di portal KPR BNI, lalu ketik *ajukan KPR* lagi."
+	}
+	values, err := s.enums(ctx, "property_type")
+	if err != nil {
+		log.Printf("[INTAKE] enum error: %v", err)
+		return "Maaf, pengajuan lewat chat belum tersedia saat ini."
+	}
+	st := &intakeState{Step: intakeStepPropertyType, Draft: domain.DraftApplication{UserID: userID}}
+	if err := s.save(ctx, phone, st); err != nil {
+		log.Printf("[INTAKE] save error: %v", err)
+		return "Maaf, pengajuan lewat chat belum bisa dimulai saat ini."
+	}
+	return "Siap, aku bantu buat draf pengajuan KPR. Progres tersimpan otomatis; ketik *batal* kapan saja untuk berhenti.\n\nJenis properti apa? Ketik nomornya:" + formatEnumOptions(values)
+}
+
+func (s *IntakeService) load(ctx context.Context, phone string) (*intakeState, error) {
+	rows, err := s.db.Query(ctx, "SELECT data, updated_at FROM intake_sessions WHERE phone = $1", phone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	var data []byte
+	var updated time.Time
+	if err := rows.Scan(&data, &updated); err != nil {
+		return nil, err
+	}
+	if time.Since(updated) > intakeTTL {
+		rows.Close()
+		s.delete(ctx, phone)
+		return nil, nil
+	}
+	st := &intakeState{}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (s *IntakeService) save(ctx context.Context, phone string, st *intakeState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(ctx, `INSERT INTO intake_sessions (phone, user_id, step, data) VALUES ($1, $2, $3, $4)
+ON CONFLICT (phone) DO UPDATE SET user_id = EXCLUDED.user_id, step = EXCLUDED.step, data = EXCLUDED.data, updated_at = now()`,
+		phone, st.Draft.UserID, st.Step, string(data))
+	return err
+}
+
+func (s *IntakeService) delete(ctx context.Context, phone string) {
+	if _, err := s.db.Exec(ctx, "DELETE FROM intake_sessions WHERE phone = $1", phone); err != nil {
+		log.Printf("[INTAKE] delete session error: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// fakeRatesCalculator mengevaluasi kelayakan terhadap daftar produk tetap (tanpa DB)
+type fakeRatesCalculator struct {
+	domain.KPRCalculatorService
+	rates []domain.KPRRate
+}
+
+func (f *fakeRatesCalculator) CheckEligibility(ctx context.Context, req domain.EligibilityRequest) (*domain.EligibilityResult, error) {
+	res := &domain.EligibilityResult{}
+	var failed []domain.ProductEligibility
+	for _, r := range f.rates {
+		if pe := evaluateEligibility(r, req); pe.Eligible {
+			res.Products = append(res.Products, pe)
+		} else {
+			failed = append(failed, pe)
+		}
+	}
+	res.Products = append(res.Products, failed...)
+	return res, nil
+}
+
+var intakeTestEnums = map[string][]string{
+	"property_type":             {"RUMAH_TAPAK", "APARTEMEN", "RUKO"},
+	"purpose":                   {"PRIMARY_RESIDENCE", "INVESTMENT", "SECOND_HOME"},
+	"property_certificate_type": {"SHM", "SHGB", "STRATA_TITLE"},
+}
+
+func newTestIntake() *IntakeService {
+	s := NewIntakeService(nil, &fakeRatesCalculator{rates: []domain.KPRRate{
+		{ID: 1, RateName: "KPR Griya Fix 3", PropertyType: "RUMAH", EffectiveRate: 0.0675, MinTermYears: 5, MaxTermYears: 20, MaxLTVRatio: 0.9, MinLoanAmount: 1e8, MaxLoanAmount: 2e9},
+		{ID: 2, RateName: "KPR Griya Reguler", EffectiveRate: 0.0825, MinTermYears: 1, MaxTermYears: 30, MaxLTVRatio: 0.85, MinLoanAmount: 5e7, MaxLoanAmount: 5e9},
+	}}, nil).(*IntakeService)
+	s.enums = func(ctx context.Context, column string) ([]string, error) { return intakeTestEnums[column], nil }
+	return s
+}
+
+func TestIntakeAdvance_FullFlow(t *testing.T) {
+	s := newTestIntake()
+	ctx := context.Background()
+	st := &intakeState{Step: intakeStepPropertyType, Draft: domain.DraftApplication{UserID: 7}}
+	steps := []struct {
+		answer   string
+		wantStep string
+		contains string
+	}{
+		{"kantor", intakeStepPropertyType, "belum dikenali"},
+		{"rumah", intakeStepPropertyValue, "rumah tapak"},
+		{"delapan ratus", intakeStepPropertyValue, "belum terbaca"},
+		{"1 miliar", intakeStepLoanAmount, "Rp 1.000.000.000"},
+		{"1,2 M", intakeStepLoanAmount, "di bawah nilai properti"},
+		{"95%", intakeStepLoanAmount, "plafon maksimal"},
+		{"800 juta", intakeStepTenor, "1–30 tahun"},
+		{"25 tahun", intakeStepPurpose, "KPR Griya Reguler"},
+		{"tinggal", intakeStepPurpose, "belum dikenali"},
+		{"1", intakeStepCertificate, "primary residence"},
+		{"shm", intakeStepAddress, "Sertifikat: *shm*"},
+		{"Jl. Mawar", intakeStepAddress, "terlalu singkat"},
+		{"Jl. Mawar No. 5, Bekasi", intakeStepDeveloper, "developer"},
+		{"PT Griya Asri", intakeStepConfirm, "Plafon: Rp 800.000.000 (DP Rp 200.000.000)"},
+		{"nanti dulu", intakeStepConfirm, "Ketik *ya*"},
+	}
+	for _, c := range steps {
+		reply, done, err := s.advance(ctx, st, c.answer)
+		if err != nil || done {
+			t.Fatalf("%q: err=%v done=%v", c.answer, err, done)
+		}
+		if st.Step != c.wantStep || !strings.Contains(reply, c.contains) {
+			t.Fatalf("%q: step=%s reply=%q; want step %s containing %q", c.answer, st.Step, reply, c.wantStep, c.contains)
+		}
+	}
+	if _, done, _ := s.advance(ctx, st, "ya"); !done {
+		t.Fatalf("confirmation must finish intake")
+	}
+	d := st.Draft
+	if d.PropertyType != "RUMAH_TAPAK" || d.Purpose != "PRIMARY_RESIDENCE" || d.PropertyCertificateType != "SHM" ||
+		d.KPRRateID != 2 || d.TenorYears != 25 || d.InterestRate != 0.0825 || d.MonthlyInstallment <= 0 || d.DeveloperName != "PT Griya Asri" {
+		t.Fatalf("draft=%+v", d)
+	}
+	if err := validateDraft(d); err != nil {
+		t.Fatalf("collected draft must be valid: %v", err)
+	}
+}
+
+func TestIntakeAdvance_TenorPicksCheapestEligible(t *testing.T) {
+	s := newTestIntake()
+	st := &intakeState{Step: intakeStepTenor, Draft: domain.DraftApplication{PropertyType: "RUMAH_TAPAK", PropertyValue: 1e9, LoanAmount: 8e8}}
+	reply, _, err := s.advance(context.Background(), st, "15")
+	if err != nil || st.Draft.KPRRateID != 1 || !strings.Contains(reply, "KPR Griya Fix 3") {
+		t.Fatalf("rate=%d reply=%q err=%v", st.Draft.KPRRateID, reply, err)
+	}
+	st = &intakeState{Step: intakeStepTenor, Draft: domain.DraftApplication{PropertyType: "RUMAH_TAPAK", PropertyValue: 1e9, LoanAmount: 8e8}}
+	if reply, _, _ := s.advance(context.Background(), st, "35 tahun"); st.Step != intakeStepTenor || !strings.Contains(reply, "tenor harus") {
+		t.Fatalf("tenor out of range: step=%s reply=%q", st.Step, reply)
+	}
+}
+
+func TestMatchEnumChoice(t *testing.T) {
+	values := intakeTestEnums["property_type"]
+	cases := []struct {
+		text, want string
+		ok         bool
+	}{
+		{"2", "APARTEMEN", true},
+		{"4", "", false},
+		{"Rumah tapak", "RUMAH_TAPAK", true},
+		{"RUKO", "RUKO", true},
+		{"apartemen.", "APARTEMEN", true},
+		{"rumah", "RUMAH_TAPAK", true},
+		{"tanah", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		got, ok := matchEnumChoice(c.text, values)
+		if got != c.want || ok != c.ok {
+			t.Fatalf("matchEnumChoice(%q)=%q,%v; want %q,%v", c.text, got, ok, c.want, c.ok)
+		}
+	}
+	// jawaban yang cocok ke dua nilai ditolak
+	if _, ok := matchEnumChoice("sh", []string{"SHM", "SHGB"}); ok {
+		t.Fatalf("ambiguous answer must not match")
+	}
+}
+
+func TestIntakeStartPattern(t *testing.T) {
+	cases := map[string]bool{
+		"saya mau ajukan KPR":            true,
+		"mulai pengajuan kpr":            true,
+		"pengajuan KPR baru":             true,
+		"bagaimana cara mengajukan kpr?": false,
+		"syarat ajukan kpr apa saja":     false,
+		"status pengajuan kpr saya":      false,
+	}
+	for text, want := range cases {
+		got := intakeStartPattern.MatchString(text) && !intakeQuestionPattern.MatchString(text)
+		if got != want {
+			t.Fatalf("start(%q)=%v; want %v", text, got, want)
+		}
+	}
+}
+
+func TestValidateDraft(t *testing.T) {
+	ok := domain.DraftApplication{UserID: 1, PropertyType: "RUMAH_TAPAK", PropertyValue: 1e9, LoanAmount: 8e8, TenorYears: 20,
+		Purpose: "INVESTMENT", PropertyCertificateType: "SHM", PropertyAddress: "Jl. Mawar No. 5, Bekasi", KPRRateID: 2, InterestRate: 0.08, MonthlyInstallment: 6e6}
+	if err := validateDraft(ok); err != nil {
+		t.Fatalf("valid draft: %v", err)
+	}
+	bad := ok
+	bad.LoanAmount = 1e9
+	if validateDraft(bad) == nil {
+		t.Fatalf("loan equal to property value must fail")
+	}
+	bad = ok
+	bad.PropertyAddress = " "
+	if err := validateDraft(bad); err == nil || !strings.Contains(err.Error(), "property_address") {
+		t.Fatalf("missing address err=%v", err)
+	}
+}
@@ -0,0 +1,500 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	ai "github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// weightedPhrase adalah frasa (satu atau beberapa kata) beserta bobotnya
+type weightedPhrase struct {
+	phrase string
+	weight float64
+}
+
+type intentRule struct {
+	intent  domain.Intent
+	phrases []weightedPhrase
+}
+
+// intentRules: bobot per frasa. Frasa dicocokkan per token (bukan substring),
+// sehingga "id" tidak lagi cocok dengan "ide" dan "data" tidak cocok dengan "update".
+var intentRules = []intentRule{
+	{domain.IntentGreeting, []weightedPhrase{
+		{"halo", 1}, {"hallo", 1}, {"hai", 1}, {"hi", 1}, {"hello", 1}, {"helo", 1}, {"hey", 1},
+		{"assalamualaikum", 1}, {"pagi", 0.6}, {"siang", 0.6}, {"sore", 0.6}, {"malam", 0.6},
+		{"selamat pagi", 0.6}, {"selamat siang", 0.6}, {"selamat sore", 0.6}, {"selamat malam", 0.6},
+		{"permisi", 0.8}, {"terima kasih", 1}, {"makasih", 1}, {"thanks", 1},
+	}},
+	{domain.IntentApplicationStatus, []weightedPhrase{
+		{"status", 1.5}, {"pengajuan", 1}, {"aplikasi", 1}, {"application", 1}, {"riwayat", 1.5},
+		{"sampai mana", 2}, {"progres", 2}, {"progress", 2}, {"disetujui", 1.5}, {"ditolak", 1.5},
+		{"approval", 1}, {"persetujuan", 1}, {"sudah cair", 2}, {"pencairan", 1.5},
+		{"pengajuan saya", 2}, {"aplikasi saya", 2}, {"kpr saya", 2}, {"pinjaman saya", 2},
+		{"list pengajuan", 3}, {"daftar pengajuan", 3},
+	}},
+	{domain.IntentInstallmentSimulation, []weightedPhrase{
+		{"simulasi", 3}, {"simulasikan", 3}, {"hitung", 1.5}, {"hitungkan", 1.5}, {"kalkulasi", 2},
+		{"cicilan", 1}, {"angsuran", 1}, {"per bulan", 1}, {"perbulan", 1}, {"tenor", 0.8},
+		{"dp", 0.8}, {"uang muka", 0.8}, {"harga rumah", 1.5}, {"kalau pinjam", 2}, {"jika pinjam", 2},
+	}},
+	{domain.IntentTotalCost, []weightedPhrase{
+		{"total biaya", 4}, {"biaya total", 4}, {"rincian biaya", 3}, {"biaya kpr", 3}, {"biaya", 1},
+		{"provisi", 2}, {"biaya admin", 2.5}, {"administrasi", 1}, {"notaris", 2}, {"appraisal", 2},
+		{"biaya asuransi", 2.5}, {"total bayar", 3}, {"total pembayaran", 3}, {"keseluruhan", 1},
+	}},
+	{domain.IntentAmortization, []weightedPhrase{
+		{"jadwal angsuran", 4}, {"jadwal cicilan", 4}, {"tabel angsuran", 4}, {"tabel cicilan", 4},
+		{"amortisasi", 4}, {"rincian angsuran", 3}, {"rincian cicilan", 3}, {"jadwal pembayaran", 3},
+		{"pdf", 2}, {"csv", 2}, {"excel", 1.5}, {"jadwal", 1}, {"tabel", 1},
+	}},
+	{domain.IntentRateInfo, []weightedPhrase{
+		{"bunga", 1.5}, {"suku bunga", 2.5}, {"rate", 1.5}, {"fixed", 1.5}, {"floating", 1.5},
+		{"promo", 2}, {"promosi", 2}, {"produk kpr", 2}, {"produk", 1}, {"bunga berapa", 1},
+	}},
+	{domain.IntentEligibility, []weightedPhrase{
+		{"memenuhi syarat", 3}, {"lolos", 1.5}, {"layak", 2}, {"eligible", 3}, {"kelayakan", 3},
+		{"bisa ajukan", 2}, {"boleh ajukan", 2}, {"bisa mengajukan", 2}, {"bisa kpr", 2},
+		{"usia", 1}, {"umur", 1}, {"gaji", 1}, {"penghasilan", 1}, {"cukup", 1}, {"masuk kriteria", 3},
+	}},
+	{domain.IntentAffordability, []weightedPhrase{
+		{"bisa pinjam berapa", 4}, {"pinjam berapa", 3}, {"dapat pinjaman berapa", 4}, {"pinjaman maksimal", 3},
+		{"maksimal pinjaman", 3}, {"plafon maksimal", 3}, {"maksimal plafon", 3}, {"kemampuan", 2}, {"mampu", 2},
+		{"sanggup", 2}, {"rumah seharga berapa", 4}, {"rumah harga berapa", 3}, {"beli rumah berapa", 3},
+		{"dsr", 3}, {"cicilan lain", 2},
+	}},
+	{domain.IntentRecommendation, []weightedPhrase{
+		{"rekomendasi", 3}, {"rekomendasikan", 3}, {"sarankan", 2.5}, {"saran produk", 3}, {"pilihkan", 2.5},
+		{"produk yang cocok", 4}, {"paling cocok", 3}, {"cocok untuk saya", 3}, {"cocok buat saya", 3},
+		{"cocok untuk aku", 3}, {"yang cocok", 2}, {"cocok", 1}, {"produk terbaik", 2.5}, {"sesuai profil", 3},
+	}},
+	{domain.IntentRefinance, []weightedPhrase{
+		{"take over", 3}, {"takeover", 3}, {"refinance", 3}, {"refinancing", 2.5}, {"refinansing", 3},
+		{"alih kredit", 4}, {"oper kredit", 4}, {"pindah promo", 4}, {"pindah ke promo", 4}, {"ganti promo", 4},
+		{"pindah kpr", 3}, {"pindah bank", 2}, {"dipindah", 2}, {"hemat", 1}, {"worth it", 1.5}, {"balik modal", 2.5}, {"break even", 2.5},
+	}},
+	{domain.IntentDocumentChecklist, []weightedPhrase{
+		{"dokumen kurang", 4}, {"berkas kurang", 4}, {"yang kurang", 2}, {"kurang", 1}, {"belum lengkap", 3},
+		{"kelengkapan dokumen", 4}, {"kelengkapan berkas", 4}, {"kelengkapan", 2}, {"checklist", 3},
+		{"belum dikirim", 2}, {"belum diterima", 2}, {"sudah diterima", 2}, {"sudah lengkap", 2},
+	}},
+	{domain.IntentFAQ, []weightedPhrase{
+		{"apa itu", 2}, {"bagaimana", 1}, {"gimana", 1}, {"cara", 1.5}, {"syarat", 1.5},
+		{"persyaratan", 2}, {"dokumen", 1.5}, {"berkas", 1.5}, {"prosedur", 2}, {"alur", 1.5},
+		{"jelaskan", 1.5}, {"apa beda", 3.5}, {"perbedaan", 3.5}, {"apa saja", 1}, {"kenapa", 0.8},
+		{"asuransi", 1}, {"take over", 1.5}, {"refinancing", 1},
+	}},
+	{domain.IntentHandoff, []weightedPhrase{
+		{"cs", 2.5}, {"customer service", 3}, {"call center", 3}, {"petugas", 2}, {"manusia", 2.5},
+		{"orang asli", 3}, {"operator", 2.5}, {"hubungi", 1.5}, {"bicara dengan", 2}, {"ngobrol dengan", 2},
+		{"sambungkan", 3}, {"telepon", 1}, {"kontak", 1.5}, {"agen", 1.5},
+	}},
+	{domain.IntentComplaint, []weightedPhrase{
+		{"komplain", 3}, {"keluhan", 3}, {"kecewa", 3}, {"mengecewakan", 3}, {"tidak puas", 3},
+		{"gak puas", 3}, {"marah", 2.5}, {"buruk", 2}, {"lambat", 1.5}, {"lama banget", 2}, {"kok lama", 2.5},
+		{"protes", 3}, {"lapor", 1.5}, {"parah", 2}, {"ribet", 1.5}, {"dipersulit", 3},
+	}},
+}
+
+// possessiveTokens menandakan pertanyaan tentang data milik pengirim sendiri
+var possessiveTokens = map[string]struct{}{"saya": {}, "aku": {}, "gue": {}, "gw": {}, "punyaku": {}}
+
+// loanTerms adalah istilah pinjaman yang, bila dipakai secara posesif, menunjuk ke pengajuan milik user
+var loanTerms = []string{"cicilan", "angsuran", "pinjaman", "plafon", "tenor", "bunga", "dp"}
+
+// intentTokens memecah teks menjadi token huruf/angka (lowercase) dan melepas sufiks posesif
+// -nya/-ku/-mu. Flag possessive bernilai true bila ada kata ganti orang pertama.
+func intentTokens(text string) (tokens []string, possessive bool) {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, f := range fields {
+		if _, ok := possessiveTokens[f]; ok {
+			possessive = true
+		}
+		for _, suf := range []string{"nya", "ku", "mu"} {
+			if strings.HasSuffix(f, suf) && len(f)-len(suf) >= 4 {
+				if suf == "ku" {
+					possessive = true
+				}
+				f = strings.TrimSuffix(f, suf)
+				break
+			}
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens, possessive
+}
+
+// containsPhrase memeriksa apakah frasa (token berurutan) muncul di token teks
+func containsPhrase(tokens []string, phrase string) bool {
+	pt := strings.Fields(phrase)
+	if len(pt) == 0 || len(pt) > len(tokens) {
+		return false
+	}
+	for i := 0; i+len(pt) <= len(tokens); i++ {
+		match := true
+		for j := range pt {
+			if tokens[i+j] != pt[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAmount menandakan adanya angka (nominal/tenor/persen) dalam teks,
+// dipakai untuk membedakan simulasi dari pertanyaan status
+func hasAmount(tokens []string) bool {
+	for _, t := range tokens {
+		if t != "" && unicode.IsDigit(rune(t[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleIntentClassifier mengklasifikasi intent memakai bobot kata kunci berbasis token
+type RuleIntentClassifier struct{}
+
+func NewRuleIntentClassifier() *RuleIntentClassifier {
+	return &RuleIntentClassifier{}
+}
+
+// scores menghitung skor mentah per intent
+func (c *RuleIntentClassifier) scores(text string) map[domain.Intent]float64 {
+	app := extractAppNumber(text)
+	if app != "" {
+		// nomor aplikasi bukan nominal; buang agar tidak terbaca sebagai angka simulasi
+		text = strings.ReplaceAll(strings.ToUpper(text), app, " ")
+	}
+	tokens, possessive := intentTokens(text)
+	out := map[domain.Intent]float64{}
+	for _, r := range intentRules {
+		for _, wp := range r.phrases {
+			if containsPhrase(tokens, wp.phrase) {
+				out[r.intent] += wp.weight
+			}
+		}
+	}
+	if app != "" {
+		out[domain.IntentApplicationStatus] += 3
+	}
+	amount := hasAmount(tokens)
+	mentionsLoan := false
+	for _, lt := range loanTerms {
+		if containsPhrase(tokens, lt) {
+			mentionsLoan = true
+			break
+		}
+	}
+	switch {
+	case mentionsLoan && amount:
+		// "cicilan 500 juta 15 tahun" -> simulasi
+		out[domain.IntentInstallmentSimulation] += 2.5
+	case mentionsLoan && possessive:
+		// "berapa cicilan saya" -> data pengajuan milik user
+		out[domain.IntentApplicationStatus] += 2.5
+	}
+	if containsPhrase(tokens, "dokumen") || containsPhrase(tokens, "berkas") {
+		// "dokumen saya", "berkas KPR-2025-001": status dokumen pengajuan tertentu, bukan FAQ/status umum
+		if possessive || app != "" {
+			out[domain.IntentDocumentChecklist] += 3.5
+		}
+	}
+	if out[domain.IntentRefinance] > 0 && (possessive || app != "") {
+		// "take over kpr saya ke promo": analisis pinjaman milik user, bukan FAQ/status
+		out[domain.IntentRefinance] += 2.5
+	}
+	if amount && out[domain.IntentTotalCost] > 0 {
+		// "total biaya kpr 1 m dp 20%" mengalahkan dorongan simulasi di atas
+		out[domain.IntentTotalCost] += 2.5
+	}
+	if amount && out[domain.IntentAmortization] > 0 {
+		out[domain.IntentAmortization] += 2.5
+	}
+	if amount && out[domain.IntentAffordability] > 0 {
+		// "gaji 12 juta bisa pinjam berapa": hitung mundur dari penghasilan, bukan simulasi harga rumah
+		out[domain.IntentAffordability] += 2.5
+	}
+	if amount && out[domain.IntentEligibility] > 0 {
+		out[domain.IntentEligibility] += 1
+	}
+	// Salam hanya menang bila tidak ada sinyal lain
+	if out[domain.IntentGreeting] > 0 {
+		for it, s := range out {
+			if it != domain.IntentGreeting && s > 0 {
+				delete(out, domain.IntentGreeting)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Classify mengembalikan intent dengan skor tertinggi. Confidence menggabungkan margin
+// terhadap kandidat kedua dan besarnya skor (makin banyak bukti makin yakin).
+func (c *RuleIntentClassifier) Classify(ctx context.Context, text string) (*domain.IntentResult, error) {
+	sc := c.scores(text)
+	best := domain.IntentOther
+	var s1, s2 float64
+	// iterasi mengikuti urutan domain.AllIntents agar hasil seri deterministik
+	for _, it := range domain.AllIntents {
+		s := sc[it]
+		if s > s1 {
+			s2 = s1
+			best, s1 = it, s
+		} else if s > s2 {
+			s2 = s
+		}
+	}
+	if s1 == 0 {
+		return &domain.IntentResult{Intent: domain.IntentOther, Confidence: 0.5, Source: "rule"}, nil
+	}
+	conf := (s1 / (s1 + s2)) * (s1 / (s1 + 1))
+	return &domain.IntentResult{Intent: best, Confidence: math.Round(conf*100) / 100, Source: "rule"}, nil
+}
+
+// LLMIntentClassifier meminta Gemini mengklasifikasi intent dengan keluaran JSON terstruktur.
+// Klasifikasi aturan dipakai sebagai jalur cepat bila sudah cukup yakin dan sebagai fallback bila Gemini gagal.
+type LLMIntentClassifier struct {
+	geminiKey     string
+	rules         *RuleIntentClassifier
+	fastPathAbove float64
+}
+
+func NewLLMIntentClassifier(geminiKey string) *LLMIntentClassifier {
+	return &LLMIntentClassifier{
+		geminiKey:     geminiKey,
+		rules:         NewRuleIntentClassifier(),
+		fastPathAbove: 0.8,
+	}
+}
+
+// NewIntentClassifier memilih implementasi sesuai ketersediaan Gemini
+func NewIntentClassifier(geminiKey string) domain.IntentClassifier {
+	if strings.TrimSpace(geminiKey) == "" {
+		return NewRuleIntentClassifier()
+	}
+	return NewLLMIntentClassifier(geminiKey)
+}
+
+func intentSchema() *ai.Schema {
+	enum := make([]string, 0, len(domain.AllIntents))
+	for _, it := range domain.AllIntents {
+		enum = append(enum, string(it))
+	}
+	return &ai.Schema{
+		Type: ai.TypeObject,
+		Properties: map[string]*ai.Schema{
+			"intent":     {Type: ai.TypeString, Format: "enum", Enum: enum},
+			"confidence": {Type: ai.TypeNumber},
+		},
+		Required: []string{"intent", "confidence"},
+	}
+}
+
+func (c *LLMIntentClassifier) Classify(ctx context.Context, text string) (*domain.IntentResult, error) {
+	ruled, _ := c.rules.Classify(ctx, text)
+	if ruled != nil && c.fastPathAbove > 0 && ruled.Confidence >= c.fastPathAbove {
+		return ruled, nil
+	}
+	res, err := c.classifyLLM(ctx, text)
+	if err != nil {
+		log.Printf("[INTENT] llm fallback to rules: %v", err)
+		return ruled, nil
+	}
+	return res, nil
+}
+
+func (c *LLMIntentClassifier) classifyLLM(ctx context.Context, text string) (*domain.IntentResult, error) {
+	client, err := ai.NewClient(ctx, option.WithAPIKey(c.geminiKey))
+	if err != nil {
+		return nil, fmt.Errorf("gemini client: %w", err)
+	}
+	defer client.Close()
+	model := client.GenerativeModel("gemini-2.5-flash-lite")
+	model.SetTemperature(0)
+	model.ResponseMIMEType = "application/json"
+	model.ResponseSchema = intentSchema()
+
+	prompt := "Klasifikasikan pesan WhatsApp nasabah KPR BNI ke salah satu intent: " +
+		"greeting (salam/basa-basi), application_status (status/data pengajuan KPR milik pengirim, termasuk cicilan/plafon miliknya), " +
+		"installment_simulation (hitung/simulasi cicilan dari harga, DP, tenor), " +
+		"total_cost (total biaya KPR: provisi/admin, appraisal, notaris, asuransi, total bunga dan total dibayar), " +
+		"amortization_schedule (minta jadwal/tabel angsuran bulanan, PDF/CSV), rate_info (suku bunga/produk/promo KPR), " +
+		"eligibility (apakah pengirim memenuhi syarat produk), " +
+		"affordability (berapa pinjaman/harga rumah maksimal dari penghasilan dan cicilan lain), " +
+		"recommendation (minta rekomendasi produk KPR yang paling cocok untuk profil pengirim), " +
+		"refinance (apakah pinjaman KPR milik pengirim layak di-take over/dipindah ke promo, penghematan dan balik modal), " +
+		"document_checklist (dokumen pengajuan milik pengirim yang sudah diterima/masih kurang), faq (pertanyaan umum/prosedur/persyaratan), " +
+		"handoff (minta bicara dengan petugas/CS), complaint (keluhan/kekecewaan), other (selain itu). " +
+		"Kembalikan JSON {intent, confidence 0..1}. Pesan: " + text
+	resp, err := model.GenerateContent(ctx, ai.Text(prompt))
+	if err != nil {
+		return nil, fmt.Errorf("gemini: %w", err)
+	}
+	var s string
+	for _, cand := range resp.Candidates {
+		for _, p := range cand.Content.Parts {
+			if t, ok := p.(ai.Text); ok {
+				s += string(t)
+			}
+		}
+	}
+	var out struct {
+		Intent     string  `json:"intent"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(s)), &out); err != nil {
+		return nil, fmt.Errorf("invalid intent json: %w", err)
+	}
+	it := domain.Intent(strings.ToLower(strings.TrimSpace(out.Intent)))
+	if !isKnownIntent(it) {
+		return nil, fmt.Errorf("unknown intent %q", out.Intent)
+	}
+	conf := math.Max(0, math.Min(1, out.Confidence))
+	return &domain.IntentResult{Intent: it, Confidence: conf, Source: "llm"}, nil
+}
+
+func isKnownIntent(it domain.Intent) bool {
+	for _, k := range domain.AllIntents {
+		if k == it {
+			return true
+		}
+	}
+	return false
+}
+
+// intentNeedsData menandai intent yang dijawab dengan akses database
+func intentNeedsData(it domain.Intent) bool {
+	switch it {
+	case domain.IntentApplicationStatus, domain.IntentRateInfo, domain.IntentEligibility, domain.IntentInstallmentSimulation, domain.IntentTotalCost, domain.IntentAmortization, domain.IntentRefinance, domain.IntentAffordability, domain.IntentRecommendation:
+		return true
+	default:
+		return false
+	}
+}
+
+// intentTable memberi petunjuk tabel utama untuk intent data (dipakai oleh perencana naif)
+func intentTable(it domain.Intent) string {
+	switch it {
+	case domain.IntentApplicationStatus:
+		return "kpr_applications"
+	case domain.IntentRateInfo, domain.IntentEligibility, domain.IntentInstallmentSimulation, domain.IntentTotalCost, domain.IntentAmortization, domain.IntentRefinance, domain.IntentAffordability, domain.IntentRecommendation:
+		return "kpr_rates"
+	default:
+		return ""
+	}
+}
+
+// intentReply mengembalikan jawaban tetap untuk intent yang tidak perlu AI maupun DB
+func intentReply(it domain.Intent) (string, bool) {
+	switch it {
+	case domain.IntentHandoff:
+		return "Baik, aku bantu sambungkan ke petugas ya. Kamu bisa hubungi BNI Call 1500046 (24 jam) atau datang ke kantor cabang BNI terdekat. Sebutkan nomor pengajuan KPR kamu supaya petugas bisa langsung bantu.", true
+	case domain.IntentComplaint:
+		return "Mohon maaf atas ketidaknyamanannya. Chat ini belum bisa meneruskan keluhan ke petugas, jadi silakan sampaikan detail keluhan beserta nomor pengajuan KPR kamu ke BNI Call 1500046 (24 jam) atau kantor cabang BNI terdekat supaya bisa segera ditindaklanjuti.", true
+	default:
+		return "", false
+	}
+}
+
+// LabelledUtterance adalah satu contoh pada set uji intent
+type LabelledUtterance struct {
+	Text   string        `json:"text"`
+	Intent domain.Intent `json:"intent"`
+}
+
+// IntentStat adalah ringkasan akurasi per intent
+type IntentStat struct {
+	Total   int
+	Correct int
+}
+
+// IntentMiss mencatat contoh yang salah diklasifikasi
+type IntentMiss struct {
+	Text string
+	Want domain.Intent
+	Got  domain.Intent
+}
+
+// IntentReport adalah hasil evaluasi classifier terhadap set berlabel
+type IntentReport struct {
+	Total     int
+	Correct   int
+	Accuracy  float64
+	PerIntent map[domain.Intent]*IntentStat
+	Misses    []IntentMiss
+}
+
+// EvaluateIntentClassifier menjalankan classifier pada set berlabel dan menghitung akurasi
+func EvaluateIntentClassifier(ctx context.Context, c domain.IntentClassifier, samples []LabelledUtterance) (*IntentReport, error) {
+	rep := &IntentReport{PerIntent: map[domain.Intent]*IntentStat{}}
+	for _, s := range samples {
+		res, err := c.Classify(ctx, s.Text)
+		if err != nil {
+			return nil, fmt.Errorf("classify %q: %w", s.Text, err)
+		}
+		st, ok := rep.PerIntent[s.Intent]
+		if !ok {
+			st = &IntentStat{}
+			rep.PerIntent[s.Intent] = st
+		}
+		st.Total++
+		rep.Total++
+		if res.Intent == s.Intent {
+			st.Correct++
+			rep.Correct++
+		} else {
+			rep.Misses = append(rep.Misses, IntentMiss{Text: s.Text, Want: s.Intent, Got: res.Intent})
+		}
+	}
+	if rep.Total > 0 {
+		rep.Accuracy = float64(rep.Correct) / float64(rep.Total)
+	}
+	return rep, nil
+}
+
+// String merender laporan akurasi yang mudah dibaca
+func (r *IntentReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "accuracy=%.2f%% (%d/%d)\n", r.Accuracy*100, r.Correct, r.Total)
+	keys := make([]string, 0, len(r.PerIntent))
+	for k := range r.PerIntent {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		st := r.PerIntent[domain.Intent(k)]
+		fmt.Fprintf(&sb, "  %-24s %d/%d\n", k, st.Correct, st.Total)
+	}
+	for _, m := range r.Misses {
+		fmt.Fprintf(&sb, "  miss: %q want=%s got=%s\n", m.Text, m.Want, m.Got)
+	}
+	return sb.String()
+}
+
+// planHintText menambahkan petunjuk intent dan tabel ke prompt perencana SQL
+func planHintText(it domain.Intent, table string) string {
+	if table == "" {
+		return " "
+	}
+	if it == "" {
+		return " Tabel utama yang dimaksud pengguna: " + table + ". "
+	}
+	return " Intent pengguna: " + string(it) + " (tabel utama yang disarankan: " + table + "). "
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func loadLabelledIntents(t *testing.T) []LabelledUtterance {
+	t.Helper()
+	f, err := os.Open("testdata/intents.jsonl")
+	if err != nil {
+		t.Fatalf("open labelled set: %v", err)
+	}
+	defer f.Close()
+	var out []LabelledUtterance
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var u LabelledUtterance
+		if err := json.Unmarshal(sc.Bytes(), &u); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+func TestRuleIntentClassifier_LabelledSetAccuracy(t *testing.T) {
+	samples := loadLabelledIntents(t)
+	rep, err := EvaluateIntentClassifier(context.Background(), NewRuleIntentClassifier(), samples)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	t.Logf("rule classifier report:\n%s", rep)
+	if rep.Accuracy < 0.9 {
+		t.Fatalf("accuracy %.2f below 0.90", rep.Accuracy)
+	}
+}
+
+func TestRuleIntentClassifier_Regressions(t *testing.T) {
+	cases := []struct {
+		in   string
+		want domain.Intent
+	}{
+		// "id" dan "data" dulu cocok sebagai substring
+		{"ide rumah impian", domain.IntentOther},
+		{"berapa cicilan saya", domain.IntentApplicationStatus},
+		{"halo", domain.IntentGreeting},
+		{"halo, status pengajuan saya?", domain.IntentApplicationStatus},
+		// dokumen milik pengirim vs persyaratan umum
+		{"dokumen saya apa yang kurang", domain.IntentDocumentChecklist},
+		{"dokumen KPR-2025-001 sudah lengkap?", domain.IntentDocumentChecklist},
+		{"apa saja syarat dokumen kpr", domain.IntentFAQ},
+		// hitung mundur dari penghasilan vs cek kelayakan
+		{"gaji saya 12 juta, bisa pinjam berapa?", domain.IntentAffordability},
+		{"gaji 8 juta layak ajukan kpr?", domain.IntentEligibility},
+		// take-over pinjaman milik pengirim vs penjelasan umum
+		{"worth it gak take over kpr saya ke promo?", domain.IntentRefinance},
+		{"kalau KPR-2025-001 dipindah ke promo fixed berapa hematnya", domain.IntentRefinance},
+		{"mau alih kredit ke bunga promo, balik modalnya kapan", domain.IntentRefinance},
+		{"apa itu take over kpr", domain.IntentFAQ},
+		// rekomendasi dari profil vs daftar produk umum
+		{"produk kpr apa yang cocok untuk saya?", domain.IntentRecommendation},
+		{"ada promo bunga kpr apa saja?", domain.IntentRateInfo},
+	}
+	c := NewRuleIntentClassifier()
+	for _, tc := range cases {
+		got, err := c.Classify(context.Background(), tc.in)
+		if err != nil {
+			t.Fatalf("Classify(%q) error: %v", tc.in, err)
+		}
+		if got.Intent != tc.want {
+			t.Fatalf("Classify(%q)=%s; want %s", tc.in, got.Intent, tc.want)
+		}
+		if got.Confidence < 0 || got.Confidence > 1 {
+			t.Fatalf("Classify(%q) confidence %.2f out of range", tc.in, got.Confidence)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain memuat ddl.sql dari root repo agar metadata tabel/kolom tersedia untuk semua test
+func TestMain(m *testing.M) {
+	const ddl = "../../ddl.sql"
+	refreshAllowedTablesFromDDL(ddl)
+	refreshAllowedColumnsFromDDL(ddl)
+	refreshEnumsFromDDL(ddl)
+	refreshColumnTypesFromDDL(ddl)
+	refreshCommentsFromDDL(ddl)
+	// ddl.sql tidak memuat CREATE TYPE; di produksi nilai enum dimuat dari pg_enum (LoadEnumsFromDB)
+	enumTypes["application_status"] = applicationStatusOrder
+	rebuildColumnEnums()
+	os.Exit(m.Run())
+}
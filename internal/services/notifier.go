@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+// notifyChannel adalah channel LISTEN/NOTIFY yang diisi trigger migrasi 001_status_notifications
+const notifyChannel = "kpr_status_changed"
+
+// wib: zona waktu jam tenang (tanpa bergantung pada tzdata di container)
+var wib = time.FixedZone("WIB", 7*3600)
+
+// wibWallClock: jam dinding WIB dari t, berlabel UTC agar sebanding dengan kolom timestamp tanpa zona
+func wibWallClock(t time.Time) time.Time {
+	n := t.In(wib)
+	return time.Date(n.Year(), n.Month(), n.Day(), n.Hour(), n.Minute(), n.Second(), n.Nanosecond(), time.UTC)
+}
+
+// quietHours adalah rentang jam (WIB) di mana notifikasi ditahan; Start == End berarti nonaktif.
+// Rentang boleh melewati tengah malam, mis. 21:00-07:00.
+type quietHours struct {
+	Start, End int // menit sejak 00:00
+}
+
+// parseQuietHours membaca "HH:MM-HH:MM"; string kosong/"off" menonaktifkan jam tenang
+func parseQuietHours(s string) (quietHours, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" || s == "off" || s == "none" {
+		return quietHours{}, nil
+	}
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return quietHours{}, fmt.Errorf("quiet hours %q: want HH:MM-HH:MM", s)
+	}
+	var q quietHours
+	for i, p := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(p))
+		if err != nil {
+			return quietHours{}, fmt.Errorf("quiet hours %q: %w", s, err)
+		}
+		m := t.Hour()*60 + t.Minute()
+		if i == 0 {
+			q.Start = m
+		} else {
+			q.End = m
+		}
+	}
+	return q, nil
+}
+
+// sendAfter mengembalikan now bila di luar jam tenang, atau akhir jam tenang berikutnya
+func (q quietHours) sendAfter(now time.Time) time.Time {
+	if q.Start == q.End {
+		return now
+	}
+	local := now.In(wib)
+	m := local.Hour()*60 + local.Minute()
+	var quiet bool
+	if q.Start < q.End {
+		quiet = m >= q.Start && m < q.End
+	} else {
+		quiet = m >= q.Start || m < q.End
+	}
+	if !quiet {
+		return now
+	}
+	end := time.Date(local.Year(), local.Month(), local.Day(), q.End/60, q.End%60, 0, 0, wib)
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// statusMessages: templat per status pengajuan; status yang tidak ada di sini memakai templat umum,
+// dan DRAFT tidak pernah diberitahukan
+var statusMessages = map[string]string{
+	"SUBMITTED":             "Pengajuan KPR *%s* sudah kami terima dan sedang menunggu verifikasi.",
+	"DOCUMENT_VERIFICATION": "Dokumen pengajuan KPR *%s* sedang diverifikasi.",
+	"PROPERTY_APPRAISAL":    "Pengajuan KPR *%s* masuk tahap penilaian (appraisal) properti.",
+	"CREDIT_ANALYSIS":       "Pengajuan KPR *%s* sedang dalam analisa kredit.",
+	"APPROVED":              "Selamat! Pengajuan KPR *%s* telah *disetujui*. Petugas kami akan menghubungi kamu untuk jadwal akad.",
+	"REJECTED":              "Mohon maaf, pengajuan KPR *%s* belum dapat kami setujui. Ketik *hubungi petugas* bila ingin penjelasan lebih lanjut.",
+	"DISBURSED":             "Dana KPR untuk pengajuan *%s* telah dicairkan. Ketik *jadwal angsuran* untuk menerima jadwal pembayaran.",
+	"CANCELLED":             "Pengajuan KPR *%s* telah dibatalkan.",
+}
+
+// humanizeEnum: "PROPERTY_APPRAISAL" -> "property appraisal"
+func humanizeEnum(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, "_", " "))
+}
+
+// statusMessage merender notifikasi perubahan status; ok=false untuk status yang tidak diberitahukan
+func statusMessage(appNumber, status string) (string, bool) {
+	status = strings.ToUpper(strings.TrimSpace(status))
+	if status == "" || status == "DRAFT" {
+		return "", false
+	}
+	if tpl, ok := statusMessages[status]; ok {
+		return fmt.Sprintf(tpl, appNumber), true
+	}
+	return fmt.Sprintf("Status pengajuan KPR *%s* kini: *%s*.", appNumber, humanizeEnum(status)), true
+}
+
+// stageMessage merender notifikasi tahap approval_workflow baru
+func stageMessage(appNumber, stage string) string {
+	return fmt.Sprintf("Pengajuan KPR *%s* masuk tahap *%s*. Kami kabari lagi bila ada perkembangan.", appNumber, humanizeEnum(stage))
+}
+
+// notifyEvent adalah payload trigger: kind "status" (id = application_status_events.id) atau "stage" (id = approval_workflow.id)
+type notifyEvent struct {
+	Kind string `json:"kind"`
+	ID   int    `json:"id"`
+}
+
+// StatusNotifier mendeteksi perubahan status pengajuan dan tahap approval, lalu mengirim
+// notifikasi WhatsApp ke users.phone pemohon. Sumber event: LISTEN/NOTIFY (cepat) dan polling
+// application_status_events/approval_workflow (cadangan saat koneksi LISTEN putus). Keduanya
+// menghasilkan dedupe_key yang sama (id event/tahap), sehingga setiap transisi dikirim sekali lewat
+// outbox notification_log.
+type StatusNotifier struct {
+	db           domain.DatabaseService
+	whatsapp     domain.WhatsAppService
+	databaseURL  string
+	pollInterval time.Duration
+	quiet        quietHours
+	now          func() time.Time
+	wake         chan struct{}
+}
+
+func NewStatusNotifier(db domain.DatabaseService, whatsapp domain.WhatsAppService, databaseURL string, pollInterval time.Duration, quietHoursSpec string) (domain.Notifier, error) {
+	q, err := parseQuietHours(quietHoursSpec)
+	if err != nil {
+		return nil, err
+	}
+	if pollInterval <= 0 {
+		pollInterval = time.Minute
+	}
+	return &StatusNotifier{
+		db:           db,
+		whatsapp:     whatsapp,
+		databaseURL:  databaseURL,
+		pollInterval: pollInterval,
+		quiet:        q,
+		now:          time.Now,
+		wake:         make(chan struct{}, 1),
+	}, nil
+}
+
+// Run berjalan sampai ctx selesai
+func (n *StatusNotifier) Run(ctx context.Context) {
+	go n.listen(ctx)
+	go n.poll(ctx)
+	n.dispatchLoop(ctx)
+}
+
+// listen menunggu NOTIFY dengan koneksi khusus; reconnect dengan backoff bila terputus
+func (n *StatusNotifier) listen(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := n.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[NOTIFY] listen stopped: %v; polling tetap berjalan, reconnect dalam %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 5*time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func (n *StatusNotifier) listenOnce(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, n.databaseURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+	log.Printf("[NOTIFY] listening on %s", notifyChannel)
+	for {
+		msg, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var ev notifyEvent
+		if err := json.Unmarshal([]byte(msg.Payload), &ev); err != nil {
+			log.Printf("[NOTIFY] bad payload %q: %v", msg.Payload, err)
+			continue
+		}
+		if err := n.handleEvent(ctx, ev); err != nil {
+			log.Printf("[NOTIFY] event %+v: %v", ev, err)
+		}
+	}
+}
+
+// poll memeriksa perubahan sejak watermark terakhir; watermark awal = waktu DB saat start,
+// sehingga perubahan lama tidak dikirim ulang saat bot baru dinyalakan
+func (n *StatusNotifier) poll(ctx context.Context) {
+	var watermark time.Time
+	for ctx.Err() == nil {
+		rows, err := n.db.Query(ctx, "SELECT LOCALTIMESTAMP")
+		if err == nil {
+			if rows.Next() {
+				err = rows.Scan(&watermark)
+			}
+			rows.Close()
+		}
+		if err == nil && !watermark.IsZero() {
+			break
+		}
+		log.Printf("[NOTIFY] poll init: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(n.pollInterval):
+		}
+	}
+	t := time.NewTicker(n.pollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		next, err := n.pollOnce(ctx, watermark)
+		if err != nil {
+			log.Printf("[NOTIFY] poll: %v", err)
+		}
+		watermark = next
+	}
+}
+
+const pollChangesQuery = `SELECT 'status', id, created_at FROM application_status_events WHERE created_at > $1
+UNION ALL
+SELECT 'stage', id, created_at FROM approval_workflow WHERE created_at > $1
+ORDER BY 3
+LIMIT 500`
+
+// pollOnce memproses perubahan setelah watermark dan mengembalikan watermark baru
+func (n *StatusNotifier) pollOnce(ctx context.Context, watermark time.Time) (time.Time, error) {
+	rows, err := n.db.Query(ctx, pollChangesQuery, watermark)
+	if err != nil {
+		return watermark, err
+	}
+	var events []notifyEvent
+	next := watermark
+	for rows.Next() {
+		var ev notifyEvent
+		var at time.Time
+		if err := rows.Scan(&ev.Kind, &ev.ID, &at); err != nil {
+			rows.Close()
+			return watermark, err
+		}
+		events = append(events, ev)
+		if at.After(next) {
+			next = at
+		}
+	}
+	rows.Close()
+	for _, ev := range events {
+		if err := n.handleEvent(ctx, ev); err != nil {
+			// watermark tidak dimajukan agar event ini dicoba lagi; dedupe mencegah kiriman ganda
+			return watermark, err
+		}
+	}
+	return next, nil
+}
+
+// statusEventQuery membaca satu transisi application_status_events (status saat transisi, bukan status terkini)
+const statusEventQuery = `SELECT a.id, a.application_number, e.status, COALESCE(u.phone, '')
+FROM application_status_events e JOIN kpr_applications a ON a.id = e.application_id JOIN users u ON u.id = a.user_id
+WHERE e.id = $1`
+
+const stageEventQuery = `SELECT a.id, a.application_number, w.stage::text, COALESCE(u.phone, '')
+FROM approval_workflow w JOIN kpr_applications a ON a.id = w.application_id JOIN users u ON u.id = a.user_id
+WHERE w.id = $1`
+
+// handleEvent membaca detail event dan memasukkannya ke outbox
+func (n *StatusNotifier) handleEvent(ctx context.Context, ev notifyEvent) error {
+	q := statusEventQuery
+	if ev.Kind == "stage" {
+		q = stageEventQuery
+	} else if ev.Kind != "status" {
+		return fmt.Errorf("unknown kind %q", ev.Kind)
+	}
+	rows, err := n.db.Query(ctx, q, ev.ID)
+	if err != nil {
+		return err
+	}
+	var appID int
+	var appNumber, value, phone string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&appID, &appNumber, &value, &phone)
+	}
+	rows.Close()
+	if err != nil || !found {
+		return err
+	}
+	if strings.TrimSpace(phone) == "" {
+		return nil
+	}
+	var key, msg string
+	if ev.Kind == "stage" {
+		key, msg = "stage:"+strconv.Itoa(ev.ID), stageMessage(appNumber, value)
+	} else {
+		var ok bool
+		if msg, ok = statusMessage(appNumber, value); !ok {
+			return nil
+		}
+		key = "status:" + strconv.Itoa(ev.ID)
+	}
+	return n.enqueue(ctx, key, appID, phone, msg)
+}
+
+// enqueue menyimpan notifikasi ke outbox; dedupe_key yang sudah ada diabaikan
+func (n *StatusNotifier) enqueue(ctx context.Context, key string, appID int, phone, msg string) error {
+	res, err := n.db.Exec(ctx, `INSERT INTO notification_log (dedupe_key, application_id, phone, message, send_after)
+VALUES ($1, $2, $3, $4, $5) ON CONFLICT (dedupe_key) DO NOTHING`, key, appID, phone, msg, n.quiet.sendAfter(n.now()))
+	if err != nil {
+		return err
+	}
+	if c, _ := res.RowsAffected(); c > 0 {
+		log.Printf("[NOTIFY] queued %s", key)
+		select {
+		case n.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// notifyMaxAttempts: setelah ini notifikasi ditandai FAILED
+const notifyMaxAttempts = 5
+
+// claimDueQuery mengambil notifikasi yang jatuh tempo secara atomik (aman untuk beberapa instance);
+// baris SENDING yang macet lebih dari 10 menit (instance mati di tengah kirim) diambil ulang
+const claimDueQuery = `UPDATE notification_log SET status = 'SENDING', send_after = now(), attempts = attempts + 1
+WHERE id IN (
+	SELECT id FROM notification_log
+	WHERE (status = 'PENDING' AND send_after <= now()) OR (status = 'SENDING' AND send_after < now() - interval '10 minutes')
+	ORDER BY id LIMIT 20 FOR UPDATE SKIP LOCKED)
+RETURNING id, phone, message, attempts`
+
+func (n *StatusNotifier) dispatchLoop(ctx context.Context) {
+	t := time.NewTicker(30 * time.Second)
+	defer t.Stop()
+	for {
+		if err := n.dispatchDue(ctx); err != nil {
+			log.Printf("[NOTIFY] dispatch: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-n.wake:
+		}
+	}
+}
+
+// dispatchDue mengirim notifikasi yang jatuh tempo; gagal kirim dijadwalkan ulang dengan backoff.
+// Selama jam tenang tidak ada yang diambil, termasuk baris lama atau yang di-enqueue sebelum jam tenang.
+func (n *StatusNotifier) dispatchDue(ctx context.Context) error {
+	if !n.whatsapp.IsConnected() {
+		return nil
+	}
+	if now := n.now(); n.quiet.sendAfter(now).After(now) {
+		return nil
+	}
+	rows, err := n.db.Query(ctx, claimDueQuery)
+	if err != nil {
+		return err
+	}
+	type due struct {
+		id             int64
+		phone, message string
+		attempts       int
+	}
+	var batch []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.id, &d.phone, &d.message, &d.attempts); err != nil {
+			rows.Close()
+			return err
+		}
+		batch = append(batch, d)
+	}
+	rows.Close()
+	for _, d := range batch {
+		if err := n.whatsapp.SendMessage(ctx, d.phone, d.message); err != nil {
+			status := "PENDING"
+			if d.attempts >= notifyMaxAttempts {
+				status = "FAILED"
+			}
+			retry := n.quiet.sendAfter(n.now().Add(time.Duration(d.attempts*d.attempts) * time.Minute))
+			_, _ = n.db.Exec(ctx, "UPDATE notification_log SET status = $2, send_after = $3, last_error = $4 WHERE id = $1", d.id, status, retry, err.Error())
+			log.Printf("[NOTIFY] send %d failed (attempt %d): %v", d.id, d.attempts, err)
+			continue
+		}
+		if _, err := n.db.Exec(ctx, "UPDATE notification_log SET status = 'SENT', sent_at = now(), last_error = NULL WHERE id = $1", d.id); err != nil {
+			log.Printf("[NOTIFY] mark sent %d: %v", d.id, err)
+		}
+	}
+	return nil
+}
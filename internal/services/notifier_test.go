@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestQuietHours_SendAfter(t *testing.T) {
+	q, err := parseQuietHours("21:00-07:00")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	at := func(day, h, m int) time.Time { return time.Date(2026, 10, day, h, m, 0, 0, wib) }
+	cases := []struct {
+		now, want time.Time
+	}{
+		{at(16, 12, 0), at(16, 12, 0)},
+		{at(16, 21, 0), at(17, 7, 0)},
+		{at(16, 23, 30), at(17, 7, 0)},
+		{at(17, 6, 59), at(17, 7, 0)},
+		{at(17, 7, 0), at(17, 7, 0)},
+		// waktu UTC dikonversi ke WIB: 15:00 UTC = 22:00 WIB
+		{time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC), at(17, 7, 0)},
+	}
+	for _, c := range cases {
+		if got := q.sendAfter(c.now); !got.Equal(c.want) {
+			t.Fatalf("sendAfter(%s)=%s; want %s", c.now, got, c.want)
+		}
+	}
+	off, _ := parseQuietHours("off")
+	if now := at(16, 23, 0); !off.sendAfter(now).Equal(now) {
+		t.Fatalf("disabled quiet hours must not delay")
+	}
+	if _, err := parseQuietHours("21-7"); err == nil {
+		t.Fatalf("invalid spec must fail")
+	}
+}
+
+func TestStatusMessage(t *testing.T) {
+	if _, ok := statusMessage("KPR-2025-001", "DRAFT"); ok {
+		t.Fatalf("DRAFT must not be notified")
+	}
+	msg, ok := statusMessage("KPR-2025-001", "approved")
+	if !ok || !strings.Contains(msg, "*KPR-2025-001*") || !strings.Contains(msg, "disetujui") {
+		t.Fatalf("approved message=%q", msg)
+	}
+	if msg, _ := statusMessage("KPR-2025-001", "FINAL_REVIEW"); !strings.Contains(msg, "final review") {
+		t.Fatalf("fallback message=%q", msg)
+	}
+	if msg := stageMessage("KPR-2025-001", "CREDIT_ANALYSIS"); !strings.Contains(msg, "credit analysis") {
+		t.Fatalf("stage message=%q", msg)
+	}
+}
+
+// connectedWA: WhatsApp yang selalu terhubung dan mencatat pesan terkirim
+type connectedWA struct {
+	domain.WhatsAppService
+	sent int
+}
+
+func (c *connectedWA) IsConnected() bool { return true }
+func (c *connectedWA) SendMessage(ctx context.Context, phone, msg string) error {
+	c.sent++
+	return nil
+}
+
+func TestDispatchDue_HoldsDuringQuietHours(t *testing.T) {
+	q, _ := parseQuietHours("21:00-07:00")
+	wa := &connectedWA{}
+	now := time.Date(2026, 10, 16, 23, 0, 0, 0, wib)
+	// brokenDB: klaim yang tetap dijalankan akan terlihat sebagai error
+	n := &StatusNotifier{db: brokenDB{}, whatsapp: wa, quiet: q, now: func() time.Time { return now }}
+	if err := n.dispatchDue(context.Background()); err != nil || wa.sent != 0 {
+		t.Fatalf("quiet hours must not claim or send: err=%v sent=%d", err, wa.sent)
+	}
+	now = time.Date(2026, 10, 17, 7, 0, 0, 0, wib)
+	if err := n.dispatchDue(context.Background()); err == nil {
+		t.Fatalf("outside quiet hours the outbox must be claimed")
+	}
+}
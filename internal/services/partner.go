@@ -0,0 +1,508 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/nlu"
+)
+
+// partnerListLimit: jumlah pengajuan maksimum yang ditampilkan ke developer per permintaan
+const partnerListLimit = 20
+
+// partnerColumns: kolom kpr_applications yang boleh dilihat developer mitra (tanpa data keuangan)
+var partnerColumns = map[string]struct{}{
+	"application_number": {}, "status": {}, "property_type": {}, "developer_name": {},
+	"created_at": {}, "submitted_at": {}, "approved_at": {}, "rejected_at": {},
+}
+
+// partnerBuyerNameColumn: satu-satunya kolom identitas untuk developer, selalu disamarkan
+const partnerBuyerNameColumn = "buyer_name"
+
+// partnerApplicationsQuery: daftar pengajuan satu developer beserta nama pembeli (disamarkan di ExecuteQuery).
+// $1 developer_name (selalu diisi policy), $2 status, $3/$4 rentang created_at [from, to), $5 nomor
+// pengajuan; string kosong = tanpa filter. LIMIT = partnerListLimit+1 untuk mendeteksi daftar terpotong.
+var partnerApplicationsQuery = fmt.Sprintf(`SELECT a.application_number, a.status::text AS status, a.property_type::text AS property_type,
+	COALESCE(p.full_name, '') AS buyer_name, a.created_at, a.submitted_at
+FROM kpr_applications a LEFT JOIN user_profiles p ON p.user_id = a.user_id
+WHERE a.developer_name = $1 AND ($2 = '' OR a.status::text = $2)
+	AND ($3 = '' OR a.created_at >= NULLIF($3, '')::timestamp) AND ($4 = '' OR a.created_at < NULLIF($4, '')::timestamp)
+	AND ($5 = '' OR a.application_number = $5)
+ORDER BY a.created_at DESC LIMIT %d`, partnerListLimit+1)
+
+// partnerStatements: query mentah yang boleh dijalankan dengan scope developer
+var partnerStatements = map[string]bool{partnerApplicationsQuery: true}
+
+// errPartnerScope: rencana di luar hak akses developer mitra
+var errPartnerScope = fmt.Errorf("Akses developer hanya untuk jumlah dan status pengajuan proyek sendiri.")
+
+// withPartnerScope menandai query berikutnya sebagai akses developer mitra; ExecuteQuery lalu
+// menerapkan sanitizePlanForPartner di atas sanitasi umum dan maskPartnerResult
+func withPartnerScope(ctx context.Context, developer string) context.Context {
+	return context.WithValue(ctx, ctxKey("partner"), developer)
+}
+
+func partnerFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(ctxKey("partner")).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// sanitizePlanForPartner membatasi rencana ke kpr_applications milik developer: hanya kolom
+// partnerColumns, agregat count, dan filter developer_name yang selalu dipaksakan; filter lain di
+// luar partnerColumns ditolak. Query mentah hanya boleh dari partnerStatements dengan $1 diganti
+// developer_name scope. Rencana terstruktur dipanggil lewat sanitizePlanForScope.
+func sanitizePlanForPartner(p *domain.SQLPlan, developer string) error {
+	if p == nil {
+		return fmt.Errorf("rencana query tidak tersedia")
+	}
+	if strings.TrimSpace(developer) == "" {
+		return errPartnerScope
+	}
+	if sql := strings.TrimSpace(p.SQL); sql != "" {
+		if !partnerStatements[sql] {
+			return errPartnerScope
+		}
+		if len(p.Args) == 0 {
+			p.Args = []string{developer}
+		} else {
+			p.Args[0] = developer
+		}
+		return nil
+	}
+	if !strings.EqualFold(strings.TrimSpace(p.Table), "kpr_applications") {
+		return errPartnerScope
+	}
+	// tanpa metadata kolom buildSafeSelect melewati filter; gagal tertutup agar filter developer tidak hilang
+	if !isColumnIn("kpr_applications", "developer_name") {
+		return fmt.Errorf("skema kpr_applications belum dimuat")
+	}
+	p.Table = "kpr_applications"
+
+	cols := make([]string, 0, len(p.Columns))
+	for _, c := range p.Columns {
+		lc := strings.ToLower(strings.TrimSpace(c))
+		if _, ok := partnerColumns[lc]; ok {
+			cols = append(cols, lc)
+		}
+	}
+	if len(cols) == 0 && len(p.Aggregates) == 0 {
+		for c := range partnerColumns {
+			cols = append(cols, c)
+		}
+		sort.Strings(cols)
+	}
+	p.Columns = cols
+
+	aliases := map[string]bool{}
+	for _, ag := range p.Aggregates {
+		if err := checkPartnerAggregate(ag.Func, ag.Column); err != nil {
+			return err
+		}
+		aliases[aggregateAlias(ag)] = true
+	}
+	for _, h := range p.Having {
+		if err := checkPartnerAggregate(h.Func, h.Column); err != nil {
+			return err
+		}
+	}
+	for _, g := range p.GroupBy {
+		if _, ok := partnerColumns[strings.ToLower(strings.TrimSpace(g))]; !ok {
+			return errPartnerScope
+		}
+	}
+	for _, o := range p.OrderBy {
+		lc := strings.ToLower(strings.TrimSpace(o.Column))
+		if _, ok := partnerColumns[lc]; !ok && !aliases[lc] {
+			return errPartnerScope
+		}
+	}
+
+	// filter di luar scope ditolak; membuangnya diam-diam akan memperluas hasil tanpa sepengetahuan developer
+	filters := make([]domain.Filter, 0, len(p.Filters)+1)
+	for _, f := range p.Filters {
+		lc := strings.ToLower(strings.TrimSpace(f.Column))
+		if _, ok := partnerColumns[lc]; !ok {
+			return errPartnerScope
+		}
+		if lc == "developer_name" {
+			if op, _ := normalizeFilterOp(f.Op); op != opEq || !strings.EqualFold(strings.TrimSpace(f.Value), strings.TrimSpace(developer)) {
+				return errPartnerScope
+			}
+			continue // sama dengan filter scope yang dipaksakan di bawah
+		}
+		filters = append(filters, f)
+	}
+	p.Filters = append(filters, domain.Filter{Column: "developer_name", Op: "=", Value: developer})
+	p.MinGroupSize = 0
+	capLimit(p, partnerListLimit+1)
+	return nil
+}
+
+// checkPartnerAggregate: developer hanya boleh menghitung jumlah, bukan nilai (sum/avg plafon, dll.)
+func checkPartnerAggregate(fn, col string) error {
+	if nfn, _ := normalizeAggregateFunc(fn); nfn != "count" {
+		return errPartnerScope
+	}
+	lc := strings.ToLower(strings.TrimSpace(col))
+	if _, ok := partnerColumns[lc]; !ok && lc != "*" && lc != "" {
+		return errPartnerScope
+	}
+	return nil
+}
+
+// maskPartnerResult: lapisan kedua setelah policy; kolom di luar hak developer dikosongkan dan
+// nama pembeli disamarkan sebelum hasil keluar dari ExecuteQuery
+func maskPartnerResult(rs *domain.ResultSet, p *domain.SQLPlan) {
+	if rs == nil {
+		return
+	}
+	allowed := map[string]bool{}
+	for c := range partnerColumns {
+		allowed[c] = true
+	}
+	if p != nil {
+		for _, ag := range p.Aggregates {
+			allowed[aggregateAlias(ag)] = true
+		}
+	}
+	for i, c := range rs.Columns {
+		name := strings.ToLower(c.Name)
+		for r := range rs.Rows {
+			switch {
+			case name == partnerBuyerNameColumn:
+				if s, ok := rs.Rows[r][i].(string); ok {
+					rs.Rows[r][i] = maskBuyerName(s)
+				}
+			case !allowed[name]:
+				rs.Rows[r][i] = nil
+				if r < len(rs.Masked) && i < len(rs.Masked[r]) {
+					rs.Masked[r][i] = true
+				}
+			}
+		}
+	}
+}
+
+// maskBuyerName: "budi santoso" -> "B*** S***"
+func maskBuyerName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		r := []rune(w)
+		words[i] = string(unicode.ToUpper(r[0])) + "***"
+	}
+	return strings.Join(words, " ")
+}
+
+// applicationStatusOrder: urutan tampilan status pengajuan (mengikuti alur proses)
+var applicationStatusOrder = []string{"DRAFT", "SUBMITTED", "DOCUMENT_VERIFICATION", "PROPERTY_APPRAISAL",
+	"CREDIT_ANALYSIS", "APPROVED", "REJECTED", "DISBURSED", "CANCELLED"}
+
+// IsApplicationStatus melaporkan apakah s (huruf besar) adalah status pengajuan yang dikenal
+func IsApplicationStatus(s string) bool {
+	for _, st := range applicationStatusOrder {
+		if st == s {
+			return true
+		}
+	}
+	return false
+}
+
+// partnerStatusKeywords: kata kunci status pada pertanyaan developer, dicoba berurutan
+var partnerStatusKeywords = []struct {
+	re     *regexp.Regexp
+	status string
+}{
+	{regexp.MustCompile(`\b(?:disetujui|approved?|acc)\b`), "APPROVED"},
+	{regexp.MustCompile(`\b(?:ditolak|rejected?)\b`), "REJECTED"},
+	{regexp.MustCompile(`\b(?:cair|dicairkan|pencairan|disbursed?)\b`), "DISBURSED"},
+	{regexp.MustCompile(`\b(?:batal|dibatalkan|cancel(?:led)?)\b`), "CANCELLED"},
+	{regexp.MustCompile(`\b(?:draft|draf)\b`), "DRAFT"},
+	{regexp.MustCompile(`\bverifikasi\b`), "DOCUMENT_VERIFICATION"},
+	{regexp.MustCompile(`\b(?:appraisal|penilaian)\b`), "PROPERTY_APPRAISAL"},
+	{regexp.MustCompile(`\banalis[ai]s?\b`), "CREDIT_ANALYSIS"},
+	{regexp.MustCompile(`\b(?:submitted|diajukan|baru masuk)\b`), "SUBMITTED"},
+}
+
+// partnerHelpPattern: sapaan atau permintaan bantuan dari developer
+var partnerHelpPattern = regexp.MustCompile(`(?i)^\s*(?:bantuan|help|menu|halo|hai|hi|pagi|siang|sore|malam)\b`)
+
+// partnerFinancialPattern: pertanyaan keuangan pembeli yang tidak tersedia untuk developer
+var partnerFinancialPattern = regexp.MustCompile(`(?i)\b(?:plafon|plafond|pinjaman|angsuran|cicilan|gaji|penghasilan|pendapatan|bunga|dp|uang muka|ltv|nilai properti)\b`)
+
+// parsePartnerQuery membaca nomor pengajuan, status, dan rentang tanggal dari pertanyaan developer
+func parsePartnerQuery(text string, now time.Time) domain.PartnerQuery {
+	q := domain.PartnerQuery{ApplicationNumber: extractAppNumber(text)}
+	if q.ApplicationNumber != "" {
+		return q
+	}
+	low := strings.ToLower(text)
+	for _, k := range partnerStatusKeywords {
+		if k.re.MatchString(low) {
+			q.Status = k.status
+			break
+		}
+	}
+	if from, to, ok := nlu.DateRange(text, now); ok {
+		q.From, q.To = from, to
+	}
+	return q
+}
+
+// PartnerService: akses developer mitra (chat dan REST) ke pengajuan proyeknya sendiri. Query
+// pengajuan selalu lewat AIQueryService.ExecuteQuery dengan scope developer.
+type PartnerService struct {
+	db    domain.DatabaseService
+	query domain.AIQueryService
+}
+
+func NewPartnerService(db domain.DatabaseService, query domain.AIQueryService) *PartnerService {
+	return &PartnerService{db: db, query: query}
+}
+
+// partnerLookup mengembalikan developer aktif pertama dari query registry; nil bila tidak ada
+func (s *PartnerService) partnerLookup(ctx context.Context, query string, arg string) (*domain.DeveloperPartner, error) {
+	rows, err := s.db.Query(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	var p domain.DeveloperPartner
+	if err := rows.Scan(&p.ID, &p.DeveloperName); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *PartnerService) partnerByPhone(ctx context.Context, phone string) (*domain.DeveloperPartner, error) {
+	return s.partnerLookup(ctx, `SELECT p.id, p.developer_name FROM developer_partner_phones ph
+JOIN developer_partners p ON p.id = ph.partner_id
+WHERE ph.phone = $1 AND p.is_active LIMIT 1`, phone)
+}
+
+// PartnerByAPIKey mencocokkan hash SHA-256 key dengan developer_partner_keys yang belum dicabut
+func (s *PartnerService) PartnerByAPIKey(ctx context.Context, key string) (*domain.DeveloperPartner, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, nil
+	}
+	sum := sha256.Sum256([]byte(key))
+	return s.partnerLookup(ctx, `SELECT p.id, p.developer_name FROM developer_partner_keys k
+JOIN developer_partners p ON p.id = k.partner_id
+WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND p.is_active LIMIT 1`, hex.EncodeToString(sum[:]))
+}
+
+// partnerCountPlan: jumlah pengajuan per status dengan filter yang sama seperti daftar
+func partnerCountPlan(q domain.PartnerQuery) *domain.SQLPlan {
+	p := &domain.SQLPlan{Version: domain.SQLPlanVersion, Operation: "SELECT", Table: "kpr_applications",
+		Aggregates: []domain.Aggregate{{Func: "count", Column: "*", Alias: "total"}}, GroupBy: []string{"status"}}
+	if q.Status != "" {
+		p.Filters = append(p.Filters, domain.Filter{Column: "status", Op: "=", Value: q.Status})
+	}
+	if q.ApplicationNumber != "" {
+		p.Filters = append(p.Filters, domain.Filter{Column: "application_number", Op: "=", Value: q.ApplicationNumber})
+	}
+	if !q.From.IsZero() {
+		p.Filters = append(p.Filters, domain.Filter{Column: "created_at", Op: ">=", Value: q.From.Format(planTimestampLayout)})
+	}
+	if !q.To.IsZero() {
+		p.Filters = append(p.Filters, domain.Filter{Column: "created_at", Op: "<", Value: q.To.Format(planTimestampLayout)})
+	}
+	return p
+}
+
+// partnerListPlan: query terdaftar partnerApplicationsQuery; $1 diisi policy
+func partnerListPlan(q domain.PartnerQuery) *domain.SQLPlan {
+	ts := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(planTimestampLayout)
+	}
+	return &domain.SQLPlan{Version: domain.SQLPlanVersion, Operation: "SELECT", Table: "kpr_applications",
+		SQL: partnerApplicationsQuery, Args: []string{"", q.Status, ts(q.From), ts(q.To), q.ApplicationNumber}}
+}
+
+// Portfolio menghitung jumlah per status dan daftar pengajuan terbaru milik developer
+func (s *PartnerService) Portfolio(ctx context.Context, partner domain.DeveloperPartner, q domain.PartnerQuery) (*domain.PartnerPortfolio, error) {
+	q.Status = strings.ToUpper(strings.TrimSpace(q.Status))
+	q.ApplicationNumber = strings.ToUpper(strings.TrimSpace(q.ApplicationNumber))
+	ctx = withPartnerScope(ctx, partner.DeveloperName)
+	if ctx.Value(ctxKey("audit_phone")) == nil {
+		ctx = context.WithValue(ctx, ctxKey("audit_phone"), fmt.Sprintf("developer:%d", partner.ID))
+	}
+	out := &domain.PartnerPortfolio{DeveloperName: partner.DeveloperName, Query: q,
+		Statuses: []domain.PartnerStatusCount{}, Applications: []domain.PartnerApplication{}}
+
+	counts, err := s.query.ExecuteQuery(ctx, partnerCountPlan(q))
+	if err != nil {
+		return nil, fmt.Errorf("partner counts: %w", err)
+	}
+	for r := 0; r < counts.Len(); r++ {
+		c := domain.PartnerStatusCount{Status: resultString(counts, r, "status")}
+		if v, ok := counts.Value(r, "total"); ok {
+			if n, ok := v.(int64); ok {
+				c.Count = int(n)
+			}
+		}
+		out.Total += c.Count
+		out.Statuses = append(out.Statuses, c)
+	}
+	sortPartnerStatuses(out.Statuses)
+
+	list, err := s.query.ExecuteQuery(ctx, partnerListPlan(q))
+	if err != nil {
+		return nil, fmt.Errorf("partner applications: %w", err)
+	}
+	for r := 0; r < list.Len(); r++ {
+		if r == partnerListLimit {
+			out.Truncated = true
+			break
+		}
+		app := domain.PartnerApplication{
+			ApplicationNumber: resultString(list, r, "application_number"),
+			Status:            resultString(list, r, "status"),
+			PropertyType:      resultString(list, r, "property_type"),
+		}
+		if v, ok := list.Value(r, partnerBuyerNameColumn); ok {
+			app.BuyerName, _ = v.(string)
+		}
+		if v, ok := list.Value(r, "created_at"); ok {
+			app.CreatedAt, _ = v.(time.Time)
+		}
+		if v, ok := list.Value(r, "submitted_at"); ok {
+			if t, ok := v.(time.Time); ok {
+				app.SubmittedAt = &t
+			}
+		}
+		out.Applications = append(out.Applications, app)
+	}
+	return out, nil
+}
+
+// sortPartnerStatuses: urut alur proses; status tak dikenal di belakang secara alfabetis
+func sortPartnerStatuses(c []domain.PartnerStatusCount) {
+	rank := func(s string) int {
+		for i, st := range applicationStatusOrder {
+			if strings.EqualFold(st, s) {
+				return i
+			}
+		}
+		return len(applicationStatusOrder)
+	}
+	sort.SliceStable(c, func(i, j int) bool {
+		if ri, rj := rank(c[i].Status), rank(c[j].Status); ri != rj {
+			return ri < rj
+		}
+		return c[i].Status < c[j].Status
+	})
+}
+
+// partnerPeriodLabel: "1 Maret 2026 – 31 Maret 2026" dari rentang [from, to)
+func partnerPeriodLabel(q domain.PartnerQuery) string {
+	switch {
+	case !q.From.IsZero() && !q.To.IsZero():
+		return formatDateID(q.From) + " – " + formatDateID(q.To.AddDate(0, 0, -1))
+	case !q.From.IsZero():
+		return "sejak " + formatDateID(q.From)
+	case !q.To.IsZero():
+		return "s.d. " + formatDateID(q.To.AddDate(0, 0, -1))
+	}
+	return ""
+}
+
+// formatPartnerPortfolio merender portofolio untuk WhatsApp; tidak pernah memuat angka keuangan
+func formatPartnerPortfolio(p *domain.PartnerPortfolio) string {
+	var b strings.Builder
+	if n := p.Query.ApplicationNumber; n != "" {
+		if len(p.Applications) == 0 {
+			return fmt.Sprintf("Pengajuan %s tidak ditemukan pada proyek %s.", n, p.DeveloperName)
+		}
+		a := p.Applications[0]
+		fmt.Fprintf(&b, "🏗️ *%s* (%s)\n• Pembeli: %s\n• Status: %s\n• Dibuat: %s", a.ApplicationNumber, p.DeveloperName,
+			a.BuyerName, humanizeEnum(a.Status), formatDateID(a.CreatedAt))
+		if a.SubmittedAt != nil {
+			fmt.Fprintf(&b, "\n• Diajukan: %s", formatDateID(*a.SubmittedAt))
+		}
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "🏗️ *Pengajuan proyek %s*", p.DeveloperName)
+	if p.Query.Status != "" {
+		fmt.Fprintf(&b, " — status %s", humanizeEnum(p.Query.Status))
+	}
+	if period := partnerPeriodLabel(p.Query); period != "" {
+		fmt.Fprintf(&b, "\nPeriode: %s", period)
+	}
+	if p.Total == 0 {
+		b.WriteString("\n\nBelum ada pengajuan yang cocok.")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\n\nTotal %d pengajuan:\n", p.Total)
+	for _, c := range p.Statuses {
+		fmt.Fprintf(&b, "• %s: %d\n", humanizeEnum(c.Status), c.Count)
+	}
+	b.WriteString("\n*Terbaru*:\n")
+	for i, a := range p.Applications {
+		fmt.Fprintf(&b, "%d. %s — %s — %s (%s)\n", i+1, a.ApplicationNumber, humanizeEnum(a.Status), a.BuyerName, formatDateID(a.CreatedAt))
+	}
+	if p.Truncated {
+		fmt.Fprintf(&b, "(hanya %d terbaru; persempit dengan status atau periode)\n", len(p.Applications))
+	}
+	b.WriteString("\n_Nama pembeli disamarkan; detail keuangan tidak tersedia untuk akun developer._")
+	return b.String()
+}
+
+// partnerHelp: contoh pertanyaan untuk developer mitra
+func partnerHelp(developer string) string {
+	return fmt.Sprintf("Halo, %s! Nomor ini terdaftar sebagai developer mitra. Contoh pertanyaan:\n"+
+		"• rekap pengajuan bulan ini\n"+
+		"• pengajuan yang disetujui 3 bulan terakhir\n"+
+		"• status KPR-2025-001\n"+
+		"Yang tersedia hanya jumlah dan status pengajuan proyek sendiri; nama pembeli disamarkan.", developer)
+}
+
+// HandleCommand: semua pesan dari nomor developer mitra dijawab di sini (tidak pernah diteruskan
+// ke alur tanya-jawab nasabah); nomor lain diteruskan apa adanya
+func (s *PartnerService) HandleCommand(ctx context.Context, phone, text string) (string, bool) {
+	if strings.TrimSpace(phone) == "" {
+		return "", false
+	}
+	partner, err := s.partnerByPhone(ctx, phone)
+	if err != nil {
+		// fail closed: nomor developer tidak boleh jatuh ke alur nasabah saat registry tidak terbaca
+		log.Printf("[PARTNER] lookup error: %v", err)
+		return "Maaf, permintaan belum bisa diproses saat ini. Coba lagi sebentar lagi ya.", true
+	}
+	if partner == nil {
+		return "", false
+	}
+	if partnerHelpPattern.MatchString(text) {
+		return partnerHelp(partner.DeveloperName), true
+	}
+	if partnerFinancialPattern.MatchString(text) {
+		return "Detail keuangan pembeli (plafon, angsuran, penghasilan) tidak tersedia untuk akun developer. " +
+			"Kamu bisa menanyakan jumlah dan status pengajuan proyek sendiri.", true
+	}
+	q := parsePartnerQuery(text, time.Now().In(wib))
+	ctx = context.WithValue(ctx, ctxKey("audit_phone"), phone)
+	p, err := s.Portfolio(ctx, *partner, q)
+	if err != nil {
+		log.Printf("[PARTNER] %s: %v", partner.DeveloperName, err)
+		return "Maaf, data pengajuan belum bisa diambil saat ini.", true
+	}
+	return formatPartnerPortfolio(p), true
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestSanitizePlanForPartner(t *testing.T) {
+	p := &domain.SQLPlan{Operation: "SELECT", Table: "KPR_Applications", Limit: 500,
+		Columns: []string{"application_number", "loan_amount", "monthly_installment", "status"},
+		Filters: []domain.Filter{
+			{Column: "developer_name", Op: "eq", Value: "pt maju jaya"},
+			{Column: "status", Op: "=", Value: "APPROVED"},
+		}}
+	if err := sanitizePlanForPartner(p, "PT Maju Jaya"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(p.Columns, ","); got != "application_number,status" {
+		t.Fatalf("columns=%s", got)
+	}
+	if len(p.Filters) != 2 || p.Filters[0].Column != "status" || p.Filters[1].Value != "PT Maju Jaya" || p.Limit != partnerListLimit+1 {
+		t.Fatalf("plan=%+v", p)
+	}
+	q, args, err := (&AIQueryService{}).buildSafeSelect(p)
+	if err != nil || !strings.Contains(q, "t.developer_name = $2") || args[1] != "PT Maju Jaya" {
+		t.Fatalf("query=%s args=%v err=%v", q, args, err)
+	}
+
+	// tanpa kolom: default kolom partner, bukan SELECT *
+	p = &domain.SQLPlan{Operation: "SELECT", Table: "kpr_applications"}
+	if err := sanitizePlanForPartner(p, "PT Maju Jaya"); err != nil || len(p.Columns) != len(partnerColumns) {
+		t.Fatalf("columns=%v err=%v", p.Columns, err)
+	}
+
+	count := partnerCountPlan(domain.PartnerQuery{})
+	if err := sanitizePlanForPartner(count, "PT Maju Jaya"); err != nil || count.MinGroupSize != 0 {
+		t.Fatalf("count plan: %+v err=%v", count, err)
+	}
+
+	rejected := []*domain.SQLPlan{
+		{Table: "users"},
+		{Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "sum", Column: "loan_amount"}}},
+		{Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "count", Column: "monthly_installment"}}},
+		{Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "count", Column: "*"}}, GroupBy: []string{"user_id"}},
+		{Table: "kpr_applications", OrderBy: []domain.OrderBy{{Column: "loan_amount", Desc: true}}},
+		{Table: "kpr_applications", SQL: "SELECT * FROM kpr_applications"},
+		// filter di luar scope ditolak, tidak dibuang diam-diam
+		{Table: "kpr_applications", Filters: []domain.Filter{{Column: "user_id", Op: "=", Value: "5"}}},
+		{Table: "kpr_applications", Filters: []domain.Filter{{Column: "loan_amount", Op: ">", Value: "1"}}},
+		{Table: "kpr_applications", Filters: []domain.Filter{{Column: "developer_name", Op: "=", Value: "PT Lain"}}},
+		{Table: "kpr_applications", Filters: []domain.Filter{{Column: "developer_name", Op: "ilike", Value: "PT"}}},
+	}
+	for _, r := range rejected {
+		if err := sanitizePlanForPartner(r, "PT Maju Jaya"); err == nil {
+			t.Errorf("plan should be rejected: %+v", r)
+		}
+	}
+	if err := sanitizePlanForPartner(&domain.SQLPlan{Table: "kpr_applications"}, " "); err == nil {
+		t.Errorf("empty developer should be rejected")
+	}
+
+	// query terdaftar: $1 selalu developer dari scope
+	raw := partnerListPlan(domain.PartnerQuery{Status: "APPROVED"})
+	raw.Args[0] = "PT Lain"
+	if err := sanitizePlanForPartner(raw, "PT Maju Jaya"); err != nil || raw.Args[0] != "PT Maju Jaya" || raw.Args[1] != "APPROVED" {
+		t.Fatalf("args=%v err=%v", raw.Args, err)
+	}
+	if want := fmt.Sprintf("LIMIT %d", partnerListLimit+1); !strings.HasSuffix(partnerApplicationsQuery, want) {
+		t.Fatalf("list query must end with %q", want)
+	}
+	if _, _, err := (&AIQueryService{}).sanitizeRawSQL(partnerApplicationsQuery); err != nil {
+		t.Fatalf("registered statement must pass raw SQL sanitizer: %v", err)
+	}
+}
+
+func TestExecuteQuery_PartnerScopeRejectsBeforeDB(t *testing.T) {
+	a := &AIQueryService{} // tanpa DB: penolakan harus terjadi sebelum query dijalankan
+	ctx := withPartnerScope(context.Background(), "PT Maju Jaya")
+	plans := []*domain.SQLPlan{
+		{Operation: "SELECT", SQL: "SELECT full_name, monthly_income FROM user_profiles"},
+		{Operation: "SELECT", Table: "user_profiles"},
+		{Operation: "SELECT", Table: "kpr_applications", Aggregates: []domain.Aggregate{{Func: "avg", Column: "loan_amount"}}},
+		{Operation: "SELECT", Table: "kpr_applications", Filters: []domain.Filter{{Column: "user_id", Op: "=", Value: "5"}}},
+	}
+	for _, p := range plans {
+		if _, err := a.ExecuteQuery(ctx, p); err != errPartnerScope {
+			t.Errorf("plan %+v: err=%v", p, err)
+		}
+	}
+}
+
+func TestSanitizePlanForScope_PartnerOnTopOfPrivacy(t *testing.T) {
+	// scope developer tetap berlaku walau sanitasi umum dilonggarkan (relaxed)
+	a := &AIQueryService{relaxed: true}
+	p := &domain.SQLPlan{Operation: "SELECT", Table: "kpr_applications", Limit: 500}
+	if err := a.sanitizePlanForScope(p, "PT Maju Jaya"); err != nil {
+		t.Fatal(err)
+	}
+	if p.Limit != partnerListLimit+1 || len(p.Filters) != 1 || p.Filters[0].Column != "developer_name" {
+		t.Fatalf("plan=%+v", p)
+	}
+	// count per status proyek sendiri tidak terkena ambang k-anonymity nasabah
+	count := partnerCountPlan(domain.PartnerQuery{})
+	if err := (&AIQueryService{}).sanitizePlanForScope(count, "PT Maju Jaya"); err != nil || count.MinGroupSize != 0 {
+		t.Fatalf("count plan: %+v err=%v", count, err)
+	}
+	// tanpa scope developer: aturan tabel sensitif nasabah tetap berlaku
+	if err := (&AIQueryService{}).sanitizePlanForScope(&domain.SQLPlan{Operation: "SELECT", Table: "kpr_applications"}, ""); err == nil {
+		t.Fatalf("mass access without partner scope allowed")
+	}
+}
+
+func TestMaskPartnerResult(t *testing.T) {
+	rs := &domain.ResultSet{
+		Columns: []domain.ResultColumn{{Name: "application_number"}, {Name: "buyer_name"}, {Name: "loan_amount"}, {Name: "total"}},
+		Rows:    [][]interface{}{{"KPR-2025-001", "budi  santoso", 500_000_000.0, int64(3)}},
+		Masked:  [][]bool{{false, false, false, false}},
+	}
+	maskPartnerResult(rs, partnerCountPlan(domain.PartnerQuery{}))
+	row := rs.Rows[0]
+	if row[0] != "KPR-2025-001" || row[1] != "B*** S***" || row[2] != nil || !rs.Masked[0][2] || row[3] != int64(3) {
+		t.Fatalf("row=%v masked=%v", row, rs.Masked[0])
+	}
+	if got := maskBuyerName("Siti"); got != "S***" {
+		t.Errorf("maskBuyerName=%q", got)
+	}
+}
+
+func TestParsePartnerQuery(t *testing.T) {
+	now := time.Date(2026, 3, 18, 10, 0, 0, 0, wib)
+	cases := []struct {
+		text, status, app, from, to string
+	}{
+		{"rekap pengajuan bulan ini", "", "", "2026-03-01", "2026-04-01"},
+		{"berapa yang disetujui 3 bulan terakhir?", "APPROVED", "", "2025-12-18", "2026-03-19"},
+		{"pengajuan yang ditolak", "REJECTED", "", "", ""},
+		{"yang masih analisa kredit", "CREDIT_ANALYSIS", "", "", ""},
+		{"status kpr-2025-001 bulan ini", "", "KPR-2025-001", "", ""},
+		{"berapa pengajuan proyek kami", "", "", "", ""},
+	}
+	day := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	}
+	for _, c := range cases {
+		q := parsePartnerQuery(c.text, now)
+		if q.Status != c.status || q.ApplicationNumber != c.app || day(q.From) != c.from || day(q.To) != c.to {
+			t.Errorf("%q: got %+v", c.text, q)
+		}
+	}
+}
+
+// fakePartnerQuery mencatat rencana dan scope yang diterima ExecuteQuery
+type fakePartnerQuery struct {
+	domain.AIQueryService
+	scopes []string
+	plans  []*domain.SQLPlan
+}
+
+func (f *fakePartnerQuery) ExecuteQuery(ctx context.Context, plan *domain.SQLPlan) (*domain.ResultSet, error) {
+	f.scopes = append(f.scopes, partnerFromContext(ctx))
+	f.plans = append(f.plans, plan)
+	if plan.SQL == "" {
+		return &domain.ResultSet{
+			Columns: []domain.ResultColumn{{Name: "status"}, {Name: "total"}},
+			Rows:    [][]interface{}{{"REJECTED", int64(1)}, {"APPROVED", int64(2)}, {"SUBMITTED", int64(4)}},
+		}, nil
+	}
+	created := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	rs := &domain.ResultSet{Columns: []domain.ResultColumn{{Name: "application_number"}, {Name: "status"},
+		{Name: "property_type"}, {Name: "buyer_name"}, {Name: "created_at"}, {Name: "submitted_at"}}}
+	for i := 0; i < partnerListLimit+1; i++ {
+		rs.Rows = append(rs.Rows, []interface{}{"KPR-2026-0" + string(rune('A'+i)), "SUBMITTED", "RUMAH", "B*** S***", created, nil})
+	}
+	return rs, nil
+}
+
+func TestPartnerPortfolio(t *testing.T) {
+	f := &fakePartnerQuery{}
+	s := NewPartnerService(nil, f)
+	p, err := s.Portfolio(context.Background(), domain.DeveloperPartner{ID: 7, DeveloperName: "PT Maju Jaya"}, domain.PartnerQuery{Status: " submitted "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.scopes) != 2 || f.scopes[0] != "PT Maju Jaya" || f.scopes[1] != "PT Maju Jaya" {
+		t.Fatalf("scopes=%v", f.scopes)
+	}
+	if f.plans[1].SQL != partnerApplicationsQuery || f.plans[1].Args[1] != "SUBMITTED" {
+		t.Fatalf("list plan=%+v", f.plans[1])
+	}
+	if p.Total != 7 || p.Statuses[0].Status != "SUBMITTED" || p.Statuses[2].Status != "REJECTED" {
+		t.Fatalf("statuses=%+v total=%d", p.Statuses, p.Total)
+	}
+	if len(p.Applications) != partnerListLimit || !p.Truncated || p.Applications[0].SubmittedAt != nil {
+		t.Fatalf("applications=%d truncated=%v", len(p.Applications), p.Truncated)
+	}
+
+	got := formatPartnerPortfolio(p)
+	for _, want := range []string{"*Pengajuan proyek PT Maju Jaya* — status submitted", "Total 7 pengajuan", "• approved: 2",
+		"1. KPR-2026-0A — submitted — B*** S*** (2 Maret 2026)", "hanya 20 terbaru", "detail keuangan tidak tersedia"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestFormatPartnerPortfolio_Single(t *testing.T) {
+	submitted := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+	p := &domain.PartnerPortfolio{DeveloperName: "PT Maju Jaya", Query: domain.PartnerQuery{ApplicationNumber: "KPR-2025-001"}, Total: 1,
+		Applications: []domain.PartnerApplication{{ApplicationNumber: "KPR-2025-001", Status: "CREDIT_ANALYSIS", BuyerName: "B*** S***",
+			CreatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), SubmittedAt: &submitted}}}
+	got := formatPartnerPortfolio(p)
+	for _, want := range []string{"*KPR-2025-001* (PT Maju Jaya)", "Pembeli: B*** S***", "Status: credit analysis", "Diajukan: 5 Maret 2026"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	p.Applications = nil
+	if got := formatPartnerPortfolio(p); got != "Pengajuan KPR-2025-001 tidak ditemukan pada proyek PT Maju Jaya." {
+		t.Errorf("got %q", got)
+	}
+}
+
+// brokenDB: setiap query gagal, mensimulasikan registry developer yang tidak terbaca
+type brokenDB struct{}
+
+func (brokenDB) Query(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, errors.New("connection refused")
+}
+func (brokenDB) Exec(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, errors.New("connection refused")
+}
+func (brokenDB) WithTx(context.Context, func(*sql.Tx) error) error {
+	return errors.New("connection refused")
+}
+func (brokenDB) Close() error { return nil }
+
+func TestPartnerHandleCommand_FailsClosedOnLookupError(t *testing.T) {
+	s := NewPartnerService(brokenDB{}, &fakePartnerQuery{})
+	reply, ok := s.HandleCommand(context.Background(), "628555", "berapa pengajuan proyek saya?")
+	if !ok || reply == "" {
+		t.Fatalf("lookup error must not fall through to the customer flow: reply=%q ok=%v", reply, ok)
+	}
+}
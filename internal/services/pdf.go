@@ -0,0 +1,80 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Penulis PDF minimal: satu font Courier (bawaan semua pembaca PDF), teks rata kiri,
+// satu halaman A4 per elemen pages. Cukup untuk tabel laporan tanpa dependensi eksternal.
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMarginLeft = 36
+	pdfMarginTop  = 42
+	pdfFontSize   = 8
+	pdfLeading    = 11
+)
+
+// pdfEscape meng-escape teks untuk string literal PDF; karakter di luar Latin-1 menjadi '?'
+func pdfEscape(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 256:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// renderTextPDF membuat dokumen PDF 1.4 dengan nomor halaman di kaki setiap halaman
+func renderTextPDF(pages [][]string) []byte {
+	if len(pages) == 0 {
+		pages = [][]string{{""}}
+	}
+	var out bytes.Buffer
+	offsets := []int{}
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	out.WriteString("%PDF-1.4\n")
+
+	// objek 1: katalog, 2: daftar halaman, 3: font; lalu pasangan (halaman, konten) per halaman
+	kids := ""
+	for i := range pages {
+		kids += fmt.Sprintf("%d 0 R ", 4+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	for i, lines := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLeading, pdfMarginLeft, pdfPageHeight-pdfMarginTop)
+		for _, l := range lines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(l))
+		}
+		content.WriteString("ET\n")
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d %d Td\n(Halaman %d/%d) Tj\nET\n", pdfFontSize, pdfMarginLeft, pdfMarginTop/2, i+1, len(pages))
+
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 5+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
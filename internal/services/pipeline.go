@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// Jendela default dan maksimum analitik pipeline (hari)
+const (
+	pipelineDefaultDays = 30
+	pipelineMaxDays     = 365
+)
+
+// priorityOrder: urutan tampilan backlog
+var priorityOrder = map[string]int{"URGENT": 0, "HIGH": 1, "NORMAL": 2, "LOW": 3}
+
+// pipelineCommandPattern: "analitik approval", "statistik pipeline 90 hari", "backlog approval"
+var pipelineCommandPattern = regexp.MustCompile(`(?i)^\s*(?:analitik|statistik|kinerja|performa|metrik|backlog)\s+(?:pipeline|approval|persetujuan)(?:\s+(\d{1,3})\s*hari)?\s*[?.!]*\s*$`)
+
+// parsePipelineCommand mengembalikan jendela hari; ok=false bila bukan perintah analitik
+func parsePipelineCommand(text string) (days int, ok bool) {
+	m := pipelineCommandPattern.FindStringSubmatch(text)
+	if m == nil {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(m[1])
+	return clampPipelineDays(n), true
+}
+
+// clampPipelineDays: 0/negatif = default, dibatasi pipelineMaxDays
+func clampPipelineDays(n int) int {
+	switch {
+	case n <= 0:
+		return pipelineDefaultDays
+	case n > pipelineMaxDays:
+		return pipelineMaxDays
+	}
+	return n
+}
+
+// PipelineAnalyticsService menghitung metrik approval_workflow untuk kepala cabang (chat) dan dashboard (REST)
+type PipelineAnalyticsService struct {
+	db domain.DatabaseService
+}
+
+func NewPipelineAnalyticsService(db domain.DatabaseService) *PipelineAnalyticsService {
+	return &PipelineAnalyticsService{db: db}
+}
+
+// staffBranchCTE memetakan users.id staf ke satu branch_code (baris aktif terbaru)
+const staffBranchCTE = `staff_branch AS (
+	SELECT DISTINCT ON (b.user_id) b.user_id, b.branch_code
+	FROM branch_staff b
+	ORDER BY b.user_id, COALESCE(b.is_active, true) DESC, b.start_date DESC
+)`
+
+// $1 = branch_code (kosong = semua), $2 = jendela hari (backlog: kondisi saat ini, tanpa jendela).
+// Cabang sebuah item = cabang staf yang ditugaskan.
+const (
+	pipelineStageQuery = `WITH ` + staffBranchCTE + `
+SELECT w.stage::text, count(*), avg(EXTRACT(EPOCH FROM w.completed_at - w.started_at)),
+	percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM w.completed_at - w.started_at))
+FROM approval_workflow w LEFT JOIN staff_branch sb ON sb.user_id = w.assigned_to
+WHERE w.started_at IS NOT NULL AND w.completed_at >= w.started_at
+	AND w.completed_at >= LOCALTIMESTAMP - make_interval(days => $2) AND ($1 = '' OR sb.branch_code = $1)
+GROUP BY 1 ORDER BY 1`
+
+	pipelineBacklogQuery = `WITH ` + staffBranchCTE + `
+SELECT COALESCE(w.priority::text, 'NORMAL'), count(*), count(*) FILTER (WHERE w.due_date < LOCALTIMESTAMP)
+FROM approval_workflow w LEFT JOIN staff_branch sb ON sb.user_id = w.assigned_to
+WHERE w.completed_at IS NULL AND COALESCE(w.status::text, 'PENDING') IN ` + openWorkflowStatuses + `
+	AND ($1 = '' OR sb.branch_code = $1)
+GROUP BY 1`
+
+	pipelineEscalationQuery = `WITH ` + staffBranchCTE + `
+SELECT count(*), count(*) FILTER (WHERE w.escalated_at IS NOT NULL)
+FROM approval_workflow w LEFT JOIN staff_branch sb ON sb.user_id = w.assigned_to
+WHERE w.created_at >= LOCALTIMESTAMP - make_interval(days => $2) AND ($1 = '' OR sb.branch_code = $1)`
+
+	pipelineThroughputQuery = `WITH ` + staffBranchCTE + `
+SELECT sb.branch_code, count(*), count(DISTINCT w.assigned_to)
+FROM approval_workflow w JOIN staff_branch sb ON sb.user_id = w.assigned_to
+WHERE w.completed_at >= LOCALTIMESTAMP - make_interval(days => $2) AND ($1 = '' OR sb.branch_code = $1)
+GROUP BY 1 ORDER BY 1`
+)
+
+func (s *PipelineAnalyticsService) Pipeline(ctx context.Context, branchCode string, days int) (*domain.PipelineAnalytics, error) {
+	branchCode = strings.ToUpper(strings.TrimSpace(branchCode))
+	days = clampPipelineDays(days)
+	out := &domain.PipelineAnalytics{BranchCode: branchCode, Days: days, GeneratedAt: time.Now(),
+		Stages: []domain.PipelineStageMetric{}, Backlog: []domain.PipelineBacklog{}, Throughput: []domain.PipelineBranchThroughput{}}
+
+	err := s.scan(ctx, pipelineStageQuery, []interface{}{branchCode, days}, func(scan func(...interface{}) error) error {
+		var m domain.PipelineStageMetric
+		var avg, p90 float64
+		if err := scan(&m.Stage, &m.Completed, &avg, &p90); err != nil {
+			return err
+		}
+		m.AvgHours, m.P90Hours = roundTo(avg/3600, 1), roundTo(p90/3600, 1)
+		out.Stages = append(out.Stages, m)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pipeline stages: %w", err)
+	}
+	err = s.scan(ctx, pipelineBacklogQuery, []interface{}{branchCode}, func(scan func(...interface{}) error) error {
+		var b domain.PipelineBacklog
+		if err := scan(&b.Priority, &b.Open, &b.Overdue); err != nil {
+			return err
+		}
+		out.Backlog = append(out.Backlog, b)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pipeline backlog: %w", err)
+	}
+	sortBacklog(out.Backlog)
+	err = s.scan(ctx, pipelineEscalationQuery, []interface{}{branchCode, days}, func(scan func(...interface{}) error) error {
+		return scan(&out.Items, &out.Escalated)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pipeline escalation: %w", err)
+	}
+	out.EscalationRate = escalationRate(out.Items, out.Escalated)
+	err = s.scan(ctx, pipelineThroughputQuery, []interface{}{branchCode, days}, func(scan func(...interface{}) error) error {
+		var t domain.PipelineBranchThroughput
+		if err := scan(&t.BranchCode, &t.Completed, &t.Assignees); err != nil {
+			return err
+		}
+		if t.Assignees > 0 {
+			t.PerAssignee = roundTo(float64(t.Completed)/float64(t.Assignees), 1)
+		}
+		out.Throughput = append(out.Throughput, t)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pipeline throughput: %w", err)
+	}
+	return out, nil
+}
+
+// scan menjalankan query lalu memanggil fn per baris
+func (s *PipelineAnalyticsService) scan(ctx context.Context, query string, args []interface{}, fn func(scan func(...interface{}) error) error) error {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows.Scan); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// sortBacklog: URGENT, HIGH, NORMAL, LOW, lalu prioritas lain secara alfabetis
+func sortBacklog(b []domain.PipelineBacklog) {
+	rank := func(p string) int {
+		if r, ok := priorityOrder[strings.ToUpper(p)]; ok {
+			return r
+		}
+		return len(priorityOrder)
+	}
+	sort.SliceStable(b, func(i, j int) bool {
+		if ri, rj := rank(b[i].Priority), rank(b[j].Priority); ri != rj {
+			return ri < rj
+		}
+		return b[i].Priority < b[j].Priority
+	})
+}
+
+// escalationRate: fraksi item yang dieskalasi (0 bila tidak ada item)
+func escalationRate(items, escalated int) float64 {
+	if items <= 0 {
+		return 0
+	}
+	return roundTo(float64(escalated)/float64(items), 4)
+}
+
+func roundTo(v float64, dec int) float64 {
+	p := math.Pow(10, float64(dec))
+	return math.Round(v*p) / p
+}
+
+// formatHours: 5.5 -> "5,5 jam", 50 -> "2,1 hari"
+func formatHours(h float64) string {
+	if h >= 48 {
+		return formatNumberID(h/24, 1) + " hari"
+	}
+	return formatNumberID(h, 1) + " jam"
+}
+
+// formatPipeline merender analitik untuk WhatsApp
+func formatPipeline(p *domain.PipelineAnalytics) string {
+	var b strings.Builder
+	scope := "semua cabang"
+	if p.BranchCode != "" {
+		scope = "cabang " + p.BranchCode
+	}
+	fmt.Fprintf(&b, "📊 *Analitik approval %s* (%d hari terakhir)\n", scope, p.Days)
+
+	b.WriteString("\n*Waktu per tahap* (rata-rata / p90):\n")
+	if len(p.Stages) == 0 {
+		b.WriteString("• belum ada tahap selesai\n")
+	}
+	for _, m := range p.Stages {
+		fmt.Fprintf(&b, "• %s: %s / %s (%d selesai)\n", humanizeEnum(m.Stage), formatHours(m.AvgHours), formatHours(m.P90Hours), m.Completed)
+	}
+
+	b.WriteString("\n*Backlog saat ini*:\n")
+	if len(p.Backlog) == 0 {
+		b.WriteString("• kosong\n")
+	}
+	for _, bl := range p.Backlog {
+		fmt.Fprintf(&b, "• %s: %d terbuka, %d lewat jatuh tempo\n", strings.ToUpper(bl.Priority), bl.Open, bl.Overdue)
+	}
+
+	fmt.Fprintf(&b, "\n*Eskalasi*: %d dari %d item (%s)\n", p.Escalated, p.Items, formatRateFraction(p.EscalationRate))
+
+	b.WriteString("\n*Throughput*:\n")
+	if len(p.Throughput) == 0 {
+		b.WriteString("• belum ada item selesai\n")
+	}
+	for _, t := range p.Throughput {
+		fmt.Fprintf(&b, "• %s: %d selesai oleh %d staf (%s per staf)\n", t.BranchCode, t.Completed, t.Assignees, formatNumberID(t.PerAssignee, 1))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// HandleCommand: "analitik approval [N hari]" khusus kepala cabang aktif, dibatasi ke cabangnya
+func (s *PipelineAnalyticsService) HandleCommand(ctx context.Context, phone, text string) (string, bool) {
+	days, ok := parsePipelineCommand(text)
+	if !ok || strings.TrimSpace(phone) == "" {
+		return "", false
+	}
+	rows, err := s.db.Query(ctx, `SELECT b.branch_code FROM branch_staff b JOIN users u ON u.id = b.user_id
+WHERE u.phone = $1 AND `+activeStaffFilter+` AND `+managerPositionFilter+`
+LIMIT 1`, phone)
+	if err != nil {
+		log.Printf("[PIPELINE] manager lookup error: %v", err)
+		return "", false
+	}
+	var branch string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&branch)
+	}
+	rows.Close()
+	if err != nil || !found {
+		return "", false // bukan kepala cabang: perlakukan sebagai pesan biasa
+	}
+	p, err := s.Pipeline(ctx, branch, days)
+	if err != nil {
+		log.Printf("[PIPELINE] %s: %v", branch, err)
+		return "Maaf, analitik approval belum bisa dihitung saat ini.", true
+	}
+	return formatPipeline(p), true
+}
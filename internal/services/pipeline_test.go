@@ -0,0 +1,83 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestParsePipelineCommand(t *testing.T) {
+	cases := []struct {
+		text string
+		days int
+		ok   bool
+	}{
+		{"analitik approval", pipelineDefaultDays, true},
+		{"Statistik pipeline 90 hari", 90, true},
+		{"backlog persetujuan?", pipelineDefaultDays, true},
+		{"kinerja approval 999 hari", pipelineMaxDays, true},
+		{"analitik approval 0 hari", pipelineDefaultDays, true},
+		{"status approval KPR-2025-001", 0, false},
+		{"berapa analitik approval bulan ini", 0, false},
+	}
+	for _, c := range cases {
+		days, ok := parsePipelineCommand(c.text)
+		if ok != c.ok || days != c.days {
+			t.Errorf("%q: got (%d,%v) want (%d,%v)", c.text, days, ok, c.days, c.ok)
+		}
+	}
+}
+
+func TestSortBacklog(t *testing.T) {
+	b := []domain.PipelineBacklog{{Priority: "LOW"}, {Priority: "CUSTOM"}, {Priority: "URGENT"}, {Priority: "NORMAL"}, {Priority: "HIGH"}}
+	sortBacklog(b)
+	var got []string
+	for _, x := range b {
+		got = append(got, x.Priority)
+	}
+	if strings.Join(got, ",") != "URGENT,HIGH,NORMAL,LOW,CUSTOM" {
+		t.Fatalf("order=%v", got)
+	}
+}
+
+func TestEscalationRate(t *testing.T) {
+	cases := []struct {
+		items, escalated int
+		want             float64
+	}{
+		{0, 0, 0},
+		{3, 1, 0.3333},
+		{8, 2, 0.25},
+	}
+	for _, c := range cases {
+		if got := escalationRate(c.items, c.escalated); got != c.want {
+			t.Errorf("escalationRate(%d,%d)=%v want %v", c.items, c.escalated, got, c.want)
+		}
+	}
+}
+
+func TestFormatPipeline(t *testing.T) {
+	p := &domain.PipelineAnalytics{
+		BranchCode: "JKT01", Days: 30,
+		Stages:         []domain.PipelineStageMetric{{Stage: "CREDIT_ANALYSIS", Completed: 12, AvgHours: 5.5, P90Hours: 50}},
+		Backlog:        []domain.PipelineBacklog{{Priority: "URGENT", Open: 3, Overdue: 1}},
+		Items:          8,
+		Escalated:      2,
+		EscalationRate: 0.25,
+		Throughput:     []domain.PipelineBranchThroughput{{BranchCode: "JKT01", Completed: 12, Assignees: 4, PerAssignee: 3}},
+	}
+	got := formatPipeline(p)
+	for _, want := range []string{"cabang JKT01", "30 hari", "credit analysis: 5,5 jam / 2,1 hari (12 selesai)",
+		"URGENT: 3 terbuka, 1 lewat jatuh tempo", "2 dari 8 item (25%)", "JKT01: 12 selesai oleh 4 staf (3 per staf)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	empty := formatPipeline(&domain.PipelineAnalytics{Days: 7})
+	for _, want := range []string{"semua cabang", "belum ada tahap selesai", "kosong", "belum ada item selesai"} {
+		if !strings.Contains(empty, want) {
+			t.Errorf("missing %q in:\n%s", want, empty)
+		}
+	}
+}
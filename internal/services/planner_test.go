@@ -0,0 +1,116 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestDecodePlanJSON(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		table   string
+		filters []domain.Filter
+		limit   int
+	}{
+		{
+			name:    "escaped quote in value",
+			in:      `{"version":1,"operation":"SELECT","table":"kpr_applications","filters":[{"column":"developer_name","op":"=","value":"PT \"Griya\" Asri"}]}`,
+			table:   "kpr_applications",
+			filters: []domain.Filter{{Column: "developer_name", Op: "=", Value: `PT "Griya" Asri`}},
+			limit:   20,
+		},
+		{
+			name:    "numeric value and limit",
+			in:      `{"operation":"SELECT","table":"kpr_applications","filters":[{"column":"loan_term_years","op":"=","value":15}],"limit":5}`,
+			table:   "kpr_applications",
+			filters: []domain.Filter{{Column: "loan_term_years", Op: "=", Value: "15"}},
+			limit:   5,
+		},
+		{
+			name:  "multiple filters with different field order",
+			in:    "```json\n{\"table\":\"kpr_rates\",\"filters\":[{\"value\":true,\"column\":\"is_active\",\"op\":\"=\"},{\"op\":\"=\",\"column\":\"rate_type\",\"value\":\"FIXED\"}],\"operation\":\"SELECT\"}\n```",
+			table: "kpr_rates",
+			filters: []domain.Filter{
+				{Column: "is_active", Op: "=", Value: "true"},
+				{Column: "rate_type", Op: "=", Value: "FIXED"},
+			},
+			limit: 20,
+		},
+		{
+			name:  "nested objects are ignored outside known fields",
+			in:    `{"operation":"SELECT","table":"kpr_rates","meta":{"reason":{"why":"x"}},"columns":["Rate_Name"]}`,
+			table: "kpr_rates",
+			limit: 20,
+		},
+	}
+	for _, c := range cases {
+		p, err := decodePlanJSON(c.in)
+		if err != nil {
+			t.Fatalf("%s: decode error: %v", c.name, err)
+		}
+		if p.Table != c.table || p.Limit != c.limit || p.Version == 0 {
+			t.Fatalf("%s: got table=%q limit=%d version=%d", c.name, p.Table, p.Limit, p.Version)
+		}
+		if len(p.Filters) != len(c.filters) {
+			t.Fatalf("%s: filters=%v; want %v", c.name, p.Filters, c.filters)
+		}
+		for i := range c.filters {
+			if !reflect.DeepEqual(p.Filters[i], c.filters[i]) {
+				t.Fatalf("%s: filter[%d]=%v; want %v", c.name, i, p.Filters[i], c.filters[i])
+			}
+		}
+	}
+}
+
+func TestDecodePlanJSON_RejectsObjectValue(t *testing.T) {
+	_, err := decodePlanJSON(`{"table":"users","filters":[{"column":"id","op":"=","value":{"$gt":1}}]}`)
+	if err == nil {
+		t.Fatalf("expected error for object value")
+	}
+}
+
+func TestValidatePlan(t *testing.T) {
+	a := &AIQueryService{}
+	ok := &domain.SQLPlan{Version: 1, Operation: "SELECT", Table: "kpr_rates", Columns: []string{"rate_name"}, Filters: []domain.Filter{{Column: "is_active", Op: "=", Value: "true"}}, Limit: 5}
+	if errs := a.validatePlan(ok); len(errs) != 0 {
+		t.Fatalf("valid plan rejected: %v", errs)
+	}
+	bad := &domain.SQLPlan{Version: 9, Operation: "DELETE", Table: "kpr_rates", Columns: []string{"nope"}, Filters: []domain.Filter{{Column: "ghost", Op: "=", Value: "1"}}}
+	errs := a.validatePlan(bad)
+	joined := strings.Join(errs, "; ")
+	for _, want := range []string{"version 9", "operation harus SELECT", `kolom "nope"`, `kolom "ghost"`} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("errors %q missing %q", joined, want)
+		}
+	}
+	if errs := a.validatePlan(&domain.SQLPlan{Operation: "SELECT", Table: "secrets"}); len(errs) == 0 {
+		t.Fatalf("table outside whitelist accepted")
+	}
+}
+
+func TestDateRangeFilter(t *testing.T) {
+	now := time.Date(2026, 3, 18, 10, 30, 0, 0, wib)
+	f, ok := dateRangeFilter("berapa pengajuan bulan lalu?", "kpr_applications", now)
+	want := domain.Filter{Column: "created_at", Op: opBetween, Values: []string{"2026-02-01 00:00:00", "2026-02-28 23:59:59"}}
+	if !ok || !reflect.DeepEqual(f, want) {
+		t.Fatalf("got %+v ok=%v", f, ok)
+	}
+	plan := &domain.SQLPlan{Version: 1, Operation: "SELECT", Table: "kpr_applications", Filters: []domain.Filter{f}, Limit: 20}
+	if errs := (&AIQueryService{}).validatePlan(plan); len(errs) != 0 {
+		t.Fatalf("date filter rejected: %v", errs)
+	}
+	if _, ok := dateRangeFilter("status pengajuan saya", "kpr_applications", now); ok {
+		t.Fatalf("no date in text")
+	}
+	if _, ok := dateRangeFilter("bulan ini", "no_such_table", now); ok {
+		t.Fatalf("table without created_at")
+	}
+	if hint := dateRangeHint("3 bulan terakhir", now); !strings.Contains(hint, `"2025-12-18 00:00:00", "2026-03-18 23:59:59"`) {
+		t.Fatalf("hint=%q", hint)
+	}
+}
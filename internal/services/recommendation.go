@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// recommendationMaxPicks: jumlah produk yang dijelaskan di chat
+const recommendationMaxPicks = 3
+
+// occupationSegment memetakan kata pada user_profiles.occupation ke segmen dan kata yang mungkin
+// muncul pada enum customer_segment
+type occupationSegment struct {
+	name     string
+	keywords []string
+	segments []string
+}
+
+// occupationSegments dicek berurutan; yang pertama cocok dipakai
+var occupationSegments = []occupationSegment{
+	{"pegawai pemerintah", []string{"pns", "asn", "pegawai negeri", "tni", "polri", "polisi", "tentara", "bumn"},
+		[]string{"pns", "asn", "government", "pemerintah", "civil"}},
+	{"profesional", []string{"dokter", "notaris", "pengacara", "advokat", "arsitek", "akuntan", "konsultan", "profesional", "professional"},
+		[]string{"profesional", "professional"}},
+	{"wiraswasta", []string{"wiraswasta", "wirausaha", "pengusaha", "usaha", "pedagang", "entrepreneur", "freelance", "pekerja lepas"},
+		[]string{"wiraswasta", "wirausaha", "entrepreneur", "self", "business", "usaha"}},
+	{"karyawan", []string{"karyawan", "pegawai", "staf", "staff", "employee", "buruh", "guru", "perawat"},
+		[]string{"karyawan", "employee", "payroll", "salaried", "pegawai"}},
+}
+
+// youngSegments: segmen produk untuk nasabah muda (usia maksimal youngMaxAge)
+var youngSegments = []string{"milenial", "millennial", "millenial", "young", "muda"}
+
+const youngMaxAge = 35
+
+// metroCities: kota dengan pasokan apartemen; di luar kota ini produk apartemen diturunkan sedikit
+var metroCities = []string{"jakarta", "surabaya", "bandung", "medan", "semarang", "makassar", "tangerang", "bekasi", "depok", "bogor", "batam", "denpasar", "yogyakarta"}
+
+// segmentFor mengembalikan segmen pekerjaan dan kata segmen enum yang cocok
+func segmentFor(occupation string) (string, []string) {
+	occ := strings.ToLower(occupation)
+	for _, s := range occupationSegments {
+		for _, k := range s.keywords {
+			if strings.Contains(occ, k) {
+				return s.name, s.segments
+			}
+		}
+	}
+	return "", nil
+}
+
+func isGenericSegment(segment string) bool {
+	seg := strings.ToLower(segment)
+	if seg == "" {
+		return true
+	}
+	for _, g := range genericSegments {
+		if strings.Contains(seg, g) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(s string, words []string) bool {
+	for _, w := range words {
+		if strings.Contains(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// recommendProduct menilai satu produk untuk profil (fungsi murni). Skor: segmen cocok, sisa tenor
+// terhadap max_age, kelonggaran penghasilan terhadap min_income, bunga, promo, dan kecocokan properti.
+// Highlights dan Reasons tidak pernah menyebut penghasilan atau usia pemohon.
+func recommendProduct(r domain.KPRRate, p domain.RecommendationProfile, segment string, segWords []string, today time.Time) domain.ProductRecommendation {
+	pr := domain.ProductRecommendation{Rate: r}
+	want := p.TenorYears
+	if want <= 0 {
+		want = affordabilityDefaultTenor
+	}
+
+	productSeg := strings.ToLower(r.CustomerSegment)
+	switch {
+	case isGenericSegment(productSeg):
+		pr.Score++
+	case containsAny(productSeg, segWords):
+		pr.Score += 3
+		pr.Highlights = append(pr.Highlights, "khusus segmen "+segment+", sesuai pekerjaan kamu")
+	case containsAny(productSeg, youngSegments) && p.Age > 0 && p.Age <= youngMaxAge:
+		pr.Score += 2
+		pr.Highlights = append(pr.Highlights, "program nasabah muda")
+	default:
+		pr.Reasons = append(pr.Reasons, "khusus segmen "+humanizeEnum(r.CustomerSegment))
+	}
+
+	pr.TenorYears = r.MaxTermYears
+	if pr.TenorYears <= 0 || pr.TenorYears > 40 {
+		pr.TenorYears = 40
+	}
+	if p.Age > 0 && r.MaxAge > 0 && p.Age+pr.TenorYears > r.MaxAge {
+		pr.TenorYears = r.MaxAge - p.Age
+	}
+	switch {
+	case pr.TenorYears <= 0 || (r.MinTermYears > 0 && pr.TenorYears < r.MinTermYears):
+		pr.Reasons = append(pr.Reasons, fmt.Sprintf("usia saat lunas melebihi %d tahun", r.MaxAge))
+	case pr.TenorYears >= want:
+		pr.Score += 2
+		pr.Highlights = append(pr.Highlights, fmt.Sprintf("tenor hingga %d tahun masih dalam batas usia produk", pr.TenorYears))
+	default:
+		pr.Score += 2 * float64(pr.TenorYears) / float64(want)
+		pr.Highlights = append(pr.Highlights, fmt.Sprintf("tenor maksimal %d tahun karena batas usia %d tahun", pr.TenorYears, r.MaxAge))
+	}
+
+	if r.MinIncome > 0 {
+		if p.MonthlyIncome < r.MinIncome {
+			pr.Reasons = append(pr.Reasons, "penghasilan di bawah minimum produk "+formatRupiah(r.MinIncome))
+		} else {
+			pr.Score += math.Min(2, (p.MonthlyIncome/r.MinIncome-1)*4)
+			pr.Highlights = append(pr.Highlights, "penghasilan memenuhi minimum produk")
+		}
+	} else {
+		pr.Score++
+	}
+
+	if p.PropertyType != "" {
+		if enumMatches(r.PropertyType, p.PropertyType, propertyTypeSynonyms[strings.ToLower(p.PropertyType)]) {
+			pr.Score++
+			pr.Highlights = append(pr.Highlights, "sesuai jenis properti pengajuan kamu")
+		} else {
+			pr.Reasons = append(pr.Reasons, "khusus properti "+strings.ToLower(r.PropertyType))
+		}
+	}
+	if enumMatches(r.PropertyType, "apartemen", propertyTypeSynonyms["apartemen"]) && strings.TrimSpace(p.City) != "" {
+		if containsAny(strings.ToLower(p.City), metroCities) {
+			pr.Score += 0.5
+		} else {
+			pr.Score -= 0.5
+		}
+	}
+
+	// bunga lebih rendah lebih baik: selisih 1% = 0,2 poin
+	pr.Score -= r.EffectiveRate * 20
+	if promoActive(r, today) {
+		pr.Score += 0.5
+		pr.Highlights = append(pr.Highlights, "sedang promo")
+	}
+	pr.Score = roundTo(pr.Score, 2)
+	pr.Eligible = len(pr.Reasons) == 0
+	return pr
+}
+
+// recommendProducts mengurutkan produk (fungsi murni): yang memenuhi syarat lebih dulu, lalu skor tertinggi
+func recommendProducts(rates []domain.KPRRate, p domain.RecommendationProfile, today time.Time) *domain.RecommendationResult {
+	segment, segWords := segmentFor(p.Occupation)
+	res := &domain.RecommendationResult{Segment: segment, Products: []domain.ProductRecommendation{}}
+	for _, r := range rates {
+		res.Products = append(res.Products, recommendProduct(r, p, segment, segWords, today))
+	}
+	sort.SliceStable(res.Products, func(i, j int) bool {
+		pi, pj := res.Products[i], res.Products[j]
+		if pi.Eligible != pj.Eligible {
+			return pi.Eligible
+		}
+		return pi.Score > pj.Score
+	})
+	return res
+}
+
+// Recommend mengurutkan produk aktif kpr_rates menurut kecocokan dengan profil pemohon
+func (s *KPRCalculatorService) Recommend(ctx context.Context, profile domain.RecommendationProfile) (*domain.RecommendationResult, error) {
+	if profile.MonthlyIncome <= 0 || profile.Age <= 0 {
+		return nil, fmt.Errorf("profil belum lengkap: penghasilan dan usia wajib")
+	}
+	rates, err := s.ActiveRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("belum ada produk KPR aktif")
+	}
+	return recommendProducts(rates, profile, time.Now().In(wib)), nil
+}
+
+// -------------------------
+// Rekomendasi lewat chat
+// -------------------------
+
+// recommendationProfileQuery: profil pemohon dan jenis properti pengajuan terbarunya (bila ada)
+const recommendationProfileQuery = `SELECT up.birth_date, up.occupation, up.monthly_income, COALESCE(up.city, ''),
+	COALESCE((SELECT a.property_type::text FROM kpr_applications a WHERE a.user_id = u.id ORDER BY a.created_at DESC LIMIT 1), '')
+FROM user_profiles up JOIN users u ON u.id = up.user_id
+WHERE u.phone = $1
+LIMIT 1`
+
+// recommendationProfile membaca profil milik nomor pengirim. Data ini hanya dipakai untuk
+// perhitungan di server dan tidak pernah dikirim ke LLM, dicatat, atau ditampilkan.
+func (a *AIQueryService) recommendationProfile(ctx context.Context, phone string) (domain.RecommendationProfile, bool, error) {
+	var p domain.RecommendationProfile
+	rows, err := a.db.Query(ctx, recommendationProfileQuery, phone)
+	if err != nil {
+		return p, false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return p, false, rows.Err()
+	}
+	var birth sql.NullTime
+	var income sql.NullFloat64
+	if err := rows.Scan(&birth, &p.Occupation, &income, &p.City, &p.PropertyType); err != nil {
+		return p, false, err
+	}
+	if birth.Valid {
+		p.Age = ageAt(birth.Time, time.Now().In(wib))
+	}
+	p.MonthlyIncome = income.Float64
+	return p, true, nil
+}
+
+const recommendationNoProfile = "Rekomendasi produk memakai profil kamu (usia, pekerjaan, penghasilan, domisili), tapi profil untuk nomor ini belum lengkap. " +
+	"Lengkapi profil di aplikasi, atau coba *cek kelayakan gaji 15 juta umur 30*."
+
+// formatRecommendation merender rekomendasi tanpa menyebut data profil pemohon
+func formatRecommendation(res *domain.RecommendationResult) string {
+	var b strings.Builder
+	b.WriteString("*Rekomendasi produk KPR untuk kamu*\n")
+	if res.Segment != "" {
+		fmt.Fprintf(&b, "_Berdasarkan profil kamu (segmen %s)._\n", res.Segment)
+	} else {
+		b.WriteString("_Berdasarkan profil kamu._\n")
+	}
+	picks, skipped := 0, 0
+	for _, p := range res.Products {
+		if !p.Eligible {
+			skipped++
+			continue
+		}
+		if picks == recommendationMaxPicks {
+			continue
+		}
+		picks++
+		fmt.Fprintf(&b, "\n*%d. %s* — bunga %s (%s)\n", picks, p.Rate.RateName, formatRateFraction(p.Rate.EffectiveRate), strings.ToLower(p.Rate.RateType))
+		for _, h := range p.Highlights {
+			b.WriteString("• " + h + "\n")
+		}
+	}
+	if picks == 0 {
+		b.WriteString("\nBelum ada produk aktif yang sesuai dengan profil kamu.\n")
+		for _, p := range res.Products {
+			fmt.Fprintf(&b, "• %s: %s\n", p.Rate.RateName, strings.Join(p.Reasons, "; "))
+		}
+	} else if skipped > 0 {
+		fmt.Fprintf(&b, "\n%d produk lain belum sesuai dengan profil kamu.\n", skipped)
+	}
+	b.WriteString("_Ketik *simulasi* dengan nama produk untuk menghitung angsuran. Hasil indikatif; keputusan akhir mengikuti analisa kredit BNI._")
+	return b.String()
+}
+
+// answerRecommendation menjawab "produk kpr apa yang cocok untuk saya?" dari user_profiles milik
+// nomor pengirim. Profil tidak pernah masuk prompt LLM.
+func (a *AIQueryService) answerRecommendation(ctx context.Context, phone, text string) (string, bool) {
+	if a.calculator == nil {
+		return "", false
+	}
+	if strings.TrimSpace(phone) == "" {
+		return "Rekomendasi produk memakai profil kamu, jadi hanya tersedia lewat chat WhatsApp dari nomor yang terdaftar.", true
+	}
+	if a.db == nil {
+		return "", false
+	}
+	profile, found, err := a.recommendationProfile(ctx, phone)
+	if err != nil {
+		log.Printf("[AI] recommendation profile error: %v", err)
+		return "Maaf, rekomendasi produk belum bisa dibuat saat ini.", true
+	}
+	if !found || profile.MonthlyIncome <= 0 || profile.Age <= 0 {
+		return recommendationNoProfile, true
+	}
+	sim := parseSimulationInput(text)
+	profile.TenorYears = sim.TenorYears
+	res, err := a.calculator.Recommend(ctx, profile)
+	if err != nil {
+		log.Printf("[AI] recommendation error: %v", err)
+		return "Maaf, rekomendasi produk belum bisa dibuat saat ini.", true
+	}
+	return formatRecommendation(res), true
+}
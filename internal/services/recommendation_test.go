@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestSegmentFor(t *testing.T) {
+	cases := []struct {
+		occupation string
+		want       string
+	}{
+		{"PNS Kemenkeu", "pegawai pemerintah"},
+		{"Pegawai Negeri Sipil", "pegawai pemerintah"},
+		{"Dokter", "profesional"},
+		{"Wiraswasta", "wiraswasta"},
+		{"Karyawan Swasta", "karyawan"},
+		{"Pegawai BUMN", "pegawai pemerintah"},
+		{"Mahasiswa", ""},
+	}
+	for _, c := range cases {
+		if got, _ := segmentFor(c.occupation); got != c.want {
+			t.Errorf("segmentFor(%q)=%q want %q", c.occupation, got, c.want)
+		}
+	}
+}
+
+func recommendationFixture() []domain.KPRRate {
+	base := domain.KPRRate{RateType: "FIXED", PropertyType: "RUMAH", CustomerSegment: "GENERAL", MinTermYears: 1, MaxTermYears: 30, MaxAge: 65, MinIncome: 5_000_000}
+	general := base
+	general.ID, general.RateName, general.EffectiveRate = 1, "Griya Umum", 0.08
+	payroll := base
+	payroll.ID, payroll.RateName, payroll.EffectiveRate, payroll.CustomerSegment = 2, "Griya Payroll", 0.085, "EMPLOYEE"
+	entre := base
+	entre.ID, entre.RateName, entre.EffectiveRate, entre.CustomerSegment = 3, "Griya Usaha", 0.07, "ENTREPRENEUR"
+	premium := base
+	premium.ID, premium.RateName, premium.EffectiveRate, premium.MinIncome = 4, "Griya Premium", 0.06, 50_000_000
+	short := base
+	short.ID, short.RateName, short.EffectiveRate, short.MaxAge, short.MinTermYears = 5, "Griya Senior", 0.075, 55, 10
+	apt := base
+	apt.ID, apt.RateName, apt.EffectiveRate, apt.PropertyType = 6, "Griya Apartemen", 0.08, "APARTEMEN"
+	return []domain.KPRRate{general, payroll, entre, premium, short, apt}
+}
+
+func TestRecommendProducts(t *testing.T) {
+	today := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	p := domain.RecommendationProfile{Age: 48, Occupation: "Karyawan Swasta", MonthlyIncome: 15_000_000, City: "Kabupaten Garut"}
+	res := recommendProducts(recommendationFixture(), p, today)
+	if res.Segment != "karyawan" {
+		t.Fatalf("segment=%q", res.Segment)
+	}
+	var names []string
+	for _, pr := range res.Products {
+		names = append(names, pr.Rate.RateName)
+	}
+	// segmen cocok di atas produk umum; apartemen diturunkan di luar kota besar; yang gagal di belakang
+	if got := strings.Join(names, ","); got != "Griya Payroll,Griya Umum,Griya Apartemen,Griya Usaha,Griya Premium,Griya Senior" {
+		t.Fatalf("order=%s", got)
+	}
+	top := res.Products[0]
+	if !top.Eligible || top.TenorYears != 17 {
+		t.Fatalf("top=%+v", top)
+	}
+	for _, pr := range res.Products[3:] {
+		if pr.Eligible || len(pr.Reasons) == 0 {
+			t.Fatalf("%s should not be eligible: %+v", pr.Rate.RateName, pr)
+		}
+	}
+	if got := strings.Join(res.Products[5].Reasons, ";"); !strings.Contains(got, "usia saat lunas melebihi 55 tahun") {
+		t.Fatalf("senior reasons=%s", got)
+	}
+
+	// pengajuan apartemen sebelumnya: produk rumah tidak cocok
+	p.PropertyType, p.City = "APARTEMEN", "Jakarta Selatan"
+	res = recommendProducts(recommendationFixture(), p, today)
+	if res.Products[0].Rate.RateName != "Griya Apartemen" {
+		t.Fatalf("top=%s", res.Products[0].Rate.RateName)
+	}
+}
+
+func TestFormatRecommendation_HidesProfile(t *testing.T) {
+	p := domain.RecommendationProfile{Age: 30, Occupation: "Karyawan", MonthlyIncome: 17_250_000, City: "Bandung"}
+	got := formatRecommendation(recommendProducts(recommendationFixture(), p, time.Now()))
+	for _, want := range []string{"segmen karyawan", "*1. Griya Payroll* — bunga 8,5% (fixed)", "penghasilan memenuhi minimum produk", "produk lain belum sesuai"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	for _, leak := range []string{"17.250.000", "Bandung"} {
+		if strings.Contains(got, leak) {
+			t.Errorf("profile value %q leaked:\n%s", leak, got)
+		}
+	}
+
+	none := formatRecommendation(recommendProducts(recommendationFixture()[3:4], p, time.Now()))
+	if !strings.Contains(none, "Belum ada produk aktif") || !strings.Contains(none, "Griya Premium: penghasilan di bawah minimum produk Rp 50.000.000") {
+		t.Errorf("got %q", none)
+	}
+}
+
+func TestAnswerRecommendation_RequiresPhone(t *testing.T) {
+	a := &AIQueryService{calculator: NewKPRCalculatorService(nil, 0.11, 0.01, 0.35)}
+	reply, ok := a.answerRecommendation(context.Background(), "", "produk apa yang cocok untuk saya")
+	if !ok || !strings.Contains(reply, "hanya tersedia lewat chat WhatsApp") {
+		t.Fatalf("reply=%q ok=%v", reply, ok)
+	}
+}
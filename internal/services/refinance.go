@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// refinanceMaxOptions: jumlah promo yang ditampilkan di chat
+const refinanceMaxOptions = 3
+
+// refinanceLoan adalah data pinjaman pengajuan yang dibandingkan dengan promo
+type refinanceLoan struct {
+	Number        string
+	PropertyType  string
+	PropertyValue float64
+	Loan          float64
+	Rate          float64
+	Years         int
+	ApprovedAt    time.Time
+	Now           time.Time
+}
+
+// promoActive: produk promosi yang masa promonya mencakup hari ini (tanggal kosong = tanpa batas)
+func promoActive(r domain.KPRRate, today time.Time) bool {
+	if !r.IsPromotional {
+		return false
+	}
+	d := today.Format("2006-01-02")
+	if r.PromoStartDate != nil && r.PromoStartDate.Format("2006-01-02") > d {
+		return false
+	}
+	if r.PromoEndDate != nil && r.PromoEndDate.Format("2006-01-02") < d {
+		return false
+	}
+	return true
+}
+
+// monthsElapsed: jumlah bulan penuh dari from sampai now (minimal 0)
+func monthsElapsed(from, now time.Time) int {
+	m := (now.Year()-from.Year())*12 + int(now.Month()-from.Month())
+	if now.Day() < from.Day() {
+		m--
+	}
+	if m < 0 {
+		return 0
+	}
+	return m
+}
+
+// buildRefinanceAdvice membandingkan sisa pinjaman dengan tiap promo aktif (fungsi murni).
+// Sisa pokok diambil dari jadwal anuitas pengajuan setelah angsuran yang sudah jatuh tempo;
+// pinjaman baru = sisa pokok dengan tenor = sisa tenor. Biaya pindah = provisi/admin, appraisal,
+// dan notaris produk baru ditambah penalti pelunasan dari sisa pokok.
+func buildRefinanceAdvice(loan refinanceLoan, rates []domain.KPRRate, floating, penalty float64) *domain.RefinanceAdvice {
+	n := loan.Years * 12
+	cur := amortizationSchedule(fixedLoanSimulation(loan.Loan, loan.Rate, n))
+	k := monthsElapsed(loan.ApprovedAt, loan.Now)
+	if k > n {
+		k = n
+	}
+	adv := &domain.RefinanceAdvice{
+		ApplicationNumber: loan.Number,
+		CurrentRate:       loan.Rate,
+		MonthsPaid:        k,
+		RemainingMonths:   n - k,
+		Balance:           loan.Loan,
+		PenaltyRate:       penalty,
+		FloatingRate:      floating,
+		Options:           []domain.RefinanceOption{},
+	}
+	if k > 0 {
+		adv.Balance = cur.Rows[k-1].Balance
+	}
+	if adv.RemainingMonths == 0 || adv.Balance <= 0 {
+		return adv
+	}
+	remaining := cur.Rows[k:]
+	adv.CurrentInstallment = remaining[0].Installment
+	for _, row := range remaining {
+		adv.CurrentRemaining += row.Installment
+	}
+
+	m := adv.RemainingMonths
+	years := (m + 11) / 12
+	price := loan.PropertyValue
+	if price <= 0 {
+		price = adv.Balance
+	}
+	for _, r := range rates {
+		if !promoActive(r, loan.Now) {
+			continue
+		}
+		periods := installmentPeriods(r, adv.Balance, m, productFixedYears(r, years)*12, floating)
+		next := amortizationSchedule(&domain.SimulationResult{Principal: adv.Balance, TenorMonths: m, Periods: periods})
+		opt := domain.RefinanceOption{
+			Rate:          r,
+			Periods:       periods,
+			TotalPayment:  next.TotalPayment,
+			SwitchingCost: r.AdminFee + r.AppraisalFee + (r.AdminFeePercent+r.NotaryFeePercent+penalty)*adv.Balance,
+			Violations:    simulationViolations(r, price, price-adv.Balance, adv.Balance, years),
+		}
+		if !enumMatches(r.PropertyType, loan.PropertyType, propertyTypeSynonyms[strings.ToLower(loan.PropertyType)]) {
+			opt.Violations = append(opt.Violations, "khusus properti "+strings.ToLower(r.PropertyType))
+		}
+		opt.GrossSaving = adv.CurrentRemaining - opt.TotalPayment
+		opt.NetSaving = opt.GrossSaving - opt.SwitchingCost
+		if opt.NetSaving > 0 {
+			var cum float64
+			for i, row := range next.Rows {
+				cum += remaining[i].Installment - row.Installment
+				if cum >= opt.SwitchingCost {
+					opt.BreakEvenMonth = i + 1
+					break
+				}
+			}
+		}
+		adv.Options = append(adv.Options, opt)
+	}
+	// promo yang memenuhi syarat lebih dulu, lalu penghematan bersih terbesar
+	sort.SliceStable(adv.Options, func(i, j int) bool {
+		oi, oj := adv.Options[i], adv.Options[j]
+		if (len(oi.Violations) == 0) != (len(oj.Violations) == 0) {
+			return len(oi.Violations) == 0
+		}
+		return oi.NetSaving > oj.NetSaving
+	})
+	return adv
+}
+
+// refinanceLoanQuery: tanpa nomor pengajuan, pengajuan yang sudah disetujui didahulukan
+const refinanceLoanQuery = `SELECT a.application_number, a.loan_amount, a.interest_rate, a.loan_term_years, a.status::text,
+	a.property_value, a.property_type::text, COALESCE(a.approved_at, a.created_at), LOCALTIMESTAMP
+FROM kpr_applications a JOIN users u ON u.id = a.user_id
+WHERE ($1 = '' OR a.application_number = $1) AND ($2 = '' OR u.phone = $2)
+ORDER BY (a.status::text IN ('APPROVED', 'DISBURSED')) DESC, COALESCE(a.approved_at, a.created_at) DESC
+LIMIT 1`
+
+// Refinance menganalisis take-over pengajuan yang sudah disetujui ke promo kpr_rates yang aktif
+func (s *KPRCalculatorService) Refinance(ctx context.Context, applicationNumber, phone string) (*domain.RefinanceAdvice, error) {
+	applicationNumber = strings.ToUpper(strings.TrimSpace(applicationNumber))
+	phone = strings.TrimSpace(phone)
+	if applicationNumber == "" && phone == "" {
+		return nil, ErrApplicationNotFound
+	}
+	rows, err := s.db.Query(ctx, refinanceLoanQuery, applicationNumber, phone)
+	if err != nil {
+		return nil, fmt.Errorf("kpr_applications: %w", err)
+	}
+	var loan refinanceLoan
+	var status string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&loan.Number, &loan.Loan, &loan.Rate, &loan.Years, &status,
+			&loan.PropertyValue, &loan.PropertyType, &loan.ApprovedAt, &loan.Now)
+	} else {
+		err = rows.Err()
+	}
+	rows.Close()
+	switch {
+	case err != nil:
+		return nil, err
+	case !found:
+		return nil, ErrApplicationNotFound
+	case !approvedStatuses[strings.ToUpper(status)]:
+		return nil, ErrApplicationNotApproved
+	case loan.Loan <= 0 || loan.Years <= 0:
+		return nil, fmt.Errorf("data pinjaman %s tidak lengkap", loan.Number)
+	}
+	rates, err := s.ActiveRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return buildRefinanceAdvice(loan, rates, s.floatingRate, s.prepaymentPenalty), nil
+}
+
+// formatRefinance merender analisis take-over untuk WhatsApp, termasuk asumsi perhitungannya
+func formatRefinance(adv *domain.RefinanceAdvice) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Analisis take-over KPR %s*\n", adv.ApplicationNumber)
+	if adv.RemainingMonths == 0 || adv.Balance <= 0 {
+		b.WriteString("Menurut jadwal angsuran, pinjaman ini sudah lunas sehingga tidak perlu dipindahkan.")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Pinjaman saat ini: bunga %s, %d angsuran sudah berjalan, sisa tenor %s.\n",
+		formatRateFraction(adv.CurrentRate), adv.MonthsPaid, formatTenorMonths(adv.RemainingMonths))
+	fmt.Fprintf(&b, "Sisa pokok ± %s, angsuran %s/bulan, sisa total angsuran %s.\n",
+		formatRupiah(adv.Balance), formatRupiah(adv.CurrentInstallment), formatRupiah(adv.CurrentRemaining))
+
+	if len(adv.Options) == 0 {
+		b.WriteString("\nSaat ini belum ada promo KPR aktif untuk dibandingkan.")
+		return b.String()
+	}
+	for i, o := range adv.Options {
+		if i == refinanceMaxOptions {
+			break
+		}
+		fmt.Fprintf(&b, "\n*%d. %s*\n", i+1, o.Rate.RateName)
+		writeInstallmentPeriods(&b, o.Periods)
+		fmt.Fprintf(&b, "• Biaya pindah: %s\n", formatRupiah(o.SwitchingCost))
+		fmt.Fprintf(&b, "• Total angsuran baru: %s\n", formatRupiah(o.TotalPayment))
+		if o.NetSaving > 0 {
+			fmt.Fprintf(&b, "• Hemat bersih: *%s*, balik modal di bulan ke-%d\n", formatRupiah(o.NetSaving), o.BreakEvenMonth)
+		} else {
+			fmt.Fprintf(&b, "• Tidak lebih hemat: lebih mahal %s setelah biaya\n", formatRupiah(math.Abs(o.NetSaving)))
+		}
+		if len(o.Violations) > 0 {
+			fmt.Fprintf(&b, "⚠️ Belum memenuhi syarat produk: %s.\n", strings.Join(o.Violations, "; "))
+		}
+	}
+
+	best := adv.Options[0]
+	if len(best.Violations) == 0 && best.NetSaving > 0 {
+		fmt.Fprintf(&b, "\nPindah ke *%s* paling menguntungkan: hemat bersih %s dan biaya pindah kembali di bulan ke-%d.\n",
+			best.Rate.RateName, formatRupiah(best.NetSaving), best.BreakEvenMonth)
+	} else {
+		b.WriteString("\nDengan asumsi di bawah, belum ada promo yang memenuhi syarat dan lebih hemat dari pinjaman kamu saat ini.\n")
+	}
+	fmt.Fprintf(&b, "_Asumsi: sisa pokok dihitung dari jadwal anuitas sejak tanggal persetujuan (angsuran pertama sebulan setelahnya, tanpa pelunasan sebagian) dan bunga pinjaman saat ini tetap; "+
+		"tenor baru = sisa tenor; biaya pindah = provisi/admin, appraisal, dan notaris produk baru ditambah penalti pelunasan %s dari sisa pokok; "+
+		"setelah masa fixed promo dipakai bunga floating indikatif %s; asuransi dan pajak tidak dihitung._",
+		formatRateFraction(adv.PenaltyRate), formatRateFraction(adv.FloatingRate))
+	return b.String()
+}
+
+// answerRefinance menjawab "worth it tidak pindah ke promo?" untuk pengajuan milik nomor pengirim
+func (a *AIQueryService) answerRefinance(ctx context.Context, phone, text string) (string, bool) {
+	if a.calculator == nil {
+		return "", false
+	}
+	if strings.TrimSpace(phone) == "" {
+		// phone kosong berarti pemanggil tepercaya bagi kalkulator; jangan dipakai untuk chat
+		return "Analisis take-over memakai data pinjaman kamu, jadi hanya tersedia lewat chat WhatsApp dari nomor yang terdaftar.", true
+	}
+	app := a.applicationFor(phone, text)
+	adv, err := a.calculator.Refinance(ctx, app, phone)
+	switch {
+	case errors.Is(err, ErrApplicationNotFound) && app != "":
+		return fmt.Sprintf("Pengajuan %s tidak ditemukan untuk nomor WhatsApp ini.", app), true
+	case errors.Is(err, ErrApplicationNotFound):
+		return "Belum ada pengajuan KPR atas nomor WhatsApp ini, jadi belum ada pinjaman yang bisa dibandingkan dengan promo.", true
+	case errors.Is(err, ErrApplicationNotApproved):
+		return "Analisis take-over baru bisa dihitung setelah pengajuan KPR kamu disetujui.", true
+	case err != nil:
+		log.Printf("[AI] refinance error: %v", err)
+		return "Maaf, analisis take-over belum bisa dihitung saat ini.", true
+	}
+	return formatRefinance(adv), true
+}
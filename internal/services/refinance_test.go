@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestPromoActive(t *testing.T) {
+	today := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	day := func(y int, m time.Month, d int) *time.Time { return tp(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)) }
+	cases := []struct {
+		name string
+		r    domain.KPRRate
+		want bool
+	}{
+		{"not promotional", domain.KPRRate{}, false},
+		{"open ended", domain.KPRRate{IsPromotional: true}, true},
+		{"within window", domain.KPRRate{IsPromotional: true, PromoStartDate: day(2026, 3, 1), PromoEndDate: day(2026, 3, 31)}, true},
+		{"ends today", domain.KPRRate{IsPromotional: true, PromoEndDate: day(2026, 3, 10)}, true},
+		{"starts today", domain.KPRRate{IsPromotional: true, PromoStartDate: day(2026, 3, 10)}, true},
+		{"expired", domain.KPRRate{IsPromotional: true, PromoEndDate: day(2026, 3, 9)}, false},
+		{"not started", domain.KPRRate{IsPromotional: true, PromoStartDate: day(2026, 4, 1)}, false},
+	}
+	for _, c := range cases {
+		if got := promoActive(c.r, today); got != c.want {
+			t.Errorf("%s: got %v want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMonthsElapsed(t *testing.T) {
+	from := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		now  time.Time
+		want int
+	}{
+		{time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(2024, 2, 14, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC), 24},
+		{time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), 0},
+	}
+	for _, c := range cases {
+		if got := monthsElapsed(from, c.now); got != c.want {
+			t.Errorf("monthsElapsed(%s)=%d want %d", c.now.Format("2006-01-02"), got, c.want)
+		}
+	}
+}
+
+func refinanceFixture() (refinanceLoan, []domain.KPRRate) {
+	loan := refinanceLoan{
+		Number: "KPR-2024-007", PropertyType: "RUMAH", PropertyValue: 800_000_000,
+		Loan: 500_000_000, Rate: 0.10, Years: 20,
+		ApprovedAt: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		Now:        time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC),
+	}
+	base := domain.KPRRate{PropertyType: "RUMAH", MinTermYears: 1, MaxTermYears: 30, MaxLTVRatio: 0.9, MaxLoanAmount: 2_000_000_000, IsPromotional: true}
+	cheap := base
+	cheap.ID, cheap.RateName, cheap.RateType, cheap.EffectiveRate, cheap.AdminFee, cheap.AppraisalFee = 1, "Promo Griya 6%", "FIXED", 0.06, 1_000_000, 500_000
+	marginal := base
+	marginal.ID, marginal.RateName, marginal.RateType, marginal.EffectiveRate, marginal.AdminFeePercent = 2, "Promo Tipis", "FIXED", 0.099, 0.01
+	capped := base
+	capped.ID, capped.RateName, capped.RateType, capped.EffectiveRate, capped.MaxLoanAmount = 3, "Promo Mikro", "FIXED", 0.05, 100_000_000
+	regular := base
+	regular.ID, regular.RateName, regular.RateType, regular.EffectiveRate, regular.IsPromotional = 4, "Griya Reguler", "FIXED", 0.04, false
+	expired := cheap
+	expired.ID, expired.RateName, expired.PromoEndDate = 5, "Promo Lama", tp(time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC))
+	return loan, []domain.KPRRate{capped, regular, marginal, expired, cheap}
+}
+
+func TestBuildRefinanceAdvice(t *testing.T) {
+	loan, rates := refinanceFixture()
+	adv := buildRefinanceAdvice(loan, rates, 0.11, 0.01)
+
+	cur := amortizationSchedule(fixedLoanSimulation(loan.Loan, loan.Rate, loan.Years*12))
+	if adv.MonthsPaid != 24 || adv.RemainingMonths != 216 {
+		t.Fatalf("paid=%d remaining=%d", adv.MonthsPaid, adv.RemainingMonths)
+	}
+	if adv.Balance != cur.Rows[23].Balance || adv.CurrentInstallment != cur.Rows[24].Installment {
+		t.Fatalf("balance=%.2f installment=%.2f", adv.Balance, adv.CurrentInstallment)
+	}
+
+	var names []string
+	for _, o := range adv.Options {
+		names = append(names, o.Rate.RateName)
+	}
+	// promo non-aktif/bukan promo dibuang; yang memenuhi syarat lebih dulu walau Promo Mikro lebih murah
+	if strings.Join(names, ",") != "Promo Griya 6%,Promo Tipis,Promo Mikro" {
+		t.Fatalf("options=%v", names)
+	}
+
+	best := adv.Options[0]
+	wantCost := 1_000_000 + 500_000 + 0.01*adv.Balance
+	if diff := best.SwitchingCost - wantCost; diff > 0.01 || diff < -0.01 {
+		t.Fatalf("switching cost=%.2f want %.2f", best.SwitchingCost, wantCost)
+	}
+	if best.NetSaving <= 0 || best.NetSaving != best.GrossSaving-best.SwitchingCost {
+		t.Fatalf("best=%+v", best)
+	}
+	// bulan balik modal: penghematan kumulatif pertama kali menutup biaya pindah
+	saving := adv.CurrentInstallment - best.Periods[0].MonthlyInstallment
+	if want := int(best.SwitchingCost/saving) + 1; best.BreakEvenMonth != want {
+		t.Fatalf("break-even=%d want %d", best.BreakEvenMonth, want)
+	}
+
+	marginal := adv.Options[1]
+	if marginal.NetSaving > 0 || marginal.BreakEvenMonth != 0 {
+		t.Fatalf("marginal=%+v", marginal)
+	}
+	if len(adv.Options[2].Violations) == 0 {
+		t.Fatalf("capped promo should report violations")
+	}
+}
+
+func TestBuildRefinanceAdvice_PaidOff(t *testing.T) {
+	loan, rates := refinanceFixture()
+	loan.Years = 1
+	adv := buildRefinanceAdvice(loan, rates, 0.11, 0.01)
+	if adv.RemainingMonths != 0 || len(adv.Options) != 0 {
+		t.Fatalf("adv=%+v", adv)
+	}
+	if got := formatRefinance(adv); !strings.Contains(got, "sudah lunas") {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestFormatRefinance(t *testing.T) {
+	loan, rates := refinanceFixture()
+	got := formatRefinance(buildRefinanceAdvice(loan, rates, 0.11, 0.01))
+	for _, want := range []string{"KPR-2024-007", "24 angsuran", "sisa tenor 18 tahun", "*1. Promo Griya 6%*", "Hemat bersih",
+		"Tidak lebih hemat", "⚠️ Belum memenuhi syarat produk: plafon maksimal Rp 100.000.000", "Pindah ke *Promo Griya 6%*",
+		"penalti pelunasan 1%", "floating indikatif 11%"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Promo Lama") || strings.Contains(got, "Griya Reguler") {
+		t.Errorf("inactive or non-promo product listed:\n%s", got)
+	}
+
+	none := formatRefinance(buildRefinanceAdvice(loan, rates[1:2], 0.11, 0.01))
+	if !strings.Contains(none, "belum ada promo KPR aktif") {
+		t.Errorf("got %q", none)
+	}
+}
+
+func TestAnswerRefinance_RequiresPhone(t *testing.T) {
+	a := &AIQueryService{calculator: NewKPRCalculatorService(nil, 0.11, 0.01, 0.35)}
+	reply, ok := a.answerRefinance(context.Background(), "", "take over KPR-2024-007 ke promo")
+	if !ok || !strings.Contains(reply, "hanya tersedia lewat chat WhatsApp") {
+		t.Fatalf("reply=%q ok=%v", reply, ok)
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// resultRows adalah bagian *sql.Rows yang dipakai scanResultSet (memudahkan pengujian)
+type resultRows interface {
+	Columns() ([]string, error)
+	ColumnTypes() ([]*sql.ColumnType, error)
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+}
+
+// isMaskedColumn: kolom PII tidak pernah dibawa keluar dari ExecuteQuery
+func isMaskedColumn(col string) bool {
+	_, ok := piiColumns[strings.ToLower(strings.TrimSpace(col))]
+	return ok
+}
+
+// scanResultSet membaca maksimal max baris menjadi domain.ResultSet bertipe.
+// Kolom PII dimasking; Truncated diset bila masih ada baris setelah batas.
+func scanResultSet(rows resultRows, max int) (*domain.ResultSet, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	types := make([]string, len(cols))
+	if cts, err := rows.ColumnTypes(); err == nil && len(cts) == len(cols) {
+		for i, ct := range cts {
+			types[i] = strings.ToUpper(ct.DatabaseTypeName())
+		}
+	}
+	rs := &domain.ResultSet{Columns: make([]domain.ResultColumn, len(cols))}
+	for i, c := range cols {
+		rs.Columns[i] = domain.ResultColumn{Name: c, DBType: types[i]}
+	}
+	for rows.Next() {
+		if len(rs.Rows) >= max {
+			rs.Truncated = true
+			break
+		}
+		vals := make([]interface{}, len(cols))
+		scans := make([]interface{}, len(cols))
+		for i := range vals {
+			scans[i] = &vals[i]
+		}
+		if err := rows.Scan(scans...); err != nil {
+			return nil, err
+		}
+		masked := make([]bool, len(cols))
+		for i, c := range cols {
+			if isMaskedColumn(c) {
+				vals[i], masked[i] = nil, true
+				continue
+			}
+			vals[i] = normalizeCell(types[i], vals[i])
+		}
+		rs.Rows = append(rs.Rows, vals)
+		rs.Masked = append(rs.Masked, masked)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// normalizeCell menyeragamkan nilai dari driver: []byte -> string, NUMERIC (teks) -> float64
+func normalizeCell(dbType string, v interface{}) interface{} {
+	switch t := v.(type) {
+	case []byte:
+		v = string(t)
+	case int:
+		return int64(t)
+	case int32:
+		return int64(t)
+	case float32:
+		return float64(t)
+	}
+	if s, ok := v.(string); ok {
+		switch dbType {
+		case "NUMERIC", "DECIMAL":
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f
+			}
+		case "INT2", "INT4", "INT8":
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n
+			}
+		}
+	}
+	return v
+}
+
+// resultString mengambil nilai kolom sebagai teks berformat ("" bila tidak ada/masked/NULL)
+func resultString(rs *domain.ResultSet, row int, col string) string {
+	if _, ok := rs.Value(row, col); !ok {
+		return ""
+	}
+	return strings.TrimSpace(formatResultValue(rs, row, rs.ColumnIndex(col)))
+}
+
+// renderResultText merender ResultSet untuk konteks AI dan fallback tanpa AI:
+// satu baris per record, "kolom: nilai" dipisah " | " sehingga nilai berspasi tetap utuh.
+func renderResultText(rs *domain.ResultSet) string {
+	if rs.Len() == 0 {
+		return "Tidak ada hasil."
+	}
+	var out strings.Builder
+	for r := range rs.Rows {
+		parts := make([]string, 0, len(rs.Columns))
+		for i, c := range rs.Columns {
+			val := formatResultValue(rs, r, i)
+			if rs.IsMasked(r, i) {
+				val = "[redacted]"
+			}
+			parts = append(parts, c.Name+": "+val)
+		}
+		out.WriteString(strings.Join(parts, " | "))
+		out.WriteString("\n")
+	}
+	if rs.Truncated {
+		fmt.Fprintf(&out, "(hanya %d baris pertama ditampilkan)\n", rs.Len())
+	}
+	return out.String()
+}
+
+// renderFacts merender fakta terstruktur untuk prompt: sel masked dan NULL dihilangkan
+func renderFacts(rs *domain.ResultSet) string {
+	if rs.Len() == 0 {
+		return "Tidak ada hasil.\n"
+	}
+	var out strings.Builder
+	for r := range rs.Rows {
+		parts := []string{}
+		for i, c := range rs.Columns {
+			if rs.IsMasked(r, i) || rs.Rows[r][i] == nil {
+				continue
+			}
+			parts = append(parts, strings.ToLower(c.Name)+": "+formatResultValue(rs, r, i))
+		}
+		if len(parts) > 0 {
+			out.WriteString(strings.Join(parts, " | "))
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func sampleResultSet() *domain.ResultSet {
+	return &domain.ResultSet{
+		Table: "kpr_applications",
+		Columns: []domain.ResultColumn{
+			{Name: "application_number", DBType: "VARCHAR"},
+			{Name: "status", DBType: "APPLICATION_STATUS"},
+			{Name: "property_address", DBType: "TEXT"},
+			{Name: "loan_amount", DBType: "NUMERIC"},
+			{Name: "submitted_at", DBType: "TIMESTAMP"},
+			{Name: "phone", DBType: "VARCHAR"},
+		},
+		Rows: [][]interface{}{{
+			"KPR-2026-001", "REVIEW", "Jl. Melati No. 5, Depok", 500000000.0,
+			time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC), nil,
+		}},
+		Masked: [][]bool{{false, false, false, false, false, true}},
+	}
+}
+
+func TestRenderResultText_KeepsValuesWithSpaces(t *testing.T) {
+	out := renderResultText(sampleResultSet())
+	for _, want := range []string{"property_address: Jl. Melati No. 5, Depok", "submitted_at: 16 Oktober 2026 pukul 09.30", "loan_amount: Rp 500.000.000", "phone: [redacted]"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in %q", want, out)
+		}
+	}
+	facts := renderFacts(sampleResultSet())
+	if strings.Contains(facts, "phone") {
+		t.Fatalf("facts must drop masked cells: %q", facts)
+	}
+	if renderResultText(&domain.ResultSet{}) != "Tidak ada hasil." {
+		t.Fatalf("empty result set rendering changed")
+	}
+}
+
+func TestSummarizers_ReadTypedValues(t *testing.T) {
+	rs := sampleResultSet()
+	if got := summarizeKPRApp(rs, ""); !strings.Contains(got, "KPR-2026-001") || !strings.Contains(got, "REVIEW") {
+		t.Fatalf("summarizeKPRApp=%q", got)
+	}
+	rates := &domain.ResultSet{
+		Columns: []domain.ResultColumn{{Name: "rate_name"}, {Name: "effective_rate"}},
+		Rows:    [][]interface{}{{"KPR Griya Fixed 3 Tahun", 0.0725}},
+	}
+	if got := summarizeGeneral("", rates); !strings.Contains(got, "KPR Griya Fixed 3 Tahun") {
+		t.Fatalf("summarizeGeneral lost rate name with spaces: %q", got)
+	}
+	if got := summarizeGeneral("", &domain.ResultSet{}); got != "Data tidak ketemu." {
+		t.Fatalf("empty summarizeGeneral=%q", got)
+	}
+}
+
+func TestNormalizeCell(t *testing.T) {
+	if v := normalizeCell("NUMERIC", "500000000.00"); v != 500000000.0 {
+		t.Fatalf("numeric=%#v", v)
+	}
+	if v := normalizeCell("VARCHAR", []byte("abc")); v != "abc" {
+		t.Fatalf("bytes=%#v", v)
+	}
+	if v := normalizeCell("INT4", int32(7)); v != int64(7) {
+		t.Fatalf("int32=%#v", v)
+	}
+}
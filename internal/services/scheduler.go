@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// Job adalah pekerjaan berkala untuk JobScheduler. Run menerima waktu tick agar keputusan
+// "sudah waktunya?" ada di job itu sendiri (mis. jam kirim per zona waktu user).
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context, now time.Time) error
+}
+
+// JobScheduler menjalankan setiap job pada interval masing-masing sampai ctx selesai.
+// Job tidak pernah berjalan tumpang-tindih dengan dirinya sendiri; panic dicatat, bukan mematikan bot.
+type JobScheduler struct {
+	jobs []Job
+	now  func() time.Time
+}
+
+func NewJobScheduler(jobs ...Job) domain.Notifier {
+	return &JobScheduler{jobs: jobs, now: time.Now}
+}
+
+func (s *JobScheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		if j.Run == nil || j.Interval <= 0 {
+			log.Printf("[SCHED] skip job %q: invalid config", j.Name)
+			continue
+		}
+		wg.Add(1)
+		go func(j Job) {
+			defer wg.Done()
+			t := time.NewTicker(j.Interval)
+			defer t.Stop()
+			for {
+				s.runJob(ctx, j)
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+				}
+			}
+		}(j)
+	}
+	wg.Wait()
+}
+
+func (s *JobScheduler) runJob(ctx context.Context, j Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[SCHED] job %s panic: %v", j.Name, r)
+		}
+	}()
+	if err := j.Run(ctx, s.now()); err != nil {
+		log.Printf("[SCHED] job %s: %v", j.Name, err)
+	}
+}
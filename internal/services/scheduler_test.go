@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJobScheduler_RunsJobsUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var ticks, panics int32
+	s := NewJobScheduler(
+		Job{Name: "count", Interval: 5 * time.Millisecond, Run: func(ctx context.Context, now time.Time) error {
+			if atomic.AddInt32(&ticks, 1) == 3 {
+				cancel()
+			}
+			return nil
+		}},
+		// panic pada satu job tidak menghentikan scheduler
+		Job{Name: "panics", Interval: 5 * time.Millisecond, Run: func(ctx context.Context, now time.Time) error {
+			atomic.AddInt32(&panics, 1)
+			panic("boom")
+		}},
+		Job{Name: "invalid"},
+	)
+	done := make(chan struct{})
+	go func() { s.Run(ctx); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("scheduler did not stop after cancel")
+	}
+	if atomic.LoadInt32(&ticks) < 3 || atomic.LoadInt32(&panics) < 1 {
+		t.Fatalf("ticks=%d panics=%d", ticks, panics)
+	}
+}
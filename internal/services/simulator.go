@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/nlu"
+)
+
+// KPRCalculatorService menjalankan perhitungan KPR deterministik (simulasi angsuran, dst.)
+// berdasarkan produk aktif di kpr_rates. Angka tidak pernah dikarang oleh AI.
+type KPRCalculatorService struct {
+	db                domain.DatabaseService
+	floatingRate      float64 // suku bunga floating indikatif setelah periode fixed (pecahan)
+	prepaymentPenalty float64 // penalti pelunasan dipercepat untuk analisis take-over (pecahan sisa pokok)
+	maxDSR            float64 // batas total angsuran / penghasilan untuk kalkulator kemampuan pinjam
+}
+
+func NewKPRCalculatorService(db domain.DatabaseService, floatingRate, prepaymentPenalty, maxDSR float64) domain.KPRCalculatorService {
+	return &KPRCalculatorService{db: db, floatingRate: floatingRate, prepaymentPenalty: prepaymentPenalty, maxDSR: maxDSR}
+}
+
+const activeRatesQuery = `SELECT id, rate_name, rate_type::text, property_type::text, customer_segment::text,
+	base_rate, margin, effective_rate, min_loan_amount, max_loan_amount, min_term_years, max_term_years,
+	max_ltv_ratio, min_income, max_age, min_down_payment_percent,
+	COALESCE(admin_fee, 0), COALESCE(admin_fee_percent, 0), COALESCE(appraisal_fee, 0), COALESCE(insurance_rate, 0),
+	COALESCE(notary_fee_percent, 0), COALESCE(is_promotional, false), COALESCE(promo_description, ''), promo_start_date, promo_end_date, expiry_date
+FROM kpr_rates
+WHERE COALESCE(is_active, true) AND effective_date <= CURRENT_DATE AND (expiry_date IS NULL OR expiry_date >= CURRENT_DATE)
+ORDER BY effective_rate, id
+LIMIT 50`
+
+// ActiveRates mengambil produk KPR yang sedang berlaku, termurah lebih dulu
+func (s *KPRCalculatorService) ActiveRates(ctx context.Context) ([]domain.KPRRate, error) {
+	rows, err := s.db.Query(ctx, activeRatesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("kpr_rates: %w", err)
+	}
+	defer rows.Close()
+	out := []domain.KPRRate{}
+	for rows.Next() {
+		var r domain.KPRRate
+		var promoStart, promoEnd, expiry sql.NullTime
+		if err := rows.Scan(&r.ID, &r.RateName, &r.RateType, &r.PropertyType, &r.CustomerSegment,
+			&r.BaseRate, &r.Margin, &r.EffectiveRate, &r.MinLoanAmount, &r.MaxLoanAmount, &r.MinTermYears, &r.MaxTermYears,
+			&r.MaxLTVRatio, &r.MinIncome, &r.MaxAge, &r.MinDownPaymentPercent,
+			&r.AdminFee, &r.AdminFeePercent, &r.AppraisalFee, &r.InsuranceRate,
+			&r.NotaryFeePercent, &r.IsPromotional, &r.PromoDescription, &promoStart, &promoEnd, &expiry); err != nil {
+			return nil, err
+		}
+		if promoStart.Valid {
+			t := promoStart.Time
+			r.PromoStartDate = &t
+		}
+		if promoEnd.Valid {
+			t := promoEnd.Time
+			r.PromoEndDate = &t
+		}
+		if expiry.Valid {
+			t := expiry.Time
+			r.ExpiryDate = &t
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// Simulate menghitung angsuran untuk produk yang diminta (RateID) atau, bila kosong,
+// produk termurah yang memenuhi semua batasan. Bila tidak ada yang memenuhi, hasil produk
+// termurah dikembalikan beserta Violations.
+func (s *KPRCalculatorService) Simulate(ctx context.Context, req domain.SimulationRequest) (*domain.SimulationResult, error) {
+	if err := ValidateSimulationRequest(req); err != nil {
+		return nil, err
+	}
+	rates, err := s.ActiveRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("belum ada produk KPR aktif")
+	}
+	if req.FloatingRate <= 0 {
+		req.FloatingRate = s.floatingRate
+	}
+	if req.RateID > 0 {
+		for _, r := range rates {
+			if r.ID == req.RateID {
+				return simulateWithRate(r, req)
+			}
+		}
+		return nil, fmt.Errorf("produk KPR id=%d tidak aktif", req.RateID)
+	}
+	var first *domain.SimulationResult
+	for _, r := range rates {
+		res, err := simulateWithRate(r, req)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Violations) == 0 {
+			return res, nil
+		}
+		if first == nil {
+			first = res
+		}
+	}
+	return first, nil
+}
+
+// annuityPayment: angsuran anuitas bulanan untuk pokok p, bunga tahunan annual (pecahan), n bulan
+func annuityPayment(p, annual float64, n int) float64 {
+	if n <= 0 {
+		return 0
+	}
+	r := annual / 12
+	if r == 0 {
+		return p / float64(n)
+	}
+	return p * r / (1 - math.Pow(1+r, -float64(n)))
+}
+
+// remainingBalance: sisa pokok setelah k angsuran sebesar pay dengan bunga tahunan annual
+func remainingBalance(p, annual, pay float64, k int) float64 {
+	r := annual / 12
+	if r == 0 {
+		return math.Max(0, p-pay*float64(k))
+	}
+	g := math.Pow(1+r, float64(k))
+	return math.Max(0, p*g-pay*(g-1)/r)
+}
+
+var fixedYearsPattern = regexp.MustCompile(`(?i)fix(?:ed)?\s*(\d{1,2})\s*(?:tahun|thn|th|years?|y)`)
+
+// productFixedYears menentukan lama periode fixed: dari nama/deskripsi promo ("fixed 3 tahun"),
+// seluruh tenor untuk produk FIXED, atau 0 untuk produk FLOATING
+func productFixedYears(r domain.KPRRate, tenorYears int) int {
+	for _, s := range []string{r.RateName, r.PromoDescription} {
+		if m := fixedYearsPattern.FindStringSubmatch(s); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			return n
+		}
+	}
+	if strings.Contains(strings.ToUpper(r.RateType), "FLOAT") {
+		return 0
+	}
+	return tenorYears
+}
+
+// simulationViolations memeriksa permintaan terhadap batas produk
+func simulationViolations(r domain.KPRRate, price, dp, principal float64, tenorYears int) []string {
+	v := []string{}
+	if r.MinTermYears > 0 && tenorYears < r.MinTermYears || r.MaxTermYears > 0 && tenorYears > r.MaxTermYears {
+		v = append(v, fmt.Sprintf("tenor harus %d–%d tahun", r.MinTermYears, r.MaxTermYears))
+	}
+	if r.MinDownPaymentPercent > 0 && dp/price*100 < r.MinDownPaymentPercent-1e-9 {
+		v = append(v, fmt.Sprintf("DP minimal %s (%s)", formatPercent(r.MinDownPaymentPercent), formatRupiah(math.Ceil(price*r.MinDownPaymentPercent/100))))
+	}
+	if r.MaxLTVRatio > 0 && principal/price > r.MaxLTVRatio+1e-9 {
+		v = append(v, fmt.Sprintf("plafon maksimal %s dari harga rumah", formatRateFraction(r.MaxLTVRatio)))
+	}
+	if r.MinLoanAmount > 0 && principal < r.MinLoanAmount {
+		v = append(v, "plafon minimal "+formatRupiah(r.MinLoanAmount))
+	}
+	if r.MaxLoanAmount > 0 && principal > r.MaxLoanAmount {
+		v = append(v, "plafon maksimal "+formatRupiah(r.MaxLoanAmount))
+	}
+	return v
+}
+
+// ValidateSimulationRequest memeriksa input dasar sebelum produk apa pun dievaluasi
+// (dipakai juga oleh handler REST untuk membedakan input salah dari galat server)
+func ValidateSimulationRequest(req domain.SimulationRequest) error {
+	if req.PropertyPrice <= 0 {
+		return fmt.Errorf("harga rumah harus lebih dari 0")
+	}
+	if req.DownPayment < 0 || req.DownPayment >= req.PropertyPrice {
+		return fmt.Errorf("DP harus antara 0 dan harga rumah")
+	}
+	if req.TenorYears <= 0 || req.TenorYears > 40 {
+		return fmt.Errorf("tenor harus 1–40 tahun")
+	}
+	return nil
+}
+
+// simulateWithRate menghitung simulasi untuk satu produk (fungsi murni, tanpa DB)
+func simulateWithRate(r domain.KPRRate, req domain.SimulationRequest) (*domain.SimulationResult, error) {
+	if err := ValidateSimulationRequest(req); err != nil {
+		return nil, err
+	}
+	principal := req.PropertyPrice - req.DownPayment
+	n := req.TenorYears * 12
+	res := &domain.SimulationResult{
+		Rate:               r,
+		PropertyPrice:      req.PropertyPrice,
+		DownPayment:        req.DownPayment,
+		DownPaymentPercent: req.DownPayment / req.PropertyPrice * 100,
+		Principal:          principal,
+		TenorMonths:        n,
+		Violations:         simulationViolations(r, req.PropertyPrice, req.DownPayment, principal, req.TenorYears),
+	}
+
+	fixedYears := req.FixedYears
+	if fixedYears <= 0 {
+		fixedYears = productFixedYears(r, req.TenorYears)
+	}
+	res.Periods = installmentPeriods(r, principal, n, fixedYears*12, req.FloatingRate)
+	for _, p := range res.Periods {
+		res.TotalPayment += p.MonthlyInstallment * float64(p.ToMonth-p.FromMonth+1)
+	}
+	res.TotalInterest = res.TotalPayment - principal
+	return res, nil
+}
+
+// installmentPeriods membagi n bulan menjadi periode fixed (fixedMonths pertama) dan floating
+// (sisa pokok dihitung ulang dengan bunga floating; 0 = effective_rate produk)
+func installmentPeriods(r domain.KPRRate, principal float64, n, fixedMonths int, floating float64) []domain.SimulationPeriod {
+	if fixedMonths > n {
+		fixedMonths = n
+	}
+	if floating <= 0 {
+		floating = r.EffectiveRate
+	}
+	switch {
+	case fixedMonths == n:
+		pay := annuityPayment(principal, r.EffectiveRate, n)
+		return []domain.SimulationPeriod{{FromMonth: 1, ToMonth: n, AnnualRate: r.EffectiveRate, MonthlyInstallment: pay}}
+	case fixedMonths == 0:
+		// produk floating: bunga efektif produk berlaku sejak awal dan dapat berubah
+		pay := annuityPayment(principal, r.EffectiveRate, n)
+		return []domain.SimulationPeriod{{FromMonth: 1, ToMonth: n, AnnualRate: r.EffectiveRate, Floating: true, MonthlyInstallment: pay}}
+	default:
+		pay1 := annuityPayment(principal, r.EffectiveRate, n)
+		bal := remainingBalance(principal, r.EffectiveRate, pay1, fixedMonths)
+		pay2 := annuityPayment(bal, floating, n-fixedMonths)
+		return []domain.SimulationPeriod{
+			{FromMonth: 1, ToMonth: fixedMonths, AnnualRate: r.EffectiveRate, MonthlyInstallment: pay1},
+			{FromMonth: fixedMonths + 1, ToMonth: n, AnnualRate: floating, Floating: true, MonthlyInstallment: pay2},
+		}
+	}
+}
+
+// -------------------------
+// Simulasi lewat chat
+// -------------------------
+
+// simulationInput adalah hasil ekstraksi parameter simulasi dari teks chat
+type simulationInput struct {
+	Price      float64
+	DP         float64 // rupiah; 0 bila DPPercent dipakai
+	DPPercent  float64
+	TenorYears int
+	FixedYears int
+}
+
+// parseSimulationInput mengekstrak harga rumah, DP, tenor, dan periode fixed dari teks.
+// Nominal berlabel harga didahulukan; tanpa label, nominal terbesar dianggap harga rumah.
+func parseSimulationInput(text string) simulationInput {
+	in := simulationInput{}
+	var dpSet bool
+	var guess float64
+	for _, e := range nlu.Extract(text, time.Now()) {
+		switch {
+		case e.Kind == nlu.KindDuration && e.Label == nlu.LabelFixed:
+			in.FixedYears = e.Years()
+		case e.Kind == nlu.KindDuration && e.Label == nlu.LabelTenor:
+			in.TenorYears = e.Years()
+		case e.Kind == nlu.KindDuration && e.Label == "" && in.TenorYears == 0:
+			in.TenorYears = e.Years()
+		case e.Label == nlu.LabelDownPayment && !dpSet:
+			if e.Kind == nlu.KindPercent {
+				in.DPPercent, dpSet = e.Value, true
+			} else if e.Kind == nlu.KindAmount {
+				in.DP, dpSet = e.Value, true
+			}
+		case e.Kind == nlu.KindAmount && e.Label == nlu.LabelPrice && e.Value >= 1e6 && in.Price == 0:
+			in.Price = e.Value
+		case e.Kind == nlu.KindAmount && e.Label == "" && e.Value >= 1e7:
+			guess = math.Max(guess, e.Value)
+		}
+	}
+	if in.Price == 0 {
+		in.Price = guess
+	}
+	if in.DP == 0 && in.DPPercent > 0 && in.Price > 0 {
+		in.DP = math.Round(in.Price * in.DPPercent / 100)
+	}
+	return in
+}
+
+// matchRateByName memilih produk yang namanya disebut di teks (nama terpanjang menang)
+func matchRateByName(text string, rates []domain.KPRRate) int {
+	low := strings.ToLower(text)
+	sorted := append([]domain.KPRRate(nil), rates...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].RateName) > len(sorted[j].RateName) })
+	for _, r := range sorted {
+		if name := strings.ToLower(strings.TrimSpace(r.RateName)); name != "" && strings.Contains(low, name) {
+			return r.ID
+		}
+	}
+	return 0
+}
+
+const simulationHowTo = "Untuk simulasi, sebutkan harga rumah, DP, dan tenor. Contoh: *simulasi harga 1 miliar dp 20% tenor 20 tahun*."
+
+// writeInstallmentPeriods menulis baris angsuran per periode fixed/floating
+func writeInstallmentPeriods(b *strings.Builder, periods []domain.SimulationPeriod) {
+	for _, p := range periods {
+		kind := "fixed"
+		if p.Floating {
+			kind = "floating"
+		}
+		fmt.Fprintf(b, "Angsuran bulan %d–%d (%s %s): *%s*/bulan\n", p.FromMonth, p.ToMonth, kind, formatRateFraction(p.AnnualRate), formatRupiah(p.MonthlyInstallment))
+	}
+}
+
+// formatSimulation merender hasil simulasi untuk WhatsApp
+func formatSimulation(res *domain.SimulationResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Simulasi KPR — %s*\n", res.Rate.RateName)
+	fmt.Fprintf(&b, "Harga rumah: %s\n", formatRupiah(res.PropertyPrice))
+	fmt.Fprintf(&b, "Uang muka: %s (%s)\n", formatRupiah(res.DownPayment), formatPercent(res.DownPaymentPercent))
+	fmt.Fprintf(&b, "Plafon pinjaman: %s\n", formatRupiah(res.Principal))
+	fmt.Fprintf(&b, "Tenor: %s\n", formatTenorMonths(res.TenorMonths))
+	writeInstallmentPeriods(&b, res.Periods)
+	fmt.Fprintf(&b, "Total bunga: %s\n", formatRupiah(res.TotalInterest))
+	fmt.Fprintf(&b, "Total pembayaran: %s\n", formatRupiah(res.TotalPayment))
+	if len(res.Violations) > 0 {
+		fmt.Fprintf(&b, "\n⚠️ Belum memenuhi syarat produk: %s.\n", strings.Join(res.Violations, "; "))
+	}
+	b.WriteString("_Simulasi indikatif; bunga floating mengikuti suku bunga yang berlaku saat itu._")
+	return b.String()
+}
+
+// simulationRequestFromText menyusun SimulationRequest dari chat. reply tidak kosong bila input
+// belum lengkap/tidak valid; isinya adalah jawaban yang langsung dikirim ke user.
+// Simulasi yang valid diingat per nomor agar bisa diminta jadwal angsurannya.
+func (a *AIQueryService) simulationRequestFromText(ctx context.Context, phone, text, howTo string) (req domain.SimulationRequest, reply string) {
+	in := parseSimulationInput(text)
+	if in.Price <= 0 || in.TenorYears <= 0 || (in.DP <= 0 && in.DPPercent <= 0) {
+		return req, howTo
+	}
+	req = domain.SimulationRequest{PropertyPrice: in.Price, DownPayment: in.DP, TenorYears: in.TenorYears, FixedYears: in.FixedYears}
+	if err := ValidateSimulationRequest(req); err != nil {
+		return req, "Simulasi belum bisa dihitung: " + err.Error() + ". " + howTo
+	}
+	if rates, err := a.calculator.ActiveRates(ctx); err == nil {
+		req.RateID = matchRateByName(text, rates)
+	}
+	if phone != "" && a.mem != nil {
+		last := req
+		a.mem.Update(phone, func(m *UserMemory) { m.LastSimulation = &last })
+	}
+	return req, ""
+}
+
+// answerSimulation menjawab pertanyaan simulasi dengan angka dari kalkulator, bukan dari AI.
+// ok=false bila kalkulator tidak tersedia sehingga alur biasa dipakai.
+func (a *AIQueryService) answerSimulation(ctx context.Context, phone, text string) (string, bool) {
+	if a.calculator == nil {
+		return "", false
+	}
+	req, reply := a.simulationRequestFromText(ctx, phone, text, simulationHowTo)
+	if reply != "" {
+		return reply, true
+	}
+	res, err := a.calculator.Simulate(ctx, req)
+	if err != nil {
+		log.Printf("[AI] simulation error: %v", err)
+		return "Maaf, data produk KPR belum tersedia untuk simulasi saat ini.", true
+	}
+	return formatSimulation(res), true
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func sampleRate() domain.KPRRate {
+	return domain.KPRRate{
+		ID: 1, RateName: "KPR Griya Fixed 3 Tahun", RateType: "FIXED",
+		EffectiveRate: 0.0675, MinLoanAmount: 100000000, MaxLoanAmount: 5000000000,
+		MinTermYears: 5, MaxTermYears: 25, MaxLTVRatio: 0.9, MinDownPaymentPercent: 10,
+	}
+}
+
+func TestAnnuityPayment(t *testing.T) {
+	// 800 juta, 6,75%, 20 tahun -> sekitar Rp 6.083.000 per bulan
+	got := annuityPayment(800000000, 0.0675, 240)
+	if math.Abs(got-6082912) > 5 {
+		t.Fatalf("annuity=%.0f", got)
+	}
+	if annuityPayment(1200, 0, 12) != 100 {
+		t.Fatalf("zero-rate annuity must split principal evenly")
+	}
+}
+
+func TestSimulateWithRate_FixedThenFloating(t *testing.T) {
+	res, err := simulateWithRate(sampleRate(), domain.SimulationRequest{PropertyPrice: 1000000000, DownPayment: 200000000, TenorYears: 20, FloatingRate: 0.11})
+	if err != nil {
+		t.Fatalf("simulate error: %v", err)
+	}
+	if len(res.Violations) != 0 {
+		t.Fatalf("unexpected violations: %v", res.Violations)
+	}
+	if len(res.Periods) != 2 || res.Periods[0].ToMonth != 36 || !res.Periods[1].Floating {
+		t.Fatalf("periods=%+v; want 36 fixed months then floating", res.Periods)
+	}
+	if res.Periods[1].MonthlyInstallment <= res.Periods[0].MonthlyInstallment {
+		t.Fatalf("floating installment should be higher at 11%%: %+v", res.Periods)
+	}
+	// pinjaman lunas tepat di akhir tenor
+	p1 := res.Periods[0]
+	bal := remainingBalance(res.Principal, p1.AnnualRate, p1.MonthlyInstallment, 36)
+	end := remainingBalance(bal, 0.11, res.Periods[1].MonthlyInstallment, 240-36)
+	if end > 1 {
+		t.Fatalf("balance after tenor=%.2f; want 0", end)
+	}
+	if math.Abs(res.TotalInterest-(res.TotalPayment-res.Principal)) > 1e-6 {
+		t.Fatalf("total interest inconsistent")
+	}
+}
+
+func TestSimulateWithRate_Violations(t *testing.T) {
+	res, err := simulateWithRate(sampleRate(), domain.SimulationRequest{PropertyPrice: 500000000, DownPayment: 25000000, TenorYears: 30})
+	if err != nil {
+		t.Fatalf("simulate error: %v", err)
+	}
+	joined := strings.Join(res.Violations, "; ")
+	for _, want := range []string{"tenor", "DP minimal", "plafon maksimal 90%"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("violations %q missing %q", joined, want)
+		}
+	}
+	if _, err := simulateWithRate(sampleRate(), domain.SimulationRequest{PropertyPrice: 500000000, DownPayment: 600000000, TenorYears: 10}); err == nil {
+		t.Fatalf("DP above price must be rejected")
+	}
+}
+
+func TestParseSimulationInput(t *testing.T) {
+	cases := []struct {
+		text  string
+		price float64
+		dp    float64
+		tenor int
+		fixed int
+	}{
+		{"simulasi harga 1 miliar dp 20% tenor 20 tahun", 1e9, 2e8, 20, 0},
+		{"rumah 850 juta, uang muka 170jt, 15 tahun", 8.5e8, 1.7e8, 15, 0},
+		{"harga Rp 1.250.000.000 dp Rp 250.000.000 tenor 240 bulan fixed 5 tahun", 1.25e9, 2.5e8, 20, 5},
+		{"cicilan rumah 1,5 m dp 10 persen tenor 25", 1.5e9, 1.5e8, 25, 0},
+		{"harga satu setengah miliar dp 300 jt tenor lima belas tahun", 1.5e9, 3e8, 15, 0},
+		{"rumah Rp 900.000.000 cicilan 6 juta dp 20%, 10 tahun", 9e8, 1.8e8, 10, 0},
+	}
+	for _, c := range cases {
+		in := parseSimulationInput(c.text)
+		if in.Price != c.price || in.DP != c.dp || in.TenorYears != c.tenor || in.FixedYears != c.fixed {
+			t.Fatalf("%q: got %+v", c.text, in)
+		}
+	}
+}
+
+func TestAnswerSimulation_AsksForMissingInput(t *testing.T) {
+	a := &AIQueryService{calculator: NewKPRCalculatorService(nil, 0.11, 0.01, 0.35)}
+	reply, ok := a.answerSimulation(context.Background(), "", "mau simulasi kpr dong")
+	if !ok || reply != simulationHowTo {
+		t.Fatalf("reply=%q ok=%v", reply, ok)
+	}
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// escalationSteps: item lewat jatuh tempo dengan prioritas ini dieskalasi ke atasan, lalu naik
+// satu tingkat lagi di rantai supervisor setiap kali jeda ini berlalu tanpa penyelesaian
+var escalationSteps = map[string]time.Duration{
+	"URGENT": 4 * time.Hour,
+	"HIGH":   24 * time.Hour,
+}
+
+// slaItem adalah satu approval_workflow yang belum selesai dan memiliki due_date.
+// Semua waktu adalah waktu dinding DB (timestamp tanpa zona), termasuk Now.
+type slaItem struct {
+	ID             int
+	AppNumber      string
+	Stage          string
+	Priority       string
+	Due            time.Time
+	AssignedTo     int
+	AssigneePhone  string
+	EscalatedTo    int // 0 = belum dieskalasi
+	EscalatedPhone string
+	EscalatedAt    *time.Time
+	Now            time.Time
+}
+
+// slaReminder adalah satu pesan yang akan dikirim (dan dicatat di workflow_reminders)
+type slaReminder struct {
+	Kind    string // DUE_SOON | OVERDUE | ESCALATED
+	UserID  int
+	Phone   string
+	Message string
+}
+
+// needsEscalation: item HIGH/URGENT yang lewat jatuh tempo dan belum dieskalasi, atau eskalasi
+// terakhirnya sudah melewati jeda prioritasnya
+func needsEscalation(it slaItem) bool {
+	step, ok := escalationSteps[strings.ToUpper(it.Priority)]
+	if !ok || it.Now.Before(it.Due) {
+		return false
+	}
+	return it.EscalatedAt == nil || it.Now.Sub(*it.EscalatedAt) >= step
+}
+
+// slaReminders menentukan pengingat untuk satu item (fungsi murni); de-duplikasi dilakukan
+// oleh constraint workflow_reminders_once saat pengiriman
+func slaReminders(it slaItem, remindBefore time.Duration) []slaReminder {
+	var out []slaReminder
+	what := fmt.Sprintf("tahap *%s* pengajuan *%s* (prioritas %s)", humanizeEnum(it.Stage), it.AppNumber, strings.ToUpper(it.Priority))
+	due := formatDateTimeID(it.Due)
+	if it.AssigneePhone != "" {
+		switch {
+		case !it.Now.Before(it.Due):
+			out = append(out, slaReminder{Kind: "OVERDUE", UserID: it.AssignedTo, Phone: it.AssigneePhone,
+				Message: fmt.Sprintf("⚠️ Lewat SLA: %s jatuh tempo %s (terlambat %s). Mohon segera diselesaikan.", what, due, formatDurationID(it.Now.Sub(it.Due)))})
+		case it.Due.Sub(it.Now) <= remindBefore:
+			out = append(out, slaReminder{Kind: "DUE_SOON", UserID: it.AssignedTo, Phone: it.AssigneePhone,
+				Message: fmt.Sprintf("⏰ Pengingat SLA: %s jatuh tempo %s.", what, due)})
+		}
+	}
+	if it.EscalatedTo > 0 && it.EscalatedPhone != "" {
+		out = append(out, slaReminder{Kind: "ESCALATED", UserID: it.EscalatedTo, Phone: it.EscalatedPhone,
+			Message: fmt.Sprintf("🔺 Eskalasi: %s jatuh tempo %s dan dieskalasi ke kamu. Mohon ditindaklanjuti.", what, due)})
+	}
+	return out
+}
+
+// SLAReminderService mengirim pengingat SLA approval_workflow ke staf yang ditugaskan, lalu
+// mengeskalasi item HIGH/URGENT yang lewat jatuh tempo ke rantai branch_staff.supervisor_id
+type SLAReminderService struct {
+	db           domain.DatabaseService
+	whatsapp     domain.WhatsAppService
+	interval     time.Duration
+	remindBefore time.Duration
+}
+
+func NewSLAReminderService(db domain.DatabaseService, whatsapp domain.WhatsAppService, interval, remindBefore time.Duration) domain.Notifier {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	return &SLAReminderService{db: db, whatsapp: whatsapp, interval: interval, remindBefore: remindBefore}
+}
+
+// Run memeriksa SLA setiap interval sampai ctx selesai
+func (s *SLAReminderService) Run(ctx context.Context) {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+	for {
+		if err := s.runOnce(ctx); err != nil {
+			log.Printf("[SLA] %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// openWorkflowQuery: item terbuka (openWorkflowStatuses, sama dengan digest dan pipeline) yang
+// memiliki due_date, dalam jendela pengingat
+const openWorkflowQuery = `SELECT w.id, a.application_number, w.stage::text, COALESCE(w.priority::text, 'NORMAL'), w.due_date,
+	w.assigned_to, COALESCE(ua.phone, ''), w.escalated_to, COALESCE(ue.phone, ''), w.escalated_at, LOCALTIMESTAMP
+FROM approval_workflow w
+JOIN kpr_applications a ON a.id = w.application_id
+LEFT JOIN users ua ON ua.id = w.assigned_to
+LEFT JOIN users ue ON ue.id = w.escalated_to
+WHERE w.completed_at IS NULL AND w.due_date IS NOT NULL
+	AND COALESCE(w.status::text, 'PENDING') IN ` + openWorkflowStatuses + `
+	AND w.due_date <= LOCALTIMESTAMP + $1 * interval '1 second'
+ORDER BY w.due_date
+LIMIT 500`
+
+func (s *SLAReminderService) runOnce(ctx context.Context) error {
+	if !s.whatsapp.IsConnected() {
+		return nil
+	}
+	rows, err := s.db.Query(ctx, openWorkflowQuery, int(s.remindBefore.Seconds()))
+	if err != nil {
+		return fmt.Errorf("approval_workflow: %w", err)
+	}
+	var items []slaItem
+	for rows.Next() {
+		var it slaItem
+		var escTo sql.NullInt64
+		var escAt sql.NullTime
+		if err := rows.Scan(&it.ID, &it.AppNumber, &it.Stage, &it.Priority, &it.Due, &it.AssignedTo, &it.AssigneePhone,
+			&escTo, &it.EscalatedPhone, &escAt, &it.Now); err != nil {
+			rows.Close()
+			return err
+		}
+		it.EscalatedTo = int(escTo.Int64)
+		if escAt.Valid {
+			t := escAt.Time
+			it.EscalatedAt = &t
+		}
+		items = append(items, it)
+	}
+	rows.Close()
+
+	for _, it := range items {
+		if needsEscalation(it) {
+			if err := s.escalate(ctx, &it); err != nil {
+				log.Printf("[SLA] escalate workflow %d: %v", it.ID, err)
+			}
+		}
+		for _, r := range slaReminders(it, s.remindBefore) {
+			if err := s.send(ctx, it.ID, r); err != nil {
+				log.Printf("[SLA] reminder %s workflow %d: %v", r.Kind, it.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// supervisorChainQuery mencari atasan aktif terdekat dari seorang user di rantai
+// branch_staff.supervisor_id (atasan nonaktif dilewati)
+const supervisorChainQuery = `WITH RECURSIVE chain AS (
+	SELECT b.supervisor_id AS sid, 1 AS depth FROM branch_staff b WHERE b.user_id = $1 AND b.supervisor_id IS NOT NULL
+	UNION ALL
+	SELECT s.supervisor_id, c.depth + 1 FROM chain c JOIN branch_staff s ON s.id = c.sid
+	WHERE NOT COALESCE(s.is_active, true) AND s.supervisor_id IS NOT NULL AND c.depth < 10
+)
+SELECT s.user_id, COALESCE(u.phone, '') FROM chain c
+JOIN branch_staff s ON s.id = c.sid JOIN users u ON u.id = s.user_id
+WHERE COALESCE(s.is_active, true) AND s.user_id <> $1
+ORDER BY c.depth
+LIMIT 1`
+
+// escalate memindahkan eskalasi satu tingkat ke atas: dari assignee (eskalasi pertama) atau dari
+// escalated_to saat ini. Update bersyarat mencegah dua instance mengeskalasi bersamaan.
+func (s *SLAReminderService) escalate(ctx context.Context, it *slaItem) error {
+	from := it.AssignedTo
+	if it.EscalatedTo > 0 {
+		from = it.EscalatedTo
+	}
+	rows, err := s.db.Query(ctx, supervisorChainQuery, from)
+	if err != nil {
+		return err
+	}
+	var sup int
+	var phone string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&sup, &phone)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no active supervisor above user %d", from)
+	}
+	res, err := s.db.Exec(ctx, `UPDATE approval_workflow SET escalated_to = $2, escalated_at = LOCALTIMESTAMP, updated_at = LOCALTIMESTAMP
+WHERE id = $1 AND completed_at IS NULL AND escalated_to IS NOT DISTINCT FROM $3`, it.ID, sup, sql.NullInt64{Int64: int64(it.EscalatedTo), Valid: it.EscalatedTo > 0})
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil // sudah diubah pihak lain
+	}
+	log.Printf("[SLA] workflow %d escalated from user %d to %d", it.ID, from, sup)
+	now := it.Now
+	it.EscalatedTo, it.EscalatedPhone, it.EscalatedAt = sup, phone, &now
+	return nil
+}
+
+// send mencatat pengingat lalu mengirimnya; bila sudah pernah tercatat, tidak dikirim lagi.
+// Bila pengiriman gagal, catatan dihapus agar dicoba lagi pada putaran berikutnya.
+func (s *SLAReminderService) send(ctx context.Context, workflowID int, r slaReminder) error {
+	res, err := s.db.Exec(ctx, `INSERT INTO workflow_reminders (workflow_id, kind, recipient_user_id, phone, message)
+VALUES ($1, $2, $3, $4, $5) ON CONFLICT ON CONSTRAINT workflow_reminders_once DO NOTHING`, workflowID, r.Kind, r.UserID, r.Phone, r.Message)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	if err := s.whatsapp.SendMessage(ctx, r.Phone, r.Message); err != nil {
+		_, _ = s.db.Exec(ctx, "DELETE FROM workflow_reminders WHERE workflow_id = $1 AND kind = $2 AND recipient_user_id = $3", workflowID, r.Kind, r.UserID)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSLAReminders(t *testing.T) {
+	due := time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC)
+	base := slaItem{ID: 7, AppNumber: "KPR-2025-001", Stage: "CREDIT_ANALYSIS", Priority: "HIGH", Due: due,
+		AssignedTo: 11, AssigneePhone: "6281111"}
+	kinds := func(rs []slaReminder) string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.Kind)
+		}
+		return strings.Join(out, ",")
+	}
+	escalatedAt := due.Add(time.Hour)
+	cases := []struct {
+		name string
+		mod  func(it *slaItem)
+		want string
+	}{
+		{"far from due", func(it *slaItem) { it.Now = due.Add(-10 * time.Hour) }, ""},
+		{"within lead time", func(it *slaItem) { it.Now = due.Add(-3 * time.Hour) }, "DUE_SOON"},
+		{"overdue", func(it *slaItem) { it.Now = due.Add(2 * time.Hour) }, "OVERDUE"},
+		{"assignee without phone", func(it *slaItem) { it.Now = due.Add(time.Hour); it.AssigneePhone = "" }, ""},
+		{"escalated", func(it *slaItem) {
+			it.Now = due.Add(2 * time.Hour)
+			it.EscalatedTo, it.EscalatedPhone, it.EscalatedAt = 21, "6282222", &escalatedAt
+		}, "OVERDUE,ESCALATED"},
+	}
+	for _, c := range cases {
+		it := base
+		c.mod(&it)
+		if got := kinds(slaReminders(it, 4*time.Hour)); got != c.want {
+			t.Fatalf("%s: kinds=%q; want %q", c.name, got, c.want)
+		}
+	}
+
+	it := base
+	it.Now = due.Add(26 * time.Hour)
+	rs := slaReminders(it, 4*time.Hour)
+	if len(rs) != 1 || rs[0].UserID != 11 || !strings.Contains(rs[0].Message, "*KPR-2025-001*") ||
+		!strings.Contains(rs[0].Message, "credit analysis") || !strings.Contains(rs[0].Message, "1 hari 2 jam") {
+		t.Fatalf("overdue reminder=%+v", rs)
+	}
+}
+
+func TestNeedsEscalation(t *testing.T) {
+	due := time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC)
+	at := func(h int) *time.Time { v := due.Add(time.Duration(h) * time.Hour); return &v }
+	cases := []struct {
+		priority    string
+		nowHours    int
+		escalatedAt *time.Time
+		want        bool
+	}{
+		{"HIGH", -1, nil, false},
+		{"HIGH", 0, nil, true},
+		{"NORMAL", 48, nil, false},
+		{"LOW", 48, nil, false},
+		{"urgent", 1, nil, true},
+		// naik satu tingkat lagi setelah jeda prioritas berlalu
+		{"URGENT", 5, at(2), false},
+		{"URGENT", 6, at(2), true},
+		{"HIGH", 20, at(1), false},
+		{"HIGH", 25, at(1), true},
+	}
+	for _, c := range cases {
+		it := slaItem{Priority: c.priority, Due: due, Now: due.Add(time.Duration(c.nowHours) * time.Hour), EscalatedAt: c.escalatedAt}
+		if got := needsEscalation(it); got != c.want {
+			t.Fatalf("needsEscalation(%s, now=+%dh, escalatedAt=%v)=%v; want %v", c.priority, c.nowHours, c.escalatedAt, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// tableKeywords: sinonim/keyword berbobot untuk membantu resolusi tabel dari teks natural.
+// Berupa slice (bukan map) agar urutan evaluasi selalu sama.
+var tableKeywords = []struct {
+	table    string
+	keywords []weightedPhrase
+}{
+	{"users", []weightedPhrase{{"user", 1}, {"pengguna", 1.5}, {"nasabah", 1}, {"akun", 2}, {"phone", 1.5}, {"email", 2}, {"username", 2}}},
+	{"roles", []weightedPhrase{{"role", 2.5}, {"hak akses", 3}, {"otorisasi", 2.5}}},
+	{"branch_staff", []weightedPhrase{{"staff", 2}, {"pegawai cabang", 3}, {"petugas", 1.5}, {"karyawan", 2}, {"cabang", 1.5}, {"supervisor", 2}}},
+	{"user_profiles", []weightedPhrase{{"profil", 2.5}, {"bio", 2}, {"pendapatan", 2}, {"income", 2}, {"pekerjaan", 2}, {"occupation", 2}, {"data diri", 2.5}}},
+	{"kpr_rates", []weightedPhrase{{"rate", 2}, {"bunga", 2}, {"suku bunga", 3}, {"kpr rate", 3}, {"bunga tetap", 3}, {"fixed", 2}, {"floating", 2}, {"bunga mengambang", 3}, {"promo", 2.5}, {"promosi", 2.5}, {"ltv", 1}, {"loan to value", 1}, {"tenor", 1}, {"jangka waktu", 1}, {"plafon", 1}, {"down payment", 1}, {"dp", 1}, {"prime lending rate", 3}, {"produk", 1.5}}},
+	{"kpr_applications", []weightedPhrase{{"kpr", 1}, {"aplikasi", 2}, {"pengajuan", 2.5}, {"application", 2}, {"apply", 1.5}, {"status pengajuan", 3}, {"report", 1.5}, {"laporan", 1.5}, {"rekap", 1.5}, {"ringkasan", 1}, {"statistik", 1.5}, {"summary", 1}, {"cicilan", 1}, {"angsuran", 1}, {"pinjaman", 1.5}}},
+	{"approval_workflow", []weightedPhrase{{"approval", 2.5}, {"persetujuan", 2.5}, {"workflow", 3}, {"review", 2}, {"status approval", 3}, {"tahap", 2}, {"stage", 2}}},
+	{"properties", []weightedPhrase{{"properti", 2}, {"rumah", 1}, {"agunan", 2.5}, {"aset", 2}, {"alamat", 1}}},
+}
+
+// tableLabels adalah nama ramah untuk tabel saat bot bertanya balik ke user
+var tableLabels = map[string]string{
+	"users":             "akun kamu",
+	"roles":             "hak akses",
+	"branch_staff":      "data petugas cabang",
+	"user_profiles":     "profil kamu",
+	"kpr_rates":         "suku bunga / produk KPR",
+	"kpr_applications":  "pengajuan KPR kamu",
+	"approval_workflow": "proses persetujuan",
+	"properties":        "data properti",
+}
+
+// userOwnedTables adalah tabel yang barisnya milik user (dipilih bila pertanyaan posesif: "saya/aku")
+var userOwnedTables = map[string]struct{}{"kpr_applications": {}, "user_profiles": {}}
+
+// genericColumns tidak dipakai sebagai bukti karena ada di banyak tabel
+var genericColumns = map[string]struct{}{
+	"id": {}, "created_at": {}, "updated_at": {}, "status": {}, "is_active": {}, "notes": {},
+	"description": {}, "name": {}, "user_id": {},
+}
+
+var commentStopwords = map[string]struct{}{
+	"with": {}, "table": {}, "main": {}, "core": {}, "enhanced": {}, "features": {}, "system": {},
+	"calculated": {}, "configuration": {}, "extended": {}, "based": {}, "control": {}, "and": {}, "for": {},
+}
+
+// Komentar DDL (COMMENT ON TABLE/COLUMN) dipakai sebagai bukti tambahan saat resolusi tabel
+var tableComments = map[string]string{}
+var columnComments = map[string]map[string]string{}
+
+func refreshCommentsFromDDL(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	tableComments, columnComments = parseDDLForComments(string(data))
+}
+
+func RefreshCommentsFromDDL(path string) { refreshCommentsFromDDL(path) }
+
+var (
+	reTableComment  = regexp.MustCompile(`(?is)comment\s+on\s+table\s+(?:public\.)?"?(\w+)"?\s+is\s+'((?:[^']|'')*)'`)
+	reColumnComment = regexp.MustCompile(`(?is)comment\s+on\s+column\s+(?:public\.)?"?(\w+)"?\."?(\w+)"?\s+is\s+'((?:[^']|'')*)'`)
+)
+
+// parseDDLForComments mengekstrak komentar tabel dan kolom dari DDL
+func parseDDLForComments(ddl string) (map[string]string, map[string]map[string]string) {
+	tbl := map[string]string{}
+	cols := map[string]map[string]string{}
+	for _, m := range reTableComment.FindAllStringSubmatch(ddl, -1) {
+		tbl[strings.ToLower(m[1])] = strings.ReplaceAll(m[2], "''", "'")
+	}
+	for _, m := range reColumnComment.FindAllStringSubmatch(ddl, -1) {
+		t := strings.ToLower(m[1])
+		if _, ok := cols[t]; !ok {
+			cols[t] = map[string]string{}
+		}
+		cols[t][strings.ToLower(m[2])] = strings.ReplaceAll(m[3], "''", "'")
+	}
+	return tbl, cols
+}
+
+// tableCandidate adalah satu kandidat tabel beserta skor dan alasannya
+type tableCandidate struct {
+	Table      string
+	Score      float64
+	Confidence float64 // porsi skor terhadap total skor semua kandidat
+	Reasons    []string
+}
+
+// rankTables memberi skor setiap tabel yang diizinkan terhadap teks dan mengembalikan kandidat
+// terurut (skor menurun, lalu nama tabel) sehingga hasilnya deterministik.
+// intent (boleh kosong) menambah bobot pada tabel yang disarankan intent.
+func rankTables(text string, intent domain.Intent) []tableCandidate {
+	lower := strings.ToLower(text)
+	tokens, possessive := intentTokens(text)
+	scores := map[string]float64{}
+	reasons := map[string][]string{}
+	add := func(tbl string, w float64, why string) {
+		if _, ok := allowedTables[tbl]; !ok {
+			return
+		}
+		scores[tbl] += w
+		reasons[tbl] = append(reasons[tbl], why)
+	}
+
+	if app := extractAppNumber(text); strings.TrimSpace(app) != "" {
+		add("kpr_applications", 5, "nomor aplikasi")
+	}
+	// 1) Nama tabel disebut langsung
+	for _, n := range sortedAllowedTables() {
+		if strings.Contains(lower, n) || strings.Contains(lower, strings.ReplaceAll(n, "_", " ")) {
+			add(n, 5, "nama tabel")
+		}
+	}
+	// 2) Keyword berbobot
+	for _, tk := range tableKeywords {
+		for _, kw := range tk.keywords {
+			if containsPhrase(tokens, kw.phrase) {
+				add(tk.table, kw.weight, "keyword:"+kw.phrase)
+			}
+		}
+	}
+	// 3) Nama kolom (mis. "loan_amount" atau "loan amount")
+	for _, tbl := range sortedAllowedTables() {
+		hits := 0
+		for _, c := range tableColumns[tbl] {
+			if _, generic := genericColumns[c]; generic || hits >= 3 {
+				continue
+			}
+			if strings.Contains(lower, c) || (strings.Contains(c, "_") && containsPhrase(tokens, strings.ReplaceAll(c, "_", " "))) {
+				add(tbl, 1, "kolom:"+c)
+				hits++
+			}
+		}
+	}
+	// 4) Komentar DDL tabel/kolom
+	for _, tbl := range sortedAllowedTables() {
+		words := commentWords(tableComments[tbl])
+		for _, cc := range columnComments[tbl] {
+			words = append(words, commentWords(cc)...)
+		}
+		seen := map[string]struct{}{}
+		for _, w := range words {
+			if _, dup := seen[w]; dup {
+				continue
+			}
+			seen[w] = struct{}{}
+			if containsPhrase(tokens, w) {
+				add(tbl, 0.5, "komentar:"+w)
+			}
+		}
+	}
+	// 5) Pertanyaan posesif condong ke tabel milik user
+	if possessive {
+		for tbl := range userOwnedTables {
+			if scores[tbl] > 0 {
+				add(tbl, 1, "posesif")
+			}
+		}
+	}
+	// 6) Intent yang sudah diklasifikasi
+	if it := intentTable(intent); it != "" {
+		add(it, 2, "intent:"+string(intent))
+	}
+
+	total := 0.0
+	for _, s := range scores {
+		total += s
+	}
+	out := make([]tableCandidate, 0, len(scores))
+	for tbl, s := range scores {
+		if s <= 0 {
+			continue
+		}
+		out = append(out, tableCandidate{Table: tbl, Score: s, Confidence: math.Round(s/total*100) / 100, Reasons: reasons[tbl]})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Table < out[j].Table
+	})
+	return out
+}
+
+func sortedAllowedTables() []string {
+	names := make([]string, 0, len(allowedTables))
+	for k := range allowedTables {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func commentWords(comment string) []string {
+	out := []string{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(comment), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	}) {
+		if len(w) < 4 {
+			continue
+		}
+		if _, stop := commentStopwords[w]; stop {
+			continue
+		}
+		out = append(out, w)
+	}
+	return out
+}
+
+// resolveTableFromText mengembalikan tabel dengan skor tertinggi, atau "" bila tidak ada bukti sama sekali
+func resolveTableFromText(text string) string {
+	cands := rankTables(text, "")
+	if len(cands) == 0 {
+		return ""
+	}
+	return cands[0].Table
+}
+
+// clarifyRatio: bila skor kandidat kedua >= rasio ini terhadap kandidat teratas, bot bertanya balik
+const clarifyRatio = 0.75
+
+// needsTableClarification true bila dua kandidat teratas terlalu dekat untuk dipilih dengan yakin
+func needsTableClarification(cands []tableCandidate) bool {
+	if len(cands) < 2 || cands[0].Score <= 0 {
+		return false
+	}
+	return cands[1].Score >= cands[0].Score*clarifyRatio
+}
+
+// TableClarification menyimpan pertanyaan klarifikasi yang menunggu jawaban user
+type TableClarification struct {
+	Text    string   // pertanyaan asli user
+	Options []string // tabel kandidat sesuai urutan nomor pilihan
+}
+
+// clarificationQuestion merakit pertanyaan pilihan untuk dua/tiga kandidat teratas
+func clarificationQuestion(cands []tableCandidate) (string, []string) {
+	opts := []string{}
+	for i, c := range cands {
+		if i >= 3 || (i > 0 && c.Score < cands[0].Score*clarifyRatio) {
+			break
+		}
+		opts = append(opts, c.Table)
+	}
+	var sb strings.Builder
+	sb.WriteString("Biar jawabanku tepat, maksud kamu soal apa?\n")
+	for i, t := range opts {
+		label := tableLabels[t]
+		if label == "" {
+			label = strings.ReplaceAll(t, "_", " ")
+		}
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, label)
+	}
+	sb.WriteString("Balas dengan nomor pilihannya ya.")
+	return sb.String(), opts
+}
+
+// matchClarificationReply mencocokkan balasan user ("1", "2", atau label/nama tabel) ke salah satu opsi
+func matchClarificationReply(reply string, opts []string) string {
+	r := strings.ToLower(strings.TrimSpace(reply))
+	r = strings.TrimSuffix(strings.TrimPrefix(r, "nomor "), ".")
+	if n, err := strconv.Atoi(r); err == nil && n >= 1 && n <= len(opts) {
+		return opts[n-1]
+	}
+	for _, t := range opts {
+		if r == t || strings.Contains(r, strings.ReplaceAll(t, "_", " ")) {
+			return t
+		}
+		if label := tableLabels[t]; label != "" && strings.Contains(label, r) && len(r) >= 4 {
+			return t
+		}
+	}
+	return ""
+}
+
+// tableHint mengembalikan tabel yang sudah pasti untuk pesan ini: hasil klarifikasi user
+// (ctxKey "table") atau tabel bawaan intent
+func tableHint(ctx context.Context) string {
+	if v := ctx.Value(ctxKey("table")); v != nil {
+		if s, ok := v.(string); ok && s != "" {
+			return s
+		}
+	}
+	return intentTable(intentFromContext(ctx))
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestRankTables_Deterministic(t *testing.T) {
+	first := rankTables("bunga pengajuan saya", "")
+	if len(first) == 0 || first[0].Table != "kpr_applications" {
+		t.Fatalf("top candidate=%v; want kpr_applications", first)
+	}
+	for i := 0; i < 50; i++ {
+		got := rankTables("bunga pengajuan saya", "")
+		if len(got) != len(first) {
+			t.Fatalf("run %d: %d candidates; want %d", i, len(got), len(first))
+		}
+		for j := range got {
+			if got[j].Table != first[j].Table || got[j].Score != first[j].Score {
+				t.Fatalf("run %d: order changed at %d: %v vs %v", i, j, got[j], first[j])
+			}
+		}
+	}
+}
+
+func TestResolveTableFromText_NoSilentDefault(t *testing.T) {
+	cases := []struct {
+		in  string
+		out string
+	}{
+		{"cuaca hari ini", ""},
+		{"promo bunga fixed", "kpr_rates"},
+		{"cek KPR-2025-001", "kpr_applications"},
+		{"tahap workflow approval", "approval_workflow"},
+	}
+	for _, c := range cases {
+		if got := resolveTableFromText(c.in); got != c.out {
+			t.Fatalf("resolveTableFromText(%q)=%q; want %q", c.in, got, c.out)
+		}
+	}
+}
+
+func TestTableClarification(t *testing.T) {
+	cands := rankTables("bunga dan pengajuan", "")
+	if !needsTableClarification(cands) {
+		t.Fatalf("expected clarification for close candidates: %v", cands)
+	}
+	q, opts := clarificationQuestion(cands)
+	if len(opts) < 2 || q == "" {
+		t.Fatalf("clarification options=%v question=%q", opts, q)
+	}
+	if got := matchClarificationReply("2", opts); got != opts[1] {
+		t.Fatalf("reply 2 -> %q; want %q", got, opts[1])
+	}
+	if got := matchClarificationReply("cuaca", opts); got != "" {
+		t.Fatalf("unrelated reply matched %q", got)
+	}
+	// intent yang jelas menghilangkan ambiguitas
+	if needsTableClarification(rankTables("bunga dan pengajuan", domain.IntentRateInfo)) {
+		t.Fatalf("intent rate_info should settle the table")
+	}
+}
+
+func TestParseDDLForComments(t *testing.T) {
+	ddl := `COMMENT ON TABLE public.kpr_rates IS 'KPR interest rates and loan terms configuration';
+COMMENT ON COLUMN public.kpr_applications.ltv_ratio IS 'Loan to Value ratio calculated as (loan_amount / property_value)';`
+	tbl, cols := parseDDLForComments(ddl)
+	if tbl["kpr_rates"] != "KPR interest rates and loan terms configuration" {
+		t.Fatalf("table comment=%q", tbl["kpr_rates"])
+	}
+	if cols["kpr_applications"]["ltv_ratio"] == "" {
+		t.Fatalf("column comment missing: %v", cols)
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timelinePattern: pertanyaan progres ("sampai mana", "tahap apa", "berapa lama lagi") dijawab
+// dengan timeline approval_workflow, bukan sekadar status pengajuan
+var timelinePattern = regexp.MustCompile(`(?i)\b(sampai mana|sampai di ?mana|sudah sampai|progres|progress|proses|tahap|tahapan|timeline|posisi|berapa lama lagi|kapan selesai)\b`)
+
+// finalApplicationStatuses: pengajuan yang prosesnya sudah selesai (tanpa perkiraan sisa waktu)
+var finalApplicationStatuses = map[string]bool{"APPROVED": true, "REJECTED": true, "DISBURSED": true, "CANCELLED": true}
+
+// timelineHistoryDays: jendela data historis untuk durasi tahap
+const timelineHistoryDays = 365
+
+// timelineStage adalah satu baris approval_workflow yang boleh dilihat nasabah: tanpa staf
+// (assigned_to/escalated_to) dan tanpa catatan internal (approval_notes/rejection_reason)
+type timelineStage struct {
+	Stage       string
+	Status      string
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+}
+
+// stageDuration: median durasi historis satu tahap dan posisi rata-ratanya dalam alur
+type stageDuration struct {
+	Stage    string
+	Median   time.Duration
+	Position float64
+}
+
+type applicationTimeline struct {
+	AppNumber string
+	Status    string
+	Done      []timelineStage
+	Current   *timelineStage
+	Elapsed   time.Duration // lama tahap berjalan
+	Upcoming  []string      // tahap yang biasanya masih menyusul
+	Remaining time.Duration
+	Estimated bool // Remaining terisi dari data historis yang cukup
+}
+
+// buildTimeline menyusun timeline dan perkiraan sisa waktu (fungsi murni). Sisa waktu = median
+// tahap berjalan dikurangi lama berjalan (minimal 0) + median tahap yang belum dimulai.
+func buildTimeline(number, status string, stages []timelineStage, history []stageDuration, now time.Time) applicationTimeline {
+	tl := applicationTimeline{AppNumber: number, Status: status}
+	seen := map[string]bool{}
+	for i := range stages {
+		st := stages[i]
+		seen[st.Stage] = true
+		if st.CompletedAt != nil {
+			tl.Done = append(tl.Done, st)
+		} else if tl.Current == nil {
+			tl.Current = &st
+		}
+	}
+	if tl.Current != nil && tl.Current.StartedAt != nil && now.After(*tl.Current.StartedAt) {
+		tl.Elapsed = now.Sub(*tl.Current.StartedAt)
+	}
+	if finalApplicationStatuses[strings.ToUpper(status)] || strings.EqualFold(status, "DRAFT") {
+		return tl
+	}
+	medians := map[string]time.Duration{}
+	ordered := append([]stageDuration(nil), history...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Position < ordered[j].Position })
+	for _, h := range ordered {
+		medians[h.Stage] = h.Median
+		if !seen[h.Stage] {
+			tl.Upcoming = append(tl.Upcoming, h.Stage)
+			tl.Remaining += h.Median
+		}
+	}
+	tl.Estimated = len(ordered) > 0
+	if tl.Current != nil {
+		m, ok := medians[tl.Current.Stage]
+		tl.Estimated = tl.Estimated && ok
+		if m > tl.Elapsed {
+			tl.Remaining += m - tl.Elapsed
+		}
+	}
+	return tl
+}
+
+// formatTimeline merender timeline untuk WhatsApp
+func formatTimeline(tl applicationTimeline, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📍 Proses pengajuan KPR *%s* (status: *%s*)\n", tl.AppNumber, humanizeEnum(tl.Status))
+	if len(tl.Done) == 0 && tl.Current == nil {
+		if strings.EqualFold(tl.Status, "DRAFT") {
+			b.WriteString("Pengajuan masih berupa draft dan belum masuk proses persetujuan.")
+			return b.String()
+		}
+		b.WriteString("Pengajuan sudah kami terima dan menunggu tahap persetujuan pertama.\n")
+	}
+	for _, st := range tl.Done {
+		line := fmt.Sprintf("✅ %s: %s", humanizeEnum(st.Stage), humanizeEnum(st.Status))
+		if st.CompletedAt != nil {
+			line += ", " + formatDateID(*st.CompletedAt)
+			if st.StartedAt != nil && st.CompletedAt.After(*st.StartedAt) {
+				line += " (" + formatDurationID(st.CompletedAt.Sub(*st.StartedAt)) + ")"
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+	if c := tl.Current; c != nil {
+		if c.StartedAt != nil {
+			fmt.Fprintf(&b, "⏳ %s: sedang diproses sejak %s (%s)\n", humanizeEnum(c.Stage), formatDateTimeID(*c.StartedAt), formatDurationID(tl.Elapsed))
+		} else {
+			fmt.Fprintf(&b, "⏳ %s: menunggu diproses\n", humanizeEnum(c.Stage))
+		}
+	}
+	for _, s := range tl.Upcoming {
+		fmt.Fprintf(&b, "▫️ %s\n", humanizeEnum(s))
+	}
+	switch {
+	case finalApplicationStatuses[strings.ToUpper(tl.Status)]:
+		b.WriteString("Proses persetujuan sudah selesai.")
+	case tl.Current == nil && len(tl.Upcoming) == 0 && len(tl.Done) > 0:
+		b.WriteString("Semua tahap sudah dilalui; keputusan akhir segera kami kabarkan.")
+	case tl.Estimated && tl.Remaining > 0:
+		fmt.Fprintf(&b, "Perkiraan sisa waktu: ± %s (perkiraan %s), berdasarkan median durasi pengajuan lain.",
+			formatDurationID(tl.Remaining), formatDateID(now.Add(tl.Remaining)))
+	case tl.Estimated:
+		b.WriteString("Tahap ini sudah melewati durasi umumnya; semoga segera selesai. Kami kabari bila ada perkembangan.")
+	default:
+		b.WriteString("Sisa waktu belum bisa diperkirakan. Kami kabari bila ada perkembangan.")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+const timelineApplicationQuery = `SELECT a.id, a.application_number, COALESCE(a.status::text, ''), LOCALTIMESTAMP
+FROM kpr_applications a JOIN users u ON u.id = a.user_id
+WHERE ($1 = '' OR a.application_number = $1) AND u.phone = $2
+ORDER BY a.created_at DESC
+LIMIT 1`
+
+// timelineStagesQuery sengaja tidak memilih kolom staf maupun catatan
+const timelineStagesQuery = `SELECT stage::text, COALESCE(status::text, 'PENDING'), started_at, completed_at
+FROM approval_workflow WHERE application_id = $1
+ORDER BY COALESCE(started_at, created_at), id`
+
+// stageDurationQuery: median durasi per tahap dari tahap approval_workflow yang sudah selesai.
+// Tahap dengan sampel < $1 (ambang k-anonymity) tidak dipakai.
+const stageDurationQuery = `WITH done AS (
+	SELECT stage::text AS stage, EXTRACT(EPOCH FROM completed_at - started_at) AS secs,
+		row_number() OVER (PARTITION BY application_id ORDER BY started_at, id) AS pos
+	FROM approval_workflow
+	WHERE started_at IS NOT NULL AND completed_at >= started_at
+		AND completed_at >= LOCALTIMESTAMP - make_interval(days => $2)
+)
+SELECT stage, percentile_cont(0.5) WITHIN GROUP (ORDER BY secs), avg(pos)
+FROM done GROUP BY stage HAVING count(*) >= $1`
+
+// answerTimeline menjawab "sampai mana proses KPR saya" untuk pengajuan milik nomor pengirim
+func (a *AIQueryService) answerTimeline(ctx context.Context, phone, text string) (string, bool) {
+	if a.db == nil || strings.TrimSpace(phone) == "" {
+		return "", false
+	}
+	app := a.applicationFor(phone, text)
+	rows, err := a.db.Query(ctx, timelineApplicationQuery, app, phone)
+	if err != nil {
+		log.Printf("[AI] timeline application error: %v", err)
+		return "", false
+	}
+	var id int
+	var number, status string
+	var now time.Time
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&id, &number, &status, &now)
+	}
+	rows.Close()
+	switch {
+	case err != nil:
+		log.Printf("[AI] timeline application error: %v", err)
+		return "", false
+	case !found && app != "":
+		return fmt.Sprintf("Pengajuan %s tidak ditemukan untuk nomor WhatsApp ini.", app), true
+	case !found:
+		return "", false
+	}
+
+	rows, err = a.db.Query(ctx, timelineStagesQuery, id)
+	if err != nil {
+		log.Printf("[AI] timeline stages error: %v", err)
+		return "", false
+	}
+	var stages []timelineStage
+	for rows.Next() {
+		var st timelineStage
+		if err := rows.Scan(&st.Stage, &st.Status, &st.StartedAt, &st.CompletedAt); err != nil {
+			rows.Close()
+			log.Printf("[AI] timeline stages error: %v", err)
+			return "", false
+		}
+		stages = append(stages, st)
+	}
+	rows.Close()
+
+	var history []stageDuration
+	rows, err = a.db.Query(ctx, stageDurationQuery, minAggregateGroupSize, timelineHistoryDays)
+	if err != nil {
+		// tanpa data historis timeline tetap dikirim, hanya tanpa perkiraan
+		log.Printf("[AI] timeline history error: %v", err)
+	} else {
+		for rows.Next() {
+			var h stageDuration
+			var secs float64
+			if err := rows.Scan(&h.Stage, &secs, &h.Position); err != nil {
+				log.Printf("[AI] timeline history error: %v", err)
+				history = nil
+				break
+			}
+			h.Median = time.Duration(secs * float64(time.Second))
+			history = append(history, h)
+		}
+		rows.Close()
+	}
+	return formatTimeline(buildTimeline(number, status, stages, history, now), now), true
+}
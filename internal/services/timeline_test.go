@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func tp(t time.Time) *time.Time { return &t }
+
+func TestBuildTimeline(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	stages := []timelineStage{
+		{Stage: "DOCUMENT_VERIFICATION", Status: "APPROVED", StartedAt: tp(now.Add(-5 * day)), CompletedAt: tp(now.Add(-3 * day))},
+		{Stage: "CREDIT_ANALYSIS", Status: "IN_PROGRESS", StartedAt: tp(now.Add(-1 * day))},
+	}
+	history := []stageDuration{
+		{Stage: "FINAL_APPROVAL", Median: 2 * day, Position: 4},
+		{Stage: "DOCUMENT_VERIFICATION", Median: 2 * day, Position: 1},
+		{Stage: "CREDIT_ANALYSIS", Median: 3 * day, Position: 2},
+		{Stage: "PROPERTY_APPRAISAL", Median: 4 * day, Position: 3},
+	}
+	cases := []struct {
+		name      string
+		status    string
+		stages    []timelineStage
+		history   []stageDuration
+		upcoming  string
+		remaining time.Duration
+		estimated bool
+	}{
+		{"in progress", "UNDER_REVIEW", stages, history, "PROPERTY_APPRAISAL,FINAL_APPROVAL", 8 * day, true},
+		{"current overdue", "UNDER_REVIEW", []timelineStage{{Stage: "CREDIT_ANALYSIS", StartedAt: tp(now.Add(-4 * day))}}, history[2:3], "", 0, true},
+		{"no history for current stage", "UNDER_REVIEW", stages, history[:1], "FINAL_APPROVAL", 2 * day, false},
+		{"submitted, no workflow yet", "SUBMITTED", nil, history, "DOCUMENT_VERIFICATION,CREDIT_ANALYSIS,PROPERTY_APPRAISAL,FINAL_APPROVAL", 11 * day, true},
+		{"approved", "APPROVED", stages, history, "", 0, false},
+	}
+	for _, c := range cases {
+		tl := buildTimeline("KPR-2025-001", c.status, c.stages, c.history, now)
+		if got := strings.Join(tl.Upcoming, ","); got != c.upcoming || tl.Remaining != c.remaining || tl.Estimated != c.estimated {
+			t.Fatalf("%s: upcoming=%q remaining=%s estimated=%v", c.name, got, tl.Remaining, tl.Estimated)
+		}
+	}
+
+	tl := buildTimeline("KPR-2025-001", "UNDER_REVIEW", stages, history, now)
+	if tl.Current == nil || tl.Current.Stage != "CREDIT_ANALYSIS" || tl.Elapsed != day || len(tl.Done) != 1 {
+		t.Fatalf("timeline=%+v", tl)
+	}
+	msg := formatTimeline(tl, now)
+	for _, want := range []string{
+		"*KPR-2025-001*", "✅ document verification: approved, 7 Maret 2026 (2 hari)",
+		"⏳ credit analysis: sedang diproses sejak 9 Maret 2026 pukul 12.00 (1 hari)",
+		"▫️ property appraisal", "± 8 hari (perkiraan 18 Maret 2026)",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("message missing %q:\n%s", want, msg)
+		}
+	}
+	if msg := formatTimeline(buildTimeline("KPR-2025-001", "DRAFT", nil, history, now), now); !strings.Contains(msg, "draft") {
+		t.Fatalf("draft message: %s", msg)
+	}
+}
+
+func TestTimelinePattern_OnlyProgressQuestions(t *testing.T) {
+	c := NewRuleIntentClassifier()
+	cases := map[string]bool{
+		"sampai mana proses KPR saya":     true,
+		"pengajuan saya sudah tahap apa?": true,
+		"berapa lama lagi KPR-2025-001":   true,
+		"berapa cicilan saya":             false,
+		"status pengajuan KPR-2025-001":   false,
+	}
+	for text, want := range cases {
+		res, _ := c.Classify(context.Background(), text)
+		got := res.Intent == domain.IntentApplicationStatus && timelinePattern.MatchString(text)
+		if got != want {
+			t.Fatalf("%q: timeline=%v (intent %s); want %v", text, got, res.Intent, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+// TotalCost menghitung total biaya pinjaman untuk produk hasil Simulate:
+// biaya di muka, bunga, asuransi, dan total yang dibayar sampai lunas
+func (s *KPRCalculatorService) TotalCost(ctx context.Context, req domain.SimulationRequest) (*domain.TotalCostResult, error) {
+	sim, err := s.Simulate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return loanCostBreakdown(sim), nil
+}
+
+// balanceAfter: sisa pokok setelah k angsuran pertama, mengikuti periode fixed/floating simulasi
+func balanceAfter(sim *domain.SimulationResult, k int) float64 {
+	bal := sim.Principal
+	for _, p := range sim.Periods {
+		if k < p.FromMonth {
+			break
+		}
+		m := p.ToMonth
+		if k < m {
+			m = k
+		}
+		bal = remainingBalance(bal, p.AnnualRate, p.MonthlyInstallment, m-p.FromMonth+1)
+	}
+	return bal
+}
+
+// loanCostBreakdown menurunkan rincian biaya dari hasil simulasi (fungsi murni, tanpa DB).
+// Provisi/admin dan notaris dihitung dari plafon; premi asuransi = insurance_rate × sisa pokok
+// di awal setiap tahun tenor.
+func loanCostBreakdown(sim *domain.SimulationResult) *domain.TotalCostResult {
+	r := sim.Rate
+	res := &domain.TotalCostResult{
+		Simulation:    *sim,
+		AdminFee:      r.AdminFee + r.AdminFeePercent*sim.Principal,
+		AppraisalFee:  r.AppraisalFee,
+		NotaryFee:     r.NotaryFeePercent * sim.Principal,
+		TotalInterest: sim.TotalInterest,
+	}
+	res.UpfrontFees = res.AdminFee + res.AppraisalFee + res.NotaryFee
+	res.CashAtSigning = sim.DownPayment + res.UpfrontFees
+	if r.InsuranceRate > 0 {
+		for month := 0; month < sim.TenorMonths; month += 12 {
+			res.TotalInsurance += r.InsuranceRate * balanceAfter(sim, month)
+		}
+	}
+	res.TotalPaid = res.CashAtSigning + sim.TotalPayment + res.TotalInsurance
+	return res
+}
+
+const totalCostHowTo = "Untuk menghitung total biaya KPR, sebutkan harga rumah, DP, dan tenor. Contoh: *total biaya kpr harga 1 miliar dp 20% tenor 20 tahun*."
+
+// FormatTotalCost merender rincian total biaya untuk WhatsApp (dipakai juga oleh endpoint REST)
+func FormatTotalCost(res *domain.TotalCostResult) string {
+	sim := res.Simulation
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Total biaya KPR — %s*\n", sim.Rate.RateName)
+	fmt.Fprintf(&b, "Harga rumah: %s\n", formatRupiah(sim.PropertyPrice))
+	fmt.Fprintf(&b, "Plafon pinjaman: %s, tenor %s\n", formatRupiah(sim.Principal), formatTenorMonths(sim.TenorMonths))
+	writeInstallmentPeriods(&b, sim.Periods)
+
+	b.WriteString("\n*Dibayar saat akad*\n")
+	fmt.Fprintf(&b, "• Uang muka: %s\n", formatRupiah(sim.DownPayment))
+	fmt.Fprintf(&b, "• Provisi & administrasi: %s\n", formatRupiah(res.AdminFee))
+	fmt.Fprintf(&b, "• Appraisal: %s\n", formatRupiah(res.AppraisalFee))
+	fmt.Fprintf(&b, "• Notaris: %s\n", formatRupiah(res.NotaryFee))
+	fmt.Fprintf(&b, "Total dana awal: *%s*\n", formatRupiah(res.CashAtSigning))
+
+	b.WriteString("\n*Selama tenor*\n")
+	fmt.Fprintf(&b, "• Total angsuran: %s\n", formatRupiah(sim.TotalPayment))
+	fmt.Fprintf(&b, "• Total bunga: %s\n", formatRupiah(res.TotalInterest))
+	if res.TotalInsurance > 0 {
+		fmt.Fprintf(&b, "• Asuransi (%s/tahun dari sisa pokok): %s\n", formatRateFraction(sim.Rate.InsuranceRate), formatRupiah(res.TotalInsurance))
+	}
+	fmt.Fprintf(&b, "\n*Total dibayar sampai lunas: %s*\n", formatRupiah(res.TotalPaid))
+	if len(sim.Violations) > 0 {
+		fmt.Fprintf(&b, "\n⚠️ Belum memenuhi syarat produk: %s.\n", strings.Join(sim.Violations, "; "))
+	}
+	b.WriteString("_Perhitungan indikatif; biaya final mengikuti ketentuan BNI saat akad, belum termasuk pajak (BPHTB/AJB)._")
+	return b.String()
+}
+
+// answerTotalCost menjawab pertanyaan total biaya KPR dengan angka dari kalkulator
+func (a *AIQueryService) answerTotalCost(ctx context.Context, phone, text string) (string, bool) {
+	if a.calculator == nil {
+		return "", false
+	}
+	req, reply := a.simulationRequestFromText(ctx, phone, text, totalCostHowTo)
+	if reply != "" {
+		return reply, true
+	}
+	res, err := a.calculator.TotalCost(ctx, req)
+	if err != nil {
+		log.Printf("[AI] total cost error: %v", err)
+		return "Maaf, data produk KPR belum tersedia untuk menghitung biaya saat ini.", true
+	}
+	return FormatTotalCost(res), true
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
+)
+
+func TestLoanCostBreakdown(t *testing.T) {
+	r := sampleRate()
+	r.AdminFee, r.AdminFeePercent, r.AppraisalFee, r.NotaryFeePercent, r.InsuranceRate = 500000, 0.01, 1500000, 0.005, 0.002
+	sim, err := simulateWithRate(r, domain.SimulationRequest{PropertyPrice: 1000000000, DownPayment: 200000000, TenorYears: 20, FloatingRate: 0.11})
+	if err != nil {
+		t.Fatalf("simulate error: %v", err)
+	}
+	res := loanCostBreakdown(sim)
+	if res.AdminFee != 8500000 || res.AppraisalFee != 1500000 || res.NotaryFee != 4000000 {
+		t.Fatalf("fees=%+v", res)
+	}
+	if res.CashAtSigning != 214000000 {
+		t.Fatalf("cash at signing=%.0f", res.CashAtSigning)
+	}
+	// premi tahun pertama dari plafon penuh, tahun berikutnya dari sisa pokok yang menurun
+	if res.TotalInsurance <= 1600000 || res.TotalInsurance >= 1600000*20 {
+		t.Fatalf("insurance=%.0f", res.TotalInsurance)
+	}
+	if math.Abs(res.TotalPaid-(res.CashAtSigning+sim.TotalPayment+res.TotalInsurance)) > 1e-6 {
+		t.Fatalf("total paid inconsistent")
+	}
+	if b := balanceAfter(sim, sim.TenorMonths); b > 1 {
+		t.Fatalf("balance at end=%.2f", b)
+	}
+	out := FormatTotalCost(res)
+	for _, want := range []string{"Provisi & administrasi: Rp 8.500.000", "Notaris: Rp 4.000.000", "Total dana awal: *Rp 214.000.000*", "Total dibayar sampai lunas"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in %q", want, out)
+		}
+	}
+}
+
+func TestClassify_TotalCost(t *testing.T) {
+	got, _ := NewRuleIntentClassifier().Classify(context.Background(), "total biaya kpr harga 1 miliar dp 20% tenor 20 tahun")
+	if got.Intent != domain.IntentTotalCost {
+		t.Fatalf("intent=%s", got.Intent)
+	}
+}
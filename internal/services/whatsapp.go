@@ -2,13 +2,16 @@ package services
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/Kelompok-1-ODP-IT-343/Bot-WA-KPR/internal/domain"
 	"github.com/mdp/qrterminal/v3"
 	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/proto/waE2E"
@@ -205,6 +208,46 @@ func (w *WhatsAppService) SendMessage(ctx context.Context, phone, message string
 	return nil
 }
 
+// SendDocument mengunggah file lalu mengirimnya sebagai pesan dokumen WhatsApp
+func (w *WhatsAppService) SendDocument(ctx context.Context, phone string, doc domain.Document) error {
+	if !w.client.IsConnected() {
+		return fmt.Errorf("WhatsApp client is not connected")
+	}
+	phoneNorm := normalizePhone(phone)
+	if phoneNorm == "" {
+		return fmt.Errorf("invalid phone input")
+	}
+	if len(doc.Data) == 0 {
+		return fmt.Errorf("empty document")
+	}
+	up, err := w.client.Upload(ctx, doc.Data, whatsmeow.MediaDocument)
+	if err != nil {
+		return fmt.Errorf("failed to upload document: %w", err)
+	}
+	fileLength := up.FileLength
+	msg := &waProto.Message{DocumentMessage: &waProto.DocumentMessage{
+		URL:           &up.URL,
+		DirectPath:    &up.DirectPath,
+		MediaKey:      up.MediaKey,
+		FileEncSHA256: up.FileEncSHA256,
+		FileSHA256:    up.FileSHA256,
+		FileLength:    &fileLength,
+		Mimetype:      &doc.MimeType,
+		FileName:      &doc.FileName,
+		Title:         &doc.FileName,
+	}}
+	if doc.Caption != "" {
+		msg.DocumentMessage.Caption = &doc.Caption
+	}
+	to := waTypes.NewJID(phoneNorm, waTypes.DefaultUserServer)
+	resp, err := w.client.SendMessage(ctx, to, msg)
+	if err != nil {
+		return fmt.Errorf("failed to send document: %w", err)
+	}
+	log.Printf("[WA] ✅ Sent document %s (%d bytes) ID: %s to %s", doc.FileName, len(doc.Data), resp.ID, phone)
+	return nil
+}
+
 // (Auto revoke dihapus)
 
 func (w *WhatsAppService) IsConnected() bool {
@@ -236,3 +279,30 @@ func ExtractText(e *waEvents.Message) string {
 	}
 	return ""
 }
+
+// ExtractMedia membaca metadata gambar/dokumen dari pesan masuk beserta kunci unduhnya
+// (direct path, media key, hash terenkripsi) agar staf dapat mengunduh file untuk verifikasi.
+// Isi file tidak diunduh di sini.
+func ExtractMedia(e *waEvents.Message) (domain.IncomingMedia, bool) {
+	m := domain.IncomingMedia{MessageID: e.Info.ID}
+	switch {
+	case e.Message.GetImageMessage() != nil:
+		img := e.Message.GetImageMessage()
+		m.Caption, m.MimeType, m.FileSHA256 = img.GetCaption(), img.GetMimetype(), hex.EncodeToString(img.GetFileSHA256())
+		m.MediaType, m.DirectPath, m.MediaKey = "image", img.GetDirectPath(), img.GetMediaKey()
+		m.FileEncSHA256, m.FileLength = hex.EncodeToString(img.GetFileEncSHA256()), img.GetFileLength()
+	case e.Message.GetDocumentMessage() != nil:
+		doc := e.Message.GetDocumentMessage()
+		m.Caption, m.MimeType, m.FileName = doc.GetCaption(), doc.GetMimetype(), doc.GetFileName()
+		m.FileSHA256 = hex.EncodeToString(doc.GetFileSHA256())
+		m.MediaType, m.DirectPath, m.MediaKey = "document", doc.GetDirectPath(), doc.GetMediaKey()
+		m.FileEncSHA256, m.FileLength = hex.EncodeToString(doc.GetFileEncSHA256()), doc.GetFileLength()
+		if strings.TrimSpace(m.Caption) == "" {
+			// dokumen tanpa caption: nama file sering sudah menyebut jenisnya (mis. slip-gaji.pdf)
+			m.Caption = strings.TrimSuffix(m.FileName, filepath.Ext(m.FileName))
+		}
+	default:
+		return m, false
+	}
+	return m, true
+}